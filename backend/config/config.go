@@ -76,6 +76,15 @@ func InitDB() error {
 	database.DB = db
 
 	log.Println("✅ Conectado a MySQL exitosamente")
+
+	// Aplicar migraciones pendientes solo si se pide explícitamente
+	if os.Getenv("DB_AUTO_MIGRATE") == "true" {
+		if err := database.Migrate(db); err != nil {
+			return fmt.Errorf("error aplicando migraciones: %w", err)
+		}
+		log.Println("✅ Esquema de BD actualizado")
+	}
+
 	return nil
 }
 
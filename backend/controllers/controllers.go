@@ -2,14 +2,15 @@ package controllers
 
 import (
 	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"math"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
-
-	"pizzas-ecos/database"
 	"pizzas-ecos/errors"
 	"pizzas-ecos/httputil"
 	"pizzas-ecos/logger"
@@ -24,12 +25,16 @@ type VentaController struct {
 	ventaService services.VentaServiceInterface
 }
 
-func NewVentaController() *VentaController {
+// NewVentaController crea el controlador de ventas con el servicio indicado
+func NewVentaController(ventaService services.VentaServiceInterface) *VentaController {
 	return &VentaController{
-		ventaService: &services.VentaService{},
+		ventaService: ventaService,
 	}
 }
 
+// maxIdempotencyKey es el largo máximo del header Idempotency-Key (columna VARCHAR(255))
+const maxIdempotencyKey = 255
+
 // CrearVenta crea una nueva venta
 func (c *VentaController) CrearVenta(w http.ResponseWriter, r *http.Request) {
 	var req models.VentaRequest
@@ -58,16 +63,54 @@ func (c *VentaController) CrearVenta(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Crear venta
-	ventaID, err := c.ventaService.CrearVenta(&req)
+	// Crear venta; con Idempotency-Key un reintento no la duplica
+	clave := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(clave) > maxIdempotencyKey {
+		errors.WriteError(w, errors.ErrBadRequest, fmt.Sprintf("Idempotency-Key demasiado larga (máximo %d caracteres)", maxIdempotencyKey))
+		return
+	}
+
+	var venta *models.VentaCreada
+	var err error
+	if clave == "" {
+		venta, err = c.ventaService.CrearVenta(&req, httputil.GetPrincipal(r))
+	} else {
+		venta, err = c.ventaService.CrearVentaIdempotente(&req, clave, httputil.GetPrincipal(r))
+		if err == nil && venta.Replay {
+			w.Header().Set("Idempotent-Replayed", "true")
+		}
+	}
+	if stderrors.Is(err, services.ErrIdempotenciaConflicto) {
+		errors.WriteError(w, errors.ErrUnprocessable, err.Error())
+		return
+	}
+	if stderrors.Is(err, services.ErrSinCampanaActiva) || stderrors.Is(err, services.ErrProductoOtraCampana) {
+		errors.WriteError(w, errors.ErrConflict, err.Error())
+		return
+	}
+	if stderrors.Is(err, services.ErrTelefonoInvalido) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	// Vendedor inexistente o item que no se puede vender (inexistente,
+	// inactivo, cantidad inválida): el detalle indica el producto_id
+	var rechazo *services.ItemRechazadoError
+	if stderrors.Is(err, services.ErrVendedorNoEncontrado) || stderrors.As(err, &rechazo) {
+		errors.WriteError(w, errors.ErrUnprocessable, err.Error())
+		return
+	}
 	if err != nil {
 		logger.Error("CrearVenta: Error al crear", "VENTA_CREATE_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al crear venta")
 		return
 	}
 
-	logger.Info("CrearVenta: Venta creada exitosamente", map[string]interface{}{"venta_id": ventaID})
-	errors.WriteSuccess(w, http.StatusCreated, map[string]interface{}{"id": ventaID}, "Venta creada")
+	logger.Info("CrearVenta: Venta creada exitosamente", map[string]interface{}{"venta_id": venta.ID})
+	mensaje := "Venta creada"
+	if len(venta.DiferenciasPrecio) > 0 {
+		mensaje = "Venta creada con precios del catálogo distintos a los enviados"
+	}
+	errors.WriteSuccess(w, http.StatusCreated, venta, mensaje)
 }
 
 // ActualizarVenta actualiza una venta existente
@@ -114,18 +157,8 @@ func (c *VentaController) ActualizarVenta(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	// Actualizar venta
-	err = c.ventaService.ActualizarVenta(ventaID, estado, paymentMethod, tipoEntrega, productosEliminar, productos)
-	if err != nil {
-		logger.Error("ActualizarVenta: Error al actualizar", "VENTA_UPDATE_ERROR", map[string]interface{}{
-			"venta_id": ventaID,
-			"error":    err.Error(),
-		})
-		errors.WriteError(w, errors.ErrServerError, "Error al actualizar venta")
-		return
-	}
-
-	// Manejar actualización del cliente (SIEMPRE, si viene en el payload)
+	// Cliente a reasignar (si viene en el payload)
+	var cliente *models.ClienteVenta
 	if clienteRaw, ok := req["cliente"].(string); ok {
 		clienteRaw = strings.TrimSpace(clienteRaw)
 		if clienteRaw == "" {
@@ -134,75 +167,170 @@ func (c *VentaController) ActualizarVenta(w http.ResponseWriter, r *http.Request
 			return
 		}
 
-		// Obtener teléfono si existe
-		var telPtr *int
+		cliente = &models.ClienteVenta{Nombre: clienteRaw}
+		// Obtener teléfono si existe: número o string, como en CrearVenta
 		if telRaw, exists := req["telefono_cliente"]; exists && telRaw != nil {
-			if telFloat, ok2 := telRaw.(float64); ok2 {
-				telInt := int(telFloat)
-				if telInt != 0 { // Solo considerar teléfono si no es 0
-					telPtr = &telInt
-				}
-			}
-		}
-
-		// Obtener o crear cliente
-		id, existingTel, found, err := database.GetClienteByNombre(clienteRaw)
-		if err == nil && found {
-			// Cliente existe: actualizar teléfono si es diferente
-			if telPtr != nil && *telPtr != existingTel {
-				if err := database.UpdateClienteTelefono(id, telPtr); err != nil {
-					logger.Error("ActualizarVenta: Error actualizando teléfono", "PHONE_UPDATE_ERROR", map[string]interface{}{
-						"cliente_id": id,
-						"error":      err.Error(),
-					})
-				}
-			}
-			// Asociar venta a este cliente
-			if err := database.UpdateVentaClienteID(ventaID, id); err != nil {
-				logger.Error("ActualizarVenta: Error asignando cliente a venta", "VENTA_CLIENT_ASSIGN_ERROR", map[string]interface{}{
-					"venta_id":   ventaID,
-					"cliente_id": id,
-					"error":      err.Error(),
-				})
-				errors.WriteError(w, errors.ErrServerError, "Error al asignar cliente a venta")
-				return
-			}
-		} else {
-			// Cliente no existe: crear con teléfono opcional
-			newID, err := database.CreateClienteWithTelefono(clienteRaw, telPtr)
-			if err != nil {
-				logger.Error("ActualizarVenta: Error creando cliente", "CLIENT_CREATE_ERROR", map[string]interface{}{
-					"cliente": clienteRaw,
-					"error":   err.Error(),
-				})
-				errors.WriteError(w, errors.ErrServerError, "Error al crear cliente")
-				return
-			}
-			// Asociar venta a nuevo cliente
-			if err := database.UpdateVentaClienteID(ventaID, newID); err != nil {
-				logger.Error("ActualizarVenta: Error asignando nuevo cliente a venta", "VENTA_NEW_CLIENT_ASSIGN_ERROR", map[string]interface{}{
-					"venta_id":   ventaID,
-					"cliente_id": newID,
-					"error":      err.Error(),
-				})
-				errors.WriteError(w, errors.ErrServerError, "Error al asignar cliente a venta")
+			raw, _ := json.Marshal(telRaw)
+			if err := json.Unmarshal(raw, &cliente.Telefono); err != nil {
+				errors.WriteError(w, errors.ErrBadRequest, err.Error())
 				return
 			}
 		}
+	}
 
-		logger.Info("ActualizarVenta: Cliente actualizado", map[string]interface{}{
+	// Actualizar venta y cliente en una sola transacción
+	err = c.ventaService.ActualizarVenta(ventaID, estado, paymentMethod, tipoEntrega, productosEliminar, productos, cliente, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrVentaNoEncontrada) {
+		errors.WriteError(w, errors.ErrNotFound, "Venta no encontrada")
+		return
+	}
+	if stderrors.Is(err, services.ErrTransicionInvalida) || stderrors.Is(err, services.ErrProductoOtraCampana) {
+		errors.WriteError(w, errors.ErrConflict, err.Error())
+		return
+	}
+	if stderrors.Is(err, services.ErrTelefonoInvalido) || stderrors.Is(err, services.ErrItemInvalido) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	// Línea nueva con un producto inexistente o inactivo, o cantidad inválida:
+	// la misma regla que en CrearVenta
+	var rechazo *services.ItemRechazadoError
+	if stderrors.As(err, &rechazo) {
+		errors.WriteError(w, errors.ErrUnprocessable, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error("ActualizarVenta: Error al actualizar", "VENTA_UPDATE_ERROR", map[string]interface{}{
 			"venta_id": ventaID,
-			"cliente":  clienteRaw,
+			"error":    err.Error(),
 		})
+		errors.WriteError(w, errors.ErrServerError, "Error al actualizar venta")
+		return
 	}
 
 	logger.Info("ActualizarVenta: Venta actualizada", map[string]interface{}{"venta_id": ventaID})
 	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": ventaID}, "Venta actualizada")
 }
 
-// ObtenerEstadisticas retorna estadísticas de ventas
+// ObtenerVenta retorna una venta con sus items
+func (c *VentaController) ObtenerVenta(w http.ResponseWriter, r *http.Request) {
+	idStr := httputil.GetParam(r, "id")
+	ventaID, err := strconv.Atoi(idStr)
+	if err != nil || ventaID <= 0 {
+		logger.Warn("ObtenerVenta: ID inválido", map[string]interface{}{"id": idStr})
+		errors.WriteError(w, errors.ErrBadRequest, "ID de venta inválido")
+		return
+	}
+
+	venta, err := c.ventaService.ObtenerVenta(ventaID)
+	if stderrors.Is(err, services.ErrVentaNoEncontrada) {
+		errors.WriteError(w, errors.ErrNotFound, "Venta no encontrada")
+		return
+	}
+	if err != nil {
+		logger.Error("ObtenerVenta: Error", "VENTA_GET_ERROR", map[string]interface{}{"venta_id": ventaID, "error": err.Error()})
+		errors.WriteError(w, errors.ErrServerError, "Error al obtener venta")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, venta, "")
+}
+
+// EliminarVenta elimina una venta y sus detalles
+func (c *VentaController) EliminarVenta(w http.ResponseWriter, r *http.Request) {
+	idStr := httputil.GetParam(r, "id")
+	ventaID, err := strconv.Atoi(idStr)
+	if err != nil || ventaID <= 0 {
+		logger.Warn("EliminarVenta: ID inválido", map[string]interface{}{"id": idStr})
+		errors.WriteError(w, errors.ErrBadRequest, "ID de venta inválido")
+		return
+	}
+
+	err = c.ventaService.EliminarVenta(ventaID, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrVentaNoEncontrada) {
+		errors.WriteError(w, errors.ErrNotFound, "Venta no encontrada")
+		return
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ErrServerError, "Error al eliminar venta")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": ventaID}, "Venta eliminada")
+}
+
+// RegistrarPago agrega un pago a una venta. Queda registrado el usuario del token
+func (c *VentaController) RegistrarPago(w http.ResponseWriter, r *http.Request) {
+	idStr := httputil.GetParam(r, "id")
+	ventaID, err := strconv.Atoi(idStr)
+	if err != nil || ventaID <= 0 {
+		logger.Warn("RegistrarPago: ID inválido", map[string]interface{}{"id": idStr})
+		errors.WriteError(w, errors.ErrBadRequest, "ID de venta inválido")
+		return
+	}
+
+	var req models.PagoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("RegistrarPago: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+
+	pago, err := c.ventaService.RegistrarPago(ventaID, &req, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrVentaNoEncontrada) {
+		errors.WriteError(w, errors.ErrNotFound, "Venta no encontrada")
+		return
+	}
+	if stderrors.Is(err, services.ErrPagoRechazado) {
+		errors.WriteError(w, errors.ErrConflict, err.Error())
+		return
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusCreated, pago, "Pago registrado")
+}
+
+// ObtenerPagos retorna el libro de pagos de una venta
+func (c *VentaController) ObtenerPagos(w http.ResponseWriter, r *http.Request) {
+	idStr := httputil.GetParam(r, "id")
+	ventaID, err := strconv.Atoi(idStr)
+	if err != nil || ventaID <= 0 {
+		logger.Warn("ObtenerPagos: ID inválido", map[string]interface{}{"id": idStr})
+		errors.WriteError(w, errors.ErrBadRequest, "ID de venta inválido")
+		return
+	}
+
+	pagos, err := c.ventaService.ObtenerPagos(ventaID)
+	if stderrors.Is(err, services.ErrVentaNoEncontrada) {
+		errors.WriteError(w, errors.ErrNotFound, "Venta no encontrada")
+		return
+	}
+	if err != nil {
+		logger.Error("ObtenerPagos: Error", "PAGOS_GET_ERROR", map[string]interface{}{"venta_id": ventaID, "error": err.Error()})
+		errors.WriteError(w, errors.ErrServerError, "Error al obtener pagos")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, pagos, "")
+}
+
+// ObtenerEstadisticas retorna estadísticas de ventas de la campaña
+// ?campana_id= (por defecto la activa)
 func (c *VentaController) ObtenerEstadisticas(w http.ResponseWriter, r *http.Request) {
-	stats, err := c.ventaService.ObtenerEstadisticas()
+	campanaID, err := parseCampanaID(r)
+	if err != nil {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	stats, err := c.ventaService.ObtenerEstadisticas(campanaID)
+	if stderrors.Is(err, services.ErrCampanaNoEncontrada) || stderrors.Is(err, services.ErrSinCampanaActiva) {
+		errors.WriteError(w, errors.ErrNotFound, err.Error())
+		return
+	}
 	if err != nil {
 		logger.Error("ObtenerEstadisticas: Error", "STATS_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al obtener estadísticas")
@@ -212,16 +340,99 @@ func (c *VentaController) ObtenerEstadisticas(w http.ResponseWriter, r *http.Req
 	errors.WriteSuccess(w, http.StatusOK, stats, "")
 }
 
-// ObtenerTodasVentas retorna todas las ventas
+// ObtenerTodasVentas retorna las ventas (canceladas incluidas) filtradas por
+// query params: campana_id, desde, hasta, vendedor, cliente, estado,
+// payment_method, tipo_entrega, orden, limit y cursor. Sin limit retorna todas
+// y sin campana_id las de todas las campañas
 func (c *VentaController) ObtenerTodasVentas(w http.ResponseWriter, r *http.Request) {
-	ventas, err := c.ventaService.ObtenerTodasVentas()
+	filtro, err := parseVentaFiltro(r)
 	if err != nil {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	ventas, nextCursor, err := c.ventaService.ObtenerTodasVentas(filtro)
+	if err != nil {
+		if stderrors.Is(err, services.ErrFiltroInvalido) {
+			errors.WriteError(w, errors.ErrBadRequest, err.Error())
+			return
+		}
 		logger.Error("ObtenerTodasVentas: Error", "VENTAS_LIST_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al obtener ventas")
 		return
 	}
 
-	errors.WriteSuccess(w, http.StatusOK, ventas, "")
+	errors.WritePage(w, http.StatusOK, ventas, nextCursor)
+}
+
+// parseVentaFiltro lee los filtros del listado de ventas desde la query string.
+// Las fechas aceptan YYYY-MM-DD (hasta incluye el día completo) o RFC3339
+func parseVentaFiltro(r *http.Request) (models.VentaFiltro, error) {
+	query := r.URL.Query()
+	filtro := models.VentaFiltro{
+		Vendedor:          strings.TrimSpace(query.Get("vendedor")),
+		Cliente:           strings.TrimSpace(query.Get("cliente")),
+		Estado:            query.Get("estado"),
+		PaymentMethod:     query.Get("payment_method"),
+		TipoEntrega:       query.Get("tipo_entrega"),
+		IncluirCanceladas: true,
+		Orden:             query.Get("orden"),
+		Cursor:            query.Get("cursor"),
+	}
+
+	if v := query.Get("desde"); v != "" {
+		t, _, err := parseFechaFiltro(v)
+		if err != nil {
+			return filtro, fmt.Errorf("desde inválido: %s", v)
+		}
+		filtro.Desde = &t
+	}
+	if v := query.Get("hasta"); v != "" {
+		t, soloFecha, err := parseFechaFiltro(v)
+		if err != nil {
+			return filtro, fmt.Errorf("hasta inválido: %s", v)
+		}
+		if soloFecha {
+			t = t.AddDate(0, 0, 1)
+		}
+		filtro.Hasta = &t
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return filtro, fmt.Errorf("limit inválido: %s", v)
+		}
+		filtro.Limit = limit
+	}
+
+	campanaID, err := parseCampanaID(r)
+	if err != nil {
+		return filtro, err
+	}
+	filtro.CampanaID = campanaID
+
+	return filtro, nil
+}
+
+// parseCampanaID lee el query param campana_id; si no viene retorna 0
+func parseCampanaID(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("campana_id")
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("campana_id inválido: %s", v)
+	}
+	return id, nil
+}
+
+func parseFechaFiltro(v string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, false, err
 }
 
 // ProductoController maneja requests relacionados con productos
@@ -229,15 +440,26 @@ type ProductoController struct {
 	productoService services.ProductoServiceInterface
 }
 
-func NewProductoController() *ProductoController {
+// NewProductoController crea el controlador de productos con el servicio indicado
+func NewProductoController(productoService services.ProductoServiceInterface) *ProductoController {
 	return &ProductoController{
-		productoService: &services.ProductoService{},
+		productoService: productoService,
 	}
 }
 
-// Listar obtiene lista de productos
+// Listar obtiene lista de productos de la campaña ?campana_id= (por defecto la activa)
 func (c *ProductoController) Listar(w http.ResponseWriter, r *http.Request) {
-	productos, err := c.productoService.ObtenerProductos()
+	campanaID, err := parseCampanaID(r)
+	if err != nil {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	productos, err := c.productoService.ObtenerProductos(campanaID)
+	if stderrors.Is(err, services.ErrCampanaNoEncontrada) || stderrors.Is(err, services.ErrSinCampanaActiva) {
+		errors.WriteError(w, errors.ErrNotFound, err.Error())
+		return
+	}
 	if err != nil {
 		logger.Error("Listar productos: Error", "PRODUCTOS_LIST_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al obtener productos")
@@ -270,7 +492,15 @@ func (c *ProductoController) Crear(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := c.productoService.CrearProducto(&req)
+	id, err := c.productoService.CrearProducto(&req, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrCampanaNoEncontrada) {
+		errors.WriteError(w, errors.ErrBadRequest, "campana_id inválido")
+		return
+	}
+	if stderrors.Is(err, services.ErrSinCampanaActiva) {
+		errors.WriteError(w, errors.ErrConflict, "No hay campaña activa: indicar campana_id")
+		return
+	}
 	if err != nil {
 		logger.Error("Crear producto: Error", "PRODUCTO_CREATE_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al crear producto")
@@ -309,7 +539,12 @@ func (c *ProductoController) Actualizar(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = c.productoService.ActualizarProducto(id, &req)
+	err = c.productoService.ActualizarProducto(id, &req, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrProductoNoEncontrado) {
+		logger.Warn("Actualizar producto: No encontrado", map[string]interface{}{"producto_id": id})
+		errors.WriteError(w, errors.ErrNotFound, "Producto no encontrado")
+		return
+	}
 	if err != nil {
 		logger.Error("Actualizar producto: Error", "PRODUCTO_UPDATE_ERROR", map[string]interface{}{
 			"producto_id": id,
@@ -334,7 +569,7 @@ func (c *ProductoController) Eliminar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = c.productoService.EliminarProducto(id)
+	err = c.productoService.EliminarProducto(id, httputil.GetPrincipal(r))
 	if err != nil {
 		logger.Warn("Eliminar producto: No encontrado", map[string]interface{}{"producto_id": id})
 		errors.WriteError(w, errors.ErrNotFound, "Producto no encontrado")
@@ -350,9 +585,10 @@ type VendedorController struct {
 	vendedorService services.VendedorServiceInterface
 }
 
-func NewVendedorController() *VendedorController {
+// NewVendedorController crea el controlador de vendedores con el servicio indicado
+func NewVendedorController(vendedorService services.VendedorServiceInterface) *VendedorController {
 	return &VendedorController{
-		vendedorService: &services.VendedorService{},
+		vendedorService: vendedorService,
 	}
 }
 
@@ -391,7 +627,7 @@ func (c *VendedorController) Crear(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := c.vendedorService.CrearVendedor(nombre)
+	id, err := c.vendedorService.CrearVendedor(nombre, httputil.GetPrincipal(r))
 	if err != nil {
 		logger.Error("Crear vendedor: Error", "VENDEDOR_CREATE_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al crear vendedor")
@@ -430,7 +666,7 @@ func (c *VendedorController) Actualizar(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = c.vendedorService.ActualizarVendedor(id, nombre)
+	err = c.vendedorService.ActualizarVendedor(id, nombre, httputil.GetPrincipal(r))
 	if err != nil {
 		logger.Error("Actualizar vendedor: Error", "VENDEDOR_UPDATE_ERROR", map[string]interface{}{
 			"vendedor_id": id,
@@ -455,7 +691,7 @@ func (c *VendedorController) Eliminar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = c.vendedorService.EliminarVendedor(id)
+	err = c.vendedorService.EliminarVendedor(id, httputil.GetPrincipal(r))
 	if err != nil {
 		logger.Warn("Eliminar vendedor: No encontrado", map[string]interface{}{"vendedor_id": id})
 		errors.WriteError(w, errors.ErrNotFound, "Vendedor no encontrado")
@@ -471,9 +707,10 @@ type DataController struct {
 	dataService *services.DataService
 }
 
-func NewDataController() *DataController {
+// NewDataController crea el controlador de datos generales con el servicio indicado
+func NewDataController(dataService *services.DataService) *DataController {
 	return &DataController{
-		dataService: &services.DataService{},
+		dataService: dataService,
 	}
 }
 
@@ -489,17 +726,28 @@ func (c *DataController) ObtenerData(w http.ResponseWriter, r *http.Request) {
 	errors.WriteSuccess(w, http.StatusOK, data, "")
 }
 
-// LimpiarBaseDatos limpia todos los datos excepto usuarios
+// LimpiarBaseDatos limpia todos los datos excepto usuarios y campañas. Exige
+// la frase de confirmación en el cuerpo y antes de borrar deja un backup en el
+// servidor
 func (c *DataController) LimpiarBaseDatos(w http.ResponseWriter, r *http.Request) {
-	err := c.dataService.LimpiarBaseDatos()
+	var req models.LimpiarBaseDatosRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("LimpiarBaseDatos: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+
+	ruta, err := c.dataService.LimpiarBaseDatos(strings.TrimSpace(req.Confirmacion), httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrConfirmacionInvalida) {
+		errors.WriteError(w, errors.ErrBadRequest, fmt.Sprintf("Para confirmar escribí %q en confirmacion", services.FraseLimpiarBaseDatos))
+		return
+	}
 	if err != nil {
-		logger.Error("LimpiarBaseDatos: Error", "DATABASE_CLEAR_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al limpiar la base de datos")
 		return
 	}
 
-	logger.Info("LimpiarBaseDatos: Base de datos limpiada exitosamente", map[string]interface{}{})
-	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"status": "cleared"}, "Base de datos limpiada exitosamente")
+	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"status": "cleared", "backup": filepath.Base(ruta)}, "Base de datos limpiada exitosamente")
 }
 
 // AuthController maneja requests de autenticación
@@ -507,9 +755,10 @@ type AuthController struct {
 	authService services.AuthServiceInterface
 }
 
-func NewAuthController() *AuthController {
+// NewAuthController crea el controlador de autenticación con el servicio indicado
+func NewAuthController(authService services.AuthServiceInterface) *AuthController {
 	return &AuthController{
-		authService: &services.AuthService{},
+		authService: authService,
 	}
 }
 
@@ -531,49 +780,154 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Autenticar
-	user, err := c.authService.AutenticarUsuario(req.Username, req.Password)
-	if err != nil {
+	user, err := c.authService.AutenticarUsuario(req.Username, req.Password, httputil.ClientIP(r))
+	var bloqueo *services.LoginBloqueadoError
+	if stderrors.As(err, &bloqueo) {
+		segundos := int(math.Ceil(bloqueo.Espera.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(segundos))
+		errors.WriteError(w, errors.ErrTooManyRequests, fmt.Sprintf("Demasiados intentos fallidos, reintentar en %d segundos", segundos))
+		return
+	}
+	if stderrors.Is(err, services.ErrCredencialesInvalidas) {
 		logger.Warn("Login: Credenciales inválidas", map[string]interface{}{"username": req.Username})
 		errors.WriteError(w, errors.ErrUnauthorized, "Usuario o contraseña incorrectos")
 		return
 	}
-
-	// Generar JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, models.TokenClaims{
-		Username: user.Username,
-		Rol:      user.Rol,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-		},
-	})
-
-	tokenString, err := token.SignedString(middleware.JWTSecret)
 	if err != nil {
-		logger.Error("Login: Error generando token", "TOKEN_GENERATION_ERROR", map[string]interface{}{
-			"error": err.Error(),
-		})
-		errors.WriteError(w, errors.ErrServerError, "Error al generar token")
+		errors.WriteError(w, errors.ErrServerError, "Error al autenticar")
 		return
 	}
 
-	// Retornar respuesta con token y usuario
-	response := models.LoginResponse{
-		Token: tokenString,
-		User:  *user,
+	// Emitir access token y refresh token
+	response, err := c.authService.IniciarSesion(user)
+	if err != nil {
+		errors.WriteError(w, errors.ErrServerError, "Error al generar token")
+		return
 	}
 
 	logger.Info("Login: Éxito", map[string]interface{}{"username": req.Username})
 	errors.WriteSuccess(w, http.StatusOK, response, "Autenticado")
 }
 
+// Refresh canjea un refresh token por un access token y un refresh token nuevos
+func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("Refresh: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+	if req.RefreshToken == "" {
+		errors.WriteError(w, errors.ErrBadRequest, "refresh_token es requerido")
+		return
+	}
+
+	response, err := c.authService.RefrescarSesion(req.RefreshToken)
+	if stderrors.Is(err, services.ErrSesionInvalida) {
+		errors.WriteError(w, errors.ErrUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ErrServerError, "Error al renovar sesión")
+		return
+	}
+
+	logger.Info("Refresh: Éxito", map[string]interface{}{"username": response.User.Username})
+	errors.WriteSuccess(w, http.StatusOK, response, "Sesión renovada")
+}
+
+// Logout revoca el refresh token del cuerpo y el access token del header, si
+// vienen. Responde 200 aunque ya estuvieran revocados
+func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Warn("Logout: JSON inválido", map[string]interface{}{"error": err.Error()})
+			errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+			return
+		}
+	}
+
+	// Un access token vencido o inválido no impide revocar el refresh token
+	claims, _ := middleware.ValidarToken(r)
+
+	if err := c.authService.CerrarSesion(req.RefreshToken, claims); err != nil {
+		errors.WriteError(w, errors.ErrServerError, "Error al cerrar sesión")
+		return
+	}
+
+	logger.Info("Logout: Éxito", map[string]interface{}{"username": httputil.GetPrincipal(r).Nombre()})
+	errors.WriteSuccess(w, http.StatusOK, nil, "Sesión cerrada")
+}
+
+// ChangePassword cambia la contraseña del usuario autenticado. Responde con
+// un par de tokens nuevo: las demás sesiones del usuario quedan cerradas
+func (c *AuthController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("ChangePassword: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		errors.WriteError(w, errors.ErrBadRequest, "current_password y new_password son requeridos")
+		return
+	}
+
+	response, err := c.authService.CambiarPassword(httputil.GetPrincipal(r), req.CurrentPassword, req.NewPassword)
+	if stderrors.Is(err, services.ErrPasswordDebil) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	// 400 y no 401: el token es válido, lo incorrecto es la contraseña actual
+	if stderrors.Is(err, services.ErrCredencialesInvalidas) || stderrors.Is(err, services.ErrUsuarioNoEncontrado) {
+		errors.WriteError(w, errors.ErrBadRequest, "Contraseña actual incorrecta")
+		return
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ErrServerError, "Error al cambiar contraseña")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, response, "Contraseña cambiada")
+}
+
+// ResetPassword fija una contraseña nueva canjeando un token de
+// restablecimiento generado por un admin
+func (c *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("ResetPassword: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+	if req.Token == "" || req.NewPassword == "" {
+		errors.WriteError(w, errors.ErrBadRequest, "token y new_password son requeridos")
+		return
+	}
+
+	err := c.authService.RestablecerPassword(req.Token, req.NewPassword)
+	if stderrors.Is(err, services.ErrPasswordDebil) || stderrors.Is(err, services.ErrResetInvalido) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ErrServerError, "Error al restablecer contraseña")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, nil, "Contraseña restablecida, iniciá sesión con la nueva")
+}
+
 // UsuarioController maneja requests relacionados con usuarios
 type UsuarioController struct {
 	usuarioService *services.UsuarioService
 }
 
-func NewUsuarioController() *UsuarioController {
+// NewUsuarioController crea el controlador de usuarios con el servicio indicado
+func NewUsuarioController(usuarioService *services.UsuarioService) *UsuarioController {
 	return &UsuarioController{
-		usuarioService: &services.UsuarioService{},
+		usuarioService: usuarioService,
 	}
 }
 
@@ -610,14 +964,14 @@ func (c *UsuarioController) Crear(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Crear usuario siempre como admin
-	usuarioID, err := c.usuarioService.CrearUsuario(req.Username, req.Password, "admin")
+	usuarioID, err := c.usuarioService.CrearUsuario(req.Username, req.Password, models.RolAdmin, httputil.GetPrincipal(r))
 	if err != nil {
 		logger.Error("Crear usuario: Error al crear", "USUARIO_CREATE_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al crear usuario")
 		return
 	}
 
-	logger.Info("Crear usuario: Éxito", map[string]interface{}{"usuario_id": usuarioID, "username": req.Username, "rol": "admin"})
+	logger.Info("Crear usuario: Éxito", map[string]interface{}{"usuario_id": usuarioID, "username": req.Username, "rol": models.RolAdmin})
 	errors.WriteSuccess(w, http.StatusCreated, map[string]interface{}{"id": usuarioID}, "Usuario creado como admin")
 }
 
@@ -650,14 +1004,14 @@ func (c *UsuarioController) Actualizar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validar rol
-	if req.Rol != "admin" && req.Rol != "vendedor" {
+	if req.Rol != models.RolAdmin && req.Rol != models.RolVendedor {
 		logger.Warn("Actualizar usuario: Rol inválido", map[string]interface{}{"rol": req.Rol})
 		errors.WriteError(w, errors.ErrBadRequest, "Rol debe ser 'admin' o 'vendedor'")
 		return
 	}
 
 	// Actualizar usuario
-	err = c.usuarioService.ActualizarUsuario(usuarioID, req.Username, req.Password, req.Rol)
+	err = c.usuarioService.ActualizarUsuario(usuarioID, req.Username, req.Password, req.Rol, httputil.GetPrincipal(r))
 	if err != nil {
 		logger.Error("Actualizar usuario: Error al actualizar", "USUARIO_UPDATE_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al actualizar usuario")
@@ -680,7 +1034,7 @@ func (c *UsuarioController) Eliminar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Eliminar usuario
-	err = c.usuarioService.EliminarUsuario(usuarioID)
+	err = c.usuarioService.EliminarUsuario(usuarioID, httputil.GetPrincipal(r))
 	if err != nil {
 		logger.Error("Eliminar usuario: Error al eliminar", "USUARIO_DELETE_ERROR", map[string]interface{}{"error": err.Error()})
 		errors.WriteError(w, errors.ErrServerError, "Error al eliminar usuario")
@@ -690,3 +1044,584 @@ func (c *UsuarioController) Eliminar(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Eliminar usuario: Éxito", map[string]interface{}{"usuario_id": usuarioID})
 	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": usuarioID}, "Usuario eliminado")
 }
+
+// Desbloquear levanta el bloqueo de login de un usuario
+func (c *UsuarioController) Desbloquear(w http.ResponseWriter, r *http.Request) {
+	idStr := httputil.GetParam(r, "id")
+	usuarioID, err := strconv.Atoi(idStr)
+	if err != nil || usuarioID <= 0 {
+		logger.Warn("Desbloquear usuario: ID inválido", map[string]interface{}{"id": idStr})
+		errors.WriteError(w, errors.ErrBadRequest, "ID de usuario inválido")
+		return
+	}
+
+	err = c.usuarioService.DesbloquearUsuario(usuarioID, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrUsuarioNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, "Usuario no encontrado")
+		return
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ErrServerError, "Error al desbloquear usuario")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": usuarioID}, "Usuario desbloqueado")
+}
+
+// GenerarResetPassword emite un token de restablecimiento de un solo uso
+// para el usuario; el admin se lo hace llegar por fuera de la aplicación
+func (c *UsuarioController) GenerarResetPassword(w http.ResponseWriter, r *http.Request) {
+	idStr := httputil.GetParam(r, "id")
+	usuarioID, err := strconv.Atoi(idStr)
+	if err != nil || usuarioID <= 0 {
+		logger.Warn("Reset password: ID inválido", map[string]interface{}{"id": idStr})
+		errors.WriteError(w, errors.ErrBadRequest, "ID de usuario inválido")
+		return
+	}
+
+	reset, err := c.usuarioService.GenerarResetPassword(usuarioID, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrUsuarioNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, "Usuario no encontrado")
+		return
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ErrServerError, "Error al generar restablecimiento")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusCreated, reset, "Token de restablecimiento generado")
+}
+
+// RevocarSesiones invalida todos los tokens emitidos para un usuario
+func (c *UsuarioController) RevocarSesiones(w http.ResponseWriter, r *http.Request) {
+	idStr := httputil.GetParam(r, "id")
+	usuarioID, err := strconv.Atoi(idStr)
+	if err != nil || usuarioID <= 0 {
+		logger.Warn("Revocar sesiones: ID inválido", map[string]interface{}{"id": idStr})
+		errors.WriteError(w, errors.ErrBadRequest, "ID de usuario inválido")
+		return
+	}
+
+	err = c.usuarioService.RevocarSesiones(usuarioID, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrUsuarioNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, "Usuario no encontrado")
+		return
+	}
+	if err != nil {
+		errors.WriteError(w, errors.ErrServerError, "Error al revocar sesiones")
+		return
+	}
+
+	logger.Info("Revocar sesiones: Éxito", map[string]interface{}{"usuario_id": usuarioID})
+	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": usuarioID}, "Sesiones revocadas")
+}
+
+// AuditoriaController expone el registro de cambios
+type AuditoriaController struct {
+	auditoriaService services.AuditoriaServiceInterface
+}
+
+// NewAuditoriaController crea el controlador de auditoría con el servicio indicado
+func NewAuditoriaController(auditoriaService services.AuditoriaServiceInterface) *AuditoriaController {
+	return &AuditoriaController{
+		auditoriaService: auditoriaService,
+	}
+}
+
+// Listar retorna las entradas de auditoría filtradas por query params:
+// entidad, entidad_id, usuario, desde, hasta y limit (por defecto 100)
+func (c *AuditoriaController) Listar(w http.ResponseWriter, r *http.Request) {
+	filtro, err := parseAuditoriaFiltro(r)
+	if err != nil {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+
+	entradas, err := c.auditoriaService.ObtenerAuditoria(filtro)
+	if err != nil {
+		if stderrors.Is(err, services.ErrFiltroInvalido) {
+			errors.WriteError(w, errors.ErrBadRequest, err.Error())
+			return
+		}
+		logger.Error("Listar auditoría: Error", "AUDITORIA_LIST_ERROR", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrServerError, "Error al obtener auditoría")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, entradas, "")
+}
+
+// parseAuditoriaFiltro lee los filtros de auditoría; las fechas siguen el
+// mismo formato que el listado de ventas
+func parseAuditoriaFiltro(r *http.Request) (models.AuditoriaFiltro, error) {
+	query := r.URL.Query()
+	filtro := models.AuditoriaFiltro{
+		Entidad: query.Get("entidad"),
+		Usuario: strings.TrimSpace(query.Get("usuario")),
+	}
+
+	if v := query.Get("entidad_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return filtro, fmt.Errorf("entidad_id inválido: %s", v)
+		}
+		filtro.EntidadID = id
+	}
+	if v := query.Get("desde"); v != "" {
+		t, _, err := parseFechaFiltro(v)
+		if err != nil {
+			return filtro, fmt.Errorf("desde inválido: %s", v)
+		}
+		filtro.Desde = &t
+	}
+	if v := query.Get("hasta"); v != "" {
+		t, soloFecha, err := parseFechaFiltro(v)
+		if err != nil {
+			return filtro, fmt.Errorf("hasta inválido: %s", v)
+		}
+		if soloFecha {
+			t = t.AddDate(0, 0, 1)
+		}
+		filtro.Hasta = &t
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return filtro, fmt.Errorf("limit inválido: %s", v)
+		}
+		filtro.Limit = limit
+	}
+
+	return filtro, nil
+}
+
+// CampanaController maneja las campañas (ediciones) de la venta
+type CampanaController struct {
+	campanaService services.CampanaServiceInterface
+}
+
+// NewCampanaController crea el controlador de campañas con el servicio indicado
+func NewCampanaController(campanaService services.CampanaServiceInterface) *CampanaController {
+	return &CampanaController{
+		campanaService: campanaService,
+	}
+}
+
+// Listar retorna todas las campañas, de la más reciente a la más antigua
+func (c *CampanaController) Listar(w http.ResponseWriter, r *http.Request) {
+	campanas, err := c.campanaService.ObtenerCampanas()
+	if err != nil {
+		logger.Error("Listar campañas: Error", "CAMPANAS_LIST_ERROR", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrServerError, "Error al obtener campañas")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, campanas, "")
+}
+
+// Crear crea una campaña, opcionalmente activa y con los productos de otra
+func (c *CampanaController) Crear(w http.ResponseWriter, r *http.Request) {
+	var req models.CampanaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("Crear campaña: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+
+	id, err := c.campanaService.CrearCampana(&req, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrCampanaInvalida) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if stderrors.Is(err, services.ErrCampanaNoEncontrada) {
+		errors.WriteError(w, errors.ErrBadRequest, "copiar_productos_de: campaña no encontrada")
+		return
+	}
+	if err != nil {
+		logger.Error("Crear campaña: Error", "CAMPANA_CREATE_ERROR", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrServerError, "Error al crear campaña")
+		return
+	}
+
+	logger.Info("Crear campaña: Éxito", map[string]interface{}{"campana_id": id})
+	errors.WriteSuccess(w, http.StatusCreated, map[string]interface{}{"id": id}, "Campaña creada")
+}
+
+// Actualizar cambia nombre, fechas o estado de una campaña. activa: true
+// desactiva la campaña activa anterior
+func (c *CampanaController) Actualizar(w http.ResponseWriter, r *http.Request) {
+	idStr := httputil.GetParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		logger.Warn("Actualizar campaña: ID inválido", map[string]interface{}{"id": idStr})
+		errors.WriteError(w, errors.ErrBadRequest, "ID de campaña inválido")
+		return
+	}
+
+	var req models.CampanaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("Actualizar campaña: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+
+	err = c.campanaService.ActualizarCampana(id, &req, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrCampanaInvalida) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if stderrors.Is(err, services.ErrCampanaNoEncontrada) {
+		errors.WriteError(w, errors.ErrNotFound, "Campaña no encontrada")
+		return
+	}
+	if err != nil {
+		logger.Error("Actualizar campaña: Error", "CAMPANA_UPDATE_ERROR", map[string]interface{}{
+			"campana_id": id,
+			"error":      err.Error(),
+		})
+		errors.WriteError(w, errors.ErrServerError, "Error al actualizar campaña")
+		return
+	}
+
+	logger.Info("Actualizar campaña: Éxito", map[string]interface{}{"campana_id": id})
+	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": id}, "Campaña actualizada")
+}
+
+// ClienteController maneja el ABM de clientes y el autocompletado del formulario de pedidos
+type ClienteController struct {
+	clienteService services.ClienteServiceInterface
+}
+
+// NewClienteController crea el controlador de clientes con el servicio indicado
+func NewClienteController(clienteService services.ClienteServiceInterface) *ClienteController {
+	return &ClienteController{
+		clienteService: clienteService,
+	}
+}
+
+// Listar retorna todos los clientes o, con ?q=, los que coinciden con el
+// prefijo ordenados para el autocompletado (?limit= opcional)
+func (c *ClienteController) Listar(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if !query.Has("q") {
+		clientes, err := c.clienteService.ObtenerClientes()
+		if err != nil {
+			logger.Error("Listar clientes: Error", "CLIENTES_LIST_ERROR", map[string]interface{}{"error": err.Error()})
+			errors.WriteError(w, errors.ErrServerError, "Error al obtener clientes")
+			return
+		}
+		errors.WriteSuccess(w, http.StatusOK, clientes, "")
+		return
+	}
+
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			errors.WriteError(w, errors.ErrBadRequest, fmt.Sprintf("limit inválido: %s", v))
+			return
+		}
+	}
+
+	clientes, err := c.clienteService.BuscarClientes(query.Get("q"), limit)
+	if stderrors.Is(err, services.ErrFiltroInvalido) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error("Buscar clientes: Error", "CLIENTES_SEARCH_ERROR", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrServerError, "Error al buscar clientes")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, clientes, "")
+}
+
+// Obtener retorna un cliente por ID
+func (c *ClienteController) Obtener(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseClienteID(w, r, "Obtener cliente")
+	if !ok {
+		return
+	}
+
+	cliente, err := c.clienteService.ObtenerCliente(id)
+	if stderrors.Is(err, services.ErrClienteNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, "Cliente no encontrado")
+		return
+	}
+	if err != nil {
+		logger.Error("Obtener cliente: Error", "CLIENTE_GET_ERROR", map[string]interface{}{
+			"cliente_id": id,
+			"error":      err.Error(),
+		})
+		errors.WriteError(w, errors.ErrServerError, "Error al obtener cliente")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, cliente, "")
+}
+
+// Historial retorna las ventas de un cliente con sus items y los totales de
+// sus compras
+func (c *ClienteController) Historial(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseClienteID(w, r, "Historial cliente")
+	if !ok {
+		return
+	}
+
+	historial, err := c.clienteService.ObtenerHistorial(id)
+	if stderrors.Is(err, services.ErrClienteNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, "Cliente no encontrado")
+		return
+	}
+	if err != nil {
+		logger.Error("Historial cliente: Error", "CLIENTE_HISTORIAL_ERROR", map[string]interface{}{
+			"cliente_id": id,
+			"error":      err.Error(),
+		})
+		errors.WriteError(w, errors.ErrServerError, "Error al obtener historial del cliente")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, historial, "")
+}
+
+// Crear crea un cliente
+func (c *ClienteController) Crear(w http.ResponseWriter, r *http.Request) {
+	var req models.ClienteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("Crear cliente: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+
+	validation := validators.ValidateClienteRequest(&req)
+	if !validation.IsValid() {
+		logger.Warn("Crear cliente: Validación fallida", map[string]interface{}{"errors": validation.GetMessage()})
+		errors.WriteError(w, errors.ErrBadRequest, validation.GetMessage())
+		return
+	}
+
+	id, err := c.clienteService.CrearCliente(&req, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrTelefonoInvalido) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if stderrors.Is(err, services.ErrClienteDuplicado) {
+		errors.WriteError(w, errors.ErrConflict, "Ya existe un cliente con ese nombre")
+		return
+	}
+	if err != nil {
+		logger.Error("Crear cliente: Error", "CLIENTE_CREATE_ERROR", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrServerError, "Error al crear cliente")
+		return
+	}
+
+	logger.Info("Crear cliente: Éxito", map[string]interface{}{"cliente_id": id})
+	errors.WriteSuccess(w, http.StatusCreated, map[string]interface{}{"id": id}, "Cliente creado")
+}
+
+// Actualizar reemplaza nombre y teléfono de un cliente
+func (c *ClienteController) Actualizar(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseClienteID(w, r, "Actualizar cliente")
+	if !ok {
+		return
+	}
+
+	var req models.ClienteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("Actualizar cliente: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+
+	validation := validators.ValidateClienteRequest(&req)
+	if !validation.IsValid() {
+		logger.Warn("Actualizar cliente: Validación fallida", map[string]interface{}{"errors": validation.GetMessage()})
+		errors.WriteError(w, errors.ErrBadRequest, validation.GetMessage())
+		return
+	}
+
+	err := c.clienteService.ActualizarCliente(id, &req, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrTelefonoInvalido) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if stderrors.Is(err, services.ErrClienteNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, "Cliente no encontrado")
+		return
+	}
+	if stderrors.Is(err, services.ErrClienteDuplicado) {
+		errors.WriteError(w, errors.ErrConflict, "Ya existe un cliente con ese nombre")
+		return
+	}
+	if err != nil {
+		logger.Error("Actualizar cliente: Error", "CLIENTE_UPDATE_ERROR", map[string]interface{}{
+			"cliente_id": id,
+			"error":      err.Error(),
+		})
+		errors.WriteError(w, errors.ErrServerError, "Error al actualizar cliente")
+		return
+	}
+
+	logger.Info("Actualizar cliente: Éxito", map[string]interface{}{"cliente_id": id})
+	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": id}, "Cliente actualizado")
+}
+
+// Eliminar elimina un cliente sin ventas
+func (c *ClienteController) Eliminar(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseClienteID(w, r, "Eliminar cliente")
+	if !ok {
+		return
+	}
+
+	err := c.clienteService.EliminarCliente(id, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrClienteNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, "Cliente no encontrado")
+		return
+	}
+	if stderrors.Is(err, services.ErrClienteConVentas) {
+		errors.WriteError(w, errors.ErrConflict, "El cliente tiene ventas asociadas y no se puede eliminar")
+		return
+	}
+	if err != nil {
+		logger.Error("Eliminar cliente: Error", "CLIENTE_DELETE_ERROR", map[string]interface{}{
+			"cliente_id": id,
+			"error":      err.Error(),
+		})
+		errors.WriteError(w, errors.ErrServerError, "Error al eliminar cliente")
+		return
+	}
+
+	logger.Info("Eliminar cliente: Éxito", map[string]interface{}{"cliente_id": id})
+	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": id}, "Cliente eliminado")
+}
+
+// Duplicados retorna los pares de clientes que probablemente son la misma
+// persona (?umbral= de 0 a 1, por defecto 0.7)
+func (c *ClienteController) Duplicados(w http.ResponseWriter, r *http.Request) {
+	umbral := 0.0
+	if v := r.URL.Query().Get("umbral"); v != "" {
+		var err error
+		if umbral, err = strconv.ParseFloat(v, 64); err != nil || umbral <= 0 {
+			errors.WriteError(w, errors.ErrBadRequest, fmt.Sprintf("umbral inválido: %s", v))
+			return
+		}
+	}
+
+	duplicados, err := c.clienteService.BuscarDuplicados(umbral)
+	if stderrors.Is(err, services.ErrFiltroInvalido) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error("Duplicados de clientes: Error", "CLIENTES_DUPLICADOS_ERROR", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrServerError, "Error al buscar clientes duplicados")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, duplicados, "")
+}
+
+// Fusionar pasa las ventas de duplicado_id al cliente de la ruta y elimina el duplicado
+func (c *ClienteController) Fusionar(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseClienteID(w, r, "Fusionar clientes")
+	if !ok {
+		return
+	}
+
+	var req models.FusionarClienteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Warn("Fusionar clientes: JSON inválido", map[string]interface{}{"error": err.Error()})
+		errors.WriteError(w, errors.ErrBadRequest, "JSON inválido")
+		return
+	}
+	if req.DuplicadoID <= 0 {
+		errors.WriteError(w, errors.ErrBadRequest, "duplicado_id es requerido")
+		return
+	}
+
+	movidas, err := c.clienteService.FusionarClientes(id, req.DuplicadoID, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrFusionInvalida) {
+		errors.WriteError(w, errors.ErrBadRequest, err.Error())
+		return
+	}
+	if stderrors.Is(err, services.ErrClienteNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error("Fusionar clientes: Error", "CLIENTE_MERGE_ERROR", map[string]interface{}{
+			"cliente_id":   id,
+			"duplicado_id": req.DuplicadoID,
+			"error":        err.Error(),
+		})
+		errors.WriteError(w, errors.ErrServerError, "Error al fusionar clientes")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": id, "ventas_movidas": movidas}, "Clientes fusionados")
+}
+
+// Exportar descarga como JSON todos los datos guardados de un cliente
+// (derecho de acceso, Ley 25.326)
+func (c *ClienteController) Exportar(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseClienteID(w, r, "Exportar cliente")
+	if !ok {
+		return
+	}
+
+	exportacion, err := c.clienteService.ExportarCliente(id, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrClienteNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, "Cliente no encontrado")
+		return
+	}
+	if err != nil {
+		logger.Error("Exportar cliente: Error", "CLIENTE_EXPORT_ERROR", map[string]interface{}{
+			"cliente_id": id,
+			"error":      err.Error(),
+		})
+		errors.WriteError(w, errors.ErrServerError, "Error al exportar datos del cliente")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="cliente-%d.json"`, id))
+	errors.WriteSuccess(w, http.StatusOK, exportacion, "")
+}
+
+// Anonimizar borra nombre y teléfono de un cliente conservando sus ventas
+// (derecho de supresión, Ley 25.326)
+func (c *ClienteController) Anonimizar(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseClienteID(w, r, "Anonimizar cliente")
+	if !ok {
+		return
+	}
+
+	err := c.clienteService.AnonimizarCliente(id, httputil.GetPrincipal(r))
+	if stderrors.Is(err, services.ErrClienteNoEncontrado) {
+		errors.WriteError(w, errors.ErrNotFound, "Cliente no encontrado")
+		return
+	}
+	if err != nil {
+		logger.Error("Anonimizar cliente: Error", "CLIENTE_ANONYMIZE_ERROR", map[string]interface{}{
+			"cliente_id": id,
+			"error":      err.Error(),
+		})
+		errors.WriteError(w, errors.ErrServerError, "Error al anonimizar cliente")
+		return
+	}
+
+	errors.WriteSuccess(w, http.StatusOK, map[string]interface{}{"id": id}, "Cliente anonimizado")
+}
+
+// parseClienteID lee el :id de la ruta; si es inválido escribe el 400 y retorna false
+func parseClienteID(w http.ResponseWriter, r *http.Request, operacion string) (int, bool) {
+	idStr := httputil.GetParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		logger.Warn(operacion+": ID inválido", map[string]interface{}{"id": idStr})
+		errors.WriteError(w, errors.ErrBadRequest, "ID de cliente inválido")
+		return 0, false
+	}
+	return id, true
+}
@@ -2,37 +2,86 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"pizzas-ecos/database"
+	"pizzas-ecos/httputil"
 	"pizzas-ecos/models"
+	"pizzas-ecos/services"
 )
 
 // TestVentaService es una versión de test que no llama a database
 type TestVentaService struct {
-	crearVentaFunc func(req *models.VentaRequest) (int, error)
+	crearVentaFunc         func(req *models.VentaRequest) (*models.VentaCreada, error)
+	actualizarVentaFunc    func(productos []map[string]interface{}) error
+	obtenerTodasVentasFunc func(filtro models.VentaFiltro) ([]models.VentaStats, string, error)
 }
 
-func (s *TestVentaService) CrearVenta(req *models.VentaRequest) (int, error) {
+func (s *TestVentaService) CrearVenta(req *models.VentaRequest, actor *models.Principal) (*models.VentaCreada, error) {
 	if s.crearVentaFunc != nil {
 		return s.crearVentaFunc(req)
 	}
-	return 1, nil
+	return &models.VentaCreada{ID: 1}, nil
+}
+
+func (s *TestVentaService) CrearVentaIdempotente(req *models.VentaRequest, clave string, actor *models.Principal) (*models.VentaCreada, error) {
+	if clave == "repetida" {
+		return &models.VentaCreada{ID: 1, Replay: true}, nil
+	}
+	if clave == "otro-cuerpo" {
+		return nil, services.ErrIdempotenciaConflicto
+	}
+	return s.CrearVenta(req, actor)
 }
 
-func (s *TestVentaService) ActualizarVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}) error {
+func (s *TestVentaService) ActualizarVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}, cliente *models.ClienteVenta, actor *models.Principal) error {
+	if s.actualizarVentaFunc != nil {
+		return s.actualizarVentaFunc(productos)
+	}
 	return nil
 }
 
-func (s *TestVentaService) ObtenerEstadisticas() (map[string]interface{}, error) {
+func (s *TestVentaService) ObtenerEstadisticas(campanaID int) (map[string]interface{}, error) {
 	return map[string]interface{}{}, nil
 }
 
-func (s *TestVentaService) ObtenerTodasVentas() ([]models.VentaStats, error) {
-	return []models.VentaStats{}, nil
+func (s *TestVentaService) ObtenerVenta(id int) (*models.VentaStats, error) {
+	if id != 1 {
+		return nil, services.ErrVentaNoEncontrada
+	}
+	return &models.VentaStats{ID: 1, Items: []models.ProductoItem{}}, nil
+}
+
+func (s *TestVentaService) EliminarVenta(id int, actor *models.Principal) error {
+	if id != 1 {
+		return services.ErrVentaNoEncontrada
+	}
+	return nil
+}
+
+func (s *TestVentaService) RegistrarPago(ventaID int, req *models.PagoRequest, actor *models.Principal) (*models.Pago, error) {
+	return &models.Pago{ID: 1, VentaID: ventaID, Monto: req.Monto, Metodo: req.Metodo, RegistradoPor: actor.Nombre()}, nil
+}
+
+func (s *TestVentaService) ObtenerPagos(ventaID int) ([]models.Pago, error) {
+	if ventaID != 1 {
+		return nil, services.ErrVentaNoEncontrada
+	}
+	return []models.Pago{}, nil
+}
+
+func (s *TestVentaService) ObtenerTodasVentas(filtro models.VentaFiltro) ([]models.VentaStats, string, error) {
+	if s.obtenerTodasVentasFunc != nil {
+		return s.obtenerTodasVentasFunc(filtro)
+	}
+	return []models.VentaStats{}, "", nil
 }
 
 // TestProductoService es una versión de test que no llama a database
@@ -43,28 +92,28 @@ type TestProductoService struct {
 	eliminarProductoFunc   func(id int) error
 }
 
-func (s *TestProductoService) ObtenerProductos() ([]models.Producto, error) {
+func (s *TestProductoService) ObtenerProductos(campanaID int) ([]models.Producto, error) {
 	if s.obtenerProductosFunc != nil {
 		return s.obtenerProductosFunc()
 	}
 	return []models.Producto{{ID: 1, TipoPizza: "Margherita", Precio: 10.0}}, nil
 }
 
-func (s *TestProductoService) CrearProducto(req *models.CrearProductoRequest) (int64, error) {
+func (s *TestProductoService) CrearProducto(req *models.CrearProductoRequest, actor *models.Principal) (int64, error) {
 	if s.crearProductoFunc != nil {
 		return s.crearProductoFunc(req)
 	}
 	return 1, nil
 }
 
-func (s *TestProductoService) ActualizarProducto(id int, req *models.ActualizarProductoRequest) error {
+func (s *TestProductoService) ActualizarProducto(id int, req *models.ActualizarProductoRequest, actor *models.Principal) error {
 	if s.actualizarProductoFunc != nil {
 		return s.actualizarProductoFunc(id, req)
 	}
 	return nil
 }
 
-func (s *TestProductoService) EliminarProducto(id int) error {
+func (s *TestProductoService) EliminarProducto(id int, actor *models.Principal) error {
 	if s.eliminarProductoFunc != nil {
 		return s.eliminarProductoFunc(id)
 	}
@@ -86,21 +135,21 @@ func (s *TestVendedorService) ObtenerVendedores() ([]models.Vendedor, error) {
 	return []models.Vendedor{{ID: 1, Nombre: "Juan Pérez"}}, nil
 }
 
-func (s *TestVendedorService) CrearVendedor(nombre string) (int64, error) {
+func (s *TestVendedorService) CrearVendedor(nombre string, actor *models.Principal) (int64, error) {
 	if s.crearVendedorFunc != nil {
 		return s.crearVendedorFunc(nombre)
 	}
 	return 1, nil
 }
 
-func (s *TestVendedorService) ActualizarVendedor(id int, nombre string) error {
+func (s *TestVendedorService) ActualizarVendedor(id int, nombre string, actor *models.Principal) error {
 	if s.actualizarVendedorFunc != nil {
 		return s.actualizarVendedorFunc(id, nombre)
 	}
 	return nil
 }
 
-func (s *TestVendedorService) EliminarVendedor(id int) error {
+func (s *TestVendedorService) EliminarVendedor(id int, actor *models.Principal) error {
 	if s.eliminarVendedorFunc != nil {
 		return s.eliminarVendedorFunc(id)
 	}
@@ -110,15 +159,39 @@ func (s *TestVendedorService) EliminarVendedor(id int) error {
 // TestAuthService es una versión de test que no llama a database
 type TestAuthService struct {
 	autenticarUsuarioFunc func(username, password string) (*models.User, error)
+	refrescarSesionFunc   func(refreshToken string) (*models.LoginResponse, error)
 }
 
-func (s *TestAuthService) AutenticarUsuario(username, password string) (*models.User, error) {
+func (s *TestAuthService) AutenticarUsuario(username, password, ip string) (*models.User, error) {
 	if s.autenticarUsuarioFunc != nil {
 		return s.autenticarUsuarioFunc(username, password)
 	}
 	return &models.User{ID: 1, Username: "Admin", Rol: "admin"}, nil
 }
 
+func (s *TestAuthService) IniciarSesion(user *models.User) (*models.LoginResponse, error) {
+	return &models.LoginResponse{Token: "access", RefreshToken: "refresh", ExpiresIn: 900, User: *user}, nil
+}
+
+func (s *TestAuthService) RefrescarSesion(refreshToken string) (*models.LoginResponse, error) {
+	if s.refrescarSesionFunc != nil {
+		return s.refrescarSesionFunc(refreshToken)
+	}
+	return &models.LoginResponse{Token: "access", RefreshToken: "refresh", ExpiresIn: 900}, nil
+}
+
+func (s *TestAuthService) CerrarSesion(refreshToken string, claims *models.TokenClaims) error {
+	return nil
+}
+
+func (s *TestAuthService) CambiarPassword(actor *models.Principal, actual, nueva string) (*models.LoginResponse, error) {
+	return &models.LoginResponse{Token: "access", RefreshToken: "refresh", ExpiresIn: 900}, nil
+}
+
+func (s *TestAuthService) RestablecerPassword(token, nueva string) error {
+	return nil
+}
+
 // Test helpers
 func createTestRequest(method, url string, body interface{}) *http.Request {
 	var reqBody bytes.Buffer
@@ -130,7 +203,21 @@ func createTestRequest(method, url string, body interface{}) *http.Request {
 	return req
 }
 
+// withPathParams agrega al request los parámetros de ruta que inyecta el router
+func withPathParams(req *http.Request, params httputil.PathParams) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), httputil.PathParamsKey, params))
+}
+
 func TestVentaController_CrearVenta(t *testing.T) {
+	ventaValida := models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+		PaymentMethod: "efectivo",
+		Estado:        "pagada",
+		TipoEntrega:   "retiro",
+	}
+
 	tests := []struct {
 		name           string
 		requestBody    models.VentaRequest
@@ -149,11 +236,11 @@ func TestVentaController_CrearVenta(t *testing.T) {
 				PaymentMethod:   "efectivo",
 				Estado:          "pagada",
 				TipoEntrega:     "retiro",
-				TelefonoCliente: 12345678,
+				TelefonoCliente: "11 2345-6789",
 			},
 			mockSetup: func(m *TestVentaService) {
-				m.crearVentaFunc = func(req *models.VentaRequest) (int, error) {
-					return 1, nil
+				m.crearVentaFunc = func(req *models.VentaRequest) (*models.VentaCreada, error) {
+					return &models.VentaCreada{ID: 1}, nil
 				}
 			},
 			expectedStatus: http.StatusCreated,
@@ -172,8 +259,8 @@ func TestVentaController_CrearVenta(t *testing.T) {
 				TipoEntrega:   "retiro",
 			},
 			mockSetup: func(m *TestVentaService) {
-				m.crearVentaFunc = func(req *models.VentaRequest) (int, error) {
-					return 1, nil
+				m.crearVentaFunc = func(req *models.VentaRequest) (*models.VentaCreada, error) {
+					return &models.VentaCreada{ID: 1}, nil
 				}
 			},
 			expectedStatus: http.StatusCreated,
@@ -222,6 +309,39 @@ func TestVentaController_CrearVenta(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  true,
 		},
+		{
+			name:        "vendedor inexistente es 422",
+			requestBody: ventaValida,
+			mockSetup: func(m *TestVentaService) {
+				m.crearVentaFunc = func(req *models.VentaRequest) (*models.VentaCreada, error) {
+					return nil, fmt.Errorf("%w: %q", services.ErrVendedorNoEncontrado, req.Vendedor)
+				}
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  true,
+		},
+		{
+			name:        "producto inactivo es 422",
+			requestBody: ventaValida,
+			mockSetup: func(m *TestVentaService) {
+				m.crearVentaFunc = func(req *models.VentaRequest) (*models.VentaCreada, error) {
+					return nil, &services.ItemRechazadoError{Item: 0, ProductoID: 1, Err: services.ErrProductoInactivo}
+				}
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  true,
+		},
+		{
+			name:        "producto inexistente es 422",
+			requestBody: ventaValida,
+			mockSetup: func(m *TestVentaService) {
+				m.crearVentaFunc = func(req *models.VentaRequest) (*models.VentaCreada, error) {
+					return nil, &services.ItemRechazadoError{Item: 0, ProductoID: 99, Err: services.ErrProductoNoEncontrado}
+				}
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -248,6 +368,297 @@ func TestVentaController_CrearVenta(t *testing.T) {
 	}
 }
 
+func TestVentaController_CrearVentaInformaDiferenciasDePrecio(t *testing.T) {
+	// Arrange
+	mockService := &TestVentaService{
+		crearVentaFunc: func(req *models.VentaRequest) (*models.VentaCreada, error) {
+			return &models.VentaCreada{ID: 7, DiferenciasPrecio: []models.PrecioDiferencia{
+				{ProductoID: 1, PrecioEnviado: 8, PrecioCatalogo: 10, TotalEnviado: 8, TotalCalculado: 10},
+			}}, nil
+		},
+	}
+	controller := &VentaController{ventaService: mockService}
+	req := createTestRequest("POST", "/api/v1/ventas", models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1, Precio: 8}},
+		PaymentMethod: "efectivo",
+		TipoEntrega:   "retiro",
+	})
+	w := httptest.NewRecorder()
+
+	// Act
+	controller.CrearVenta(w, req)
+
+	// Assert
+	var resp struct {
+		Data models.VentaCreada `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decodificando respuesta: %v", err)
+	}
+	if w.Code != http.StatusCreated || resp.Data.ID != 7 || len(resp.Data.DiferenciasPrecio) != 1 || resp.Data.DiferenciasPrecio[0].PrecioCatalogo != 10 {
+		t.Errorf("status = %d, data = %+v, want 201 con la diferencia del producto 1", w.Code, resp.Data)
+	}
+}
+
+func TestVentaController_CrearVentaIdempotencyKey(t *testing.T) {
+	body := models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1, Precio: 10.0}},
+		PaymentMethod: "efectivo",
+		Estado:        "pagada",
+		TipoEntrega:   "retiro",
+	}
+
+	tests := []struct {
+		name           string
+		clave          string
+		expectedStatus int
+		expectedReplay bool
+	}{
+		{"clave nueva crea la venta", "nueva", http.StatusCreated, false},
+		{"reintento con el mismo cuerpo retorna la venta original", "repetida", http.StatusCreated, true},
+		{"reintento con otro cuerpo es 422", "otro-cuerpo", http.StatusUnprocessableEntity, false},
+		{"clave demasiado larga es 400", strings.Repeat("k", 256), http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			controller := &VentaController{ventaService: &TestVentaService{}}
+			req := createTestRequest("POST", "/api/v1/ventas", body)
+			req.Header.Set("Idempotency-Key", tt.clave)
+			w := httptest.NewRecorder()
+
+			// Act
+			controller.CrearVenta(w, req)
+
+			// Assert
+			if w.Code != tt.expectedStatus {
+				t.Errorf("CrearVenta() status = %v, want %v", w.Code, tt.expectedStatus)
+			}
+			if replay := w.Header().Get("Idempotent-Replayed") == "true"; replay != tt.expectedReplay {
+				t.Errorf("Idempotent-Replayed = %v, want %v", replay, tt.expectedReplay)
+			}
+		})
+	}
+}
+
+func TestVentaController_CrearVentaReintentoRetornaLaMismaRespuesta(t *testing.T) {
+	// Arrange: servicio real sobre el store en memoria; el precio enviado no
+	// coincide con el catálogo, así que la respuesta informa la diferencia
+	store := database.NewMemoryStore()
+	store.Vendedores().CreateVendedor("Juan Pérez", nil)
+	store.Productos().CreateProducto(1, "Margherita", "Clásica", 10.0, nil)
+	controller := &VentaController{ventaService: services.NewVentaService(store)}
+	body := models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 2, Precio: 8.0, Total: 16.0}},
+		PaymentMethod: "efectivo",
+		TipoEntrega:   "retiro",
+	}
+	crear := func() *httptest.ResponseRecorder {
+		req := createTestRequest("POST", "/api/v1/ventas", body)
+		req.Header.Set("Idempotency-Key", "clave-1")
+		w := httptest.NewRecorder()
+		controller.CrearVenta(w, req)
+		return w
+	}
+
+	// Act
+	original := crear()
+	repetida := crear()
+
+	// Assert
+	if original.Code != http.StatusCreated || repetida.Code != http.StatusCreated {
+		t.Fatalf("status = %v y %v, want %v", original.Code, repetida.Code, http.StatusCreated)
+	}
+	if repetida.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("el reintento no tiene Idempotent-Replayed: true")
+	}
+	if !strings.Contains(original.Body.String(), "diferencias_precio") {
+		t.Fatalf("respuesta original sin diferencias_precio: %s", original.Body.String())
+	}
+	if !bytes.Equal(repetida.Body.Bytes(), original.Body.Bytes()) {
+		t.Errorf("cuerpo del reintento = %s, want %s", repetida.Body.String(), original.Body.String())
+	}
+}
+
+func TestVentaController_ObtenerTodasVentas(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		checkFiltro    func(t *testing.T, f models.VentaFiltro)
+	}{
+		{
+			name:           "filtros válidos se pasan al servicio",
+			url:            "/api/v1/ventas/todas?desde=2024-05-01&hasta=2024-05-31&vendedor=Juan&estado=pagada&orden=-total&limit=20",
+			expectedStatus: http.StatusOK,
+			checkFiltro: func(t *testing.T, f models.VentaFiltro) {
+				if f.Vendedor != "Juan" || f.Estado != "pagada" || f.Orden != "-total" || f.Limit != 20 {
+					t.Errorf("filtro = %+v", f)
+				}
+				if f.Hasta == nil || f.Hasta.Format("2006-01-02") != "2024-06-01" {
+					t.Errorf("hasta = %v, want 2024-06-01 (día completo)", f.Hasta)
+				}
+				if !f.IncluirCanceladas {
+					t.Error("el listado debe incluir canceladas")
+				}
+			},
+		},
+		{
+			name:           "fecha inválida debe fallar",
+			url:            "/api/v1/ventas/todas?desde=ayer",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "limit inválido debe fallar",
+			url:            "/api/v1/ventas/todas?limit=-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var recibido models.VentaFiltro
+			mockService := &TestVentaService{
+				obtenerTodasVentasFunc: func(f models.VentaFiltro) ([]models.VentaStats, string, error) {
+					recibido = f
+					return []models.VentaStats{{ID: 1}}, "siguiente", nil
+				},
+			}
+			controller := &VentaController{ventaService: mockService}
+
+			req := createTestRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+
+			// Act
+			controller.ObtenerTodasVentas(w, req)
+
+			// Assert
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("ObtenerTodasVentas() status = %v, want %v", w.Code, tt.expectedStatus)
+			}
+			if tt.checkFiltro == nil {
+				return
+			}
+			tt.checkFiltro(t, recibido)
+
+			var response struct {
+				Data       []models.VentaStats `json:"data"`
+				NextCursor string              `json:"next_cursor"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Data) != 1 || response.NextCursor != "siguiente" {
+				t.Errorf("response = %+v", response)
+			}
+		})
+	}
+}
+
+func TestVentaController_ObtenerYEliminarVenta(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		id             string
+		expectedStatus int
+	}{
+		{"obtener venta existente", "GET", "1", http.StatusOK},
+		{"obtener venta inexistente", "GET", "99", http.StatusNotFound},
+		{"obtener con id inválido", "GET", "abc", http.StatusBadRequest},
+		{"eliminar venta existente", "DELETE", "1", http.StatusOK},
+		{"eliminar venta inexistente", "DELETE", "99", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			controller := &VentaController{ventaService: &TestVentaService{}}
+			req := withPathParams(createTestRequest(tt.method, "/api/v1/ventas/"+tt.id, nil), httputil.PathParams{"id": tt.id})
+			w := httptest.NewRecorder()
+
+			// Act
+			if tt.method == "GET" {
+				controller.ObtenerVenta(w, req)
+			} else {
+				controller.EliminarVenta(w, req)
+			}
+
+			// Assert
+			if w.Code != tt.expectedStatus {
+				t.Errorf("%s status = %v, want %v", tt.method, w.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestVentaController_ActualizarVentaRechazaItems(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{
+			name:           "producto inactivo es 422",
+			err:            &services.ItemRechazadoError{Item: 0, ProductoID: 1, Err: services.ErrProductoInactivo},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "producto inexistente es 422",
+			err:            &services.ItemRechazadoError{Item: 0, ProductoID: 99, Err: services.ErrProductoNoEncontrado},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "cantidad inválida es 422",
+			err:            &services.ItemRechazadoError{Item: 0, ProductoID: 1, Err: services.ErrCantidadInvalida},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "producto de otra campaña es 409",
+			err:            &services.ItemRechazadoError{Item: 0, ProductoID: 2, Err: services.ErrProductoOtraCampana},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "item sin producto_id es 400",
+			err:            fmt.Errorf("%w: item 0 sin producto_id numérico", services.ErrItemInvalido),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockService := &TestVentaService{
+				actualizarVentaFunc: func(productos []map[string]interface{}) error { return tt.err },
+			}
+			controller := &VentaController{ventaService: mockService}
+
+			req := createTestRequest("PUT", "/api/v1/ventas/1", map[string]interface{}{
+				"estado":         "sin_pagar",
+				"payment_method": "efectivo",
+				"productos":      []map[string]interface{}{{"producto_id": 1, "cantidad": 1}},
+			})
+			req = withPathParams(req, httputil.PathParams{"id": "1"})
+			w := httptest.NewRecorder()
+
+			// Act
+			controller.ActualizarVenta(w, req)
+
+			// Assert
+			if w.Code != tt.expectedStatus {
+				t.Errorf("ActualizarVenta() status = %v, want %v", w.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
+
 func TestProductoController_Listar(t *testing.T) {
 	// Arrange
 	mockService := &TestProductoService{
@@ -344,6 +755,31 @@ func TestProductoController_CrearProducto(t *testing.T) {
 	}
 }
 
+func TestProductoController_ActualizarInexistente(t *testing.T) {
+	// Arrange
+	mockService := &TestProductoService{
+		actualizarProductoFunc: func(id int, req *models.ActualizarProductoRequest) error {
+			return services.ErrProductoNoEncontrado
+		},
+	}
+	controller := &ProductoController{productoService: mockService}
+
+	req := createTestRequest("PUT", "/api/v1/productos/99", models.ActualizarProductoRequest{
+		TipoPizza: "Napolitana",
+		Precio:    12.0,
+	})
+	req = withPathParams(req, httputil.PathParams{"id": "99"})
+	w := httptest.NewRecorder()
+
+	// Act
+	controller.Actualizar(w, req)
+
+	// Assert
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Actualizar() status = %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
 func TestVendedorController_Listar(t *testing.T) {
 	// Arrange
 	mockService := &TestVendedorService{
@@ -415,11 +851,24 @@ func TestAuthController_Login(t *testing.T) {
 			},
 			mockSetup: func(m *TestAuthService) {
 				m.autenticarUsuarioFunc = func(username, password string) (*models.User, error) {
-					return nil, fmt.Errorf("credenciales inválidas")
+					return nil, services.ErrCredencialesInvalidas
 				}
 			},
 			expectedStatus: http.StatusUnauthorized,
 		},
+		{
+			name: "login bloqueado por intentos fallidos debe retornar 429",
+			requestBody: models.LoginRequest{
+				Username: "admin",
+				Password: "password123",
+			},
+			mockSetup: func(m *TestAuthService) {
+				m.autenticarUsuarioFunc = func(username, password string) (*models.User, error) {
+					return nil, &services.LoginBloqueadoError{Espera: 90 * time.Second}
+				}
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
 	}
 
 	for _, tt := range tests {
@@ -445,3 +894,55 @@ func TestAuthController_Login(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthController_Refresh(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    models.RefreshRequest
+		mockSetup      func(*TestAuthService)
+		expectedStatus int
+	}{
+		{
+			name:           "refresh token válido debe retornar tokens nuevos",
+			requestBody:    models.RefreshRequest{RefreshToken: "vigente"},
+			mockSetup:      func(m *TestAuthService) {},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:        "refresh token revocado debe retornar 401",
+			requestBody: models.RefreshRequest{RefreshToken: "usado"},
+			mockSetup: func(m *TestAuthService) {
+				m.refrescarSesionFunc = func(refreshToken string) (*models.LoginResponse, error) {
+					return nil, services.ErrSesionInvalida
+				}
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "sin refresh token debe retornar 400",
+			requestBody:    models.RefreshRequest{},
+			mockSetup:      func(m *TestAuthService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockService := &TestAuthService{}
+			tt.mockSetup(mockService)
+			controller := &AuthController{authService: mockService}
+
+			req := createTestRequest("POST", "/api/v1/auth/refresh", tt.requestBody)
+			w := httptest.NewRecorder()
+
+			// Act
+			controller.Refresh(w, req)
+
+			// Assert
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Refresh() status = %v, want %v", w.Code, tt.expectedStatus)
+			}
+		})
+	}
+}
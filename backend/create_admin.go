@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+
+	"pizzas-ecos/database"
+	"pizzas-ecos/models"
+	"pizzas-ecos/services"
+)
+
+/* =========================
+   SUBCOMANDO create-admin
+========================= */
+
+// Crea el primer usuario admin de un entorno nuevo sin pasar por la API (que
+// ya exige un token de admin):
+//
+//	echo "$PASSWORD" | ./pizzas-ecos create-admin -username admin
+//	./pizzas-ecos create-admin -username admin -env-file /run/secrets/admin.env
+//
+// Con -env-file la contraseña se lee de ADMIN_PASSWORD en ese archivo; si no,
+// de la primera línea de stdin. Si ya existe algún admin no hace nada salvo
+// que se pase -force
+
+// errAdminExistente indica que ya hay un admin y no se pidió -force
+var errAdminExistente = errors.New("ya existe un usuario admin (usar -force para crear otro)")
+
+// ejecutarCreateAdmin parsea los argumentos del subcomando, conecta a la BD y
+// crea el admin. Retorna el código de salida del proceso
+func ejecutarCreateAdmin(args []string, stdin io.Reader, salida io.Writer) int {
+	fs := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	fs.SetOutput(salida)
+	username := fs.String("username", "admin", "nombre del usuario admin")
+	envFile := fs.String("env-file", "", "archivo con ADMIN_PASSWORD (si no, se lee de stdin)")
+	forzar := fs.Bool("force", false, "crear el admin aunque ya exista otro")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	password, err := leerPasswordAdmin(*envFile, stdin)
+	if err != nil {
+		fmt.Fprintf(salida, "❌ %v\n", err)
+		return 1
+	}
+
+	if err := initDB(); err != nil {
+		fmt.Fprintf(salida, "❌ Error inicializando BD: %v\n", err)
+		return 1
+	}
+
+	id, err := crearAdmin(database.NewMySQLStore(database.DB), *username, password, *forzar)
+	if err != nil {
+		fmt.Fprintf(salida, "❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(salida, "✅ Usuario admin %q creado (id %d)\n", *username, id)
+	return 0
+}
+
+// leerPasswordAdmin retorna ADMIN_PASSWORD de envFile o, si envFile está
+// vacío, la primera línea de stdin
+func leerPasswordAdmin(envFile string, stdin io.Reader) (string, error) {
+	if envFile != "" {
+		vars, err := godotenv.Read(envFile)
+		if err != nil {
+			return "", fmt.Errorf("leyendo %s: %w", envFile, err)
+		}
+		if vars["ADMIN_PASSWORD"] == "" {
+			return "", fmt.Errorf("%s no define ADMIN_PASSWORD", envFile)
+		}
+		return vars["ADMIN_PASSWORD"], nil
+	}
+
+	if f, ok := stdin.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprint(os.Stderr, "Contraseña del admin: ")
+		}
+	}
+	linea, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("leyendo contraseña de stdin: %w", err)
+	}
+	password := strings.TrimRight(linea, "\r\n")
+	if password == "" {
+		return "", errors.New("contraseña vacía: pasarla por stdin o con -env-file")
+	}
+	return password, nil
+}
+
+// crearAdmin crea el usuario con rol admin si todavía no hay ninguno (o si
+// forzar). El alta queda en la auditoría sin usuario, como hecha por el sistema
+func crearAdmin(store database.Store, username, password string, forzar bool) (int, error) {
+	if strings.TrimSpace(username) == "" {
+		return 0, errors.New("username requerido")
+	}
+
+	if !forzar {
+		usuarios, err := store.Usuarios().GetAllUsers()
+		if err != nil {
+			return 0, fmt.Errorf("error obteniendo usuarios: %w", err)
+		}
+		for _, u := range usuarios {
+			if u.Rol == models.RolAdmin {
+				return 0, errAdminExistente
+			}
+		}
+	}
+
+	return services.NewUsuarioService(store).CrearUsuario(username, password, models.RolAdmin, nil)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pizzas-ecos/database"
+	"pizzas-ecos/models"
+)
+
+func TestLeerPasswordAdmin(t *testing.T) {
+	dir := t.TempDir()
+	conPassword := filepath.Join(dir, "admin.env")
+	os.WriteFile(conPassword, []byte("ADMIN_PASSWORD=desde-archivo\n"), 0600)
+	sinPassword := filepath.Join(dir, "vacio.env")
+	os.WriteFile(sinPassword, []byte("OTRA=1\n"), 0600)
+
+	tests := []struct {
+		name      string
+		envFile   string
+		stdin     string
+		want      string
+		wantError bool
+	}{
+		{"primera línea de stdin", "", "secreta123\nresto\n", "secreta123", false},
+		{"stdin sin salto de línea", "", "secreta123", "secreta123", false},
+		{"stdin con CRLF", "", "secreta123\r\n", "secreta123", false},
+		{"stdin vacío", "", "", "", true},
+		{"env file tiene prioridad", conPassword, "ignorada\n", "desde-archivo", false},
+		{"env file sin ADMIN_PASSWORD", sinPassword, "", "", true},
+		{"env file inexistente", filepath.Join(dir, "no.env"), "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := leerPasswordAdmin(tt.envFile, strings.NewReader(tt.stdin))
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Fatalf("leerPasswordAdmin() error = %v, wantError %v", err, tt.wantError)
+			}
+			if got != tt.want {
+				t.Errorf("leerPasswordAdmin() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrearAdmin(t *testing.T) {
+	// Arrange
+	store := database.NewMemoryStore()
+
+	// Act + Assert: sin admins lo crea con la contraseña hasheada
+	id, err := crearAdmin(store, "admin", "secreta123", false)
+	if err != nil {
+		t.Fatalf("crearAdmin() error = %v", err)
+	}
+	user, err := store.Usuarios().GetUserByCredentials("admin", "secreta123")
+	if err != nil || user.ID != id || user.Rol != models.RolAdmin {
+		t.Errorf("admin creado = %v, %v; want id %d con rol admin", user, err, id)
+	}
+
+	// Act + Assert: con un admin existente se niega salvo -force
+	if _, err := crearAdmin(store, "otro", "secreta123", false); !errors.Is(err, errAdminExistente) {
+		t.Errorf("segundo admin error = %v, want errAdminExistente", err)
+	}
+	if _, err := crearAdmin(store, "otro", "secreta123", true); err != nil {
+		t.Errorf("segundo admin con force error = %v", err)
+	}
+	if _, err := crearAdmin(store, "otro", "secreta123", true); err == nil {
+		t.Error("crear un username repetido no falló")
+	}
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"pizzas-ecos/models"
+)
+
+// MaxLimitAuditoria es la cantidad máxima de entradas por consulta de auditoría
+const MaxLimitAuditoria = 500
+
+// InsertAuditoria guarda una entrada de auditoría. Debe ejecutarse en la misma
+// transacción que el cambio que registra
+func InsertAuditoria(q Querier, a models.Auditoria) (int, error) {
+	var usuario interface{}
+	if a.Usuario != "" {
+		usuario = a.Usuario
+	}
+	res, err := q.Exec(`
+		INSERT INTO auditoria (entidad, entidad_id, accion, usuario_id, usuario, cambios, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, a.Entidad, a.EntidadID, a.Accion, a.UsuarioID, usuario, string(a.Cambios), a.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetAuditoria retorna las entradas que cumplen el filtro, de la más nueva a
+// la más vieja
+func GetAuditoria(q Querier, f models.AuditoriaFiltro) ([]models.Auditoria, error) {
+	if f.Limit <= 0 || f.Limit > MaxLimitAuditoria {
+		return nil, fmt.Errorf("%w: limit debe estar entre 1 y %d", ErrFiltroInvalido, MaxLimitAuditoria)
+	}
+
+	b := &ventasQuery{}
+	if f.Entidad != "" {
+		b.add("entidad = ?", f.Entidad)
+	}
+	if f.EntidadID != 0 {
+		b.add("entidad_id = ?", f.EntidadID)
+	}
+	if f.Usuario != "" {
+		b.add("usuario = ?", f.Usuario)
+	}
+	if f.Desde != nil {
+		b.add("created_at >= ?", *f.Desde)
+	}
+	if f.Hasta != nil {
+		b.add("created_at < ?", *f.Hasta)
+	}
+	if f.AntesDeID != 0 {
+		b.add("id < ?", f.AntesDeID)
+	}
+
+	query := `
+		SELECT id, entidad, entidad_id, accion, usuario_id, COALESCE(usuario, ''), cambios, created_at
+		FROM auditoria`
+	if len(b.where) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(b.where, " AND ")
+	}
+	query += "\n\t\tORDER BY id DESC\n\t\tLIMIT ?"
+	b.args = append(b.args, f.Limit)
+
+	rows, err := q.Query(query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entradas := []models.Auditoria{}
+	for rows.Next() {
+		var a models.Auditoria
+		var usuarioID sql.NullInt64
+		var cambios []byte
+		if err := rows.Scan(&a.ID, &a.Entidad, &a.EntidadID, &a.Accion, &usuarioID, &a.Usuario, &cambios, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if usuarioID.Valid {
+			id := int(usuarioID.Int64)
+			a.UsuarioID = &id
+		}
+		a.Cambios = cambios
+		entradas = append(entradas, a)
+	}
+	return entradas, rows.Err()
+}
+
+// UpdateAuditoriaCambios reemplaza los cambios de una entrada. La auditoría
+// no se edita salvo para quitar datos personales de un cliente anonimizado.
+// Retorna sql.ErrNoRows si no existe
+func UpdateAuditoriaCambios(q Querier, id int, cambios json.RawMessage) error {
+	result, err := q.Exec("UPDATE auditoria SET cambios = ? WHERE id = ?", string(cambios), id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+
+	"pizzas-ecos/models"
+)
+
+// campanaColumnas es el SELECT común de las queries de campañas
+const campanaColumnas = "SELECT id, nombre, fecha_inicio, fecha_fin, activa, created_at FROM campanas"
+
+// scanCampana lee una fila con las columnas de campanaColumnas
+func scanCampana(row interface{ Scan(...interface{}) error }) (*models.Campana, error) {
+	var c models.Campana
+	var fin sql.NullTime
+	if err := row.Scan(&c.ID, &c.Nombre, &c.FechaInicio, &fin, &c.Activa, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	if fin.Valid {
+		t := fin.Time
+		c.FechaFin = &t
+	}
+	return &c, nil
+}
+
+// GetCampanas retorna todas las campañas, de la más reciente a la más antigua
+func GetCampanas(q Querier) ([]models.Campana, error) {
+	rows, err := q.Query(campanaColumnas + " ORDER BY fecha_inicio DESC, id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campanas := []models.Campana{}
+	for rows.Next() {
+		c, err := scanCampana(rows)
+		if err != nil {
+			return nil, err
+		}
+		campanas = append(campanas, *c)
+	}
+	return campanas, rows.Err()
+}
+
+// GetCampanaByID obtiene una campaña. Retorna sql.ErrNoRows si no existe
+func GetCampanaByID(q Querier, id int) (*models.Campana, error) {
+	return scanCampana(q.QueryRow(campanaColumnas+" WHERE id = ?", id))
+}
+
+// GetCampanaActiva obtiene la campaña que recibe las ventas nuevas. Retorna
+// sql.ErrNoRows si no hay ninguna activa
+func GetCampanaActiva(q Querier) (*models.Campana, error) {
+	return scanCampana(q.QueryRow(campanaColumnas + " WHERE activa = TRUE ORDER BY id DESC LIMIT 1"))
+}
+
+// CreateCampana crea una campaña. Que quede una sola activa es
+// responsabilidad del caller
+func CreateCampana(q Querier, c models.Campana, createdBy *int) (int, error) {
+	result, err := q.Exec(
+		"INSERT INTO campanas (nombre, fecha_inicio, fecha_fin, activa, created_by, updated_by) VALUES (?, ?, ?, ?, ?, ?)",
+		c.Nombre, c.FechaInicio, c.FechaFin, c.Activa, createdBy, createdBy,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// UpdateCampana actualiza nombre, fechas y estado de una campaña. Retorna
+// sql.ErrNoRows si no existe
+func UpdateCampana(q Querier, c models.Campana, updatedBy *int) error {
+	if _, err := GetCampanaByID(q, c.ID); err != nil {
+		return err
+	}
+	_, err := q.Exec(
+		"UPDATE campanas SET nombre = ?, fecha_inicio = ?, fecha_fin = ?, activa = ?, updated_by = ? WHERE id = ?",
+		c.Nombre, c.FechaInicio, c.FechaFin, c.Activa, updatedBy, c.ID,
+	)
+	return err
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+
+	"pizzas-ecos/models"
+)
+
+// ErrClienteDuplicado indica que ya existe otro cliente con el mismo nombre
+// normalizado
+var ErrClienteDuplicado = errors.New("ya existe un cliente con ese nombre")
+
+// esClaveDuplicada indica si err es una violación de clave única de MySQL
+func esClaveDuplicada(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1062
+}
+
+// GetClienteByID obtiene un cliente. Retorna sql.ErrNoRows si no existe
+func GetClienteByID(q Querier, id int) (*models.Cliente, error) {
+	var c models.Cliente
+	err := q.QueryRow("SELECT id, nombre, COALESCE(telefono, '') FROM clientes WHERE id = ?", id).
+		Scan(&c.ID, &c.Nombre, &c.Telefono)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// BuscarClientes retorna hasta limit clientes cuyo nombre, o alguna de sus
+// palabras, empieza con prefijo. La collation utf8mb4_unicode_ci de la tabla
+// hace que la comparación ignore mayúsculas y acentos. El orden es el que
+// necesita el autocompletado: nombre exacto, nombre que empieza con el
+// prefijo, palabra que empieza con el prefijo; dentro de cada grupo primero
+// los clientes con más ventas
+func BuscarClientes(q Querier, prefijo string, limit int) ([]models.Cliente, error) {
+	like := escapeLike(prefijo) + "%"
+	rows, err := q.Query(`
+		SELECT c.id, c.nombre, COALESCE(c.telefono, ''),
+			CASE WHEN c.nombre = ? THEN 0 WHEN c.nombre LIKE ? THEN 1 ELSE 2 END AS rango,
+			(SELECT COUNT(*) FROM ventas v WHERE v.cliente_id = c.id) AS cantidad_ventas
+		FROM clientes c
+		WHERE c.nombre LIKE ? OR c.nombre LIKE ?
+		ORDER BY rango, cantidad_ventas DESC, c.nombre, c.id
+		LIMIT ?
+	`, prefijo, like, like, "% "+like, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clientes := []models.Cliente{}
+	for rows.Next() {
+		var c models.Cliente
+		var rango, cantidad int
+		if err := rows.Scan(&c.ID, &c.Nombre, &c.Telefono, &rango, &cantidad); err != nil {
+			return nil, err
+		}
+		clientes = append(clientes, c)
+	}
+	return clientes, rows.Err()
+}
+
+// UpdateCliente reemplaza nombre y teléfono (nil lo borra) de un cliente.
+// Retorna sql.ErrNoRows si no existe y ErrClienteDuplicado si el nombre
+// normalizado es de otro cliente
+func UpdateCliente(q Querier, id int, nombre string, telefono *string) error {
+	if _, err := GetClienteByID(q, id); err != nil {
+		return err
+	}
+	_, err := q.Exec(
+		"UPDATE clientes SET nombre = ?, nombre_normalizado = ?, telefono = ? WHERE id = ?",
+		nombre, NormalizarNombre(nombre), telefono, id,
+	)
+	if esClaveDuplicada(err) {
+		return ErrClienteDuplicado
+	}
+	return err
+}
+
+// ReasignarVentasCliente pasa todas las ventas del cliente desde al cliente
+// hacia y retorna cuántas movió
+func ReasignarVentasCliente(q Querier, desde, hacia int) (int, error) {
+	result, err := q.Exec("UPDATE ventas SET cliente_id = ? WHERE cliente_id = ?", hacia, desde)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// DeleteCliente elimina un cliente. Retorna sql.ErrNoRows si no existe; si
+// tiene ventas falla por la foreign key, por lo que el caller debe chequearlo
+// antes con ContarVentasCliente
+func DeleteCliente(q Querier, id int) error {
+	result, err := q.Exec("DELETE FROM clientes WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ContarVentasCliente retorna cuántas ventas (incluidas las canceladas)
+// están asociadas a un cliente
+func ContarVentasCliente(q Querier, id int) (int, error) {
+	var n int
+	err := q.QueryRow("SELECT COUNT(*) FROM ventas WHERE cliente_id = ?", id).Scan(&n)
+	return n, err
+}
+
+// plegarAcentos reemplaza las vocales acentuadas, la ñ y la ç por su letra
+// base, como hace la collation utf8mb4_unicode_ci al comparar
+var plegarAcentos = strings.NewReplacer(
+	"á", "a", "à", "a", "ä", "a", "â", "a",
+	"é", "e", "è", "e", "ë", "e", "ê", "e",
+	"í", "i", "ì", "i", "ï", "i", "î", "i",
+	"ó", "o", "ò", "o", "ö", "o", "ô", "o",
+	"ú", "u", "ù", "u", "ü", "u", "û", "u",
+	"ñ", "n", "ç", "c",
+)
+
+// NormalizarNombre pasa un nombre a minúsculas, sin acentos y con los
+// espacios colapsados. Es la clave única de clientes.nombre_normalizado
+func NormalizarNombre(s string) string {
+	return plegarAcentos.Replace(strings.ToLower(strings.Join(strings.Fields(s), " ")))
+}
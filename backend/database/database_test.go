@@ -4,12 +4,21 @@ import (
 	"testing"
 )
 
+// requireDB omite el test si no hay conexión configurada (config.InitDB no fue invocado)
+func requireDB(t *testing.T) {
+	t.Helper()
+	if DB == nil {
+		t.Skip("Skipping database integration test: DB no inicializada")
+	}
+}
+
 // TestGetClientesPorVendedor verifica que la función retorne clientes agrupados por vendedor correctamente
 func TestGetClientesPorVendedor(t *testing.T) {
 	// Skip test if database is not available (for CI/CD or when running unit tests)
 	if testing.Short() {
 		t.Skip("Skipping database integration test")
 	}
+	requireDB(t)
 
 	// Este test requiere una base de datos con datos de prueba
 	// Por ahora solo verificamos que la función no retorne error y retorne un map válido
@@ -18,7 +27,7 @@ func TestGetClientesPorVendedor(t *testing.T) {
 	// (En un test real, aquí configuraríamos una base de datos de prueba)
 
 	// Act
-	clientesPorVendedor, err := GetClientesPorVendedor()
+	clientesPorVendedor, err := GetClientesPorVendedor(DB)
 
 	// Assert
 	if err != nil {
@@ -60,6 +69,7 @@ func TestGetVendedorID(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping database integration test")
 	}
+	requireDB(t)
 	tests := []struct {
 		name           string
 		vendedorNombre string
@@ -86,7 +96,7 @@ func TestGetVendedorID(t *testing.T) {
 			// (Configuración de base de datos de prueba)
 
 			// Act
-			id, err := GetVendedorID(tt.vendedorNombre)
+			id, err := GetVendedorID(DB, tt.vendedorNombre)
 
 			// Assert
 			if tt.expectError && err == nil {
@@ -110,6 +120,7 @@ func TestGetOrCreateCliente(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping database integration test")
 	}
+	requireDB(t)
 	tests := []struct {
 		name          string
 		clienteNombre string
@@ -136,7 +147,7 @@ func TestGetOrCreateCliente(t *testing.T) {
 			// (Configuración de base de datos de prueba)
 
 			// Act
-			id, err := GetOrCreateCliente(tt.clienteNombre)
+			id, err := GetOrCreateCliente(DB, tt.clienteNombre)
 
 			// Assert
 			if tt.expectError && err == nil {
@@ -160,11 +171,12 @@ func TestGetProductos(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping database integration test")
 	}
+	requireDB(t)
 	// Arrange
 	// (Configuración de base de datos de prueba)
 
 	// Act
-	productos, err := GetProductos()
+	productos, err := GetProductos(DB, 1)
 
 	// Assert
 	if err != nil {
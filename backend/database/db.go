@@ -2,17 +2,20 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"pizzas-ecos/models"
 	"strings"
+	"time"
 )
 
+// DB es la conexión global, inicializada por config.InitDB
 var DB *sql.DB
 
 // GetVendedores retorna lista de vendedores
-func GetVendedores() ([]models.Vendedor, error) {
-	rows, err := DB.Query("SELECT id, nombre FROM vendedores ORDER BY nombre")
+func GetVendedores(q Querier) ([]models.Vendedor, error) {
+	rows, err := q.Query("SELECT id, nombre FROM vendedores ORDER BY nombre")
 	if err != nil {
 		return nil, err
 	}
@@ -30,15 +33,25 @@ func GetVendedores() ([]models.Vendedor, error) {
 	return vendedores, nil
 }
 
+// GetVendedorByID obtiene un vendedor por ID
+func GetVendedorByID(q Querier, id int) (*models.Vendedor, error) {
+	var v models.Vendedor
+	err := q.QueryRow("SELECT id, nombre FROM vendedores WHERE id = ?", id).Scan(&v.ID, &v.Nombre)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
 // GetVendedorID obtiene el ID de un vendedor por nombre
-func GetVendedorID(nombre string) (int, error) {
+func GetVendedorID(q Querier, nombre string) (int, error) {
 	var id int
-	err := DB.QueryRow("SELECT id FROM vendedores WHERE nombre = ?", nombre).Scan(&id)
+	err := q.QueryRow("SELECT id FROM vendedores WHERE nombre = ?", nombre).Scan(&id)
 	return id, err
 }
 
 // GetClientesPorVendedor obtiene clientes agrupados por vendedor (solo clientes que han tenido ventas con ese vendedor)
-func GetClientesPorVendedor() (map[string][]models.Cliente, error) {
+func GetClientesPorVendedor(q Querier) (map[string][]models.Cliente, error) {
 	result := make(map[string][]models.Cliente)
 
 	// Query para obtener clientes por vendedor basándose en ventas
@@ -47,14 +60,14 @@ func GetClientesPorVendedor() (map[string][]models.Cliente, error) {
 			v.nombre as vendedor,
 			c.id,
 			c.nombre,
-			COALESCE(c.telefono, 0) as telefono
+			COALESCE(c.telefono, '') as telefono
 		FROM ventas vt
 		JOIN vendedores v ON vt.vendedor_id = v.id
 		JOIN clientes c ON vt.cliente_id = c.id
 		ORDER BY v.nombre, c.nombre
 	`
 
-	rows, err := DB.Query(query)
+	rows, err := q.Query(query)
 	if err != nil {
 		return nil, err
 	}
@@ -74,27 +87,36 @@ func GetClientesPorVendedor() (map[string][]models.Cliente, error) {
 	return result, nil
 }
 
-// GetOrCreateCliente obtiene o crea un cliente
-func GetOrCreateCliente(nombre string) (int, error) {
-	// Limpieza básica
-	nombre = strings.TrimSpace(nombre)
-
-	// Usamos transacción para asegurar lectura consistente o escritura
-	tx, err := DB.Begin()
+// GetAllClientes obtiene todos los clientes, tengan o no ventas
+func GetAllClientes(q Querier) ([]models.Cliente, error) {
+	rows, err := q.Query("SELECT id, nombre, COALESCE(telefono, '') FROM clientes ORDER BY id")
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer tx.Rollback() // Rollback seguro si no hay commit
+	defer rows.Close()
 
-	var id int
-	// Intentamos buscar primero
-	err = tx.QueryRow("SELECT id FROM clientes WHERE nombre = ?", nombre).Scan(&id)
-	if err == nil {
-		return id, nil // Ya existe, no hacemos commit porque fue solo lectura, rollback limpia el contexto
+	var clientes []models.Cliente
+	for rows.Next() {
+		var c models.Cliente
+		if err := rows.Scan(&c.ID, &c.Nombre, &c.Telefono); err != nil {
+			return nil, err
+		}
+		clientes = append(clientes, c)
 	}
+	return clientes, rows.Err()
+}
 
-	// Si no existe, creamos
-	res, err := tx.Exec("INSERT INTO clientes (nombre) VALUES (?)", nombre)
+// GetOrCreateCliente obtiene o crea un cliente por nombre normalizado. El
+// upsert sobre la clave única hace que dos pedidos simultáneos del mismo
+// cliente nuevo no lo creen dos veces
+func GetOrCreateCliente(q Querier, nombre string) (int, error) {
+	nombre = strings.TrimSpace(nombre)
+
+	// LAST_INSERT_ID(id) hace que LastInsertId retorne el cliente existente
+	res, err := q.Exec(
+		"INSERT INTO clientes (nombre, nombre_normalizado) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)",
+		nombre, NormalizarNombre(nombre),
+	)
 	if err != nil {
 		return 0, err
 	}
@@ -104,42 +126,34 @@ func GetOrCreateCliente(nombre string) (int, error) {
 		return 0, err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return 0, err
-	}
-
 	return int(idInt), nil
 }
 
-// GetClienteByNombre devuelve id y telefono (0 si null) y si existe
-func GetClienteByNombre(nombre string) (int, int, bool, error) {
+// GetClienteByNombre devuelve id y telefono ("" si null) y si existe. Compara
+// por nombre normalizado
+func GetClienteByNombre(q Querier, nombre string) (int, string, bool, error) {
 	var id int
-	var telefono sql.NullInt64
-	err := DB.QueryRow("SELECT id, telefono FROM clientes WHERE nombre = ?", nombre).Scan(&id, &telefono)
+	var telefono string
+	err := q.QueryRow("SELECT id, COALESCE(telefono, '') FROM clientes WHERE nombre_normalizado = ?", NormalizarNombre(nombre)).Scan(&id, &telefono)
 	if err == sql.ErrNoRows {
-		return 0, 0, false, nil
+		return 0, "", false, nil
 	}
 	if err != nil {
-		return 0, 0, false, err
-	}
-	tel := 0
-	if telefono.Valid {
-		tel = int(telefono.Int64)
+		return 0, "", false, err
 	}
-	return id, tel, true, nil
+	return id, telefono, true, nil
 }
 
-// CreateClienteWithTelefono crea un cliente con telefono opcional
-func CreateClienteWithTelefono(nombre string, telefono *int) (int, error) {
-	if telefono != nil {
-		res, err := DB.Exec("INSERT INTO clientes (nombre, telefono) VALUES (?, ?)", nombre, *telefono)
-		if err != nil {
-			return 0, err
-		}
-		id64, _ := res.LastInsertId()
-		return int(id64), nil
+// CreateClienteWithTelefono crea un cliente con telefono opcional. Retorna
+// ErrClienteDuplicado si ya existe uno con el mismo nombre normalizado
+func CreateClienteWithTelefono(q Querier, nombre string, telefono *string) (int, error) {
+	res, err := q.Exec(
+		"INSERT INTO clientes (nombre, nombre_normalizado, telefono) VALUES (?, ?, ?)",
+		nombre, NormalizarNombre(nombre), telefono,
+	)
+	if esClaveDuplicada(err) {
+		return 0, ErrClienteDuplicado
 	}
-	res, err := DB.Exec("INSERT INTO clientes (nombre) VALUES (?)", nombre)
 	if err != nil {
 		return 0, err
 	}
@@ -148,31 +162,31 @@ func CreateClienteWithTelefono(nombre string, telefono *int) (int, error) {
 }
 
 // UpdateClienteTelefono actualiza el telefono de un cliente
-func UpdateClienteTelefono(id int, telefono *int) error {
+func UpdateClienteTelefono(q Querier, id int, telefono *string) error {
 	if telefono == nil {
-		_, err := DB.Exec("UPDATE clientes SET telefono = NULL WHERE id = ?", id)
+		_, err := q.Exec("UPDATE clientes SET telefono = NULL WHERE id = ?", id)
 		return err
 	}
-	_, err := DB.Exec("UPDATE clientes SET telefono = ? WHERE id = ?", *telefono, id)
+	_, err := q.Exec("UPDATE clientes SET telefono = ? WHERE id = ?", *telefono, id)
 	return err
 }
 
 // UpdateVentaClienteID asocia una venta a un cliente
-func UpdateVentaClienteID(ventaID int, clienteID int) error {
-	_, err := DB.Exec("UPDATE ventas SET cliente_id = ? WHERE id = ?", clienteID, ventaID)
+func UpdateVentaClienteID(q Querier, ventaID int, clienteID int) error {
+	_, err := q.Exec("UPDATE ventas SET cliente_id = ? WHERE id = ?", clienteID, ventaID)
 	return err
 }
 
-// GetProductos retorna lista de productos activos
-func GetProductos() ([]models.Producto, error) {
+// GetProductos retorna lista de productos activos de una campaña
+func GetProductos(q Querier, campanaID int) ([]models.Producto, error) {
 	var productos []models.Producto
 
-	rows, err := DB.Query(`
-		SELECT id, tipo_pizza, descripcion, precio, activo, created_at
+	rows, err := q.Query(`
+		SELECT id, campana_id, tipo_pizza, descripcion, precio, activo, created_at
 		FROM productos
-		WHERE activo = TRUE
+		WHERE activo = TRUE AND campana_id = ?
 		ORDER BY tipo_pizza
-	`)
+	`, campanaID)
 	if err != nil {
 		return nil, err
 	}
@@ -180,7 +194,7 @@ func GetProductos() ([]models.Producto, error) {
 
 	for rows.Next() {
 		var p models.Producto
-		if err := rows.Scan(&p.ID, &p.TipoPizza, &p.Descripcion, &p.Precio, &p.Activo, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.CampanaID, &p.TipoPizza, &p.Descripcion, &p.Precio, &p.Activo, &p.CreatedAt); err != nil {
 			return nil, err
 		}
 		productos = append(productos, p)
@@ -189,13 +203,39 @@ func GetProductos() ([]models.Producto, error) {
 	return productos, nil
 }
 
-// InsertVenta inserta una nueva venta
-func InsertVenta(clienteID *int, vendedorID int, total float64, payment, estado, tipoEntrega string) (int, error) {
+// GetAllProductos obtiene todos los productos, incluidos los dados de baja
+func GetAllProductos(q Querier) ([]models.Producto, error) {
+	var productos []models.Producto
+
+	rows, err := q.Query(`
+		SELECT id, campana_id, tipo_pizza, descripcion, precio, activo, created_at
+		FROM productos
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Producto
+		if err := rows.Scan(&p.ID, &p.CampanaID, &p.TipoPizza, &p.Descripcion, &p.Precio, &p.Activo, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		productos = append(productos, p)
+	}
+
+	return productos, rows.Err()
+}
+
+// InsertVenta inserta una nueva venta en la campaña indicada. createdBy es el
+// usuario que la registra (nil desde rutas públicas sin token)
+func InsertVenta(q Querier, campanaID int, clienteID *int, vendedorID int, total float64, payment, estado, tipoEntrega string, createdBy *int) (int, error) {
 	query := `
-		INSERT INTO ventas (cliente_id, vendedor_id, total, payment_method, estado, tipo_entrega)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO ventas (campana_id, cliente_id, vendedor_id, total, payment_method, estado, tipo_entrega, created_by, updated_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	res, err := DB.Exec(query, clienteID, vendedorID, total, payment, estado, tipoEntrega)
+	res, err := q.Exec(query, campanaID, clienteID, vendedorID, total, payment, estado, tipoEntrega, createdBy, createdBy)
 	if err != nil {
 		return 0, err
 	}
@@ -205,38 +245,29 @@ func InsertVenta(clienteID *int, vendedorID int, total float64, payment, estado,
 }
 
 // InsertDetalle inserta un detalle de venta
-func InsertDetalle(ventaID int, item models.ProductoItem) error {
+func InsertDetalle(q Querier, ventaID int, item models.ProductoItem) error {
 	productoID := item.ProductID
 
 	query := `
 		INSERT INTO detalle_ventas (venta_id, producto_id, cantidad, precio_unitario, subtotal)
 		VALUES (?, ?, ?, ?, ?)
 	`
-	_, err := DB.Exec(query, ventaID, productoID, item.Cantidad, item.Precio, item.Total)
+	_, err := q.Exec(query, ventaID, productoID, item.Cantidad, item.Precio, item.Total)
 	return err
 }
 
-// GetAllVentas retorna todas las ventas
-func GetAllVentas(includeCanceladas bool) ([]models.VentaStats, error) {
-	whereClause := ""
-	if !includeCanceladas {
-		whereClause = "WHERE v.estado != 'cancelada'"
-	}
-
+// GetAllVentas retorna las ventas que cumplen el filtro, en el orden pedido,
+// y el cursor de la página siguiente ("" si no hay más)
+func GetAllVentas(q Querier, filtro models.VentaFiltro) ([]models.VentaStats, string, error) {
 	// 1. Obtener solo las ventas (sin detalles)
-	ventasQuery := `
-		SELECT v.id, ve.nombre, COALESCE(c.nombre, 'Sin cliente'), 
-		       c.telefono, v.total, v.payment_method, v.estado, v.tipo_entrega, v.created_at
-		FROM ventas v
-		JOIN vendedores ve ON v.vendedor_id = ve.id
-		LEFT JOIN clientes c ON v.cliente_id = c.id
-		` + whereClause + `
-		ORDER BY v.created_at DESC
-	`
+	ventasQuery, queryArgs, orden, err := buildVentasQuery(filtro)
+	if err != nil {
+		return nil, "", err
+	}
 
-	rows, err := DB.Query(ventasQuery)
+	rows, err := q.Query(ventasQuery, queryArgs...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
@@ -247,67 +278,27 @@ func GetAllVentas(includeCanceladas bool) ([]models.VentaStats, error) {
 
 	for rows.Next() {
 		v := &models.VentaStats{}
-		var telefono sql.NullInt64
-		if err := rows.Scan(&v.ID, &v.Vendedor, &v.Cliente, &telefono, &v.Total, &v.PaymentMethod, &v.Estado, &v.TipoEntrega, &v.CreatedAt); err != nil {
-			return nil, err
-		}
-		if telefono.Valid {
-			tel := int(telefono.Int64)
-			v.TelefonoCliente = &tel
-		} else {
-			v.TelefonoCliente = nil
+		if err := rows.Scan(&v.ID, &v.CampanaID, &v.Vendedor, &v.Cliente, &v.TelefonoCliente, &v.Total, &v.PaymentMethod, &v.Estado, &v.TipoEntrega, &v.CreatedAt,
+			&v.PagadaAt, &v.EntregadaAt, &v.CanceladaAt, &v.Pagado, &v.RegistradaPor); err != nil {
+			return nil, "", err
 		}
 		v.Items = []models.ProductoItem{}
 		ventaOrder = append(ventaOrder, v.ID)
 		ventaIDs = append(ventaIDs, v.ID)
 		ventasMap[v.ID] = v
 	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
 
-	// 2. Si hay ventas, obtener todos los items de una sola vez
-	if len(ventaIDs) > 0 {
-		// Construir placeholders para la query
-		placeholders := ""
-		args := make([]interface{}, len(ventaIDs))
-		for i, id := range ventaIDs {
-			if i > 0 {
-				placeholders += ","
-			}
-			placeholders += "?"
-			args[i] = id
-		}
-
-		itemsQuery := `
-			SELECT dv.venta_id, dv.id, dv.producto_id, dv.cantidad, p.tipo_pizza, p.precio
-			FROM detalle_ventas dv
-			JOIN productos p ON dv.producto_id = p.id
-			WHERE dv.venta_id IN (` + placeholders + `)
-			ORDER BY dv.venta_id, dv.id
-		`
+	// Descartar la fila extra antes de cargar items
+	if filtro.Limit > 0 && len(ventaIDs) > filtro.Limit {
+		ventaIDs = ventaIDs[:filtro.Limit]
+	}
 
-		itemRows, err := DB.Query(itemsQuery, args...)
-		if err == nil {
-			for itemRows.Next() {
-				var ventaID int
-				var item models.ProductoItem
-				var productoID int
-				var tipo_pizza string
-				var precio float64
-				var cantidad int
-
-				if err := itemRows.Scan(&ventaID, &item.DetalleID, &productoID, &cantidad, &tipo_pizza, &precio); err == nil {
-					item.ProductID = productoID
-					item.Cantidad = cantidad
-					item.Tipo = tipo_pizza
-					item.Precio = precio
-					item.Total = float64(cantidad) * precio
-
-					if venta, ok := ventasMap[ventaID]; ok {
-						venta.Items = append(venta.Items, item)
-					}
-				}
-			}
-			itemRows.Close()
-		}
+	// 2. Obtener todos los items de una sola vez
+	if err := cargarItemsVentas(q, ventasMap, ventaIDs); err != nil {
+		return nil, "", err
 	}
 
 	// Reconstruir slice en el mismo orden en que se obtuvieron las ventas
@@ -318,55 +309,306 @@ func GetAllVentas(includeCanceladas bool) ([]models.VentaStats, error) {
 		}
 	}
 
-	return ventas, nil
+	ventas, nextCursor := paginarVentas(ventas, filtro.Limit, orden)
+	return ventas, nextCursor, nil
 }
 
-// GetResumen retorna el resumen de ventas
-func GetResumen() (map[string]interface{}, error) {
+// cargarItemsVentas agrega a cada venta de ventasMap sus items, con una sola
+// query para todos los ids
+func cargarItemsVentas(q Querier, ventasMap map[int]*models.VentaStats, ventaIDs []int) error {
+	if len(ventaIDs) == 0 {
+		return nil
+	}
+
+	// Construir placeholders para la query
+	placeholders := ""
+	args := make([]interface{}, len(ventaIDs))
+	for i, id := range ventaIDs {
+		if i > 0 {
+			placeholders += ","
+		}
+		placeholders += "?"
+		args[i] = id
+	}
+
+	itemsQuery := `
+		SELECT dv.venta_id, dv.id, dv.producto_id, dv.cantidad, p.tipo_pizza,
+		       dv.precio_unitario, dv.subtotal, p.precio
+		FROM detalle_ventas dv
+		JOIN productos p ON dv.producto_id = p.id
+		WHERE dv.venta_id IN (` + placeholders + `)
+		ORDER BY dv.venta_id, dv.id
+	`
+
+	itemRows, err := q.Query(itemsQuery, args...)
+	if err != nil {
+		return err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var ventaID int
+		var item models.ProductoItem
+
+		// Precio y total son los guardados al vender; el precio del
+		// catálogo puede haber cambiado desde entonces
+		if err := itemRows.Scan(&ventaID, &item.DetalleID, &item.ProductID, &item.Cantidad, &item.Tipo,
+			&item.Precio, &item.Total, &item.PrecioActual); err != nil {
+			return err
+		}
+
+		if venta, ok := ventasMap[ventaID]; ok {
+			venta.Items = append(venta.Items, item)
+		}
+	}
+
+	return itemRows.Err()
+}
+
+// GetVentaByID retorna una venta con sus items, cliente y teléfono.
+// Retorna sql.ErrNoRows si no existe
+func GetVentaByID(q Querier, id int) (*models.VentaStats, error) {
+	v := &models.VentaStats{}
+	err := q.QueryRow(`
+		SELECT v.id, v.campana_id, ve.nombre, COALESCE(c.nombre, 'Sin cliente'),
+		       c.telefono, v.total, v.payment_method, v.estado, v.tipo_entrega, v.created_at,
+		       v.pagada_at, v.entregada_at, v.cancelada_at,
+		       (SELECT COALESCE(SUM(p.monto), 0) FROM pagos p WHERE p.venta_id = v.id),
+		       COALESCE(u.username, '')
+		FROM ventas v
+		JOIN vendedores ve ON v.vendedor_id = ve.id
+		LEFT JOIN clientes c ON v.cliente_id = c.id
+		LEFT JOIN usuarios u ON v.created_by = u.id
+		WHERE v.id = ?
+	`, id).Scan(&v.ID, &v.CampanaID, &v.Vendedor, &v.Cliente, &v.TelefonoCliente, &v.Total, &v.PaymentMethod, &v.Estado, &v.TipoEntrega, &v.CreatedAt,
+		&v.PagadaAt, &v.EntregadaAt, &v.CanceladaAt, &v.Pagado, &v.RegistradaPor)
+	if err != nil {
+		return nil, err
+	}
+	v.Items = []models.ProductoItem{}
+
+	if err := cargarItemsVentas(q, map[int]*models.VentaStats{v.ID: v}, []int{v.ID}); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetEstadoVenta retorna el estado actual de una venta bloqueando la fila
+// hasta el fin de la transacción, para validar la transición sin carreras.
+// Retorna sql.ErrNoRows si no existe
+func GetEstadoVenta(q Querier, ventaID int) (models.EstadoVenta, error) {
+	var estado string
+	err := q.QueryRow("SELECT estado FROM ventas WHERE id = ? FOR UPDATE", ventaID).Scan(&estado)
+	return models.EstadoVenta(estado), err
+}
+
+// columnasTransicion son las marcas de tiempo que se completan al entrar en
+// cada estado. Entregar implica cobrar, por eso entregada también marca pagada_at
+var columnasTransicion = map[models.EstadoVenta][]string{
+	models.EstadoPagada:    {"pagada_at"},
+	models.EstadoEntregada: {"pagada_at", "entregada_at"},
+	models.EstadoCancelada: {"cancelada_at"},
+}
+
+// RegistrarTransicionVenta guarda el momento en que la venta entró en estado.
+// Las marcas existentes se conservan; volver a sin_pagar borra pagada_at
+func RegistrarTransicionVenta(q Querier, ventaID int, estado models.EstadoVenta, at time.Time) error {
+	if estado == models.EstadoSinPagar {
+		_, err := q.Exec("UPDATE ventas SET pagada_at = NULL WHERE id = ?", ventaID)
+		return err
+	}
+
+	columnas := columnasTransicion[estado]
+	if len(columnas) == 0 {
+		return nil
+	}
+	sets := make([]string, len(columnas))
+	args := make([]interface{}, 0, len(columnas)+1)
+	for i, col := range columnas {
+		sets[i] = col + " = COALESCE(" + col + ", ?)"
+		args = append(args, at)
+	}
+	args = append(args, ventaID)
+
+	_, err := q.Exec("UPDATE ventas SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...)
+	return err
+}
+
+// UpdateEstadoVenta cambia solo el estado de una venta. Las marcas de tiempo
+// se registran aparte con RegistrarTransicionVenta
+func UpdateEstadoVenta(q Querier, ventaID int, estado models.EstadoVenta) error {
+	_, err := q.Exec("UPDATE ventas SET estado = ? WHERE id = ?", string(estado), ventaID)
+	return err
+}
+
+// InsertPago agrega un pago al libro de la venta. registradoPor vacío se guarda como NULL
+func InsertPago(q Querier, ventaID int, monto float64, metodo, registradoPor string) (int, error) {
+	var usuario interface{}
+	if registradoPor != "" {
+		usuario = registradoPor
+	}
+	result, err := q.Exec(
+		"INSERT INTO pagos (venta_id, monto, metodo, registrado_por) VALUES (?, ?, ?, ?)",
+		ventaID, monto, metodo, usuario,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetPagosVenta retorna los pagos de una venta en el orden en que se registraron
+func GetPagosVenta(q Querier, ventaID int) ([]models.Pago, error) {
+	rows, err := q.Query(`
+		SELECT id, venta_id, monto, metodo, COALESCE(registrado_por, ''), created_at
+		FROM pagos
+		WHERE venta_id = ?
+		ORDER BY created_at, id
+	`, ventaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pagos := []models.Pago{}
+	for rows.Next() {
+		var p models.Pago
+		if err := rows.Scan(&p.ID, &p.VentaID, &p.Monto, &p.Metodo, &p.RegistradoPor, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		pagos = append(pagos, p)
+	}
+	return pagos, rows.Err()
+}
+
+// GetSaldoVenta retorna el total de una venta y lo pagado según el libro de
+// pagos. Retorna sql.ErrNoRows si la venta no existe
+func GetSaldoVenta(q Querier, ventaID int) (total, pagado float64, err error) {
+	err = q.QueryRow(`
+		SELECT v.total, (SELECT COALESCE(SUM(p.monto), 0) FROM pagos p WHERE p.venta_id = v.id)
+		FROM ventas v
+		WHERE v.id = ?
+	`, ventaID).Scan(&total, &pagado)
+	return total, pagado, err
+}
+
+// DeleteVenta elimina una venta con sus detalles y pagos. Retorna sql.ErrNoRows
+// si no existe. Para que sea atómica el caller debe pasar una transacción
+func DeleteVenta(q Querier, id int) error {
+	if _, err := q.Exec("DELETE FROM detalle_ventas WHERE venta_id = ?", id); err != nil {
+		return fmt.Errorf("error eliminando detalles: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM pagos WHERE venta_id = ?", id); err != nil {
+		return fmt.Errorf("error eliminando pagos: %w", err)
+	}
+
+	result, err := q.Exec("DELETE FROM ventas WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReservarClaveIdempotencia registra clave con requestHash si no existe o
+// venció, y retorna el hash, la venta y la respuesta de la fila vigente,
+// bloqueada hasta el fin de la transacción. ventaID es 0 si la clave se acaba
+// de reservar; respuesta es nil también en claves completadas antes de que se
+// guardara. Un pedido concurrente con la misma clave espera en el INSERT hasta
+// que el primero confirme. Aprovecha para borrar las claves vencidas
+func ReservarClaveIdempotencia(q Querier, clave, requestHash string, ahora, expira time.Time) (string, int, json.RawMessage, error) {
+	if _, err := q.Exec("DELETE FROM idempotency_keys WHERE expires_at <= ?", ahora); err != nil {
+		return "", 0, nil, fmt.Errorf("error purgando claves vencidas: %w", err)
+	}
+	if _, err := q.Exec(
+		"INSERT IGNORE INTO idempotency_keys (clave, request_hash, expires_at) VALUES (?, ?, ?)",
+		clave, requestHash, expira,
+	); err != nil {
+		return "", 0, nil, err
+	}
+
+	var hash string
+	var ventaID int
+	var respuesta []byte
+	err := q.QueryRow(
+		"SELECT request_hash, COALESCE(venta_id, 0), respuesta FROM idempotency_keys WHERE clave = ? FOR UPDATE",
+		clave,
+	).Scan(&hash, &ventaID, &respuesta)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return hash, ventaID, respuesta, nil
+}
+
+// CompletarClaveIdempotencia asocia a una clave reservada la venta que creó y
+// la respuesta que se retorna en los reintentos
+func CompletarClaveIdempotencia(q Querier, clave string, ventaID int, respuesta json.RawMessage) error {
+	_, err := q.Exec("UPDATE idempotency_keys SET venta_id = ?, respuesta = ? WHERE clave = ?", ventaID, string(respuesta), clave)
+	return err
+}
+
+// GetResumen retorna el resumen de ventas de una campaña. Lo cobrado por
+// método sale del libro de pagos; lo pendiente es el saldo de cada venta no cancelada
+func GetResumen(q Querier, campanaID int) (map[string]interface{}, error) {
 	query := `
 		SELECT 
-			COALESCE(SUM(CASE WHEN (v.estado='pagada' OR v.estado='entregada') AND v.payment_method='efectivo' THEN v.total ELSE 0 END), 0) as efectivo,
-			COALESCE(SUM(CASE WHEN (v.estado='pagada' OR v.estado='entregada') AND v.payment_method='transferencia' THEN v.total ELSE 0 END), 0) as transferencia,
-			COALESCE(SUM(CASE WHEN v.estado='sin_pagar' THEN v.total ELSE 0 END), 0) as pendiente,
-			COALESCE(SUM(CASE WHEN v.estado='pagada' OR v.estado='entregada' THEN v.total ELSE 0 END), 0) as total_cobrado,
+			COALESCE(SUM(GREATEST(v.total - COALESCE(pg.pagado, 0), 0)), 0) as pendiente,
+			COALESCE(SUM(pg.pagado), 0) as total_cobrado,
 			COUNT(CASE WHEN v.estado='sin_pagar' THEN 1 END) as ventas_sin_pagar,
 			COUNT(CASE WHEN v.estado='pagada' OR v.estado='entregada' THEN 1 END) as ventas_pagadas,
 			COUNT(CASE WHEN v.estado='entregada' THEN 1 END) as ventas_entregadas,
 			COUNT(*) as ventas_totales
 		FROM ventas v
-		WHERE v.estado != 'cancelada'
+		LEFT JOIN (
+			SELECT venta_id, SUM(monto) as pagado
+			FROM pagos
+			GROUP BY venta_id
+		) pg ON pg.venta_id = v.id
+		WHERE v.estado != 'cancelada' AND v.campana_id = ?
 	`
 
-	var efectivo, transferencia, pendiente, total float64
+	var pendiente, total float64
 	var sinPagar, pagadas, entregadas, totalVentas int
 
-	err := DB.QueryRow(query).Scan(&efectivo, &transferencia, &pendiente, &total, &sinPagar, &pagadas, &entregadas, &totalVentas)
+	err := q.QueryRow(query, campanaID).Scan(&pendiente, &total, &sinPagar, &pagadas, &entregadas, &totalVentas)
 	if err != nil {
 		log.Printf("Error en GetResumen: %v", err)
 		return nil, err
 	}
 
+	porMetodo, err := cobradoPorMetodo(q, campanaID)
+	if err != nil {
+		log.Printf("Error en GetResumen por método: %v", err)
+		return nil, err
+	}
+
 	// Ahora calcular cantidad de ventas por tipo de entrega
 	itemsQuery := `
 		SELECT 
 			COALESCE(COUNT(DISTINCT CASE WHEN v.tipo_entrega IN ('delivery', 'envio') OR (v.tipo_entrega IS NULL OR v.tipo_entrega = '') THEN v.id END), 0) as total_ventas_delivery,
 			COALESCE(COUNT(DISTINCT CASE WHEN v.tipo_entrega='retiro' THEN v.id END), 0) as total_ventas_retiro
 		FROM ventas v
-		WHERE v.estado != 'cancelada'
+		WHERE v.estado != 'cancelada' AND v.campana_id = ?
 	`
 
 	var delivery, retiro int
-	err = DB.QueryRow(itemsQuery).Scan(&delivery, &retiro)
+	err = q.QueryRow(itemsQuery, campanaID).Scan(&delivery, &retiro)
 	if err != nil {
 		log.Printf("Error en GetResumen items: %v", err)
 		delivery, retiro = 0, 0
 	}
 
+	cobrado := completarMetodosPago(porMetodo)
 	return map[string]interface{}{
 		"total_delivery":        delivery,
 		"total_retiro":          retiro,
-		"efectivo_cobrado":      efectivo,
-		"transferencia_cobrada": transferencia,
+		"cobrado_por_metodo":    cobrado,
+		"efectivo_cobrado":      cobrado["efectivo"],
+		"transferencia_cobrada": cobrado["transferencia"],
 		"pendiente_cobro":       pendiente,
 		"total_cobrado":         total,
 		"ventas_sin_pagar":      sinPagar,
@@ -376,28 +618,75 @@ func GetResumen() (map[string]interface{}, error) {
 	}, nil
 }
 
-// GetVendedoresConStats retorna vendedores con estadísticas
-func GetVendedoresConStats() ([]map[string]interface{}, error) {
-	vendedores, _ := GetVendedores()
+// cobradoPorMetodo suma el libro de pagos de las ventas no canceladas de una
+// campaña agrupado por método
+func cobradoPorMetodo(q Querier, campanaID int) (map[string]float64, error) {
+	rows, err := q.Query(`
+		SELECT p.metodo, SUM(p.monto)
+		FROM pagos p
+		JOIN ventas v ON v.id = p.venta_id
+		WHERE v.estado != 'cancelada' AND v.campana_id = ?
+		GROUP BY p.metodo
+	`, campanaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	porMetodo := map[string]float64{}
+	for rows.Next() {
+		var metodo string
+		var monto float64
+		if err := rows.Scan(&metodo, &monto); err != nil {
+			return nil, err
+		}
+		porMetodo[metodo] += monto
+	}
+	return porMetodo, rows.Err()
+}
+
+// completarMetodosPago retorna lo cobrado por método, redondeado a centavos,
+// con todos los models.MetodosPago (en cero si no hubo cobros) más cualquier
+// otro método presente en el libro. efectivo_cobrado y transferencia_cobrada
+// del resumen salen de acá y se mantienen por compatibilidad
+func completarMetodosPago(porMetodo map[string]float64) map[string]float64 {
+	cobrado := make(map[string]float64, len(models.MetodosPago))
+	for _, m := range models.MetodosPago {
+		cobrado[m] = 0
+	}
+	for m, monto := range porMetodo {
+		cobrado[m] = RedondearMonto(monto)
+	}
+	return cobrado
+}
+
+// GetVendedoresConStats retorna vendedores con estadísticas de una campaña
+func GetVendedoresConStats(q Querier, campanaID int) ([]map[string]interface{}, error) {
+	vendedores, _ := GetVendedores(q)
 	var result []map[string]interface{}
 
 	for _, vendedor := range vendedores {
-		// Query 1: Dinero sin JOIN (para evitar multiplicación)
+		// Query 1: Dinero sin JOIN a detalles (para evitar multiplicación).
+		// Pagado y deuda salen del libro de pagos, como en el listado de ventas
 		query := `
 			SELECT 
-				COUNT(DISTINCT v.id) as cantidad,
-				COALESCE(SUM(CASE WHEN v.estado='sin_pagar' THEN v.total ELSE 0 END), 0) as deuda,
-				COALESCE(SUM(CASE WHEN v.estado='pagada' OR v.estado='entregada' THEN v.total ELSE 0 END), 0) as pagado,
-				COALESCE(SUM(v.total), 0) as total
-			FROM ventas v
-			JOIN vendedores ve ON v.vendedor_id = ve.id
-			WHERE ve.nombre = ? AND v.estado != 'cancelada'
+				COUNT(*) as cantidad,
+				COALESCE(SUM(GREATEST(x.total - x.pagado, 0)), 0) as deuda,
+				COALESCE(SUM(x.pagado), 0) as pagado,
+				COALESCE(SUM(x.total), 0) as total
+			FROM (
+				SELECT v.total,
+				       (SELECT COALESCE(SUM(p.monto), 0) FROM pagos p WHERE p.venta_id = v.id) as pagado
+				FROM ventas v
+				JOIN vendedores ve ON v.vendedor_id = ve.id
+				WHERE ve.nombre = ? AND v.estado != 'cancelada' AND v.campana_id = ?
+			) x
 		`
 
 		var cantidad int
 		var deuda, pagado, total float64
 
-		err := DB.QueryRow(query, vendedor.Nombre).Scan(&cantidad, &deuda, &pagado, &total)
+		err := q.QueryRow(query, vendedor.Nombre, campanaID).Scan(&cantidad, &deuda, &pagado, &total)
 		if err != nil {
 			log.Printf("Error consultando vendor %s: %v", vendedor.Nombre, err)
 			continue
@@ -409,11 +698,11 @@ func GetVendedoresConStats() ([]map[string]interface{}, error) {
 			FROM detalle_ventas dv
 			JOIN ventas v ON dv.venta_id = v.id
 			JOIN vendedores ve ON v.vendedor_id = ve.id
-			WHERE ve.nombre = ? AND v.estado != 'cancelada'
+			WHERE ve.nombre = ? AND v.estado != 'cancelada' AND v.campana_id = ?
 		`
 
 		var totalItems int
-		err = DB.QueryRow(itemsQuery, vendedor.Nombre).Scan(&totalItems)
+		err = q.QueryRow(itemsQuery, vendedor.Nombre, campanaID).Scan(&totalItems)
 		if err != nil {
 			log.Printf("Error consultando items vendor %s: %v", vendedor.Nombre, err)
 			totalItems = 0
@@ -432,114 +721,103 @@ func GetVendedoresConStats() ([]map[string]interface{}, error) {
 	return result, nil
 }
 
-// UpdateVenta actualiza una venta de forma atómica usando transacciones
-func UpdateVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}) error {
-	// 1. Iniciar Transacción
-	tx, err := DB.Begin()
+// UpdateVenta actualiza cabecera, detalles y total de una venta. Los productos
+// nuevos tienen que ser de la campaña de la venta. Para que sea atómica el
+// caller debe pasar una transacción (ver WithTransaction)
+func UpdateVenta(q Querier, ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}, updatedBy *int) error {
+	lineas, err := leerLineasVenta(productos)
 	if err != nil {
-		return fmt.Errorf("error iniciando transacción: %w", err)
-	}
-
-	// Defer para Rollback en caso de pánico o error no manejado explícitamente
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			panic(p) // Re-lanzar pánico después de rollback
-		} else if err != nil {
-			tx.Rollback() // Rollback si hay error retornado
-		}
-	}()
-
-	// 2. Actualizar cabecera de venta
-	query := `UPDATE ventas SET estado = ?, payment_method = ?, tipo_entrega = ? WHERE id = ?`
-	if _, err = tx.Exec(query, estado, paymentMethod, tipoEntrega, ventaID); err != nil {
-		return fmt.Errorf("error actualizando cabecera venta: %w", err)
+		return err
 	}
 
-	// 3. Eliminar productos (Batch)
-	if len(productosEliminar) > 0 {
-		// Nota de eficiencia: Podríamos usar IN (?) dinámico, pero un loop simple dentro de Tx es aceptable para pocos items
-		deleteQuery := `DELETE FROM detalle_ventas WHERE id = ?`
-		stmt, err := tx.Prepare(deleteQuery)
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
-
-		for _, detalleID := range productosEliminar {
-			if _, err = stmt.Exec(detalleID); err != nil {
-				return fmt.Errorf("error eliminando producto %d: %w", detalleID, err)
-			}
-		}
+	var campanaID int
+	err = q.QueryRow(`SELECT campana_id FROM ventas WHERE id = ?`, ventaID).Scan(&campanaID)
+	if err == sql.ErrNoRows {
+		// Como el UPDATE sin filas afectadas, no es error
+		return nil
 	}
-
-	// 4. Upsert (Insertar o Actualizar) productos
-	// Preparamos los statements fuera del loop para eficiencia
-	insertStmt, err := tx.Prepare(`INSERT INTO detalle_ventas (venta_id, producto_id, cantidad, precio_unitario, subtotal) VALUES (?, ?, ?, ?, ?)`)
 	if err != nil {
-		return err
+		return fmt.Errorf("error obteniendo venta: %w", err)
 	}
-	defer insertStmt.Close()
 
-	updateStmt, err := tx.Prepare(`UPDATE detalle_ventas SET cantidad = ?, subtotal = ? WHERE id = ?`)
-	if err != nil {
-		return err
+	// 1. Actualizar cabecera de venta
+	query := `UPDATE ventas SET estado = ?, payment_method = ?, tipo_entrega = ?, updated_by = ? WHERE id = ?`
+	if _, err := q.Exec(query, estado, paymentMethod, tipoEntrega, updatedBy, ventaID); err != nil {
+		return fmt.Errorf("error actualizando cabecera venta: %w", err)
 	}
-	defer updateStmt.Close()
 
-	for _, p := range productos {
-		detalleID := p["detalle_id"]
-		productoID := int(p["producto_id"].(float64))
-		cantidad := int(p["cantidad"].(float64))
-
-		// Necesitamos el precio actual del producto para consistencia
-		var precio float64
-		err = tx.QueryRow("SELECT precio FROM productos WHERE id = ?", productoID).Scan(&precio)
-		if err != nil {
-			return fmt.Errorf("producto %d no encontrado o inactivo", productoID)
+	// 2. Eliminar productos
+	// Nota de eficiencia: Podríamos usar IN (?) dinámico, pero un loop simple dentro de Tx es aceptable para pocos items
+	for _, detalleID := range productosEliminar {
+		if _, err := q.Exec(`DELETE FROM detalle_ventas WHERE id = ? AND venta_id = ?`, detalleID, ventaID); err != nil {
+			return fmt.Errorf("error eliminando producto %d: %w", detalleID, err)
 		}
+	}
 
-		subtotal := float64(cantidad) * precio
-
-		if detalleID == nil {
-			if _, err = insertStmt.Exec(ventaID, productoID, cantidad, precio, subtotal); err != nil {
+	// 3. Upsert (Insertar o Actualizar) productos
+	for i, l := range lineas {
+		if l.DetalleID == 0 {
+			// Línea nueva: precio del catálogo según PrecioItem
+			producto, err := GetProductoByID(q, l.ProductoID)
+			if err == sql.ErrNoRows {
+				return &ItemRechazadoError{Item: i, ProductoID: l.ProductoID, Err: ErrProductoNoEncontrado}
+			}
+			if err != nil {
+				return fmt.Errorf("item %d: error obteniendo producto %d: %w", i, l.ProductoID, err)
+			}
+			if producto.CampanaID != campanaID {
+				return &ItemRechazadoError{Item: i, ProductoID: l.ProductoID, Err: ErrProductoOtraCampana}
+			}
+			item, _, err := PrecioItem(producto, models.ProductoItem{ProductID: l.ProductoID, Cantidad: l.Cantidad})
+			if err != nil {
+				return &ItemRechazadoError{Item: i, ProductoID: l.ProductoID, Err: err}
+			}
+			if err := InsertDetalle(q, ventaID, item); err != nil {
 				return err
 			}
 		} else {
-			detalleIDInt := int(detalleID.(float64))
-			if _, err = updateStmt.Exec(cantidad, subtotal, detalleIDInt); err != nil {
+			// Línea existente: conserva el precio_unitario con que se vendió
+			if l.Cantidad <= 0 {
+				return &ItemRechazadoError{Item: i, ProductoID: l.ProductoID, Err: fmt.Errorf("%w: %d", ErrCantidadInvalida, l.Cantidad)}
+			}
+			var precio float64
+			err := q.QueryRow(`SELECT precio_unitario FROM detalle_ventas WHERE id = ? AND venta_id = ?`,
+				l.DetalleID, ventaID).Scan(&precio)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := q.Exec(`UPDATE detalle_ventas SET cantidad = ?, subtotal = ? WHERE id = ? AND venta_id = ?`,
+				l.Cantidad, subtotalLinea(precio, l.Cantidad), l.DetalleID, ventaID); err != nil {
 				return err
 			}
 		}
 	}
 
-	// 5. Recalcular total usando la misma transacción (ve los cambios no confirmados)
+	// 4. Recalcular total (dentro de una transacción ve los cambios no confirmados)
 	var nuevoTotal float64
 	// Sumamos directamente de detalle_ventas que ya tiene el subtotal actualizado
 	totalQuery := `SELECT COALESCE(SUM(subtotal), 0) FROM detalle_ventas WHERE venta_id = ?`
-	if err = tx.QueryRow(totalQuery, ventaID).Scan(&nuevoTotal); err != nil {
+	if err := q.QueryRow(totalQuery, ventaID).Scan(&nuevoTotal); err != nil {
 		return fmt.Errorf("error recalculando total: %w", err)
 	}
 
-	if _, err = tx.Exec(`UPDATE ventas SET total = ? WHERE id = ?`, nuevoTotal, ventaID); err != nil {
+	if _, err := q.Exec(`UPDATE ventas SET total = ? WHERE id = ?`, nuevoTotal, ventaID); err != nil {
 		return fmt.Errorf("error actualizando total final: %w", err)
 	}
 
-	// 6. Commit final
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error en commit: %w", err)
-	}
-
 	return nil
 }
 
 // GetProductoByID obtiene un producto por ID
-func GetProductoByID(id int) (*models.Producto, error) {
+func GetProductoByID(q Querier, id int) (*models.Producto, error) {
 	var p models.Producto
-	err := DB.QueryRow(`
-		SELECT id, tipo_pizza, descripcion, precio, activo, created_at
+	err := q.QueryRow(`
+		SELECT id, campana_id, tipo_pizza, descripcion, precio, activo, created_at
 		FROM productos WHERE id = ?
-	`, id).Scan(&p.ID, &p.TipoPizza, &p.Descripcion, &p.Precio, &p.Activo, &p.CreatedAt)
+	`, id).Scan(&p.ID, &p.CampanaID, &p.TipoPizza, &p.Descripcion, &p.Precio, &p.Activo, &p.CreatedAt)
 
 	if err != nil {
 		return nil, err
@@ -547,11 +825,22 @@ func GetProductoByID(id int) (*models.Producto, error) {
 	return &p, nil
 }
 
+// GetUserByID obtiene un usuario por ID (sin el hash de la contraseña)
+func GetUserByID(q Querier, id int) (*models.User, error) {
+	var user models.User
+	err := q.QueryRow("SELECT id, username, rol FROM usuarios WHERE id = ?", id).
+		Scan(&user.ID, &user.Username, &user.Rol)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 // GetUserByCredentials obtiene un usuario por credenciales
-func GetUserByCredentials(username, plainPassword string) (*models.User, error) {
+func GetUserByCredentials(q Querier, username, plainPassword string) (*models.User, error) {
 	var user models.User
 	var storedHash string
-	err := DB.QueryRow(
+	err := q.QueryRow(
 		"SELECT id, username, rol, password_hash FROM usuarios WHERE username = ?",
 		username).Scan(&user.ID, &user.Username, &user.Rol, &storedHash)
 
@@ -561,36 +850,78 @@ func GetUserByCredentials(username, plainPassword string) (*models.User, error)
 
 	// Comparar la contraseña en texto plano con el hash almacenado
 	if !VerifyPassword(storedHash, plainPassword) {
-		return nil, fmt.Errorf("contraseña inválida")
+		return nil, ErrPasswordInvalida
 	}
 
 	return &user, nil
 }
 
-// CreateProducto crea un nuevo producto
-func CreateProducto(tipoPizza, descripcion string, precio float64) (int64, error) {
-	result, err := DB.Exec(
-		"INSERT INTO productos (tipo_pizza, descripcion, precio, activo) VALUES (?, ?, ?, TRUE)",
-		tipoPizza, descripcion, precio,
+// CreateProducto crea un nuevo producto en una campaña y registra su precio
+// inicial en el historial. Para que sea atómica el caller debe pasar una transacción
+func CreateProducto(q Querier, campanaID int, tipoPizza, descripcion string, precio float64, createdBy *int) (int64, error) {
+	result, err := q.Exec(
+		"INSERT INTO productos (campana_id, tipo_pizza, descripcion, precio, activo, created_by, updated_by) VALUES (?, ?, ?, ?, TRUE, ?, ?)",
+		campanaID, tipoPizza, descripcion, precio, createdBy, createdBy,
 	)
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := q.Exec("INSERT INTO producto_precios (producto_id, precio) VALUES (?, ?)", id, precio); err != nil {
+		return 0, fmt.Errorf("error registrando precio inicial: %w", err)
+	}
+	return id, nil
 }
 
-// UpdateProducto actualiza un producto
-func UpdateProducto(id int, tipoPizza, descripcion string, precio float64, activo bool) error {
-	_, err := DB.Exec(
-		"UPDATE productos SET tipo_pizza = ?, precio = ?, descripcion = ?, activo = ? WHERE id = ?",
-		tipoPizza, precio, descripcion, activo, id,
+// UpdateProducto actualiza un producto y, si cambió el precio, registra el
+// nuevo en producto_precios. Para que sea atómica el caller debe pasar una transacción
+func UpdateProducto(q Querier, id int, tipoPizza, descripcion string, precio float64, activo bool, updatedBy *int) error {
+	// Se registra antes del UPDATE para poder comparar contra el precio anterior
+	if _, err := q.Exec(
+		"INSERT INTO producto_precios (producto_id, precio) SELECT id, ? FROM productos WHERE id = ? AND precio <> ?",
+		precio, id, precio,
+	); err != nil {
+		return fmt.Errorf("error registrando historial de precio: %w", err)
+	}
+
+	_, err := q.Exec(
+		"UPDATE productos SET tipo_pizza = ?, precio = ?, descripcion = ?, activo = ?, updated_by = ? WHERE id = ?",
+		tipoPizza, precio, descripcion, activo, updatedBy, id,
 	)
 	return err
 }
 
+// GetHistorialPrecios retorna los precios de un producto, del más antiguo al vigente
+func GetHistorialPrecios(q Querier, productoID int) ([]models.PrecioProducto, error) {
+	rows, err := q.Query(`
+		SELECT producto_id, precio, vigente_desde
+		FROM producto_precios
+		WHERE producto_id = ?
+		ORDER BY vigente_desde, id
+	`, productoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	historial := []models.PrecioProducto{}
+	for rows.Next() {
+		var p models.PrecioProducto
+		if err := rows.Scan(&p.ProductoID, &p.Precio, &p.VigenteDesde); err != nil {
+			return nil, err
+		}
+		historial = append(historial, p)
+	}
+	return historial, rows.Err()
+}
+
 // DeleteProducto desactiva un producto
-func DeleteProducto(id int) error {
-	result, err := DB.Exec("UPDATE productos SET activo = FALSE WHERE id = ?", id)
+func DeleteProducto(q Querier, id int, updatedBy *int) error {
+	result, err := q.Exec("UPDATE productos SET activo = FALSE, updated_by = ? WHERE id = ?", updatedBy, id)
 	if err != nil {
 		return err
 	}
@@ -604,8 +935,8 @@ func DeleteProducto(id int) error {
 }
 
 // CreateVendedor crea un nuevo vendedor
-func CreateVendedor(nombre string) (int64, error) {
-	result, err := DB.Exec(`INSERT INTO vendedores (nombre) VALUES (?)`, nombre)
+func CreateVendedor(q Querier, nombre string, createdBy *int) (int64, error) {
+	result, err := q.Exec(`INSERT INTO vendedores (nombre, created_by, updated_by) VALUES (?, ?, ?)`, nombre, createdBy, createdBy)
 	if err != nil {
 		return 0, err
 	}
@@ -613,8 +944,8 @@ func CreateVendedor(nombre string) (int64, error) {
 }
 
 // UpdateVendedor actualiza un vendedor
-func UpdateVendedor(id int, nombre string) error {
-	result, err := DB.Exec(`UPDATE vendedores SET nombre = ? WHERE id = ?`, nombre, id)
+func UpdateVendedor(q Querier, id int, nombre string, updatedBy *int) error {
+	result, err := q.Exec(`UPDATE vendedores SET nombre = ?, updated_by = ? WHERE id = ?`, nombre, updatedBy, id)
 	if err != nil {
 		return err
 	}
@@ -628,8 +959,8 @@ func UpdateVendedor(id int, nombre string) error {
 }
 
 // DeleteVendedor elimina un vendedor
-func DeleteVendedor(id int) error {
-	result, err := DB.Exec(`DELETE FROM vendedores WHERE id = ?`, id)
+func DeleteVendedor(q Querier, id int) error {
+	result, err := q.Exec(`DELETE FROM vendedores WHERE id = ?`, id)
 	if err != nil {
 		return err
 	}
@@ -643,8 +974,8 @@ func DeleteVendedor(id int) error {
 }
 
 // GetAllUsers obtiene todos los usuarios sin contraseñas
-func GetAllUsers() ([]models.User, error) {
-	rows, err := DB.Query("SELECT id, username, rol FROM usuarios ORDER BY username")
+func GetAllUsers(q Querier) ([]models.User, error) {
+	rows, err := q.Query("SELECT id, username, rol FROM usuarios ORDER BY username")
 	if err != nil {
 		return nil, err
 	}
@@ -671,23 +1002,23 @@ func GetAllUsers() ([]models.User, error) {
 }
 
 // UserExists verifica si un usuario existe
-func UserExists(username string) (bool, error) {
+func UserExists(q Querier, username string) (bool, error) {
 	var exists bool
-	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM usuarios WHERE username = ?)", username).Scan(&exists)
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM usuarios WHERE username = ?)", username).Scan(&exists)
 	return exists, err
 }
 
 // CreateUser crea un nuevo usuario con contraseña hasheada
-func CreateUser(username, password, rol string) (int, error) {
+func CreateUser(q Querier, username, password, rol string, createdBy *int) (int, error) {
 	// Hash la contraseña
 	hash, err := HashPassword(password)
 	if err != nil {
 		return 0, err
 	}
 
-	result, err := DB.Exec(
-		"INSERT INTO usuarios (username, password_hash, rol) VALUES (?, ?, ?)",
-		username, hash, rol,
+	result, err := q.Exec(
+		"INSERT INTO usuarios (username, password_hash, rol, created_by, updated_by) VALUES (?, ?, ?, ?, ?)",
+		username, hash, rol, createdBy, createdBy,
 	)
 	if err != nil {
 		return 0, err
@@ -698,7 +1029,7 @@ func CreateUser(username, password, rol string) (int, error) {
 }
 
 // UpdateUser actualiza un usuario existente
-func UpdateUser(id int, username, password, rol string) error {
+func UpdateUser(q Querier, id int, username, password, rol string, updatedBy *int) error {
 	var query string
 	var args []interface{}
 
@@ -708,15 +1039,15 @@ func UpdateUser(id int, username, password, rol string) error {
 		if err != nil {
 			return err
 		}
-		query = "UPDATE usuarios SET username = ?, password_hash = ?, rol = ? WHERE id = ?"
-		args = []interface{}{username, hash, rol, id}
+		query = "UPDATE usuarios SET username = ?, password_hash = ?, rol = ?, updated_by = ? WHERE id = ?"
+		args = []interface{}{username, hash, rol, updatedBy, id}
 	} else {
 		// Si no se proporciona contraseña, solo actualizar username y rol
-		query = "UPDATE usuarios SET username = ?, rol = ? WHERE id = ?"
-		args = []interface{}{username, rol, id}
+		query = "UPDATE usuarios SET username = ?, rol = ?, updated_by = ? WHERE id = ?"
+		args = []interface{}{username, rol, updatedBy, id}
 	}
 
-	result, err := DB.Exec(query, args...)
+	result, err := q.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -730,8 +1061,8 @@ func UpdateUser(id int, username, password, rol string) error {
 }
 
 // DeleteUser elimina un usuario
-func DeleteUser(id int) error {
-	result, err := DB.Exec("DELETE FROM usuarios WHERE id = ?", id)
+func DeleteUser(q Querier, id int) error {
+	result, err := q.Exec("DELETE FROM usuarios WHERE id = ?", id)
 	if err != nil {
 		return err
 	}
@@ -744,32 +1075,38 @@ func DeleteUser(id int) error {
 	return nil
 }
 
-// ClearDetalleVentas elimina todos los detalles de ventas
-func ClearDetalleVentas() error {
-	_, err := DB.Exec("DELETE FROM detalle_ventas")
+// ClearDetalleVentas elimina todos los detalles y pagos de ventas
+func ClearDetalleVentas(q Querier) error {
+	if _, err := q.Exec("DELETE FROM pagos"); err != nil {
+		return err
+	}
+	_, err := q.Exec("DELETE FROM detalle_ventas")
 	return err
 }
 
 // ClearVentas elimina todas las ventas
-func ClearVentas() error {
-	_, err := DB.Exec("DELETE FROM ventas")
+func ClearVentas(q Querier) error {
+	_, err := q.Exec("DELETE FROM ventas")
 	return err
 }
 
 // ClearClientes elimina todos los clientes
-func ClearClientes() error {
-	_, err := DB.Exec("DELETE FROM clientes")
+func ClearClientes(q Querier) error {
+	_, err := q.Exec("DELETE FROM clientes")
 	return err
 }
 
 // ClearVendedores elimina todos los vendedores
-func ClearVendedores() error {
-	_, err := DB.Exec("DELETE FROM vendedores")
+func ClearVendedores(q Querier) error {
+	_, err := q.Exec("DELETE FROM vendedores")
 	return err
 }
 
 // ClearProductos elimina todos los productos
-func ClearProductos() error {
-	_, err := DB.Exec("DELETE FROM productos")
+func ClearProductos(q Querier) error {
+	if _, err := q.Exec("DELETE FROM producto_precios"); err != nil {
+		return err
+	}
+	_, err := q.Exec("DELETE FROM productos")
 	return err
 }
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+
+	"pizzas-ecos/models"
+)
+
+// GetIntentoLogin retorna los fallos acumulados de un usuario o IP y bloquea
+// la fila hasta el fin de la transacción, para que intentos simultáneos no
+// pasen todos el chequeo antes de registrar su fallo. Retorna sql.ErrNoRows
+// si no hay fallos registrados
+func GetIntentoLogin(q Querier, tipo, valor string) (*models.IntentoLogin, error) {
+	i := models.IntentoLogin{Tipo: tipo, Valor: valor}
+	var bloqueado sql.NullTime
+	err := q.QueryRow(`
+		SELECT fallos, ultimo_fallo, bloqueado_hasta
+		FROM intentos_login WHERE tipo = ? AND valor = ? FOR UPDATE
+	`, tipo, valor).Scan(&i.Fallos, &i.UltimoFallo, &bloqueado)
+	if err != nil {
+		return nil, err
+	}
+	if bloqueado.Valid {
+		hasta := bloqueado.Time
+		i.BloqueadoHasta = &hasta
+	}
+	return &i, nil
+}
+
+// GuardarIntentoLogin crea o reemplaza los fallos de un usuario o IP
+func GuardarIntentoLogin(q Querier, i models.IntentoLogin) error {
+	_, err := q.Exec(`
+		INSERT INTO intentos_login (tipo, valor, fallos, ultimo_fallo, bloqueado_hasta)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE fallos = VALUES(fallos), ultimo_fallo = VALUES(ultimo_fallo),
+			bloqueado_hasta = VALUES(bloqueado_hasta)
+	`, i.Tipo, i.Valor, i.Fallos, i.UltimoFallo, i.BloqueadoHasta)
+	return err
+}
+
+// BorrarIntentoLogin olvida los fallos de un usuario o IP (login exitoso o
+// desbloqueo por un admin). Retorna si había fallos registrados
+func BorrarIntentoLogin(q Querier, tipo, valor string) (bool, error) {
+	res, err := q.Exec("DELETE FROM intentos_login WHERE tipo = ? AND valor = ?", tipo, valor)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
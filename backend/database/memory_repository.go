@@ -0,0 +1,1936 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pizzas-ecos/models"
+)
+
+// Estructuras internas equivalentes a las filas de cada tabla
+
+type memCliente struct {
+	ID       int
+	Nombre   string
+	Telefono *string
+}
+
+type memVenta struct {
+	ID            int
+	CampanaID     int
+	ClienteID     *int
+	VendedorID    int
+	Total         float64
+	PaymentMethod string
+	Estado        string
+	TipoEntrega   string
+	CreatedAt     time.Time
+	PagadaAt      *time.Time
+	EntregadaAt   *time.Time
+	CanceladaAt   *time.Time
+	CreatedBy     *int
+	UpdatedBy     *int
+}
+
+type memDetalle struct {
+	ID             int
+	VentaID        int
+	ProductoID     int
+	Cantidad       int
+	PrecioUnitario float64
+	Subtotal       float64
+}
+
+type memClaveIdempotencia struct {
+	RequestHash string
+	VentaID     int
+	Respuesta   json.RawMessage
+	ExpiresAt   time.Time
+}
+
+type memUsuario struct {
+	models.User
+	PasswordHash  string
+	SesionesDesde *time.Time
+}
+
+// memoryState contiene todas las "tablas" del store en memoria
+type memoryState struct {
+	vendedores map[int]models.Vendedor
+	productos  map[int]models.Producto
+	precios    []models.PrecioProducto
+	clientes   map[int]memCliente
+	ventas     map[int]memVenta
+	detalles   map[int]memDetalle
+	pagos      []models.Pago
+	claves     map[string]memClaveIdempotencia
+	usuarios   map[int]memUsuario
+	auditoria  []models.Auditoria
+	refresh    map[int]models.RefreshToken
+	revocados  map[string]time.Time // jti → expires_at
+	intentos   map[string]models.IntentoLogin
+	resets     map[int]models.PasswordReset
+	campanas   map[int]models.Campana
+	lastID     map[string]int
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{
+		vendedores: map[int]models.Vendedor{},
+		productos:  map[int]models.Producto{},
+		clientes:   map[int]memCliente{},
+		ventas:     map[int]memVenta{},
+		detalles:   map[int]memDetalle{},
+		claves:     map[string]memClaveIdempotencia{},
+		usuarios:   map[int]memUsuario{},
+		refresh:    map[int]models.RefreshToken{},
+		revocados:  map[string]time.Time{},
+		intentos:   map[string]models.IntentoLogin{},
+		resets:     map[int]models.PasswordReset{},
+		campanas:   map[int]models.Campana{},
+		lastID:     map[string]int{},
+	}
+}
+
+// nextID emula AUTO_INCREMENT por tabla
+func (st *memoryState) nextID(table string) int {
+	st.lastID[table]++
+	return st.lastID[table]
+}
+
+// clone copia el estado para poder revertir una transacción. Los punteros
+// (*int) se comparten porque el store nunca escribe a través de ellos
+func (st *memoryState) clone() *memoryState {
+	c := newMemoryState()
+	for k, v := range st.vendedores {
+		c.vendedores[k] = v
+	}
+	for k, v := range st.productos {
+		c.productos[k] = v
+	}
+	c.precios = append(c.precios, st.precios...)
+	for k, v := range st.clientes {
+		c.clientes[k] = v
+	}
+	for k, v := range st.ventas {
+		c.ventas[k] = v
+	}
+	for k, v := range st.detalles {
+		c.detalles[k] = v
+	}
+	c.pagos = append(c.pagos, st.pagos...)
+	for k, v := range st.claves {
+		c.claves[k] = v
+	}
+	for k, v := range st.usuarios {
+		c.usuarios[k] = v
+	}
+	c.auditoria = append(c.auditoria, st.auditoria...)
+	for k, v := range st.refresh {
+		c.refresh[k] = v
+	}
+	for k, v := range st.revocados {
+		c.revocados[k] = v
+	}
+	for k, v := range st.intentos {
+		c.intentos[k] = v
+	}
+	for k, v := range st.resets {
+		c.resets[k] = v
+	}
+	for k, v := range st.campanas {
+		c.campanas[k] = v
+	}
+	for k, v := range st.lastID {
+		c.lastID[k] = v
+	}
+	return c
+}
+
+// MemoryStore implementa Store completamente en memoria. Está pensado para
+// tests unitarios y desarrollo sin MySQL; replica la semántica de las
+// funciones SQL de este paquete (errores sql.ErrNoRows incluidos)
+type MemoryStore struct {
+	mu    *sync.Mutex // protege state
+	txMu  *sync.Mutex // serializa transacciones
+	state *memoryState
+	inTx  bool
+}
+
+// NewMemoryStore crea un store en memoria vacío salvo por una campaña activa
+// con ID 1, como la que crea la migración de campañas
+func NewMemoryStore() *MemoryStore {
+	state := newMemoryState()
+	id := state.nextID("campanas")
+	now := time.Now()
+	state.campanas[id] = models.Campana{ID: id, Nombre: "Campaña inicial", FechaInicio: now, Activa: true, CreatedAt: now}
+	return &MemoryStore{
+		mu:    &sync.Mutex{},
+		txMu:  &sync.Mutex{},
+		state: state,
+	}
+}
+
+func (s *MemoryStore) Ventas() VentaRepository        { return &memVentaRepository{s: s} }
+func (s *MemoryStore) Productos() ProductoRepository  { return &memProductoRepository{s: s} }
+func (s *MemoryStore) Vendedores() VendedorRepository { return &memVendedorRepository{s: s} }
+func (s *MemoryStore) Clientes() ClienteRepository    { return &memClienteRepository{s: s} }
+func (s *MemoryStore) Usuarios() UsuarioRepository    { return &memUsuarioRepository{s: s} }
+func (s *MemoryStore) Auditoria() AuditoriaRepository { return &memAuditoriaRepository{s: s} }
+func (s *MemoryStore) Sesiones() SesionRepository     { return &memSesionRepository{s: s} }
+func (s *MemoryStore) IntentosLogin() IntentoLoginRepository {
+	return &memIntentoLoginRepository{s: s}
+}
+func (s *MemoryStore) Campanas() CampanaRepository { return &memCampanaRepository{s: s} }
+
+// WithTx ejecuta fn y, si retorna error o entra en pánico, restaura el estado
+// previo. Las transacciones se serializan entre sí
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(tx Store) error) (err error) {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.Lock()
+	snapshot := s.state.clone()
+	s.mu.Unlock()
+
+	rollback := func() {
+		s.mu.Lock()
+		*s.state = *snapshot
+		s.mu.Unlock()
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	tx := &MemoryStore{mu: s.mu, txMu: s.txMu, state: s.state, inTx: true}
+	if err = fn(tx); err != nil {
+		rollback()
+		return err
+	}
+	return nil
+}
+
+// lock toma el mutex del estado y lo retorna para usarse con defer
+func (s *MemoryStore) lock() *memoryState {
+	s.mu.Lock()
+	return s.state
+}
+
+func (s *MemoryStore) unlock() {
+	s.mu.Unlock()
+}
+
+// ============================================
+// Ventas
+// ============================================
+
+type memVentaRepository struct {
+	s *MemoryStore
+}
+
+func (r *memVentaRepository) InsertVenta(campanaID int, clienteID *int, vendedorID int, total float64, payment, estado, tipoEntrega string, createdBy *int) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.campanas[campanaID]; !ok {
+		return 0, fmt.Errorf("campaña %d no existe", campanaID)
+	}
+	if _, ok := st.vendedores[vendedorID]; !ok {
+		return 0, fmt.Errorf("vendedor %d no existe", vendedorID)
+	}
+	if clienteID != nil {
+		if _, ok := st.clientes[*clienteID]; !ok {
+			return 0, fmt.Errorf("cliente %d no existe", *clienteID)
+		}
+		id := *clienteID
+		clienteID = &id
+	}
+
+	id := st.nextID("ventas")
+	st.ventas[id] = memVenta{
+		ID:            id,
+		CampanaID:     campanaID,
+		ClienteID:     clienteID,
+		VendedorID:    vendedorID,
+		Total:         total,
+		PaymentMethod: payment,
+		Estado:        estado,
+		TipoEntrega:   tipoEntrega,
+		CreatedAt:     time.Now(),
+		CreatedBy:     copiarID(createdBy),
+		UpdatedBy:     copiarID(createdBy),
+	}
+	return id, nil
+}
+
+func (r *memVentaRepository) InsertDetalle(ventaID int, item models.ProductoItem) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.ventas[ventaID]; !ok {
+		return fmt.Errorf("venta %d no existe", ventaID)
+	}
+	if _, ok := st.productos[item.ProductID]; !ok {
+		return fmt.Errorf("producto %d no existe", item.ProductID)
+	}
+
+	id := st.nextID("detalle_ventas")
+	st.detalles[id] = memDetalle{
+		ID:             id,
+		VentaID:        ventaID,
+		ProductoID:     item.ProductID,
+		Cantidad:       item.Cantidad,
+		PrecioUnitario: item.Precio,
+		Subtotal:       item.Total,
+	}
+	return nil
+}
+
+func (r *memVentaRepository) UpdateVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}, updatedBy *int) error {
+	lineas, err := leerLineasVenta(productos)
+	if err != nil {
+		return err
+	}
+
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	venta, ok := st.ventas[ventaID]
+	if !ok {
+		// UPDATE sin filas afectadas no es error en MySQL
+		return nil
+	}
+	venta.Estado = estado
+	venta.PaymentMethod = paymentMethod
+	venta.TipoEntrega = tipoEntrega
+	venta.UpdatedBy = copiarID(updatedBy)
+
+	for _, detalleID := range productosEliminar {
+		if d, ok := st.detalles[detalleID]; ok && d.VentaID == ventaID {
+			delete(st.detalles, detalleID)
+		}
+	}
+
+	for i, l := range lineas {
+		if l.DetalleID == 0 {
+			producto, ok := st.productos[l.ProductoID]
+			if !ok {
+				return &ItemRechazadoError{Item: i, ProductoID: l.ProductoID, Err: ErrProductoNoEncontrado}
+			}
+			if producto.CampanaID != venta.CampanaID {
+				return &ItemRechazadoError{Item: i, ProductoID: l.ProductoID, Err: ErrProductoOtraCampana}
+			}
+			item, _, err := PrecioItem(&producto, models.ProductoItem{ProductID: l.ProductoID, Cantidad: l.Cantidad})
+			if err != nil {
+				return &ItemRechazadoError{Item: i, ProductoID: l.ProductoID, Err: err}
+			}
+			id := st.nextID("detalle_ventas")
+			st.detalles[id] = memDetalle{
+				ID:             id,
+				VentaID:        ventaID,
+				ProductoID:     l.ProductoID,
+				Cantidad:       l.Cantidad,
+				PrecioUnitario: item.Precio,
+				Subtotal:       item.Total,
+			}
+		} else {
+			if l.Cantidad <= 0 {
+				return &ItemRechazadoError{Item: i, ProductoID: l.ProductoID, Err: fmt.Errorf("%w: %d", ErrCantidadInvalida, l.Cantidad)}
+			}
+			if d, ok := st.detalles[l.DetalleID]; ok && d.VentaID == ventaID {
+				d.Cantidad = l.Cantidad
+				d.Subtotal = subtotalLinea(d.PrecioUnitario, l.Cantidad)
+				st.detalles[l.DetalleID] = d
+			}
+		}
+	}
+
+	nuevoTotal := 0.0
+	for _, d := range st.detalles {
+		if d.VentaID == ventaID {
+			nuevoTotal += d.Subtotal
+		}
+	}
+	venta.Total = nuevoTotal
+	st.ventas[ventaID] = venta
+
+	return nil
+}
+
+func (r *memVentaRepository) UpdateVentaClienteID(ventaID int, clienteID int) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.clientes[clienteID]; !ok {
+		return fmt.Errorf("cliente %d no existe", clienteID)
+	}
+	if venta, ok := st.ventas[ventaID]; ok {
+		id := clienteID
+		venta.ClienteID = &id
+		st.ventas[ventaID] = venta
+	}
+	return nil
+}
+
+func (r *memVentaRepository) GetAllVentas(filtro models.VentaFiltro) ([]models.VentaStats, string, error) {
+	// Se reutiliza el builder SQL solo para validar orden, cursor y limit
+	if _, _, _, err := buildVentasQuery(filtro); err != nil {
+		return nil, "", err
+	}
+	orden, o, _ := resolverOrden(filtro.Orden)
+	cursor, _ := decodeVentaCursor(filtro.Cursor, orden)
+
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	result := []models.VentaStats{}
+	for _, v := range st.ventas {
+		if filtro.ClienteID != 0 && (v.ClienteID == nil || *v.ClienteID != filtro.ClienteID) {
+			continue
+		}
+		vs, clienteNombre := st.ventaStats(v)
+
+		if !memVentaCumpleFiltro(vs, clienteNombre, filtro) {
+			continue
+		}
+		if cursor != nil && !memVentaDespuesDeCursor(vs, o, cursor) {
+			continue
+		}
+		result = append(result, vs)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if c := memCompararVentas(a, b, o.columna); c != 0 {
+			return (c < 0) != o.desc
+		}
+		return (a.ID < b.ID) != o.desc
+	})
+
+	if filtro.Limit > 0 && len(result) > filtro.Limit+1 {
+		result = result[:filtro.Limit+1]
+	}
+	for i := range result {
+		result[i].Items = st.itemsDeVenta(result[i].ID)
+	}
+
+	result, nextCursor := paginarVentas(result, filtro.Limit, orden)
+	return result, nextCursor, nil
+}
+
+// ventaStats arma la cabecera de una venta (sin items) como la retorna el
+// JOIN de las queries SQL, junto con el nombre real del cliente ("" si no tiene)
+func (st *memoryState) ventaStats(v memVenta) (models.VentaStats, string) {
+	vs := models.VentaStats{
+		ID:            v.ID,
+		CampanaID:     v.CampanaID,
+		Vendedor:      st.vendedores[v.VendedorID].Nombre,
+		Cliente:       "Sin cliente",
+		Total:         v.Total,
+		PaymentMethod: v.PaymentMethod,
+		Estado:        v.Estado,
+		TipoEntrega:   v.TipoEntrega,
+		CreatedAt:     v.CreatedAt,
+		PagadaAt:      v.PagadaAt,
+		EntregadaAt:   v.EntregadaAt,
+		CanceladaAt:   v.CanceladaAt,
+		Pagado:        st.pagadoVenta(v.ID),
+	}
+	if v.CreatedBy != nil {
+		vs.RegistradaPor = st.usuarios[*v.CreatedBy].Username
+	}
+	clienteNombre := ""
+	if v.ClienteID != nil {
+		if c, ok := st.clientes[*v.ClienteID]; ok {
+			vs.Cliente = c.Nombre
+			clienteNombre = c.Nombre
+			if c.Telefono != nil {
+				tel := *c.Telefono
+				vs.TelefonoCliente = &tel
+			}
+		}
+	}
+	return vs, clienteNombre
+}
+
+func (r *memVentaRepository) GetVentaByID(id int) (*models.VentaStats, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	v, ok := st.ventas[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	vs, _ := st.ventaStats(v)
+	vs.Items = st.itemsDeVenta(id)
+	return &vs, nil
+}
+
+func (r *memVentaRepository) GetEstadoVenta(ventaID int) (models.EstadoVenta, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	v, ok := st.ventas[ventaID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return models.EstadoVenta(v.Estado), nil
+}
+
+func (r *memVentaRepository) RegistrarTransicionVenta(ventaID int, estado models.EstadoVenta, at time.Time) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	v, ok := st.ventas[ventaID]
+	if !ok {
+		return nil
+	}
+	// Mismas reglas que columnasTransicion; los punteros no se comparten entre ventas
+	marcar := func(campo **time.Time) {
+		if *campo == nil {
+			t := at
+			*campo = &t
+		}
+	}
+	switch estado {
+	case models.EstadoSinPagar:
+		v.PagadaAt = nil
+	case models.EstadoPagada:
+		marcar(&v.PagadaAt)
+	case models.EstadoEntregada:
+		marcar(&v.PagadaAt)
+		marcar(&v.EntregadaAt)
+	case models.EstadoCancelada:
+		marcar(&v.CanceladaAt)
+	}
+	st.ventas[ventaID] = v
+	return nil
+}
+
+func (r *memVentaRepository) UpdateEstadoVenta(ventaID int, estado models.EstadoVenta) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if v, ok := st.ventas[ventaID]; ok {
+		v.Estado = string(estado)
+		st.ventas[ventaID] = v
+	}
+	return nil
+}
+
+func (r *memVentaRepository) InsertPago(ventaID int, monto float64, metodo, registradoPor string) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	// Equivalente a la FOREIGN KEY de pagos.venta_id
+	if _, ok := st.ventas[ventaID]; !ok {
+		return 0, fmt.Errorf("venta %d no existe", ventaID)
+	}
+	id := st.nextID("pagos")
+	st.pagos = append(st.pagos, models.Pago{
+		ID:            id,
+		VentaID:       ventaID,
+		Monto:         monto,
+		Metodo:        metodo,
+		RegistradoPor: registradoPor,
+		CreatedAt:     time.Now(),
+	})
+	return id, nil
+}
+
+func (r *memVentaRepository) GetPagosVenta(ventaID int) ([]models.Pago, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	pagos := []models.Pago{}
+	for _, p := range st.pagos {
+		if p.VentaID == ventaID {
+			pagos = append(pagos, p)
+		}
+	}
+	return pagos, nil
+}
+
+func (r *memVentaRepository) GetSaldoVenta(ventaID int) (float64, float64, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	v, ok := st.ventas[ventaID]
+	if !ok {
+		return 0, 0, sql.ErrNoRows
+	}
+	return v.Total, st.pagadoVenta(ventaID), nil
+}
+
+func (r *memVentaRepository) ReservarClaveIdempotencia(clave, requestHash string, ahora, expira time.Time) (string, int, json.RawMessage, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for k, c := range st.claves {
+		if !c.ExpiresAt.After(ahora) {
+			delete(st.claves, k)
+		}
+	}
+	c, ok := st.claves[clave]
+	if !ok {
+		c = memClaveIdempotencia{RequestHash: requestHash, ExpiresAt: expira}
+		st.claves[clave] = c
+	}
+	return c.RequestHash, c.VentaID, append(json.RawMessage(nil), c.Respuesta...), nil
+}
+
+func (r *memVentaRepository) CompletarClaveIdempotencia(clave string, ventaID int, respuesta json.RawMessage) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if c, ok := st.claves[clave]; ok {
+		c.VentaID = ventaID
+		c.Respuesta = append(json.RawMessage(nil), respuesta...)
+		st.claves[clave] = c
+	}
+	return nil
+}
+
+// borrarClavesDeVenta replica el ON DELETE CASCADE de idempotency_keys.venta_id
+func (st *memoryState) borrarClavesDeVenta(ventaID int) {
+	for k, c := range st.claves {
+		if c.VentaID == ventaID {
+			delete(st.claves, k)
+		}
+	}
+}
+
+// pagadoVenta suma el libro de pagos de una venta
+func (st *memoryState) pagadoVenta(ventaID int) float64 {
+	pagado := 0.0
+	for _, p := range st.pagos {
+		if p.VentaID == ventaID {
+			pagado += p.Monto
+		}
+	}
+	return pagado
+}
+
+func (r *memVentaRepository) DeleteVenta(id int) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.ventas[id]; !ok {
+		return sql.ErrNoRows
+	}
+	for detalleID, d := range st.detalles {
+		if d.VentaID == id {
+			delete(st.detalles, detalleID)
+		}
+	}
+	pagos := st.pagos[:0]
+	for _, p := range st.pagos {
+		if p.VentaID != id {
+			pagos = append(pagos, p)
+		}
+	}
+	st.pagos = pagos
+	st.borrarClavesDeVenta(id)
+	delete(st.ventas, id)
+	return nil
+}
+
+// memVentaCumpleFiltro replica las condiciones WHERE de buildVentasQuery
+func memVentaCumpleFiltro(v models.VentaStats, cliente string, f models.VentaFiltro) bool {
+	switch {
+	case f.CampanaID != 0 && v.CampanaID != f.CampanaID:
+		return false
+	case !f.IncluirCanceladas && v.Estado == "cancelada":
+		return false
+	case f.Desde != nil && v.CreatedAt.Before(*f.Desde):
+		return false
+	case f.Hasta != nil && !v.CreatedAt.Before(*f.Hasta):
+		return false
+	case f.Vendedor != "" && !strings.EqualFold(v.Vendedor, f.Vendedor):
+		return false
+	case f.Cliente != "" && !strings.Contains(strings.ToLower(cliente), strings.ToLower(f.Cliente)):
+		return false
+	case f.Estado != "" && v.Estado != f.Estado:
+		return false
+	case f.PaymentMethod != "" && v.PaymentMethod != f.PaymentMethod:
+		return false
+	case f.TipoEntrega != "" && v.TipoEntrega != f.TipoEntrega:
+		return false
+	}
+	return true
+}
+
+// memCompararVentas compara dos ventas por la columna de orden (-1, 0, 1)
+func memCompararVentas(a, b models.VentaStats, columna string) int {
+	if columna == "v.total" {
+		switch {
+		case a.Total < b.Total:
+			return -1
+		case a.Total > b.Total:
+			return 1
+		}
+		return 0
+	}
+	switch {
+	case a.CreatedAt.Before(b.CreatedAt):
+		return -1
+	case a.CreatedAt.After(b.CreatedAt):
+		return 1
+	}
+	return 0
+}
+
+// memVentaDespuesDeCursor replica la condición keyset de buildVentasQuery
+func memVentaDespuesDeCursor(v models.VentaStats, o ordenVentas, c *ventaCursor) bool {
+	ref := models.VentaStats{ID: c.ID, Total: c.Total, CreatedAt: c.Fecha}
+	cmp := memCompararVentas(v, ref, o.columna)
+	if cmp == 0 {
+		cmp = v.ID - c.ID
+	}
+	if o.desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+// itemsDeVenta arma los items de una venta ordenados por id de detalle
+func (st *memoryState) itemsDeVenta(ventaID int) []models.ProductoItem {
+	var detalles []memDetalle
+	for _, d := range st.detalles {
+		if d.VentaID == ventaID {
+			detalles = append(detalles, d)
+		}
+	}
+	sort.Slice(detalles, func(i, j int) bool { return detalles[i].ID < detalles[j].ID })
+
+	items := []models.ProductoItem{}
+	for _, d := range detalles {
+		p := st.productos[d.ProductoID]
+		items = append(items, models.ProductoItem{
+			DetalleID:    d.ID,
+			Tipo:         p.TipoPizza,
+			ProductID:    d.ProductoID,
+			Cantidad:     d.Cantidad,
+			Precio:       d.PrecioUnitario,
+			Total:        d.Subtotal,
+			PrecioActual: p.Precio,
+		})
+	}
+	return items
+}
+
+func (r *memVentaRepository) GetResumen(campanaID int) (map[string]interface{}, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	var pendiente, total float64
+	var sinPagar, pagadas, entregadas, totalVentas, delivery, retiro int
+	porMetodo := map[string]float64{}
+
+	for _, v := range st.ventas {
+		if v.Estado == "cancelada" || v.CampanaID != campanaID {
+			continue
+		}
+		totalVentas++
+		pagado := 0.0
+		for _, p := range st.pagos {
+			if p.VentaID != v.ID {
+				continue
+			}
+			pagado += p.Monto
+			porMetodo[p.Metodo] += p.Monto
+		}
+		total += pagado
+		if v.Total > pagado {
+			pendiente += v.Total - pagado
+		}
+		if v.Estado == "pagada" || v.Estado == "entregada" {
+			pagadas++
+		}
+		if v.Estado == "sin_pagar" {
+			sinPagar++
+		}
+		if v.Estado == "entregada" {
+			entregadas++
+		}
+		switch v.TipoEntrega {
+		case "delivery", "envio", "":
+			delivery++
+		case "retiro":
+			retiro++
+		}
+	}
+
+	cobrado := completarMetodosPago(porMetodo)
+	return map[string]interface{}{
+		"total_delivery":        delivery,
+		"total_retiro":          retiro,
+		"cobrado_por_metodo":    cobrado,
+		"efectivo_cobrado":      cobrado["efectivo"],
+		"transferencia_cobrada": cobrado["transferencia"],
+		"pendiente_cobro":       pendiente,
+		"total_cobrado":         total,
+		"ventas_sin_pagar":      sinPagar,
+		"ventas_pagadas":        pagadas,
+		"ventas_entregadas":     entregadas,
+		"ventas_totales":        totalVentas,
+	}, nil
+}
+
+func (r *memVentaRepository) GetVendedoresConStats(campanaID int) ([]map[string]interface{}, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	var result []map[string]interface{}
+	for _, vendedor := range st.vendedoresOrdenados() {
+		var cantidad, totalItems int
+		var deuda, pagado, total float64
+
+		for _, v := range st.ventas {
+			if v.VendedorID != vendedor.ID || v.Estado == "cancelada" || v.CampanaID != campanaID {
+				continue
+			}
+			cantidad++
+			total += v.Total
+			pagadoVenta := 0.0
+			for _, p := range st.pagos {
+				if p.VentaID == v.ID {
+					pagadoVenta += p.Monto
+				}
+			}
+			pagado += pagadoVenta
+			if v.Total > pagadoVenta {
+				deuda += v.Total - pagadoVenta
+			}
+			for _, d := range st.detalles {
+				if d.VentaID == v.ID {
+					totalItems += d.Cantidad
+				}
+			}
+		}
+
+		result = append(result, map[string]interface{}{
+			"nombre":      vendedor.Nombre,
+			"cantidad":    cantidad,
+			"total_items": totalItems,
+			"deuda":       deuda,
+			"pagado":      pagado,
+			"total":       total,
+		})
+	}
+
+	return result, nil
+}
+
+func (r *memVentaRepository) ExistsVenta(ctx context.Context, id int) (bool, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	_, ok := st.ventas[id]
+	return ok, nil
+}
+
+func (r *memVentaRepository) ClearDetalleVentas() error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	st.pagos = nil
+	st.detalles = map[int]memDetalle{}
+	return nil
+}
+
+func (r *memVentaRepository) ClearVentas() error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for _, d := range st.detalles {
+		if _, ok := st.ventas[d.VentaID]; ok {
+			return fmt.Errorf("no se pueden eliminar ventas con detalles asociados")
+		}
+	}
+	if len(st.pagos) > 0 {
+		return fmt.Errorf("no se pueden eliminar ventas con pagos asociados")
+	}
+	st.ventas = map[int]memVenta{}
+	st.claves = map[string]memClaveIdempotencia{}
+	return nil
+}
+
+// ============================================
+// Productos
+// ============================================
+
+type memProductoRepository struct {
+	s *MemoryStore
+}
+
+func (r *memProductoRepository) GetProductos(campanaID int) ([]models.Producto, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	var productos []models.Producto
+	for _, p := range st.productos {
+		if p.Activo && p.CampanaID == campanaID {
+			productos = append(productos, p)
+		}
+	}
+	sort.Slice(productos, func(i, j int) bool { return productos[i].TipoPizza < productos[j].TipoPizza })
+	return productos, nil
+}
+
+func (r *memProductoRepository) GetAllProductos() ([]models.Producto, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	var productos []models.Producto
+	for _, p := range st.productos {
+		productos = append(productos, p)
+	}
+	sort.Slice(productos, func(i, j int) bool { return productos[i].ID < productos[j].ID })
+	return productos, nil
+}
+
+func (r *memProductoRepository) GetProductoByID(id int) (*models.Producto, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	p, ok := st.productos[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &p, nil
+}
+
+// created_by/updated_by de productos, vendedores y usuarios no se exponen en
+// los modelos, así que el store en memoria no los guarda
+func (r *memProductoRepository) CreateProducto(campanaID int, tipoPizza, descripcion string, precio float64, createdBy *int) (int64, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.campanas[campanaID]; !ok {
+		return 0, fmt.Errorf("campaña %d no existe", campanaID)
+	}
+	id := st.nextID("productos")
+	now := time.Now()
+	st.productos[id] = models.Producto{
+		ID:          id,
+		CampanaID:   campanaID,
+		TipoPizza:   tipoPizza,
+		Descripcion: descripcion,
+		Precio:      precio,
+		Activo:      true,
+		CreatedAt:   now,
+	}
+	st.precios = append(st.precios, models.PrecioProducto{ProductoID: id, Precio: precio, VigenteDesde: now})
+	return int64(id), nil
+}
+
+func (r *memProductoRepository) UpdateProducto(id int, tipoPizza, descripcion string, precio float64, activo bool, updatedBy *int) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	p, ok := st.productos[id]
+	if !ok {
+		return nil
+	}
+	if p.Precio != precio {
+		st.precios = append(st.precios, models.PrecioProducto{ProductoID: id, Precio: precio, VigenteDesde: time.Now()})
+	}
+	p.TipoPizza = tipoPizza
+	p.Descripcion = descripcion
+	p.Precio = precio
+	p.Activo = activo
+	st.productos[id] = p
+	return nil
+}
+
+func (r *memProductoRepository) DeleteProducto(id int, updatedBy *int) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	p, ok := st.productos[id]
+	if !ok || !p.Activo {
+		return sql.ErrNoRows
+	}
+	p.Activo = false
+	st.productos[id] = p
+	return nil
+}
+
+func (r *memProductoRepository) GetHistorialPrecios(productoID int) ([]models.PrecioProducto, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	// st.precios ya está en orden de inserción
+	historial := []models.PrecioProducto{}
+	for _, p := range st.precios {
+		if p.ProductoID == productoID {
+			historial = append(historial, p)
+		}
+	}
+	return historial, nil
+}
+
+func (r *memProductoRepository) ExistsProducto(ctx context.Context, id int) (bool, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	_, ok := st.productos[id]
+	return ok, nil
+}
+
+func (r *memProductoRepository) ClearProductos() error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if len(st.detalles) > 0 {
+		return fmt.Errorf("no se pueden eliminar productos referenciados por detalles")
+	}
+	st.productos = map[int]models.Producto{}
+	st.precios = nil
+	return nil
+}
+
+// ============================================
+// Vendedores
+// ============================================
+
+type memVendedorRepository struct {
+	s *MemoryStore
+}
+
+// vendedoresOrdenados retorna los vendedores ordenados por nombre
+func (st *memoryState) vendedoresOrdenados() []models.Vendedor {
+	var vendedores []models.Vendedor
+	for _, v := range st.vendedores {
+		vendedores = append(vendedores, v)
+	}
+	sort.Slice(vendedores, func(i, j int) bool { return vendedores[i].Nombre < vendedores[j].Nombre })
+	return vendedores
+}
+
+func (r *memVendedorRepository) GetVendedores() ([]models.Vendedor, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	return st.vendedoresOrdenados(), nil
+}
+
+func (r *memVendedorRepository) GetVendedorByID(id int) (*models.Vendedor, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	v, ok := st.vendedores[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &v, nil
+}
+
+func (r *memVendedorRepository) GetVendedorID(nombre string) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for _, v := range st.vendedores {
+		if v.Nombre == nombre {
+			return v.ID, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (r *memVendedorRepository) CreateVendedor(nombre string, createdBy *int) (int64, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	id := st.nextID("vendedores")
+	st.vendedores[id] = models.Vendedor{ID: id, Nombre: nombre}
+	return int64(id), nil
+}
+
+func (r *memVendedorRepository) UpdateVendedor(id int, nombre string, updatedBy *int) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	v, ok := st.vendedores[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	v.Nombre = nombre
+	st.vendedores[id] = v
+	return nil
+}
+
+func (r *memVendedorRepository) DeleteVendedor(id int) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.vendedores[id]; !ok {
+		return sql.ErrNoRows
+	}
+	for _, v := range st.ventas {
+		if v.VendedorID == id {
+			return fmt.Errorf("vendedor %d tiene ventas asociadas", id)
+		}
+	}
+	delete(st.vendedores, id)
+	return nil
+}
+
+func (r *memVendedorRepository) ExistsVendedor(ctx context.Context, id int) (bool, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	_, ok := st.vendedores[id]
+	return ok, nil
+}
+
+func (r *memVendedorRepository) ClearVendedores() error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if len(st.ventas) > 0 {
+		return fmt.Errorf("no se pueden eliminar vendedores con ventas asociadas")
+	}
+	st.vendedores = map[int]models.Vendedor{}
+	return nil
+}
+
+// ============================================
+// Clientes
+// ============================================
+
+type memClienteRepository struct {
+	s *MemoryStore
+}
+
+func (r *memClienteRepository) GetClientesPorVendedor() (map[string][]models.Cliente, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	type par struct{ vendedorID, clienteID int }
+	vistos := map[par]bool{}
+	result := make(map[string][]models.Cliente)
+
+	for _, v := range st.ventas {
+		if v.ClienteID == nil {
+			continue
+		}
+		vendedor, ok := st.vendedores[v.VendedorID]
+		if !ok {
+			continue
+		}
+		c, ok := st.clientes[*v.ClienteID]
+		if !ok || vistos[par{vendedor.ID, c.ID}] {
+			continue
+		}
+		vistos[par{vendedor.ID, c.ID}] = true
+
+		cliente := models.Cliente{ID: c.ID, Nombre: strings.TrimSpace(c.Nombre)}
+		if c.Telefono != nil {
+			cliente.Telefono = models.Telefono(*c.Telefono)
+		}
+		result[vendedor.Nombre] = append(result[vendedor.Nombre], cliente)
+	}
+
+	for vendedor := range result {
+		clientes := result[vendedor]
+		sort.Slice(clientes, func(i, j int) bool { return clientes[i].Nombre < clientes[j].Nombre })
+	}
+
+	return result, nil
+}
+
+func (r *memClienteRepository) GetAllClientes() ([]models.Cliente, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	var clientes []models.Cliente
+	for _, c := range st.clientes {
+		cliente := models.Cliente{ID: c.ID, Nombre: c.Nombre}
+		if c.Telefono != nil {
+			cliente.Telefono = models.Telefono(*c.Telefono)
+		}
+		clientes = append(clientes, cliente)
+	}
+	sort.Slice(clientes, func(i, j int) bool { return clientes[i].ID < clientes[j].ID })
+	return clientes, nil
+}
+
+// toModel convierte la fila interna al modelo (teléfono 0 si no tiene)
+func (c memCliente) toModel() models.Cliente {
+	cliente := models.Cliente{ID: c.ID, Nombre: c.Nombre}
+	if c.Telefono != nil {
+		cliente.Telefono = models.Telefono(*c.Telefono)
+	}
+	return cliente
+}
+
+func (r *memClienteRepository) GetClienteByID(id int) (*models.Cliente, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	c, ok := st.clientes[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	cliente := c.toModel()
+	return &cliente, nil
+}
+
+// BuscarClientes replica la búsqueda de MySQL: compara sin mayúsculas ni
+// acentos y ordena por rango (exacto, prefijo del nombre, prefijo de una
+// palabra), cantidad de ventas y nombre
+func (r *memClienteRepository) BuscarClientes(prefijo string, limit int) ([]models.Cliente, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	buscado := NormalizarNombre(prefijo)
+	ventas := map[int]int{}
+	for _, v := range st.ventas {
+		if v.ClienteID != nil {
+			ventas[*v.ClienteID]++
+		}
+	}
+
+	type candidato struct {
+		cliente models.Cliente
+		nombre  string
+		rango   int
+	}
+	var candidatos []candidato
+	for _, c := range st.clientes {
+		nombre := NormalizarNombre(c.Nombre)
+		rango := 0
+		switch {
+		case nombre == buscado:
+		case strings.HasPrefix(nombre, buscado):
+			rango = 1
+		case strings.Contains(nombre, " "+buscado):
+			rango = 2
+		default:
+			continue
+		}
+		candidatos = append(candidatos, candidato{cliente: c.toModel(), nombre: nombre, rango: rango})
+	}
+	sort.Slice(candidatos, func(i, j int) bool {
+		a, b := candidatos[i], candidatos[j]
+		if a.rango != b.rango {
+			return a.rango < b.rango
+		}
+		if ventas[a.cliente.ID] != ventas[b.cliente.ID] {
+			return ventas[a.cliente.ID] > ventas[b.cliente.ID]
+		}
+		if a.nombre != b.nombre {
+			return a.nombre < b.nombre
+		}
+		return a.cliente.ID < b.cliente.ID
+	})
+
+	clientes := []models.Cliente{}
+	for i := 0; i < len(candidatos) && i < limit; i++ {
+		clientes = append(clientes, candidatos[i].cliente)
+	}
+	return clientes, nil
+}
+
+// clienteByNombre busca un cliente por nombre normalizado, como la clave
+// única de MySQL
+func (st *memoryState) clienteByNombre(nombre string) (memCliente, bool) {
+	clave := NormalizarNombre(nombre)
+	for _, c := range st.clientes {
+		if NormalizarNombre(c.Nombre) == clave {
+			return c, true
+		}
+	}
+	return memCliente{}, false
+}
+
+func (r *memClienteRepository) GetOrCreateCliente(nombre string) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	nombre = strings.TrimSpace(nombre)
+	if c, ok := st.clienteByNombre(nombre); ok {
+		return c.ID, nil
+	}
+
+	id := st.nextID("clientes")
+	st.clientes[id] = memCliente{ID: id, Nombre: nombre}
+	return id, nil
+}
+
+func (r *memClienteRepository) GetClienteByNombre(nombre string) (int, string, bool, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	c, ok := st.clienteByNombre(nombre)
+	if !ok {
+		return 0, "", false, nil
+	}
+	tel := ""
+	if c.Telefono != nil {
+		tel = *c.Telefono
+	}
+	return c.ID, tel, true, nil
+}
+
+func (r *memClienteRepository) CreateClienteWithTelefono(nombre string, telefono *string) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.clienteByNombre(nombre); ok {
+		return 0, ErrClienteDuplicado
+	}
+
+	id := st.nextID("clientes")
+	c := memCliente{ID: id, Nombre: nombre}
+	if telefono != nil {
+		tel := *telefono
+		c.Telefono = &tel
+	}
+	st.clientes[id] = c
+	return id, nil
+}
+
+func (r *memClienteRepository) UpdateClienteTelefono(id int, telefono *string) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	c, ok := st.clientes[id]
+	if !ok {
+		return nil
+	}
+	c.Telefono = nil
+	if telefono != nil {
+		tel := *telefono
+		c.Telefono = &tel
+	}
+	st.clientes[id] = c
+	return nil
+}
+
+func (r *memClienteRepository) UpdateCliente(id int, nombre string, telefono *string) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.clientes[id]; !ok {
+		return sql.ErrNoRows
+	}
+	if otro, ok := st.clienteByNombre(nombre); ok && otro.ID != id {
+		return ErrClienteDuplicado
+	}
+	c := memCliente{ID: id, Nombre: nombre}
+	if telefono != nil {
+		tel := *telefono
+		c.Telefono = &tel
+	}
+	st.clientes[id] = c
+	return nil
+}
+
+func (r *memClienteRepository) DeleteCliente(id int) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.clientes[id]; !ok {
+		return sql.ErrNoRows
+	}
+	if st.ventasDeCliente(id) > 0 {
+		return fmt.Errorf("cliente %d tiene ventas asociadas", id)
+	}
+	delete(st.clientes, id)
+	return nil
+}
+
+func (r *memClienteRepository) ReasignarVentasCliente(desde, hacia int) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	n := 0
+	for id, v := range st.ventas {
+		if v.ClienteID != nil && *v.ClienteID == desde {
+			nuevo := hacia
+			v.ClienteID = &nuevo
+			st.ventas[id] = v
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memClienteRepository) ContarVentasCliente(id int) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	return st.ventasDeCliente(id), nil
+}
+
+// ventasDeCliente cuenta las ventas asociadas a un cliente
+func (st *memoryState) ventasDeCliente(id int) int {
+	n := 0
+	for _, v := range st.ventas {
+		if v.ClienteID != nil && *v.ClienteID == id {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *memClienteRepository) ExistsCliente(ctx context.Context, id int) (bool, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	_, ok := st.clientes[id]
+	return ok, nil
+}
+
+func (r *memClienteRepository) ClearClientes() error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for _, v := range st.ventas {
+		if v.ClienteID != nil {
+			return fmt.Errorf("no se pueden eliminar clientes con ventas asociadas")
+		}
+	}
+	st.clientes = map[int]memCliente{}
+	return nil
+}
+
+// ============================================
+// Usuarios
+// ============================================
+
+type memUsuarioRepository struct {
+	s *MemoryStore
+}
+
+func (r *memUsuarioRepository) GetUserByCredentials(username, plainPassword string) (*models.User, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for _, u := range st.usuarios {
+		if u.Username != username {
+			continue
+		}
+		if !VerifyPassword(u.PasswordHash, plainPassword) {
+			return nil, ErrPasswordInvalida
+		}
+		user := u.User
+		return &user, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memUsuarioRepository) GetAllUsers() ([]models.User, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	usuarios := []models.User{}
+	for _, u := range st.usuarios {
+		usuarios = append(usuarios, u.User)
+	}
+	sort.Slice(usuarios, func(i, j int) bool { return usuarios[i].Username < usuarios[j].Username })
+	return usuarios, nil
+}
+
+func (r *memUsuarioRepository) GetUserByID(id int) (*models.User, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	u, ok := st.usuarios[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	user := u.User
+	return &user, nil
+}
+
+func (r *memUsuarioRepository) UserExists(username string) (bool, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for _, u := range st.usuarios {
+		if u.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memUsuarioRepository) CreateUser(username, password, rol string, createdBy *int) (int, error) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for _, u := range st.usuarios {
+		if u.Username == username {
+			return 0, fmt.Errorf("usuario %s duplicado", username)
+		}
+	}
+
+	id := st.nextID("usuarios")
+	st.usuarios[id] = memUsuario{
+		User:         models.User{ID: id, Username: username, Rol: rol},
+		PasswordHash: hash,
+	}
+	return id, nil
+}
+
+func (r *memUsuarioRepository) UpdateUser(id int, username, password, rol string, updatedBy *int) error {
+	var hash string
+	if password != "" {
+		var err error
+		if hash, err = HashPassword(password); err != nil {
+			return err
+		}
+	}
+
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	u, ok := st.usuarios[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.Username = username
+	u.Rol = rol
+	if hash != "" {
+		u.PasswordHash = hash
+	}
+	st.usuarios[id] = u
+	return nil
+}
+
+func (r *memUsuarioRepository) DeleteUser(id int) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.usuarios[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(st.usuarios, id)
+
+	// ON DELETE CASCADE de refresh_tokens y password_resets; SET NULL del
+	// admin que generó el reset
+	for rid, t := range st.refresh {
+		if t.UsuarioID == id {
+			delete(st.refresh, rid)
+		}
+	}
+	for rid, p := range st.resets {
+		if p.UsuarioID == id {
+			delete(st.resets, rid)
+		} else if p.CreatedBy != nil && *p.CreatedBy == id {
+			p.CreatedBy = nil
+			st.resets[rid] = p
+		}
+	}
+
+	// ON DELETE SET NULL de auditoria.usuario_id
+	for i, a := range st.auditoria {
+		if a.UsuarioID != nil && *a.UsuarioID == id {
+			st.auditoria[i].UsuarioID = nil
+		}
+	}
+
+	// ON DELETE SET NULL de ventas.created_by/updated_by
+	for vid, v := range st.ventas {
+		if v.CreatedBy != nil && *v.CreatedBy == id {
+			v.CreatedBy = nil
+		}
+		if v.UpdatedBy != nil && *v.UpdatedBy == id {
+			v.UpdatedBy = nil
+		}
+		st.ventas[vid] = v
+	}
+	return nil
+}
+
+// copiarID evita que el store comparta punteros con el llamador
+func copiarID(id *int) *int {
+	if id == nil {
+		return nil
+	}
+	v := *id
+	return &v
+}
+
+// ============================================
+// Auditoría
+// ============================================
+
+type memAuditoriaRepository struct {
+	s *MemoryStore
+}
+
+func (r *memAuditoriaRepository) InsertAuditoria(entrada models.Auditoria) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if entrada.UsuarioID != nil {
+		if _, ok := st.usuarios[*entrada.UsuarioID]; !ok {
+			return 0, fmt.Errorf("usuario %d no existe", *entrada.UsuarioID)
+		}
+	}
+	entrada.ID = st.nextID("auditoria")
+	entrada.UsuarioID = copiarID(entrada.UsuarioID)
+	entrada.Cambios = append([]byte(nil), entrada.Cambios...)
+	st.auditoria = append(st.auditoria, entrada)
+	return entrada.ID, nil
+}
+
+func (r *memAuditoriaRepository) GetAuditoria(f models.AuditoriaFiltro) ([]models.Auditoria, error) {
+	if f.Limit <= 0 || f.Limit > MaxLimitAuditoria {
+		return nil, fmt.Errorf("%w: limit debe estar entre 1 y %d", ErrFiltroInvalido, MaxLimitAuditoria)
+	}
+
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	entradas := []models.Auditoria{}
+	for i := len(st.auditoria) - 1; i >= 0 && len(entradas) < f.Limit; i-- {
+		a := st.auditoria[i]
+		if f.Entidad != "" && a.Entidad != f.Entidad {
+			continue
+		}
+		if f.EntidadID != 0 && a.EntidadID != f.EntidadID {
+			continue
+		}
+		if f.Usuario != "" && a.Usuario != f.Usuario {
+			continue
+		}
+		if f.Desde != nil && a.CreatedAt.Before(*f.Desde) {
+			continue
+		}
+		if f.Hasta != nil && !a.CreatedAt.Before(*f.Hasta) {
+			continue
+		}
+		if f.AntesDeID != 0 && a.ID >= f.AntesDeID {
+			continue
+		}
+		entradas = append(entradas, a)
+	}
+	return entradas, nil
+}
+
+func (r *memAuditoriaRepository) UpdateAuditoriaCambios(id int, cambios json.RawMessage) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for i := range st.auditoria {
+		if st.auditoria[i].ID == id {
+			st.auditoria[i].Cambios = append([]byte(nil), cambios...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// ============================================
+// Sesiones
+// ============================================
+
+type memSesionRepository struct {
+	s *MemoryStore
+}
+
+func (r *memSesionRepository) InsertRefreshToken(token models.RefreshToken) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.usuarios[token.UsuarioID]; !ok {
+		return 0, fmt.Errorf("usuario %d no existe", token.UsuarioID)
+	}
+	for id, t := range st.refresh {
+		if t.UsuarioID == token.UsuarioID && t.ExpiresAt.Before(token.CreatedAt) {
+			delete(st.refresh, id)
+		}
+		if t.TokenHash == token.TokenHash {
+			return 0, fmt.Errorf("refresh token duplicado")
+		}
+	}
+	token.ID = st.nextID("refresh_tokens")
+	token.RevocadoAt = nil
+	st.refresh[token.ID] = token
+	return token.ID, nil
+}
+
+func (r *memSesionRepository) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for _, t := range st.refresh {
+		if t.TokenHash == tokenHash {
+			return &t, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memSesionRepository) RevocarRefreshToken(id int, at time.Time) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if t, ok := st.refresh[id]; ok && t.RevocadoAt == nil {
+		t.RevocadoAt = &at
+		st.refresh[id] = t
+	}
+	return nil
+}
+
+func (r *memSesionRepository) RevocarFamiliaRefresh(familia string, at time.Time) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for id, t := range st.refresh {
+		if t.Familia == familia && t.RevocadoAt == nil {
+			t.RevocadoAt = &at
+			st.refresh[id] = t
+		}
+	}
+	return nil
+}
+
+func (r *memSesionRepository) RevocarAccessToken(jti string, expira, ahora time.Time) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for k, exp := range st.revocados {
+		if exp.Before(ahora) {
+			delete(st.revocados, k)
+		}
+	}
+	if _, ok := st.revocados[jti]; !ok {
+		st.revocados[jti] = expira
+	}
+	return nil
+}
+
+func (r *memSesionRepository) RevocarSesionesUsuario(usuarioID int, at time.Time) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	at = at.Truncate(time.Millisecond)
+	u, ok := st.usuarios[usuarioID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.SesionesDesde = &at
+	st.usuarios[usuarioID] = u
+	for id, t := range st.refresh {
+		if t.UsuarioID == usuarioID && t.RevocadoAt == nil {
+			t.RevocadoAt = &at
+			st.refresh[id] = t
+		}
+	}
+	return nil
+}
+
+func (r *memSesionRepository) TokenRevocado(jti string, usuarioID int, emitido time.Time) (bool, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	u, ok := st.usuarios[usuarioID]
+	if !ok {
+		return true, nil
+	}
+	if u.SesionesDesde != nil && !emitido.After(*u.SesionesDesde) {
+		return true, nil
+	}
+	_, revocado := st.revocados[jti]
+	return revocado, nil
+}
+
+func (r *memSesionRepository) InsertPasswordReset(reset models.PasswordReset) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if _, ok := st.usuarios[reset.UsuarioID]; !ok {
+		return 0, fmt.Errorf("usuario %d no existe", reset.UsuarioID)
+	}
+	for id, p := range st.resets {
+		if p.UsuarioID == reset.UsuarioID && p.UsadoAt == nil {
+			at := reset.CreatedAt
+			p.UsadoAt = &at
+			st.resets[id] = p
+		}
+	}
+	reset.ID = st.nextID("password_resets")
+	reset.UsadoAt = nil
+	reset.CreatedBy = copiarID(reset.CreatedBy)
+	st.resets[reset.ID] = reset
+	return reset.ID, nil
+}
+
+func (r *memSesionRepository) GetPasswordReset(tokenHash string) (*models.PasswordReset, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	for _, p := range st.resets {
+		if p.TokenHash == tokenHash {
+			return &p, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memSesionRepository) MarcarPasswordResetUsado(id int, at time.Time) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if p, ok := st.resets[id]; ok && p.UsadoAt == nil {
+		p.UsadoAt = &at
+		st.resets[id] = p
+	}
+	return nil
+}
+
+// ============================================
+// Intentos de login
+// ============================================
+
+type memIntentoLoginRepository struct {
+	s *MemoryStore
+}
+
+func (r *memIntentoLoginRepository) GetIntentoLogin(tipo, valor string) (*models.IntentoLogin, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	i, ok := st.intentos[tipo+":"+strings.ToLower(valor)]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &i, nil
+}
+
+func (r *memIntentoLoginRepository) GuardarIntentoLogin(intento models.IntentoLogin) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	st.intentos[intento.Tipo+":"+strings.ToLower(intento.Valor)] = intento
+	return nil
+}
+
+func (r *memIntentoLoginRepository) BorrarIntentoLogin(tipo, valor string) (bool, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	clave := tipo + ":" + strings.ToLower(valor)
+	_, ok := st.intentos[clave]
+	delete(st.intentos, clave)
+	return ok, nil
+}
+
+// ============================================
+// Campañas
+// ============================================
+
+type memCampanaRepository struct {
+	s *MemoryStore
+}
+
+func (r *memCampanaRepository) GetCampanas() ([]models.Campana, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	campanas := []models.Campana{}
+	for _, c := range st.campanas {
+		campanas = append(campanas, c)
+	}
+	sort.Slice(campanas, func(i, j int) bool {
+		a, b := campanas[i], campanas[j]
+		if !a.FechaInicio.Equal(b.FechaInicio) {
+			return a.FechaInicio.After(b.FechaInicio)
+		}
+		return a.ID > b.ID
+	})
+	return campanas, nil
+}
+
+func (r *memCampanaRepository) GetCampanaByID(id int) (*models.Campana, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	c, ok := st.campanas[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &c, nil
+}
+
+func (r *memCampanaRepository) GetCampanaActiva() (*models.Campana, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	var activa *models.Campana
+	for _, c := range st.campanas {
+		if c.Activa && (activa == nil || c.ID > activa.ID) {
+			c := c
+			activa = &c
+		}
+	}
+	if activa == nil {
+		return nil, sql.ErrNoRows
+	}
+	return activa, nil
+}
+
+// campanaNombreLibre replica el UNIQUE de campanas.nombre
+func (st *memoryState) campanaNombreLibre(nombre string, excepto int) error {
+	for _, c := range st.campanas {
+		if c.ID != excepto && strings.EqualFold(c.Nombre, nombre) {
+			return fmt.Errorf("campaña %s duplicada", nombre)
+		}
+	}
+	return nil
+}
+
+func (r *memCampanaRepository) CreateCampana(c models.Campana, createdBy *int) (int, error) {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	if err := st.campanaNombreLibre(c.Nombre, 0); err != nil {
+		return 0, err
+	}
+	c.ID = st.nextID("campanas")
+	c.CreatedAt = time.Now()
+	st.campanas[c.ID] = c
+	return c.ID, nil
+}
+
+func (r *memCampanaRepository) UpdateCampana(c models.Campana, updatedBy *int) error {
+	st := r.s.lock()
+	defer r.s.unlock()
+
+	actual, ok := st.campanas[c.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if err := st.campanaNombreLibre(c.Nombre, c.ID); err != nil {
+		return err
+	}
+	c.CreatedAt = actual.CreatedAt
+	st.campanas[c.ID] = c
+	return nil
+}
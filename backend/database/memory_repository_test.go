@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"pizzas-ecos/models"
+)
+
+// TestMemoryStore_WithTxRollback verifica que un error dentro de WithTx revierta todas las escrituras
+func TestMemoryStore_WithTxRollback(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	vendedorID, _ := store.Vendedores().CreateVendedor("Juan Pérez", nil)
+
+	// Act
+	err := store.WithTx(context.Background(), func(tx Store) error {
+		clienteID, err := tx.Clientes().CreateClienteWithTelefono("María García", nil)
+		if err != nil {
+			return err
+		}
+		ventaID, err := tx.Ventas().InsertVenta(1, &clienteID, int(vendedorID), 10, "efectivo", "sin_pagar", "retiro", nil)
+		if err != nil {
+			return err
+		}
+		// Producto inexistente: equivalente a violar la FK en MySQL
+		return tx.Ventas().InsertDetalle(ventaID, models.ProductoItem{ProductID: 999, Cantidad: 1})
+	})
+
+	// Assert
+	if err == nil {
+		t.Fatal("WithTx() expected error but got none")
+	}
+	ventas, _, _ := store.Ventas().GetAllVentas(models.VentaFiltro{IncluirCanceladas: true})
+	if len(ventas) != 0 {
+		t.Errorf("GetAllVentas() len = %d, want 0 tras rollback", len(ventas))
+	}
+	if _, _, exists, _ := store.Clientes().GetClienteByNombre("María García"); exists {
+		t.Error("el cliente no debería existir tras rollback")
+	}
+}
+
+// TestMemoryStore_WithTxCommit verifica que las escrituras persistan si fn no falla
+func TestMemoryStore_WithTxCommit(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	vendedorID, _ := store.Vendedores().CreateVendedor("Juan Pérez", nil)
+	productoID, _ := store.Productos().CreateProducto(1, "Muzzarella", "", 10, nil)
+
+	// Act
+	err := store.WithTx(context.Background(), func(tx Store) error {
+		clienteID, err := tx.Clientes().GetOrCreateCliente("María García")
+		if err != nil {
+			return err
+		}
+		ventaID, err := tx.Ventas().InsertVenta(1, &clienteID, int(vendedorID), 20, "efectivo", "sin_pagar", "retiro", nil)
+		if err != nil {
+			return err
+		}
+		return tx.Ventas().InsertDetalle(ventaID, models.ProductoItem{ProductID: int(productoID), Cantidad: 2, Precio: 10, Total: 20})
+	})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("WithTx() unexpected error: %v", err)
+	}
+	ventas, _, _ := store.Ventas().GetAllVentas(models.VentaFiltro{})
+	if len(ventas) != 1 {
+		t.Fatalf("GetAllVentas() len = %d, want 1", len(ventas))
+	}
+	if ventas[0].Cliente != "María García" || ventas[0].Vendedor != "Juan Pérez" {
+		t.Errorf("GetAllVentas() venta = %+v", ventas[0])
+	}
+	if len(ventas[0].Items) != 1 || ventas[0].Items[0].Tipo != "Muzzarella" {
+		t.Errorf("GetAllVentas() items = %+v", ventas[0].Items)
+	}
+}
+
+// TestMemoryStore_ErrNoRows verifica que el store replique los errores de las funciones SQL
+func TestMemoryStore_ErrNoRows(t *testing.T) {
+	store := NewMemoryStore()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"GetVendedorID", func() error { _, err := store.Vendedores().GetVendedorID("Nadie"); return err }},
+		{"UpdateVendedor", func() error { return store.Vendedores().UpdateVendedor(1, "X", nil) }},
+		{"DeleteProducto", func() error { return store.Productos().DeleteProducto(1, nil) }},
+		{"DeleteUser", func() error { return store.Usuarios().DeleteUser(1) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err != sql.ErrNoRows {
+				t.Errorf("%s() error = %v, want sql.ErrNoRows", tt.name, err)
+			}
+		})
+	}
+}
+
+// TestMemoryStore_WithTxPanic verifica que un pánico dentro de WithTx también revierta
+func TestMemoryStore_WithTxPanic(t *testing.T) {
+	store := NewMemoryStore()
+
+	func() {
+		defer func() { recover() }()
+		store.WithTx(context.Background(), func(tx Store) error {
+			tx.Vendedores().CreateVendedor("Juan Pérez", nil)
+			panic("fallo inesperado")
+		})
+	}()
+
+	vendedores, _ := store.Vendedores().GetVendedores()
+	if len(vendedores) != 0 {
+		t.Errorf("GetVendedores() len = %d, want 0 tras pánico", len(vendedores))
+	}
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pizzas-ecos/logger"
+)
+
+// migrationsFS contiene los archivos NNNN_nombre.up.sql / NNNN_nombre.down.sql
+// embebidos en el binario
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migration es una versión del esquema con su script de subida y de bajada
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// LoadMigrations lee las migraciones embebidas ordenadas por versión
+func LoadMigrations() ([]Migration, error) {
+	return loadMigrations(migrationsFS, "migrations")
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo migraciones: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		sep := strings.IndexByte(base, '_')
+		if sep <= 0 {
+			return nil, fmt.Errorf("nombre de migración inválido: %s", name)
+		}
+		version, err := strconv.Atoi(base[:sep])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("versión de migración inválida: %s", name)
+		}
+
+		content, err := fs.ReadFile(fsys, dir+"/"+name)
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo %s: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: base[sep+1:]}
+			byVersion[version] = m
+		} else if m.Name != base[sep+1:] {
+			return nil, fmt.Errorf("versión %d duplicada: %s y %s", version, m.Name, base[sep+1:])
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migración %04d_%s requiere archivos up y down", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// splitStatements separa un script en sentencias individuales (el driver no
+// ejecuta múltiples sentencias por Exec sin multiStatements=true). Ignora las
+// líneas de comentario "--"; no soporta ';' dentro de literales
+func splitStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	var statements []string
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			statements = append(statements, stmt)
+		}
+	}
+	return statements
+}
+
+// ensureMigrationsTable crea la tabla de control de versiones si no existe
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// AppliedMigrations retorna las versiones ya aplicadas
+func AppliedMigrations(db *sql.DB) (map[int]bool, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, fmt.Errorf("error creando schema_migrations: %w", err)
+	}
+
+	rows, err := db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// Migrate aplica en orden todas las migraciones pendientes. Cada una se
+// registra en schema_migrations apenas termina; MySQL confirma el DDL de forma
+// implícita, así que una migración que falla a mitad debe corregirse a mano
+func Migrate(db *sql.DB) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		for _, stmt := range splitStatements(m.Up) {
+			if _, err := db.Exec(stmt); err != nil {
+				logger.Error("Migrate: Error aplicando migración", "MIGRATION_UP_ERROR", map[string]interface{}{
+					"version": m.Version,
+					"name":    m.Name,
+					"error":   err.Error(),
+				})
+				return fmt.Errorf("migración %04d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+
+		if _, err := db.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+			return fmt.Errorf("error registrando migración %04d: %w", m.Version, err)
+		}
+
+		logger.Info("Migrate: Migración aplicada", map[string]interface{}{
+			"version": m.Version,
+			"name":    m.Name,
+		})
+	}
+
+	return nil
+}
+
+// MigrateDown revierte las últimas steps migraciones aplicadas
+func MigrateDown(db *sql.DB, steps int) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if !applied[m.Version] {
+			continue
+		}
+
+		for _, stmt := range splitStatements(m.Down) {
+			if _, err := db.Exec(stmt); err != nil {
+				logger.Error("MigrateDown: Error revirtiendo migración", "MIGRATION_DOWN_ERROR", map[string]interface{}{
+					"version": m.Version,
+					"name":    m.Name,
+					"error":   err.Error(),
+				})
+				return fmt.Errorf("revirtiendo migración %04d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+
+		if _, err := db.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+			return fmt.Errorf("error desregistrando migración %04d: %w", m.Version, err)
+		}
+
+		logger.Info("MigrateDown: Migración revertida", map[string]interface{}{
+			"version": m.Version,
+			"name":    m.Name,
+		})
+		steps--
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// TestLoadMigrations verifica que las migraciones embebidas estén completas y ordenadas
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("LoadMigrations() no retornó migraciones")
+	}
+
+	for i, m := range migrations {
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			t.Errorf("migración %d fuera de orden", m.Version)
+		}
+		if len(splitStatements(m.Up)) == 0 || len(splitStatements(m.Down)) == 0 {
+			t.Errorf("migración %04d_%s tiene scripts vacíos", m.Version, m.Name)
+		}
+	}
+}
+
+// TestLoadMigrations_Invalidas verifica los errores de archivos mal formados
+func TestLoadMigrations_Invalidas(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name: "falta down",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql": {Data: []byte("CREATE TABLE a (id INT)")},
+			},
+		},
+		{
+			name: "versión no numérica",
+			files: fstest.MapFS{
+				"m/abc_init.up.sql":   {Data: []byte("SELECT 1")},
+				"m/abc_init.down.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+		{
+			name: "versión duplicada",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql":   {Data: []byte("SELECT 1")},
+				"m/0001_a.down.sql": {Data: []byte("SELECT 1")},
+				"m/0001_b.up.sql":   {Data: []byte("SELECT 1")},
+				"m/0001_b.down.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadMigrations(tt.files, "m"); err == nil {
+				t.Error("loadMigrations() expected error but got none")
+			}
+		})
+	}
+}
+
+// TestSplitStatements verifica la separación de sentencias e ignorado de comentarios
+func TestSplitStatements(t *testing.T) {
+	script := `
+-- comentario; con punto y coma
+CREATE TABLE a (id INT);
+
+CREATE TABLE b (id INT);
+`
+	statements := splitStatements(script)
+	if len(statements) != 2 {
+		t.Fatalf("splitStatements() len = %d, want 2: %q", len(statements), statements)
+	}
+	if statements[0] != "CREATE TABLE a (id INT)" {
+		t.Errorf("splitStatements()[0] = %q", statements[0])
+	}
+}
@@ -0,0 +1,15 @@
+-- Historial de precios: cada fila es el precio vigente de un producto desde
+-- vigente_desde hasta la fila siguiente del mismo producto
+
+CREATE TABLE IF NOT EXISTS producto_precios (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    producto_id INT NOT NULL,
+    precio DECIMAL(10,2) NOT NULL,
+    vigente_desde TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_producto_precios_producto (producto_id, vigente_desde),
+    CONSTRAINT fk_producto_precios_producto FOREIGN KEY (producto_id) REFERENCES productos (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- El precio actual de cada producto existente es su primera entrada
+INSERT INTO producto_precios (producto_id, precio, vigente_desde)
+SELECT id, precio, created_at FROM productos;
@@ -0,0 +1,4 @@
+ALTER TABLE ventas
+    DROP COLUMN pagada_at,
+    DROP COLUMN entregada_at,
+    DROP COLUMN cancelada_at;
@@ -0,0 +1,7 @@
+-- Momento en que la venta entró a cada estado (NULL si nunca pasó por él).
+-- Las ventas anteriores a esta migración quedan sin marcas
+
+ALTER TABLE ventas
+    ADD COLUMN pagada_at TIMESTAMP NULL DEFAULT NULL,
+    ADD COLUMN entregada_at TIMESTAMP NULL DEFAULT NULL,
+    ADD COLUMN cancelada_at TIMESTAMP NULL DEFAULT NULL;
@@ -0,0 +1,19 @@
+-- Libro de pagos: una venta puede cobrarse en varias partes y con distintos
+-- métodos. Los montos negativos son reversiones de pagos anteriores
+
+CREATE TABLE IF NOT EXISTS pagos (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    venta_id INT NOT NULL,
+    monto DECIMAL(10,2) NOT NULL,
+    metodo VARCHAR(20) NOT NULL,
+    registrado_por VARCHAR(100) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_pagos_venta (venta_id, created_at),
+    CONSTRAINT fk_pagos_venta FOREIGN KEY (venta_id) REFERENCES ventas (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Las ventas ya cobradas quedan saldadas con un único pago por su total
+INSERT INTO pagos (venta_id, monto, metodo, created_at)
+SELECT id, total, payment_method, COALESCE(pagada_at, created_at)
+FROM ventas
+WHERE estado IN ('pagada', 'entregada');
@@ -0,0 +1,12 @@
+-- Claves Idempotency-Key de POST /api/v1/ventas: un reintento con la misma
+-- clave y el mismo cuerpo retorna la venta ya creada en lugar de duplicarla
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    clave VARCHAR(255) NOT NULL PRIMARY KEY,
+    request_hash CHAR(64) NOT NULL,
+    venta_id INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    KEY idx_idempotency_keys_expires (expires_at),
+    CONSTRAINT fk_idempotency_keys_venta FOREIGN KEY (venta_id) REFERENCES ventas (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,23 @@
+ALTER TABLE usuarios
+    DROP FOREIGN KEY fk_usuarios_created_by,
+    DROP FOREIGN KEY fk_usuarios_updated_by,
+    DROP COLUMN created_by,
+    DROP COLUMN updated_by;
+
+ALTER TABLE vendedores
+    DROP FOREIGN KEY fk_vendedores_created_by,
+    DROP FOREIGN KEY fk_vendedores_updated_by,
+    DROP COLUMN created_by,
+    DROP COLUMN updated_by;
+
+ALTER TABLE productos
+    DROP FOREIGN KEY fk_productos_created_by,
+    DROP FOREIGN KEY fk_productos_updated_by,
+    DROP COLUMN created_by,
+    DROP COLUMN updated_by;
+
+ALTER TABLE ventas
+    DROP FOREIGN KEY fk_ventas_created_by,
+    DROP FOREIGN KEY fk_ventas_updated_by,
+    DROP COLUMN created_by,
+    DROP COLUMN updated_by;
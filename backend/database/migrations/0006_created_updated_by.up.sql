@@ -0,0 +1,26 @@
+-- Usuario que creó y que modificó por última vez cada fila. NULL en filas
+-- anteriores a esta migración o creadas desde rutas públicas sin token
+
+ALTER TABLE ventas
+    ADD COLUMN created_by INT NULL,
+    ADD COLUMN updated_by INT NULL,
+    ADD CONSTRAINT fk_ventas_created_by FOREIGN KEY (created_by) REFERENCES usuarios (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_ventas_updated_by FOREIGN KEY (updated_by) REFERENCES usuarios (id) ON DELETE SET NULL;
+
+ALTER TABLE productos
+    ADD COLUMN created_by INT NULL,
+    ADD COLUMN updated_by INT NULL,
+    ADD CONSTRAINT fk_productos_created_by FOREIGN KEY (created_by) REFERENCES usuarios (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_productos_updated_by FOREIGN KEY (updated_by) REFERENCES usuarios (id) ON DELETE SET NULL;
+
+ALTER TABLE vendedores
+    ADD COLUMN created_by INT NULL,
+    ADD COLUMN updated_by INT NULL,
+    ADD CONSTRAINT fk_vendedores_created_by FOREIGN KEY (created_by) REFERENCES usuarios (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_vendedores_updated_by FOREIGN KEY (updated_by) REFERENCES usuarios (id) ON DELETE SET NULL;
+
+ALTER TABLE usuarios
+    ADD COLUMN created_by INT NULL,
+    ADD COLUMN updated_by INT NULL,
+    ADD CONSTRAINT fk_usuarios_created_by FOREIGN KEY (created_by) REFERENCES usuarios (id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_usuarios_updated_by FOREIGN KEY (updated_by) REFERENCES usuarios (id) ON DELETE SET NULL;
@@ -0,0 +1,18 @@
+-- Registro de cambios de ventas, productos, vendedores y usuarios. entidad_id
+-- no tiene FK para conservar la historia de filas borradas; usuario guarda el
+-- username del momento por si el usuario se elimina después
+
+CREATE TABLE IF NOT EXISTS auditoria (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    entidad VARCHAR(20) NOT NULL,
+    entidad_id INT NOT NULL,
+    accion VARCHAR(20) NOT NULL,
+    usuario_id INT NULL,
+    usuario VARCHAR(100) NULL,
+    cambios JSON NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_auditoria_entidad (entidad, entidad_id),
+    KEY idx_auditoria_usuario (usuario),
+    KEY idx_auditoria_created_at (created_at),
+    CONSTRAINT fk_auditoria_usuario FOREIGN KEY (usuario_id) REFERENCES usuarios (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
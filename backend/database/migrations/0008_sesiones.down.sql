@@ -0,0 +1,5 @@
+ALTER TABLE usuarios
+    DROP COLUMN sesiones_desde;
+
+DROP TABLE IF EXISTS tokens_revocados;
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,28 @@
+-- Refresh tokens (solo su hash SHA-256) y revocación de access tokens. Los
+-- refresh tokens rotados comparten familia: reutilizar uno ya rotado revoca
+-- toda la familia
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    usuario_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    familia CHAR(32) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revocado_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_refresh_tokens_hash (token_hash),
+    KEY idx_refresh_tokens_familia (familia),
+    KEY idx_refresh_tokens_usuario (usuario_id),
+    CONSTRAINT fk_refresh_tokens_usuario FOREIGN KEY (usuario_id) REFERENCES usuarios (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Access tokens revocados por logout, hasta que expiren
+CREATE TABLE IF NOT EXISTS tokens_revocados (
+    jti CHAR(32) PRIMARY KEY,
+    expires_at DATETIME NOT NULL,
+    KEY idx_tokens_revocados_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Los access tokens emitidos hasta este momento (inclusive) quedan revocados
+ALTER TABLE usuarios
+    ADD COLUMN sesiones_desde DATETIME NULL;
@@ -0,0 +1,11 @@
+-- Intentos fallidos de login por usuario y por IP. fallos se reinicia si el
+-- último fallo es más viejo que la ventana de bloqueo
+CREATE TABLE IF NOT EXISTS intentos_login (
+    tipo ENUM('usuario', 'ip') NOT NULL,
+    valor VARCHAR(100) NOT NULL,
+    fallos INT NOT NULL,
+    ultimo_fallo DATETIME NOT NULL,
+    bloqueado_hasta DATETIME NULL,
+    PRIMARY KEY (tipo, valor),
+    KEY idx_intentos_login_ultimo (ultimo_fallo)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,11 @@
+ALTER TABLE productos
+    DROP FOREIGN KEY fk_productos_campana,
+    DROP KEY idx_productos_campana,
+    DROP COLUMN campana_id;
+
+ALTER TABLE ventas
+    DROP FOREIGN KEY fk_ventas_campana,
+    DROP KEY idx_ventas_campana,
+    DROP COLUMN campana_id;
+
+DROP TABLE IF EXISTS campanas;
@@ -0,0 +1,34 @@
+-- Campañas (ediciones) de la venta de pizzas. Cada venta y cada producto
+-- pertenecen a una campaña; a lo sumo una está activa y es la que recibe las
+-- ventas nuevas. Los datos existentes quedan en una campaña inicial
+CREATE TABLE IF NOT EXISTS campanas (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    nombre VARCHAR(100) NOT NULL,
+    fecha_inicio DATE NOT NULL,
+    fecha_fin DATE NULL,
+    activa BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by INT NULL,
+    updated_by INT NULL,
+    UNIQUE KEY uq_campanas_nombre (nombre),
+    CONSTRAINT fk_campanas_created_by FOREIGN KEY (created_by) REFERENCES usuarios (id) ON DELETE SET NULL,
+    CONSTRAINT fk_campanas_updated_by FOREIGN KEY (updated_by) REFERENCES usuarios (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT INTO campanas (nombre, fecha_inicio, activa)
+SELECT 'Campaña inicial', COALESCE(DATE(MIN(created_at)), CURRENT_DATE), TRUE
+FROM ventas;
+
+ALTER TABLE ventas ADD COLUMN campana_id INT NULL;
+UPDATE ventas SET campana_id = (SELECT MIN(id) FROM campanas);
+ALTER TABLE ventas
+    MODIFY campana_id INT NOT NULL,
+    ADD KEY idx_ventas_campana (campana_id),
+    ADD CONSTRAINT fk_ventas_campana FOREIGN KEY (campana_id) REFERENCES campanas (id);
+
+ALTER TABLE productos ADD COLUMN campana_id INT NULL;
+UPDATE productos SET campana_id = (SELECT MIN(id) FROM campanas);
+ALTER TABLE productos
+    MODIFY campana_id INT NOT NULL,
+    ADD KEY idx_productos_campana (campana_id),
+    ADD CONSTRAINT fk_productos_campana FOREIGN KEY (campana_id) REFERENCES campanas (id);
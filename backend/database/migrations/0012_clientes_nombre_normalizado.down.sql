@@ -0,0 +1,4 @@
+-- Los clientes fusionados por la migración no se recuperan
+ALTER TABLE clientes
+    DROP KEY uq_clientes_nombre_normalizado,
+    DROP COLUMN nombre_normalizado;
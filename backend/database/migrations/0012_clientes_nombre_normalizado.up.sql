@@ -0,0 +1,40 @@
+-- Clave única por nombre normalizado: "Juan Perez", "juan perez " y "Juan
+-- Pérez" son el mismo cliente. La aplicación guarda el nombre en minúsculas,
+-- sin acentos y con los espacios colapsados; acá alcanza con minúsculas y
+-- espacios porque la collation utf8mb4_unicode_ci ya ignora los acentos al
+-- comparar y al agrupar
+ALTER TABLE clientes ADD COLUMN nombre_normalizado VARCHAR(100) NULL;
+UPDATE clientes SET nombre_normalizado = LOWER(TRIM(REGEXP_REPLACE(nombre, '[[:space:]]+', ' ')));
+
+-- Los duplicados existentes se fusionan en el cliente más antiguo: hereda el
+-- teléfono si no tenía y pasa a tener todas las ventas
+UPDATE clientes c
+JOIN (
+    SELECT MIN(id) AS id, MAX(telefono) AS telefono
+    FROM clientes
+    GROUP BY nombre_normalizado
+) k ON k.id = c.id
+SET c.telefono = k.telefono
+WHERE c.telefono IS NULL;
+
+UPDATE ventas v
+JOIN clientes c ON c.id = v.cliente_id
+JOIN (
+    SELECT nombre_normalizado, MIN(id) AS id
+    FROM clientes
+    GROUP BY nombre_normalizado
+) k ON k.nombre_normalizado = c.nombre_normalizado
+SET v.cliente_id = k.id
+WHERE c.id <> k.id;
+
+DELETE c FROM clientes c
+JOIN (
+    SELECT nombre_normalizado, MIN(id) AS id
+    FROM clientes
+    GROUP BY nombre_normalizado
+) k ON k.nombre_normalizado = c.nombre_normalizado
+WHERE c.id <> k.id;
+
+ALTER TABLE clientes
+    MODIFY nombre_normalizado VARCHAR(100) NOT NULL,
+    ADD UNIQUE KEY uq_clientes_nombre_normalizado (nombre_normalizado);
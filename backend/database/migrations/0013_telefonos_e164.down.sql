@@ -0,0 +1,3 @@
+-- Los teléfonos vuelven a entero sin el '+': el código de país queda en el número
+UPDATE clientes SET telefono = REPLACE(telefono, '+', '');
+ALTER TABLE clientes MODIFY telefono BIGINT NULL;
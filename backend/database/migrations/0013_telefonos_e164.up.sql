@@ -0,0 +1,19 @@
+-- Los teléfonos pasan de entero a texto en E.164 ("+541123456789"). Un entero
+-- pierde el '+' y los ceros iniciales y no distingue un número nacional de
+-- uno con código de país
+ALTER TABLE clientes MODIFY telefono VARCHAR(16) NULL;
+
+-- 0 era "sin teléfono"
+UPDATE clientes SET telefono = NULL WHERE telefono = '0';
+
+-- Los enteros existentes se cargaron desde Argentina (TELEFONO_PAIS=AR por
+-- defecto): 10 dígitos es un fijo o celular sin 9, 11 con 9 adelante es un
+-- celular, y 12 o 13 empezando con 54 ya traían el código de país
+UPDATE clientes SET telefono = CONCAT('+54', telefono)
+WHERE telefono REGEXP '^[1-9][0-9]{9}$' OR telefono REGEXP '^9[0-9]{10}$';
+
+UPDATE clientes SET telefono = CONCAT('+', telefono)
+WHERE telefono REGEXP '^54[1-9][0-9]{9}$' OR telefono REGEXP '^549[0-9]{10}$';
+
+-- El resto (números cortos o de otro país) se deja en dígitos: la aplicación
+-- lo normaliza la próxima vez que se actualiza el cliente
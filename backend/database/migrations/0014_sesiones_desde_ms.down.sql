@@ -0,0 +1,2 @@
+ALTER TABLE usuarios
+    MODIFY sesiones_desde DATETIME NULL;
@@ -0,0 +1,4 @@
+-- Milisegundos: el corte de sesiones se compara contra el claim iat_ms del
+-- access token, así un token emitido justo después de revocar sigue valiendo
+ALTER TABLE usuarios
+    MODIFY sesiones_desde DATETIME(3) NULL;
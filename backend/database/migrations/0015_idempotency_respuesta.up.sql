@@ -0,0 +1,4 @@
+-- Respuesta de la venta creada con cada Idempotency-Key: un reintento retorna
+-- el mismo cuerpo, incluidas las diferencias de precio informadas
+ALTER TABLE idempotency_keys
+    ADD COLUMN respuesta JSON NULL;
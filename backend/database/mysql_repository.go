@@ -0,0 +1,406 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"pizzas-ecos/models"
+)
+
+// mysqlStore implementa Store sobre MySQL. Fuera de una transacción q es el
+// *sql.DB; dentro de WithTx es la *Transaction en curso
+type mysqlStore struct {
+	db *sql.DB
+	q  Querier
+}
+
+// NewMySQLStore crea un Store respaldado por la conexión MySQL indicada
+func NewMySQLStore(db *sql.DB) Store {
+	return &mysqlStore{db: db, q: db}
+}
+
+func (s *mysqlStore) Ventas() VentaRepository        { return &mysqlVentaRepository{q: s.q} }
+func (s *mysqlStore) Productos() ProductoRepository  { return &mysqlProductoRepository{q: s.q} }
+func (s *mysqlStore) Vendedores() VendedorRepository { return &mysqlVendedorRepository{q: s.q} }
+func (s *mysqlStore) Clientes() ClienteRepository    { return &mysqlClienteRepository{q: s.q} }
+func (s *mysqlStore) Usuarios() UsuarioRepository    { return &mysqlUsuarioRepository{q: s.q} }
+func (s *mysqlStore) Auditoria() AuditoriaRepository { return &mysqlAuditoriaRepository{q: s.q} }
+func (s *mysqlStore) Sesiones() SesionRepository     { return &mysqlSesionRepository{q: s.q} }
+func (s *mysqlStore) IntentosLogin() IntentoLoginRepository {
+	return &mysqlIntentoLoginRepository{q: s.q}
+}
+func (s *mysqlStore) Campanas() CampanaRepository { return &mysqlCampanaRepository{q: s.q} }
+
+// WithTx ejecuta fn dentro de una transacción. Si el store ya está dentro de
+// una, fn participa de la transacción existente
+func (s *mysqlStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if _, inTx := s.q.(*Transaction); inTx {
+		return fn(s)
+	}
+	return withTransaction(ctx, s.db, func(tx *Transaction) error {
+		return fn(&mysqlStore{db: s.db, q: tx})
+	})
+}
+
+// mysqlVentaRepository implementa VentaRepository
+type mysqlVentaRepository struct {
+	q Querier
+}
+
+func (r *mysqlVentaRepository) InsertVenta(campanaID int, clienteID *int, vendedorID int, total float64, payment, estado, tipoEntrega string, createdBy *int) (int, error) {
+	return InsertVenta(r.q, campanaID, clienteID, vendedorID, total, payment, estado, tipoEntrega, createdBy)
+}
+
+func (r *mysqlVentaRepository) InsertDetalle(ventaID int, item models.ProductoItem) error {
+	return InsertDetalle(r.q, ventaID, item)
+}
+
+func (r *mysqlVentaRepository) UpdateVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}, updatedBy *int) error {
+	return UpdateVenta(r.q, ventaID, estado, paymentMethod, tipoEntrega, productosEliminar, productos, updatedBy)
+}
+
+func (r *mysqlVentaRepository) UpdateVentaClienteID(ventaID int, clienteID int) error {
+	return UpdateVentaClienteID(r.q, ventaID, clienteID)
+}
+
+func (r *mysqlVentaRepository) GetAllVentas(filtro models.VentaFiltro) ([]models.VentaStats, string, error) {
+	return GetAllVentas(r.q, filtro)
+}
+
+func (r *mysqlVentaRepository) GetVentaByID(id int) (*models.VentaStats, error) {
+	return GetVentaByID(r.q, id)
+}
+
+func (r *mysqlVentaRepository) DeleteVenta(id int) error {
+	return DeleteVenta(r.q, id)
+}
+
+func (r *mysqlVentaRepository) GetEstadoVenta(ventaID int) (models.EstadoVenta, error) {
+	return GetEstadoVenta(r.q, ventaID)
+}
+
+func (r *mysqlVentaRepository) RegistrarTransicionVenta(ventaID int, estado models.EstadoVenta, at time.Time) error {
+	return RegistrarTransicionVenta(r.q, ventaID, estado, at)
+}
+
+func (r *mysqlVentaRepository) UpdateEstadoVenta(ventaID int, estado models.EstadoVenta) error {
+	return UpdateEstadoVenta(r.q, ventaID, estado)
+}
+
+func (r *mysqlVentaRepository) InsertPago(ventaID int, monto float64, metodo, registradoPor string) (int, error) {
+	return InsertPago(r.q, ventaID, monto, metodo, registradoPor)
+}
+
+func (r *mysqlVentaRepository) GetPagosVenta(ventaID int) ([]models.Pago, error) {
+	return GetPagosVenta(r.q, ventaID)
+}
+
+func (r *mysqlVentaRepository) GetSaldoVenta(ventaID int) (float64, float64, error) {
+	return GetSaldoVenta(r.q, ventaID)
+}
+
+func (r *mysqlVentaRepository) ReservarClaveIdempotencia(clave, requestHash string, ahora, expira time.Time) (string, int, json.RawMessage, error) {
+	return ReservarClaveIdempotencia(r.q, clave, requestHash, ahora, expira)
+}
+
+func (r *mysqlVentaRepository) CompletarClaveIdempotencia(clave string, ventaID int, respuesta json.RawMessage) error {
+	return CompletarClaveIdempotencia(r.q, clave, ventaID, respuesta)
+}
+
+func (r *mysqlVentaRepository) GetResumen(campanaID int) (map[string]interface{}, error) {
+	return GetResumen(r.q, campanaID)
+}
+
+func (r *mysqlVentaRepository) GetVendedoresConStats(campanaID int) ([]map[string]interface{}, error) {
+	return GetVendedoresConStats(r.q, campanaID)
+}
+
+func (r *mysqlVentaRepository) ExistsVenta(ctx context.Context, id int) (bool, error) {
+	return ExistsVenta(ctx, r.q, id)
+}
+
+func (r *mysqlVentaRepository) ClearDetalleVentas() error {
+	return ClearDetalleVentas(r.q)
+}
+
+func (r *mysqlVentaRepository) ClearVentas() error {
+	return ClearVentas(r.q)
+}
+
+// mysqlProductoRepository implementa ProductoRepository
+type mysqlProductoRepository struct {
+	q Querier
+}
+
+func (r *mysqlProductoRepository) GetProductos(campanaID int) ([]models.Producto, error) {
+	return GetProductos(r.q, campanaID)
+}
+
+func (r *mysqlProductoRepository) GetAllProductos() ([]models.Producto, error) {
+	return GetAllProductos(r.q)
+}
+
+func (r *mysqlProductoRepository) GetProductoByID(id int) (*models.Producto, error) {
+	return GetProductoByID(r.q, id)
+}
+
+func (r *mysqlProductoRepository) CreateProducto(campanaID int, tipoPizza, descripcion string, precio float64, createdBy *int) (int64, error) {
+	return CreateProducto(r.q, campanaID, tipoPizza, descripcion, precio, createdBy)
+}
+
+func (r *mysqlProductoRepository) UpdateProducto(id int, tipoPizza, descripcion string, precio float64, activo bool, updatedBy *int) error {
+	return UpdateProducto(r.q, id, tipoPizza, descripcion, precio, activo, updatedBy)
+}
+
+func (r *mysqlProductoRepository) DeleteProducto(id int, updatedBy *int) error {
+	return DeleteProducto(r.q, id, updatedBy)
+}
+
+func (r *mysqlProductoRepository) GetHistorialPrecios(productoID int) ([]models.PrecioProducto, error) {
+	return GetHistorialPrecios(r.q, productoID)
+}
+
+func (r *mysqlProductoRepository) ExistsProducto(ctx context.Context, id int) (bool, error) {
+	return ExistsProducto(ctx, r.q, id)
+}
+
+func (r *mysqlProductoRepository) ClearProductos() error {
+	return ClearProductos(r.q)
+}
+
+// mysqlVendedorRepository implementa VendedorRepository
+type mysqlVendedorRepository struct {
+	q Querier
+}
+
+func (r *mysqlVendedorRepository) GetVendedores() ([]models.Vendedor, error) {
+	return GetVendedores(r.q)
+}
+
+func (r *mysqlVendedorRepository) GetVendedorByID(id int) (*models.Vendedor, error) {
+	return GetVendedorByID(r.q, id)
+}
+
+func (r *mysqlVendedorRepository) GetVendedorID(nombre string) (int, error) {
+	return GetVendedorID(r.q, nombre)
+}
+
+func (r *mysqlVendedorRepository) CreateVendedor(nombre string, createdBy *int) (int64, error) {
+	return CreateVendedor(r.q, nombre, createdBy)
+}
+
+func (r *mysqlVendedorRepository) UpdateVendedor(id int, nombre string, updatedBy *int) error {
+	return UpdateVendedor(r.q, id, nombre, updatedBy)
+}
+
+func (r *mysqlVendedorRepository) DeleteVendedor(id int) error {
+	return DeleteVendedor(r.q, id)
+}
+
+func (r *mysqlVendedorRepository) ExistsVendedor(ctx context.Context, id int) (bool, error) {
+	return ExistsVendedor(ctx, r.q, id)
+}
+
+func (r *mysqlVendedorRepository) ClearVendedores() error {
+	return ClearVendedores(r.q)
+}
+
+// mysqlClienteRepository implementa ClienteRepository
+type mysqlClienteRepository struct {
+	q Querier
+}
+
+func (r *mysqlClienteRepository) GetClientesPorVendedor() (map[string][]models.Cliente, error) {
+	return GetClientesPorVendedor(r.q)
+}
+
+func (r *mysqlClienteRepository) GetAllClientes() ([]models.Cliente, error) {
+	return GetAllClientes(r.q)
+}
+
+func (r *mysqlClienteRepository) GetClienteByID(id int) (*models.Cliente, error) {
+	return GetClienteByID(r.q, id)
+}
+
+func (r *mysqlClienteRepository) BuscarClientes(prefijo string, limit int) ([]models.Cliente, error) {
+	return BuscarClientes(r.q, prefijo, limit)
+}
+
+func (r *mysqlClienteRepository) GetOrCreateCliente(nombre string) (int, error) {
+	return GetOrCreateCliente(r.q, nombre)
+}
+
+func (r *mysqlClienteRepository) GetClienteByNombre(nombre string) (int, string, bool, error) {
+	return GetClienteByNombre(r.q, nombre)
+}
+
+func (r *mysqlClienteRepository) CreateClienteWithTelefono(nombre string, telefono *string) (int, error) {
+	return CreateClienteWithTelefono(r.q, nombre, telefono)
+}
+
+func (r *mysqlClienteRepository) UpdateClienteTelefono(id int, telefono *string) error {
+	return UpdateClienteTelefono(r.q, id, telefono)
+}
+
+func (r *mysqlClienteRepository) UpdateCliente(id int, nombre string, telefono *string) error {
+	return UpdateCliente(r.q, id, nombre, telefono)
+}
+
+func (r *mysqlClienteRepository) DeleteCliente(id int) error {
+	return DeleteCliente(r.q, id)
+}
+
+func (r *mysqlClienteRepository) ReasignarVentasCliente(desde, hacia int) (int, error) {
+	return ReasignarVentasCliente(r.q, desde, hacia)
+}
+
+func (r *mysqlClienteRepository) ContarVentasCliente(id int) (int, error) {
+	return ContarVentasCliente(r.q, id)
+}
+
+func (r *mysqlClienteRepository) ExistsCliente(ctx context.Context, id int) (bool, error) {
+	return ExistsCliente(ctx, r.q, id)
+}
+
+func (r *mysqlClienteRepository) ClearClientes() error {
+	return ClearClientes(r.q)
+}
+
+// mysqlUsuarioRepository implementa UsuarioRepository
+type mysqlUsuarioRepository struct {
+	q Querier
+}
+
+func (r *mysqlUsuarioRepository) GetUserByCredentials(username, plainPassword string) (*models.User, error) {
+	return GetUserByCredentials(r.q, username, plainPassword)
+}
+
+func (r *mysqlUsuarioRepository) GetAllUsers() ([]models.User, error) {
+	return GetAllUsers(r.q)
+}
+
+func (r *mysqlUsuarioRepository) GetUserByID(id int) (*models.User, error) {
+	return GetUserByID(r.q, id)
+}
+
+func (r *mysqlUsuarioRepository) UserExists(username string) (bool, error) {
+	return UserExists(r.q, username)
+}
+
+func (r *mysqlUsuarioRepository) CreateUser(username, password, rol string, createdBy *int) (int, error) {
+	return CreateUser(r.q, username, password, rol, createdBy)
+}
+
+func (r *mysqlUsuarioRepository) UpdateUser(id int, username, password, rol string, updatedBy *int) error {
+	return UpdateUser(r.q, id, username, password, rol, updatedBy)
+}
+
+func (r *mysqlUsuarioRepository) DeleteUser(id int) error {
+	return DeleteUser(r.q, id)
+}
+
+// mysqlAuditoriaRepository implementa AuditoriaRepository
+type mysqlAuditoriaRepository struct {
+	q Querier
+}
+
+func (r *mysqlAuditoriaRepository) InsertAuditoria(entrada models.Auditoria) (int, error) {
+	return InsertAuditoria(r.q, entrada)
+}
+
+func (r *mysqlAuditoriaRepository) GetAuditoria(filtro models.AuditoriaFiltro) ([]models.Auditoria, error) {
+	return GetAuditoria(r.q, filtro)
+}
+
+func (r *mysqlAuditoriaRepository) UpdateAuditoriaCambios(id int, cambios json.RawMessage) error {
+	return UpdateAuditoriaCambios(r.q, id, cambios)
+}
+
+// mysqlSesionRepository implementa SesionRepository
+type mysqlSesionRepository struct {
+	q Querier
+}
+
+func (r *mysqlSesionRepository) InsertRefreshToken(token models.RefreshToken) (int, error) {
+	return InsertRefreshToken(r.q, token)
+}
+
+func (r *mysqlSesionRepository) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	return GetRefreshToken(r.q, tokenHash)
+}
+
+func (r *mysqlSesionRepository) RevocarRefreshToken(id int, at time.Time) error {
+	return RevocarRefreshToken(r.q, id, at)
+}
+
+func (r *mysqlSesionRepository) RevocarFamiliaRefresh(familia string, at time.Time) error {
+	return RevocarFamiliaRefresh(r.q, familia, at)
+}
+
+func (r *mysqlSesionRepository) RevocarAccessToken(jti string, expira, ahora time.Time) error {
+	return RevocarAccessToken(r.q, jti, expira, ahora)
+}
+
+func (r *mysqlSesionRepository) RevocarSesionesUsuario(usuarioID int, at time.Time) error {
+	return RevocarSesionesUsuario(r.q, usuarioID, at)
+}
+
+func (r *mysqlSesionRepository) TokenRevocado(jti string, usuarioID int, emitido time.Time) (bool, error) {
+	return TokenRevocado(r.q, jti, usuarioID, emitido)
+}
+
+func (r *mysqlSesionRepository) InsertPasswordReset(reset models.PasswordReset) (int, error) {
+	return InsertPasswordReset(r.q, reset)
+}
+
+func (r *mysqlSesionRepository) GetPasswordReset(tokenHash string) (*models.PasswordReset, error) {
+	return GetPasswordReset(r.q, tokenHash)
+}
+
+func (r *mysqlSesionRepository) MarcarPasswordResetUsado(id int, at time.Time) error {
+	return MarcarPasswordResetUsado(r.q, id, at)
+}
+
+// mysqlIntentoLoginRepository implementa IntentoLoginRepository
+type mysqlIntentoLoginRepository struct {
+	q Querier
+}
+
+func (r *mysqlIntentoLoginRepository) GetIntentoLogin(tipo, valor string) (*models.IntentoLogin, error) {
+	return GetIntentoLogin(r.q, tipo, valor)
+}
+
+func (r *mysqlIntentoLoginRepository) GuardarIntentoLogin(intento models.IntentoLogin) error {
+	return GuardarIntentoLogin(r.q, intento)
+}
+
+func (r *mysqlIntentoLoginRepository) BorrarIntentoLogin(tipo, valor string) (bool, error) {
+	return BorrarIntentoLogin(r.q, tipo, valor)
+}
+
+// mysqlCampanaRepository implementa CampanaRepository
+type mysqlCampanaRepository struct {
+	q Querier
+}
+
+func (r *mysqlCampanaRepository) GetCampanas() ([]models.Campana, error) {
+	return GetCampanas(r.q)
+}
+
+func (r *mysqlCampanaRepository) GetCampanaByID(id int) (*models.Campana, error) {
+	return GetCampanaByID(r.q, id)
+}
+
+func (r *mysqlCampanaRepository) GetCampanaActiva() (*models.Campana, error) {
+	return GetCampanaActiva(r.q)
+}
+
+func (r *mysqlCampanaRepository) CreateCampana(c models.Campana, createdBy *int) (int, error) {
+	return CreateCampana(r.q, c, createdBy)
+}
+
+func (r *mysqlCampanaRepository) UpdateCampana(c models.Campana, updatedBy *int) error {
+	return UpdateCampana(r.q, c, updatedBy)
+}
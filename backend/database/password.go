@@ -1,12 +1,42 @@
 package database
 
 import (
+	"errors"
+	"os"
+	"strconv"
+
 	"golang.org/x/crypto/bcrypt"
+
+	"pizzas-ecos/logger"
 )
 
-// HashPassword genera un hash bcrypt de una contraseña
+// ErrPasswordInvalida indica que el usuario existe pero la contraseña no coincide
+var ErrPasswordInvalida = errors.New("contraseña inválida")
+
+// BcryptCost es el costo con que se hashean las contraseñas nuevas. Se lee
+// de BCRYPT_COST (entre 4 y 31, por defecto 10); los hashes existentes
+// guardan su propio costo y se siguen verificando igual
+var BcryptCost = costoBcryptDesdeEnv()
+
+func costoBcryptDesdeEnv() int {
+	valor := os.Getenv("BCRYPT_COST")
+	if valor == "" {
+		return bcrypt.DefaultCost
+	}
+	costo, err := strconv.Atoi(valor)
+	if err != nil || costo < bcrypt.MinCost || costo > bcrypt.MaxCost {
+		logger.Warn("BCRYPT_COST inválido, se usa el valor por defecto", map[string]interface{}{
+			"valor":       valor,
+			"por_defecto": bcrypt.DefaultCost,
+		})
+		return bcrypt.DefaultCost
+	}
+	return costo
+}
+
+// HashPassword genera un hash bcrypt de una contraseña con BcryptCost
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
 	if err != nil {
 		return "", err
 	}
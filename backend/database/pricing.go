@@ -0,0 +1,135 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"pizzas-ecos/models"
+)
+
+// ErrProductoInactivo indica que se intentó vender un producto dado de baja
+var ErrProductoInactivo = errors.New("producto inactivo")
+
+// ErrProductoOtraCampana indica que se intentó vender un producto de una
+// campaña distinta a la de la venta
+var ErrProductoOtraCampana = errors.New("el producto pertenece a otra campaña")
+
+// ErrProductoNoEncontrado indica que el producto pedido no existe
+var ErrProductoNoEncontrado = errors.New("producto no encontrado")
+
+// ErrCantidadInvalida indica una línea de venta con cantidad no positiva
+var ErrCantidadInvalida = errors.New("cantidad inválida")
+
+// ErrItemInvalido indica un item de UpdateVenta sin producto_id o cantidad
+// numéricos
+var ErrItemInvalido = errors.New("item de venta inválido")
+
+// ItemRechazadoError indica qué item de la venta (y qué producto) no se puede
+// vender. Err es ErrProductoNoEncontrado, ErrProductoInactivo,
+// ErrProductoOtraCampana o ErrCantidadInvalida
+type ItemRechazadoError struct {
+	Item       int
+	ProductoID int
+	Err        error
+}
+
+func (e *ItemRechazadoError) Error() string {
+	return fmt.Sprintf("item %d (producto_id %d): %v", e.Item, e.ProductoID, e.Err)
+}
+
+// Unwrap permite errors.Is con el motivo del rechazo
+func (e *ItemRechazadoError) Unwrap() error { return e.Err }
+
+// PrecioItem es la única regla de precios de una línea de venta: el precio
+// unitario sale siempre de productos.precio y el subtotal es cantidad * precio.
+// Rechaza productos inactivos y cantidades no positivas. El item retornado
+// tiene Precio y Total del servidor; si el cliente envió valores distintos
+// (distintos de cero) se informa la diferencia
+func PrecioItem(producto *models.Producto, item models.ProductoItem) (models.ProductoItem, *models.PrecioDiferencia, error) {
+	if !producto.Activo {
+		return item, nil, fmt.Errorf("%w: %d", ErrProductoInactivo, producto.ID)
+	}
+	if item.Cantidad <= 0 {
+		return item, nil, fmt.Errorf("%w para producto %d: %d", ErrCantidadInvalida, producto.ID, item.Cantidad)
+	}
+
+	resuelto := item
+	resuelto.ProductID = producto.ID
+	resuelto.Precio = producto.Precio
+	resuelto.Total = subtotalLinea(producto.Precio, item.Cantidad)
+
+	var diff *models.PrecioDiferencia
+	if (item.Precio != 0 && !mismoMonto(item.Precio, resuelto.Precio)) ||
+		(item.Total != 0 && !mismoMonto(item.Total, resuelto.Total)) {
+		diff = &models.PrecioDiferencia{
+			ProductoID:     producto.ID,
+			PrecioEnviado:  item.Precio,
+			PrecioCatalogo: resuelto.Precio,
+			TotalEnviado:   item.Total,
+			TotalCalculado: resuelto.Total,
+		}
+	}
+
+	return resuelto, diff, nil
+}
+
+// subtotalLinea calcula el subtotal redondeado a centavos (DECIMAL(10,2))
+func subtotalLinea(precio float64, cantidad int) float64 {
+	return math.Round(precio*float64(cantidad)*100) / 100
+}
+
+// mismoMonto compara dos montos a nivel de centavo
+func mismoMonto(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+// RedondearMonto lleva un monto a centavos, como lo guarda DECIMAL(10,2)
+func RedondearMonto(monto float64) float64 {
+	return math.Round(monto*100) / 100
+}
+
+// SaldoPendiente retorna lo que falta cobrar de total, redondeado a centavos.
+// Es negativo si lo pagado excede el total
+func SaldoPendiente(total, pagado float64) float64 {
+	saldo := RedondearMonto(total - pagado)
+	if mismoMonto(saldo, 0) {
+		return 0
+	}
+	return saldo
+}
+
+// lineaVenta es un item de UpdateVenta ya leído del JSON. DetalleID 0 es una
+// línea nueva
+type lineaVenta struct {
+	DetalleID  int
+	ProductoID int
+	Cantidad   int
+}
+
+// leerLineasVenta convierte los items de UpdateVenta ({"detalle_id",
+// "producto_id", "cantidad"} decodificados de JSON) antes de escribir nada.
+// Un campo faltante o no numérico es ErrItemInvalido
+func leerLineasVenta(productos []map[string]interface{}) ([]lineaVenta, error) {
+	lineas := make([]lineaVenta, 0, len(productos))
+	for i, p := range productos {
+		productoID, ok := p["producto_id"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("%w: item %d sin producto_id numérico", ErrItemInvalido, i)
+		}
+		cantidad, ok := p["cantidad"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("%w: item %d sin cantidad numérica", ErrItemInvalido, i)
+		}
+		linea := lineaVenta{ProductoID: int(productoID), Cantidad: int(cantidad)}
+		if raw, ok := p["detalle_id"]; ok && raw != nil {
+			detalleID, ok := raw.(float64)
+			if !ok {
+				return nil, fmt.Errorf("%w: item %d con detalle_id no numérico", ErrItemInvalido, i)
+			}
+			linea.DetalleID = int(detalleID)
+		}
+		lineas = append(lineas, linea)
+	}
+	return lineas, nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"pizzas-ecos/models"
+)
+
+// TestPrecioItem verifica la regla de precios de una línea de venta
+func TestPrecioItem(t *testing.T) {
+	activo := &models.Producto{ID: 1, Precio: 10.5, Activo: true}
+	inactivo := &models.Producto{ID: 2, Precio: 8, Activo: false}
+
+	tests := []struct {
+		name       string
+		producto   *models.Producto
+		item       models.ProductoItem
+		wantTotal  float64
+		wantDiff   bool
+		wantErr    error
+		wantAnyErr bool
+	}{
+		{"sin precio enviado", activo, models.ProductoItem{ProductID: 1, Cantidad: 3}, 31.5, false, nil, false},
+		{"precio enviado correcto", activo, models.ProductoItem{ProductID: 1, Cantidad: 2, Precio: 10.5, Total: 21}, 21, false, nil, false},
+		{"precio enviado distinto", activo, models.ProductoItem{ProductID: 1, Cantidad: 2, Precio: 1, Total: 2}, 21, true, nil, false},
+		{"producto inactivo", inactivo, models.ProductoItem{ProductID: 2, Cantidad: 1}, 0, false, ErrProductoInactivo, true},
+		{"cantidad cero", activo, models.ProductoItem{ProductID: 1, Cantidad: 0}, 0, false, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			item, diff, err := PrecioItem(tt.producto, tt.item)
+
+			// Assert
+			if tt.wantAnyErr {
+				if err == nil {
+					t.Fatal("PrecioItem() expected error but got none")
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("PrecioItem() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PrecioItem() unexpected error: %v", err)
+			}
+			if item.Precio != tt.producto.Precio || item.Total != tt.wantTotal {
+				t.Errorf("PrecioItem() = %+v, want precio %v total %v", item, tt.producto.Precio, tt.wantTotal)
+			}
+			if (diff != nil) != tt.wantDiff {
+				t.Errorf("PrecioItem() diff = %+v, want diff %v", diff, tt.wantDiff)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"pizzas-ecos/models"
+)
+
+// VentaRepository define el acceso a datos de ventas y sus detalles
+type VentaRepository interface {
+	InsertVenta(campanaID int, clienteID *int, vendedorID int, total float64, payment, estado, tipoEntrega string, createdBy *int) (int, error)
+	InsertDetalle(ventaID int, item models.ProductoItem) error
+	UpdateVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}, updatedBy *int) error
+	UpdateVentaClienteID(ventaID int, clienteID int) error
+	GetAllVentas(filtro models.VentaFiltro) ([]models.VentaStats, string, error)
+	GetVentaByID(id int) (*models.VentaStats, error)
+	DeleteVenta(id int) error
+	GetEstadoVenta(ventaID int) (models.EstadoVenta, error)
+	RegistrarTransicionVenta(ventaID int, estado models.EstadoVenta, at time.Time) error
+	UpdateEstadoVenta(ventaID int, estado models.EstadoVenta) error
+	InsertPago(ventaID int, monto float64, metodo, registradoPor string) (int, error)
+	GetPagosVenta(ventaID int) ([]models.Pago, error)
+	GetSaldoVenta(ventaID int) (total, pagado float64, err error)
+	ReservarClaveIdempotencia(clave, requestHash string, ahora, expira time.Time) (hash string, ventaID int, respuesta json.RawMessage, err error)
+	CompletarClaveIdempotencia(clave string, ventaID int, respuesta json.RawMessage) error
+	GetResumen(campanaID int) (map[string]interface{}, error)
+	GetVendedoresConStats(campanaID int) ([]map[string]interface{}, error)
+	ExistsVenta(ctx context.Context, id int) (bool, error)
+	ClearDetalleVentas() error
+	ClearVentas() error
+}
+
+// ProductoRepository define el acceso a datos de productos
+type ProductoRepository interface {
+	GetProductos(campanaID int) ([]models.Producto, error)
+	GetAllProductos() ([]models.Producto, error)
+	GetProductoByID(id int) (*models.Producto, error)
+	CreateProducto(campanaID int, tipoPizza, descripcion string, precio float64, createdBy *int) (int64, error)
+	UpdateProducto(id int, tipoPizza, descripcion string, precio float64, activo bool, updatedBy *int) error
+	DeleteProducto(id int, updatedBy *int) error
+	GetHistorialPrecios(productoID int) ([]models.PrecioProducto, error)
+	ExistsProducto(ctx context.Context, id int) (bool, error)
+	ClearProductos() error
+}
+
+// VendedorRepository define el acceso a datos de vendedores
+type VendedorRepository interface {
+	GetVendedores() ([]models.Vendedor, error)
+	GetVendedorByID(id int) (*models.Vendedor, error)
+	GetVendedorID(nombre string) (int, error)
+	CreateVendedor(nombre string, createdBy *int) (int64, error)
+	UpdateVendedor(id int, nombre string, updatedBy *int) error
+	DeleteVendedor(id int) error
+	ExistsVendedor(ctx context.Context, id int) (bool, error)
+	ClearVendedores() error
+}
+
+// ClienteRepository define el acceso a datos de clientes
+type ClienteRepository interface {
+	GetClientesPorVendedor() (map[string][]models.Cliente, error)
+	GetAllClientes() ([]models.Cliente, error)
+	GetClienteByID(id int) (*models.Cliente, error)
+	BuscarClientes(prefijo string, limit int) ([]models.Cliente, error)
+	GetOrCreateCliente(nombre string) (int, error)
+	GetClienteByNombre(nombre string) (int, string, bool, error)
+	CreateClienteWithTelefono(nombre string, telefono *string) (int, error)
+	UpdateClienteTelefono(id int, telefono *string) error
+	UpdateCliente(id int, nombre string, telefono *string) error
+	DeleteCliente(id int) error
+	ReasignarVentasCliente(desde, hacia int) (int, error)
+	ContarVentasCliente(id int) (int, error)
+	ExistsCliente(ctx context.Context, id int) (bool, error)
+	ClearClientes() error
+}
+
+// UsuarioRepository define el acceso a datos de usuarios
+type UsuarioRepository interface {
+	GetUserByCredentials(username, plainPassword string) (*models.User, error)
+	GetAllUsers() ([]models.User, error)
+	GetUserByID(id int) (*models.User, error)
+	UserExists(username string) (bool, error)
+	CreateUser(username, password, rol string, createdBy *int) (int, error)
+	UpdateUser(id int, username, password, rol string, updatedBy *int) error
+	DeleteUser(id int) error
+}
+
+// AuditoriaRepository define el acceso al registro de cambios
+type AuditoriaRepository interface {
+	InsertAuditoria(entrada models.Auditoria) (int, error)
+	GetAuditoria(filtro models.AuditoriaFiltro) ([]models.Auditoria, error)
+	UpdateAuditoriaCambios(id int, cambios json.RawMessage) error
+}
+
+// SesionRepository define el acceso a refresh tokens y revocaciones
+type SesionRepository interface {
+	InsertRefreshToken(token models.RefreshToken) (int, error)
+	GetRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	RevocarRefreshToken(id int, at time.Time) error
+	RevocarFamiliaRefresh(familia string, at time.Time) error
+	RevocarAccessToken(jti string, expira, ahora time.Time) error
+	RevocarSesionesUsuario(usuarioID int, at time.Time) error
+	TokenRevocado(jti string, usuarioID int, emitido time.Time) (bool, error)
+	InsertPasswordReset(reset models.PasswordReset) (int, error)
+	GetPasswordReset(tokenHash string) (*models.PasswordReset, error)
+	MarcarPasswordResetUsado(id int, at time.Time) error
+}
+
+// IntentoLoginRepository define el acceso a los intentos fallidos de login
+type IntentoLoginRepository interface {
+	GetIntentoLogin(tipo, valor string) (*models.IntentoLogin, error)
+	GuardarIntentoLogin(intento models.IntentoLogin) error
+	BorrarIntentoLogin(tipo, valor string) (bool, error)
+}
+
+// CampanaRepository define el acceso a las campañas
+type CampanaRepository interface {
+	GetCampanas() ([]models.Campana, error)
+	GetCampanaByID(id int) (*models.Campana, error)
+	GetCampanaActiva() (*models.Campana, error)
+	CreateCampana(c models.Campana, createdBy *int) (int, error)
+	UpdateCampana(c models.Campana, updatedBy *int) error
+}
+
+// Store agrupa los repositorios y es la unidad de trabajo de la aplicación:
+// los repositorios obtenidos del Store que recibe fn dentro de WithTx se
+// confirman o revierten juntos
+type Store interface {
+	Ventas() VentaRepository
+	Productos() ProductoRepository
+	Vendedores() VendedorRepository
+	Clientes() ClienteRepository
+	Usuarios() UsuarioRepository
+	Auditoria() AuditoriaRepository
+	Sesiones() SesionRepository
+	IntentosLogin() IntentoLoginRepository
+	Campanas() CampanaRepository
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"pizzas-ecos/models"
+)
+
+// InsertRefreshToken guarda un refresh token (ya hasheado) y borra los
+// vencidos del mismo usuario
+func InsertRefreshToken(q Querier, t models.RefreshToken) (int, error) {
+	if _, err := q.Exec(
+		"DELETE FROM refresh_tokens WHERE usuario_id = ? AND expires_at < ?",
+		t.UsuarioID, t.CreatedAt,
+	); err != nil {
+		return 0, err
+	}
+
+	res, err := q.Exec(`
+		INSERT INTO refresh_tokens (usuario_id, token_hash, familia, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, t.UsuarioID, t.TokenHash, t.Familia, t.ExpiresAt, t.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetRefreshToken busca un refresh token por hash y bloquea la fila para que
+// dos rotaciones simultáneas del mismo token no puedan ganar ambas. Retorna
+// sql.ErrNoRows si no existe
+func GetRefreshToken(q Querier, tokenHash string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	var revocado sql.NullTime
+	err := q.QueryRow(`
+		SELECT id, usuario_id, token_hash, familia, expires_at, revocado_at, created_at
+		FROM refresh_tokens WHERE token_hash = ? FOR UPDATE
+	`, tokenHash).Scan(&t.ID, &t.UsuarioID, &t.TokenHash, &t.Familia, &t.ExpiresAt, &revocado, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revocado.Valid {
+		at := revocado.Time
+		t.RevocadoAt = &at
+	}
+	return &t, nil
+}
+
+// RevocarRefreshToken marca un refresh token como usado o revocado
+func RevocarRefreshToken(q Querier, id int, at time.Time) error {
+	_, err := q.Exec("UPDATE refresh_tokens SET revocado_at = ? WHERE id = ? AND revocado_at IS NULL", at, id)
+	return err
+}
+
+// RevocarFamiliaRefresh revoca todos los refresh tokens de una familia
+func RevocarFamiliaRefresh(q Querier, familia string, at time.Time) error {
+	_, err := q.Exec("UPDATE refresh_tokens SET revocado_at = ? WHERE familia = ? AND revocado_at IS NULL", at, familia)
+	return err
+}
+
+// RevocarAccessToken agrega el jti a la lista de revocados hasta que el token
+// expire, y limpia los que ya expiraron
+func RevocarAccessToken(q Querier, jti string, expira, ahora time.Time) error {
+	if _, err := q.Exec("DELETE FROM tokens_revocados WHERE expires_at < ?", ahora); err != nil {
+		return err
+	}
+	_, err := q.Exec("INSERT IGNORE INTO tokens_revocados (jti, expires_at) VALUES (?, ?)", jti, expira)
+	return err
+}
+
+// RevocarSesionesUsuario invalida los access tokens emitidos hasta at y todos
+// los refresh tokens del usuario. Retorna sql.ErrNoRows si el usuario no existe
+func RevocarSesionesUsuario(q Querier, usuarioID int, at time.Time) error {
+	// Milisegundos enteros como iat_ms: MySQL redondearía el resto de la fracción
+	at = at.Truncate(time.Millisecond)
+	var id int
+	if err := q.QueryRow("SELECT id FROM usuarios WHERE id = ? FOR UPDATE", usuarioID).Scan(&id); err != nil {
+		return err
+	}
+	if _, err := q.Exec("UPDATE usuarios SET sesiones_desde = ? WHERE id = ?", at, usuarioID); err != nil {
+		return err
+	}
+	_, err := q.Exec("UPDATE refresh_tokens SET revocado_at = ? WHERE usuario_id = ? AND revocado_at IS NULL", at, usuarioID)
+	return err
+}
+
+// TokenRevocado indica si un access token válido ya no debe aceptarse: su jti
+// fue revocado, el usuario cerró todas sus sesiones después de emitirlo o el
+// usuario ya no existe
+func TokenRevocado(q Querier, jti string, usuarioID int, emitido time.Time) (bool, error) {
+	var revocado bool
+	var desde sql.NullTime
+	err := q.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM tokens_revocados WHERE jti = ?), u.sesiones_desde
+		FROM usuarios u WHERE u.id = ?
+	`, jti, usuarioID).Scan(&revocado, &desde)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if desde.Valid && !emitido.After(desde.Time) {
+		return true, nil
+	}
+	return revocado, nil
+}
+
+// InsertPasswordReset guarda un token de restablecimiento (ya hasheado) e
+// invalida los anteriores del usuario que no se usaron
+func InsertPasswordReset(q Querier, p models.PasswordReset) (int, error) {
+	if _, err := q.Exec(
+		"UPDATE password_resets SET usado_at = ? WHERE usuario_id = ? AND usado_at IS NULL",
+		p.CreatedAt, p.UsuarioID,
+	); err != nil {
+		return 0, err
+	}
+
+	res, err := q.Exec(`
+		INSERT INTO password_resets (usuario_id, token_hash, expires_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, p.UsuarioID, p.TokenHash, p.ExpiresAt, p.CreatedBy, p.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// GetPasswordReset busca un token de restablecimiento por hash y bloquea la
+// fila para que no se pueda canjear dos veces. Retorna sql.ErrNoRows si no existe
+func GetPasswordReset(q Querier, tokenHash string) (*models.PasswordReset, error) {
+	var p models.PasswordReset
+	var usado sql.NullTime
+	var createdBy sql.NullInt64
+	err := q.QueryRow(`
+		SELECT id, usuario_id, token_hash, expires_at, usado_at, created_by, created_at
+		FROM password_resets WHERE token_hash = ? FOR UPDATE
+	`, tokenHash).Scan(&p.ID, &p.UsuarioID, &p.TokenHash, &p.ExpiresAt, &usado, &createdBy, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if usado.Valid {
+		at := usado.Time
+		p.UsadoAt = &at
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		p.CreatedBy = &id
+	}
+	return &p, nil
+}
+
+// MarcarPasswordResetUsado consume un token de restablecimiento
+func MarcarPasswordResetUsado(q Querier, id int, at time.Time) error {
+	_, err := q.Exec("UPDATE password_resets SET usado_at = ? WHERE id = ? AND usado_at IS NULL", at, id)
+	return err
+}
@@ -8,14 +8,30 @@ import (
 	"pizzas-ecos/logger"
 )
 
+// Querier es el subconjunto de operaciones común a *sql.DB, *sql.Tx y *Transaction.
+// Todas las funciones de acceso a datos lo reciben, de modo que el caller decide
+// si se ejecutan sobre la conexión global o dentro de una transacción
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 // Transaction wrapper para manejar transacciones de forma segura
 type Transaction struct {
 	tx *sql.Tx
 }
 
-// BeginTx inicia una nueva transacción
+// BeginTx inicia una nueva transacción sobre la conexión global
 func BeginTx(ctx context.Context) (*Transaction, error) {
-	tx, err := DB.BeginTx(ctx, &sql.TxOptions{
+	return beginTx(ctx, DB)
+}
+
+func beginTx(ctx context.Context, db *sql.DB) (*Transaction, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
 	if err != nil {
@@ -65,6 +81,48 @@ func (t *Transaction) QueryRow(query string, args ...interface{}) *sql.Row {
 	return t.tx.QueryRow(query, args...)
 }
 
+// ExecContext ejecuta una query en la transacción respetando el contexto
+func (t *Transaction) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+// QueryContext ejecuta una query de lectura en la transacción respetando el contexto
+func (t *Transaction) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext ejecuta una query que retorna una fila respetando el contexto
+func (t *Transaction) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+// WithTransaction ejecuta fn como una unidad de trabajo: si fn retorna error o
+// entra en pánico se hace rollback de todo lo escrito, de lo contrario commit
+func WithTransaction(ctx context.Context, fn func(tx *Transaction) error) error {
+	return withTransaction(ctx, DB, fn)
+}
+
+func withTransaction(ctx context.Context, db *sql.DB, fn func(tx *Transaction) error) error {
+	tx, err := beginTx(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p) // Re-lanzar pánico después de rollback
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // ValidateData contiene validaciones de datos
 type ValidateData struct {
 	Errors []string
@@ -140,29 +198,29 @@ func ValidateVendedor(nombre string) *ValidateData {
 }
 
 // ExistsVendedor verifica si un vendedor existe
-func ExistsVendedor(ctx context.Context, id int) (bool, error) {
+func ExistsVendedor(ctx context.Context, q Querier, id int) (bool, error) {
 	var count int
-	err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM vendedores WHERE id = ?", id).Scan(&count)
+	err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM vendedores WHERE id = ?", id).Scan(&count)
 	return count > 0, err
 }
 
 // ExistsCliente verifica si un cliente existe
-func ExistsCliente(ctx context.Context, id int) (bool, error) {
+func ExistsCliente(ctx context.Context, q Querier, id int) (bool, error) {
 	var count int
-	err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM clientes WHERE id = ?", id).Scan(&count)
+	err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM clientes WHERE id = ?", id).Scan(&count)
 	return count > 0, err
 }
 
 // ExistsProducto verifica si un producto existe
-func ExistsProducto(ctx context.Context, id int) (bool, error) {
+func ExistsProducto(ctx context.Context, q Querier, id int) (bool, error) {
 	var count int
-	err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM productos WHERE id = ?", id).Scan(&count)
+	err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM productos WHERE id = ?", id).Scan(&count)
 	return count > 0, err
 }
 
 // ExistsVenta verifica si una venta existe
-func ExistsVenta(ctx context.Context, id int) (bool, error) {
+func ExistsVenta(ctx context.Context, q Querier, id int) (bool, error) {
 	var count int
-	err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM ventas WHERE id = ?", id).Scan(&count)
+	err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM ventas WHERE id = ?", id).Scan(&count)
 	return count > 0, err
 }
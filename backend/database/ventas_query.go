@@ -0,0 +1,189 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pizzas-ecos/models"
+)
+
+// ErrFiltroInvalido indica un orden o cursor de listado que no se puede usar
+var ErrFiltroInvalido = errors.New("filtro inválido")
+
+// MaxLimitVentas es el tamaño máximo de página del listado de ventas
+const MaxLimitVentas = 500
+
+// ordenVentas describe un orden permitido. Las columnas salen solo de este
+// mapa, nunca del request, así que pueden ir concatenadas en el SQL
+type ordenVentas struct {
+	columna string
+	desc    bool
+}
+
+var ordenesVentas = map[string]ordenVentas{
+	"fecha":  {columna: "v.created_at", desc: false},
+	"-fecha": {columna: "v.created_at", desc: true},
+	"total":  {columna: "v.total", desc: false},
+	"-total": {columna: "v.total", desc: true},
+}
+
+const ordenVentasDefault = "-fecha"
+
+// ventaCursor es la posición (valor de la columna de orden, id) de la última
+// venta entregada. Se serializa como base64 opaco para el cliente
+type ventaCursor struct {
+	Orden string    `json:"o"`
+	Fecha time.Time `json:"f,omitempty"`
+	Total float64   `json:"t,omitempty"`
+	ID    int       `json:"id"`
+}
+
+func resolverOrden(orden string) (string, ordenVentas, error) {
+	if orden == "" {
+		orden = ordenVentasDefault
+	}
+	o, ok := ordenesVentas[orden]
+	if !ok {
+		return "", o, fmt.Errorf("%w: orden %q no soportado", ErrFiltroInvalido, orden)
+	}
+	return orden, o, nil
+}
+
+func encodeVentaCursor(orden string, v models.VentaStats) string {
+	data, _ := json.Marshal(ventaCursor{Orden: orden, Fecha: v.CreatedAt, Total: v.Total, ID: v.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeVentaCursor valida el cursor y que corresponda al mismo orden con el
+// que se generó
+func decodeVentaCursor(cursor, orden string) (*ventaCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cursor mal formado", ErrFiltroInvalido)
+	}
+	var c ventaCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID <= 0 {
+		return nil, fmt.Errorf("%w: cursor mal formado", ErrFiltroInvalido)
+	}
+	if c.Orden != orden {
+		return nil, fmt.Errorf("%w: el cursor pertenece a otro orden", ErrFiltroInvalido)
+	}
+	return &c, nil
+}
+
+// ventasQuery arma el SELECT de cabeceras de ventas. Todo valor del usuario
+// va como argumento (?); solo se concatenan fragmentos fijos
+type ventasQuery struct {
+	where []string
+	args  []interface{}
+}
+
+func (b *ventasQuery) add(cond string, args ...interface{}) {
+	b.where = append(b.where, cond)
+	b.args = append(b.args, args...)
+}
+
+// escapeLike escapa los comodines de LIKE para buscar el texto literal
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// buildVentasQuery retorna la query y sus argumentos para el filtro indicado,
+// junto con el orden resuelto (necesario para generar el siguiente cursor)
+func buildVentasQuery(f models.VentaFiltro) (string, []interface{}, string, error) {
+	orden, o, err := resolverOrden(f.Orden)
+	if err != nil {
+		return "", nil, "", err
+	}
+	cursor, err := decodeVentaCursor(f.Cursor, orden)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if f.Limit < 0 || f.Limit > MaxLimitVentas {
+		return "", nil, "", fmt.Errorf("%w: limit debe estar entre 1 y %d", ErrFiltroInvalido, MaxLimitVentas)
+	}
+
+	b := &ventasQuery{}
+	if f.CampanaID != 0 {
+		b.add("v.campana_id = ?", f.CampanaID)
+	}
+	if !f.IncluirCanceladas {
+		b.add("v.estado != 'cancelada'")
+	}
+	if f.Desde != nil {
+		b.add("v.created_at >= ?", *f.Desde)
+	}
+	if f.Hasta != nil {
+		b.add("v.created_at < ?", *f.Hasta)
+	}
+	if f.Vendedor != "" {
+		b.add("ve.nombre = ?", f.Vendedor)
+	}
+	if f.Cliente != "" {
+		b.add("c.nombre LIKE ?", "%"+escapeLike(f.Cliente)+"%")
+	}
+	if f.ClienteID != 0 {
+		b.add("v.cliente_id = ?", f.ClienteID)
+	}
+	if f.Estado != "" {
+		b.add("v.estado = ?", f.Estado)
+	}
+	if f.PaymentMethod != "" {
+		b.add("v.payment_method = ?", f.PaymentMethod)
+	}
+	if f.TipoEntrega != "" {
+		b.add("v.tipo_entrega = ?", f.TipoEntrega)
+	}
+
+	cmp, dir := ">", "ASC"
+	if o.desc {
+		cmp, dir = "<", "DESC"
+	}
+	if cursor != nil {
+		var valor interface{} = cursor.Fecha
+		if o.columna == "v.total" {
+			valor = cursor.Total
+		}
+		b.add("("+o.columna+" "+cmp+" ? OR ("+o.columna+" = ? AND v.id "+cmp+" ?))", valor, valor, cursor.ID)
+	}
+
+	query := `
+		SELECT v.id, v.campana_id, ve.nombre, COALESCE(c.nombre, 'Sin cliente'),
+		       c.telefono, v.total, v.payment_method, v.estado, v.tipo_entrega, v.created_at,
+		       v.pagada_at, v.entregada_at, v.cancelada_at,
+		       (SELECT COALESCE(SUM(p.monto), 0) FROM pagos p WHERE p.venta_id = v.id),
+		       COALESCE(u.username, '')
+		FROM ventas v
+		JOIN vendedores ve ON v.vendedor_id = ve.id
+		LEFT JOIN clientes c ON v.cliente_id = c.id
+		LEFT JOIN usuarios u ON v.created_by = u.id`
+	if len(b.where) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(b.where, " AND ")
+	}
+	query += "\n\t\tORDER BY " + o.columna + " " + dir + ", v.id " + dir
+
+	// Se pide una fila de más para saber si hay página siguiente
+	if f.Limit > 0 {
+		query += "\n\t\tLIMIT ?"
+		b.args = append(b.args, f.Limit+1)
+	}
+
+	return query, b.args, orden, nil
+}
+
+// paginarVentas recorta la fila extra pedida por buildVentasQuery y genera el
+// cursor de la página siguiente ("" si es la última)
+func paginarVentas(ventas []models.VentaStats, limit int, orden string) ([]models.VentaStats, string) {
+	if limit <= 0 || len(ventas) <= limit {
+		return ventas, ""
+	}
+	ventas = ventas[:limit]
+	return ventas, encodeVentaCursor(orden, ventas[limit-1])
+}
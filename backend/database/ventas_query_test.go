@@ -0,0 +1,110 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"pizzas-ecos/models"
+)
+
+// TestBuildVentasQuery verifica que los valores del usuario nunca se concatenen en el SQL
+func TestBuildVentasQuery(t *testing.T) {
+	// Arrange
+	filtro := models.VentaFiltro{
+		Vendedor: "x' OR '1'='1",
+		Cliente:  "50%_off",
+		Estado:   "pagada",
+		Orden:    "total",
+		Limit:    10,
+	}
+
+	// Act
+	query, args, orden, err := buildVentasQuery(filtro)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("buildVentasQuery() error = %v", err)
+	}
+	if strings.Contains(query, filtro.Vendedor) || strings.Contains(query, "'pagada'") {
+		t.Errorf("la query contiene valores del usuario: %s", query)
+	}
+	if strings.Count(query, "?") != len(args) {
+		t.Errorf("placeholders = %d, args = %d", strings.Count(query, "?"), len(args))
+	}
+	if args[1] != `%50\%\_off%` {
+		t.Errorf("cliente arg = %v, want comodines escapados", args[1])
+	}
+	if args[len(args)-1] != 11 {
+		t.Errorf("limit arg = %v, want 11 (una fila extra)", args[len(args)-1])
+	}
+	if orden != "total" || !strings.Contains(query, "ORDER BY v.total ASC, v.id ASC") {
+		t.Errorf("orden = %s, query = %s", orden, query)
+	}
+}
+
+// TestBuildVentasQuery_Invalidos verifica el rechazo de orden, cursor y limit inválidos
+func TestBuildVentasQuery_Invalidos(t *testing.T) {
+	cursorFecha := encodeVentaCursor("-fecha", models.VentaStats{ID: 3})
+
+	tests := []struct {
+		name   string
+		filtro models.VentaFiltro
+	}{
+		{"orden desconocido", models.VentaFiltro{Orden: "v.id; DROP TABLE ventas"}},
+		{"cursor mal formado", models.VentaFiltro{Cursor: "no-es-un-cursor"}},
+		{"cursor de otro orden", models.VentaFiltro{Orden: "total", Cursor: cursorFecha}},
+		{"limit excesivo", models.VentaFiltro{Limit: MaxLimitVentas + 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := buildVentasQuery(tt.filtro); !errors.Is(err, ErrFiltroInvalido) {
+				t.Errorf("buildVentasQuery() error = %v, want ErrFiltroInvalido", err)
+			}
+		})
+	}
+}
+
+// TestMemoryStore_GetAllVentasPaginado recorre todas las páginas con el cursor
+func TestMemoryStore_GetAllVentasPaginado(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	vendedorID, _ := store.Vendedores().CreateVendedor("Juan Pérez", nil)
+	for _, total := range []float64{30, 10, 20, 10, 40} {
+		store.Ventas().InsertVenta(1, nil, int(vendedorID), total, "efectivo", "sin_pagar", "retiro", nil)
+	}
+
+	// Act
+	var ids []int
+	var totales []float64
+	cursor := ""
+	for paginas := 0; ; paginas++ {
+		if paginas > 5 {
+			t.Fatal("la paginación no termina")
+		}
+		ventas, next, err := store.Ventas().GetAllVentas(models.VentaFiltro{Orden: "total", Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("GetAllVentas() error = %v", err)
+		}
+		for _, v := range ventas {
+			ids = append(ids, v.ID)
+			totales = append(totales, v.Total)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	// Assert: empates en total se desempatan por id
+	wantIDs := []int{2, 4, 3, 1, 5}
+	if len(ids) != len(wantIDs) {
+		t.Fatalf("ids = %v, want %v (totales %v)", ids, wantIDs, totales)
+	}
+	for i := range wantIDs {
+		if ids[i] != wantIDs[i] {
+			t.Fatalf("ids = %v, want %v", ids, wantIDs)
+		}
+	}
+}
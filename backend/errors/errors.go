@@ -16,9 +16,10 @@ type ResponseError struct {
 
 // ResponseSuccess es la estructura estándar para respuestas exitosas
 type ResponseSuccess struct {
-	Status  int         `json:"status"`
-	Data    interface{} `json:"data"`
-	Message string      `json:"message,omitempty"`
+	Status     int         `json:"status"`
+	Data       interface{} `json:"data"`
+	Message    string      `json:"message,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
 // CustomError es un error personalizado con código
@@ -56,6 +57,16 @@ var (
 		Message:  "Recurso en conflicto",
 		HTTPCode: http.StatusConflict,
 	}
+	ErrUnprocessable = CustomError{
+		Code:     "UNPROCESSABLE_ENTITY",
+		Message:  "Solicitud no procesable",
+		HTTPCode: http.StatusUnprocessableEntity,
+	}
+	ErrTooManyRequests = CustomError{
+		Code:     "TOO_MANY_REQUESTS",
+		Message:  "Demasiados intentos",
+		HTTPCode: http.StatusTooManyRequests,
+	}
 	ErrServerError = CustomError{
 		Code:     "INTERNAL_SERVER_ERROR",
 		Message:  "Error interno del servidor",
@@ -95,6 +106,20 @@ func WriteSuccess(w http.ResponseWriter, statusCode int, data interface{}, messa
 	json.NewEncoder(w).Encode(resp)
 }
 
+// WritePage escribe una página de un listado; nextCursor vacío indica que es la última
+func WritePage(w http.ResponseWriter, statusCode int, data interface{}, nextCursor string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	resp := ResponseSuccess{
+		Status:     statusCode,
+		Data:       data,
+		NextCursor: nextCursor,
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
 // WriteJSON escribe JSON directamente (para backward compatibility)
 func WriteJSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
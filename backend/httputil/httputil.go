@@ -1,13 +1,23 @@
 package httputil
 
 import (
+	"context"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"pizzas-ecos/models"
 )
 
 // contextKey para almacenar parámetros
 type contextKey string
 
-const PathParamsKey contextKey = "path_params"
+const (
+	PathParamsKey contextKey = "path_params"
+	PrincipalKey  contextKey = "principal"
+)
 
 // PathParams contiene parámetros extraídos de la ruta
 type PathParams map[string]string
@@ -20,3 +30,112 @@ func GetParam(r *http.Request, key string) string {
 	}
 	return params[key]
 }
+
+// GetPrincipal obtiene el usuario autenticado desde el request context, o nil
+// si el request no trae un token válido
+func GetPrincipal(r *http.Request) *models.Principal {
+	principal, _ := r.Context().Value(PrincipalKey).(*models.Principal)
+	return principal
+}
+
+// WithPrincipal retorna r con el usuario autenticado en el context
+func WithPrincipal(r *http.Request, principal *models.Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), PrincipalKey, principal))
+}
+
+// ClientIP retorna la IP del cliente. X-Forwarded-For solo se usa si el
+// request llega desde un proxy de TRUSTED_PROXIES; si no, lo puede mandar el
+// propio cliente y se usa RemoteAddr
+func ClientIP(r *http.Request) string {
+	return clientIP(r, proxiesConfiables())
+}
+
+// proxies es la lista de TRUSTED_PROXIES: IPs o CIDRs separados por coma, o
+// "*" para confiar en cualquier par (solo si el servicio es accesible
+// únicamente a través del proxy, como en Cloud Run). Vacía = ningún proxy
+type proxies struct {
+	todos bool
+	redes []*net.IPNet
+}
+
+var (
+	proxiesOnce   sync.Once
+	proxiesActual proxies
+)
+
+// proxiesConfiables lee TRUSTED_PROXIES la primera vez que se necesita
+func proxiesConfiables() proxies {
+	proxiesOnce.Do(func() {
+		proxiesActual = parsearProxies(os.Getenv("TRUSTED_PROXIES"))
+	})
+	return proxiesActual
+}
+
+// parsearProxies interpreta TRUSTED_PROXIES; las entradas inválidas se ignoran
+func parsearProxies(valor string) proxies {
+	var p proxies
+	for _, item := range strings.Split(valor, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+		case item == "*":
+			p.todos = true
+		case strings.Contains(item, "/"):
+			if _, red, err := net.ParseCIDR(item); err == nil {
+				p.redes = append(p.redes, red)
+			}
+		default:
+			if ip := net.ParseIP(item); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				p.redes = append(p.redes, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+		}
+	}
+	return p
+}
+
+// confiable indica si ip es uno de los proxies configurados
+func (p proxies) confiable(ip string) bool {
+	if p.todos {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, red := range p.redes {
+		if red.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP implementa ClientIP. Si el par es un proxy confiable recorre
+// X-Forwarded-For de derecha a izquierda salteando proxies confiables: la
+// primera IP que no lo es la agregó un proxy propio y es la del cliente
+func clientIP(r *http.Request, p proxies) string {
+	remota := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remota = host
+	}
+	xff := r.Header.Get("X-Forwarded-For")
+	if xff == "" || !p.confiable(remota) {
+		return remota
+	}
+
+	partes := strings.Split(xff, ",")
+	for i := len(partes) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(partes[i])
+		if ip == "" {
+			continue
+		}
+		if i == 0 || p.todos || !p.confiable(ip) {
+			return ip
+		}
+	}
+	return remota
+}
@@ -0,0 +1,43 @@
+package httputil
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP_SoloConfiaEnXFFDesdeProxiesConfigurados(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    string
+		remoteAddr string
+		xff        string
+		want       string
+	}{
+		{"sin proxies se ignora XFF", "", "203.0.113.7:5000", "1.2.3.4", "203.0.113.7"},
+		{"par no confiable se ignora XFF", "10.0.0.0/8", "203.0.113.7:5000", "1.2.3.4", "203.0.113.7"},
+		{"proxy confiable usa XFF", "10.0.0.0/8", "10.0.0.2:5000", "1.2.3.4", "1.2.3.4"},
+		{"se saltean proxies confiables de XFF", "10.0.0.0/8", "10.0.0.2:5000", "6.6.6.6, 1.2.3.4, 10.0.0.9", "1.2.3.4"},
+		{"IP suelta como proxy", "10.0.0.2", "10.0.0.2:5000", "1.2.3.4", "1.2.3.4"},
+		{"asterisco toma la última de XFF", "*", "169.254.1.1:5000", "6.6.6.6, 1.2.3.4", "1.2.3.4"},
+		{"proxy confiable sin XFF", "10.0.0.0/8", "10.0.0.2:5000", "", "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			r := httptest.NewRequest("POST", "/api/v1/auth/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+
+			// Act
+			got := clientIP(r, parsearProxies(tt.proxies))
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"pizzas-ecos/config"
+	"pizzas-ecos/database"
 	"pizzas-ecos/logger"
 	"pizzas-ecos/middleware"
 	"pizzas-ecos/ratelimit"
@@ -20,6 +21,11 @@ func initDB() error {
 }
 
 func main() {
+	// Subcomandos administrativos: corren y terminan sin levantar el servidor
+	if len(os.Args) > 1 && os.Args[1] == "create-admin" {
+		os.Exit(ejecutarCreateAdmin(os.Args[2:], os.Stdin, os.Stdout))
+	}
+
 	// v2: CORS middleware fully enabled - no origin restrictions
 	// All origins allowed for testing
 
@@ -28,9 +34,14 @@ func main() {
 		log.Fatalf("❌ Error inicializando BD: %v", err)
 	}
 
+	// Claves JWT: sin secreto configurado solo se arranca en modo local
+	if err := middleware.CargarClaves(); err != nil {
+		log.Fatalf("❌ Error cargando claves JWT: %v", err)
+	}
+
 	// 2. Router
 	mux := http.NewServeMux()
-	apiRouter := routes.SetupRoutes()
+	apiRouter := routes.SetupRoutes(database.NewMySQLStore(database.DB))
 	apiRouter.Register(mux)
 
 	logger.Info("Rutas registradas", map[string]interface{}{
@@ -49,11 +60,11 @@ func main() {
 	}
 
 	// 5. Middleware chain (CRITICAL: CORS must be FIRST after Recovery to handle OPTIONS)
+	// La autenticación y los roles los aplica el router según el Acceso de cada ruta
 	handler := http.Handler(mux)
 
 	handler = middleware.RecoveryMiddleware(handler)
 	handler = middleware.CORSMiddleware(corsOrigins)(handler)
-	handler = middleware.AuthMiddleware(handler)
 	handler = middleware.LoggingMiddleware(handler)
 	handler = ratelimit.Middleware(limiter)(handler)
 	handler = security.Middleware(ddosDetector)(handler)
@@ -74,7 +85,7 @@ func main() {
 
 	logger.Info("Servidor iniciado", map[string]interface{}{
 		"port": port,
-		"env":  os.Getenv("GO_ENV"),
+		"env":  os.Getenv("ENV"),
 	})
 
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
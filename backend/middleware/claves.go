@@ -0,0 +1,238 @@
+package middleware
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"pizzas-ecos/logger"
+	"pizzas-ecos/models"
+)
+
+/* =========================
+   CLAVES JWT
+========================= */
+
+// Configuración de las claves de firma:
+//
+//	JWT_ALG   HS256 (por defecto), EdDSA o RS256
+//	JWT_KEYS  "kid:valor,kid:valor". La primera firma y todas verifican, así
+//	          que para rotar se agrega la nueva adelante y la vieja se quita
+//	          cuando vencen sus tokens. Con HS256 valor es el secreto; con
+//	          EdDSA/RS256 es la ruta a un PEM (privado para la primera,
+//	          público o privado para las demás)
+//	JWT_SECRET secreto HS256 único (kid "default") si JWT_KEYS no está
+//
+// Sin claves configuradas solo arranca con ENV=local explícito, con un
+// secreto de desarrollo. ENV vacío no cuenta como local
+
+// kidPorDefecto identifica la clave de JWT_SECRET
+const kidPorDefecto = "default"
+
+// secretoDesarrollo firma tokens en modo local sin JWT_SECRET. Es público:
+// nunca debe usarse fuera de local
+const secretoDesarrollo = "uytrewghbvcxbvnbvnz"
+
+// claveJWT es una clave identificada por kid. firma es nil si solo verifica
+type claveJWT struct {
+	kid          string
+	firma        interface{}
+	verificacion interface{}
+}
+
+// llavero es el conjunto de claves vigentes; activa es la que firma
+type llavero struct {
+	metodo jwt.SigningMethod
+	activa *claveJWT
+	porKid map[string]*claveJWT
+}
+
+var (
+	llaveroMu     sync.Mutex
+	llaveroActual *llavero
+)
+
+// CargarClaves lee las claves JWT del entorno y las deja activas. main la
+// llama al iniciar para que una configuración inválida frene el arranque
+func CargarClaves() error {
+	l, err := leerLlavero(os.Getenv, os.ReadFile)
+	if err != nil {
+		return err
+	}
+	llaveroMu.Lock()
+	llaveroActual = l
+	llaveroMu.Unlock()
+
+	logger.Info("Claves JWT cargadas", map[string]interface{}{
+		"alg":        l.metodo.Alg(),
+		"kid_activo": l.activa.kid,
+		"claves":     len(l.porKid),
+	})
+	return nil
+}
+
+// claves retorna el llavero vigente, cargándolo la primera vez
+func claves() (*llavero, error) {
+	llaveroMu.Lock()
+	defer llaveroMu.Unlock()
+	if llaveroActual == nil {
+		l, err := leerLlavero(os.Getenv, os.ReadFile)
+		if err != nil {
+			return nil, err
+		}
+		llaveroActual = l
+	}
+	return llaveroActual, nil
+}
+
+// leerLlavero arma el llavero a partir de las variables de entorno; getenv y
+// readFile se reciben para poder probarlo sin tocar el entorno real
+func leerLlavero(getenv func(string) string, readFile func(string) ([]byte, error)) (*llavero, error) {
+	alg := getenv("JWT_ALG")
+	if alg == "" {
+		alg = jwt.SigningMethodHS256.Alg()
+	}
+	var metodo jwt.SigningMethod
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		metodo = jwt.SigningMethodHS256
+	case jwt.SigningMethodEdDSA.Alg():
+		metodo = jwt.SigningMethodEdDSA
+	case jwt.SigningMethodRS256.Alg():
+		metodo = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("JWT_ALG %q no soportado (HS256, EdDSA o RS256)", alg)
+	}
+
+	entradas, err := entradasClaves(getenv, metodo)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &llavero{metodo: metodo, porKid: map[string]*claveJWT{}}
+	for i, e := range entradas {
+		if _, repetido := l.porKid[e.kid]; repetido {
+			return nil, fmt.Errorf("JWT_KEYS: kid %q repetido", e.kid)
+		}
+		clave, err := parsearClave(metodo, e.kid, e.valor, readFile)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			if clave.firma == nil {
+				return nil, fmt.Errorf("JWT_KEYS: la clave activa %q debe ser privada", e.kid)
+			}
+			l.activa = clave
+		}
+		l.porKid[e.kid] = clave
+	}
+	return l, nil
+}
+
+type entradaClave struct {
+	kid   string
+	valor string
+}
+
+// entradasClaves retorna las entradas "kid:valor" de JWT_KEYS, o JWT_SECRET
+// como única clave. Sin ninguna de las dos solo acepta ENV=local
+func entradasClaves(getenv func(string) string, metodo jwt.SigningMethod) ([]entradaClave, error) {
+	if lista := getenv("JWT_KEYS"); lista != "" {
+		var entradas []entradaClave
+		for _, item := range strings.Split(lista, ",") {
+			kid, valor, ok := strings.Cut(strings.TrimSpace(item), ":")
+			if !ok || kid == "" || valor == "" {
+				return nil, fmt.Errorf("JWT_KEYS: entrada %q inválida, se espera kid:valor", item)
+			}
+			entradas = append(entradas, entradaClave{kid: kid, valor: valor})
+		}
+		return entradas, nil
+	}
+
+	if metodo != jwt.SigningMethodHS256 {
+		return nil, fmt.Errorf("JWT_ALG %s requiere JWT_KEYS con rutas a claves PEM", metodo.Alg())
+	}
+	if secreto := getenv("JWT_SECRET"); secreto != "" {
+		return []entradaClave{{kid: kidPorDefecto, valor: secreto}}, nil
+	}
+	if env := getenv("ENV"); env != "local" {
+		return nil, fmt.Errorf("JWT_SECRET o JWT_KEYS es obligatorio salvo con ENV=local (ENV=%q)", env)
+	}
+	logger.Warn("JWT_SECRET no configurado, se usa el secreto de desarrollo (solo modo local)", map[string]interface{}{})
+	return []entradaClave{{kid: kidPorDefecto, valor: secretoDesarrollo}}, nil
+}
+
+// parsearClave interpreta valor como secreto (HS256) o como ruta a un PEM
+func parsearClave(metodo jwt.SigningMethod, kid, valor string, readFile func(string) ([]byte, error)) (*claveJWT, error) {
+	if metodo == jwt.SigningMethodHS256 {
+		return &claveJWT{kid: kid, firma: []byte(valor), verificacion: []byte(valor)}, nil
+	}
+
+	pem, err := readFile(valor)
+	if err != nil {
+		return nil, fmt.Errorf("JWT_KEYS: leyendo clave %q: %w", kid, err)
+	}
+
+	clave := &claveJWT{kid: kid}
+	switch metodo {
+	case jwt.SigningMethodEdDSA:
+		if priv, err := jwt.ParseEdPrivateKeyFromPEM(pem); err == nil {
+			clave.firma = priv
+			clave.verificacion = priv.(crypto.Signer).Public()
+		} else if pub, err := jwt.ParseEdPublicKeyFromPEM(pem); err == nil {
+			clave.verificacion = pub
+		}
+	case jwt.SigningMethodRS256:
+		if priv, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+			clave.firma = priv
+			clave.verificacion = &priv.PublicKey
+		} else if pub, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+			clave.verificacion = pub
+		}
+	}
+	if clave.verificacion == nil {
+		return nil, fmt.Errorf("JWT_KEYS: clave %q no es un PEM %s válido", kid, metodo.Alg())
+	}
+	return clave, nil
+}
+
+// firmar firma claims con la clave activa e incluye su kid en el header
+func (l *llavero) firmar(claims models.TokenClaims) (string, error) {
+	token := jwt.NewWithClaims(l.metodo, claims)
+	token.Header["kid"] = l.activa.kid
+	return token.SignedString(l.activa.firma)
+}
+
+// validar verifica firma, algoritmo y vencimiento de un token y retorna sus claims
+func (l *llavero) validar(tokenString string) (*models.TokenClaims, error) {
+	claims := &models.TokenClaims{}
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		l.claveDe,
+		jwt.WithValidMethods([]string{l.metodo.Alg()}),
+	)
+	if err != nil || !token.Valid {
+		return nil, errors.New("Token inválido o expirado")
+	}
+	return claims, nil
+}
+
+// claveDe es el jwt.Keyfunc: elige la clave por el kid del header. Tokens
+// sin kid se verifican con la clave activa
+func (l *llavero) claveDe(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return l.activa.verificacion, nil
+	}
+	clave, ok := l.porKid[kid]
+	if !ok {
+		return nil, errors.New("kid desconocido")
+	}
+	return clave.verificacion, nil
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"pizzas-ecos/models"
+)
+
+// entorno simula variables de entorno para leerLlavero
+func entorno(vars map[string]string) func(string) string {
+	return func(nombre string) string { return vars[nombre] }
+}
+
+// claimsDePrueba son claims vigentes por una hora
+func claimsDePrueba() models.TokenClaims {
+	return models.TokenClaims{
+		UserID:   1,
+		Username: "admin",
+		Rol:      models.RolAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+// escribirClavesEd genera un par EdDSA y retorna las rutas a los PEM
+func escribirClavesEd(t *testing.T) (privada, publica string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generando clave: %v", err)
+	}
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+
+	dir := t.TempDir()
+	privada = filepath.Join(dir, "privada.pem")
+	publica = filepath.Join(dir, "publica.pem")
+	os.WriteFile(privada, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600)
+	os.WriteFile(publica, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644)
+	return privada, publica
+}
+
+func TestLeerLlavero_Configuracion(t *testing.T) {
+	privada, publica := escribirClavesEd(t)
+
+	tests := []struct {
+		name      string
+		vars      map[string]string
+		wantError bool
+		wantKid   string
+	}{
+		{"ENV vacío sin secreto falla", map[string]string{}, true, ""},
+		{"ENV=local sin secreto usa el de desarrollo", map[string]string{"ENV": "local"}, false, kidPorDefecto},
+		{"prod sin secreto falla", map[string]string{"ENV": "prod"}, true, ""},
+		{"prod con JWT_SECRET", map[string]string{"ENV": "prod", "JWT_SECRET": "s3cr3t"}, false, kidPorDefecto},
+		{"JWT_KEYS: la primera firma", map[string]string{"ENV": "qa", "JWT_KEYS": "2025b:nuevo,2025a:viejo"}, false, "2025b"},
+		{"JWT_KEYS mal formada", map[string]string{"JWT_KEYS": "sin-separador"}, true, ""},
+		{"kid repetido", map[string]string{"JWT_KEYS": "a:x,a:y"}, true, ""},
+		{"alg no soportado", map[string]string{"JWT_ALG": "none"}, true, ""},
+		{"EdDSA sin JWT_KEYS", map[string]string{"JWT_ALG": "EdDSA", "JWT_SECRET": "x"}, true, ""},
+		{"EdDSA con clave privada", map[string]string{"JWT_ALG": "EdDSA", "JWT_KEYS": "ed1:" + privada}, false, "ed1"},
+		{"EdDSA con activa solo pública", map[string]string{"JWT_ALG": "EdDSA", "JWT_KEYS": "ed1:" + publica}, true, ""},
+		{"EdDSA con archivo inexistente", map[string]string{"JWT_ALG": "EdDSA", "JWT_KEYS": "ed1:/no/existe.pem"}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			l, err := leerLlavero(entorno(tt.vars), os.ReadFile)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Fatalf("leerLlavero() error = %v, wantError %v", err, tt.wantError)
+			}
+			if err == nil && l.activa.kid != tt.wantKid {
+				t.Errorf("kid activo = %q, want %q", l.activa.kid, tt.wantKid)
+			}
+		})
+	}
+}
+
+func TestLlavero_RotacionPorKid(t *testing.T) {
+	// Arrange
+	viejo, _ := leerLlavero(entorno(map[string]string{"JWT_KEYS": "2025a:viejo"}), os.ReadFile)
+	rotado, _ := leerLlavero(entorno(map[string]string{"JWT_KEYS": "2025b:nuevo,2025a:viejo"}), os.ReadFile)
+	retirado, _ := leerLlavero(entorno(map[string]string{"JWT_KEYS": "2025b:nuevo"}), os.ReadFile)
+
+	// Act
+	tokenViejo, err := viejo.firmar(claimsDePrueba())
+	if err != nil {
+		t.Fatalf("firmar() error = %v", err)
+	}
+	tokenNuevo, _ := rotado.firmar(claimsDePrueba())
+
+	// Assert: tras rotar, los tokens de la clave anterior siguen valiendo
+	if _, err := rotado.validar(tokenViejo); err != nil {
+		t.Errorf("token de la clave anterior tras rotar: %v", err)
+	}
+	if _, err := retirado.validar(tokenNuevo); err != nil {
+		t.Errorf("token de la clave nueva: %v", err)
+	}
+	if _, err := retirado.validar(tokenViejo); err == nil {
+		t.Error("token de una clave retirada fue aceptado")
+	}
+}
+
+func TestLlavero_EdDSARechazaOtroAlgoritmo(t *testing.T) {
+	// Arrange
+	privada, publica := escribirClavesEd(t)
+	firmante, _ := leerLlavero(entorno(map[string]string{"JWT_ALG": "EdDSA", "JWT_KEYS": "ed1:" + privada}), os.ReadFile)
+	verificador, err := leerLlavero(entorno(map[string]string{
+		"JWT_ALG":  "EdDSA",
+		"JWT_KEYS": "ed2:" + privada + ",ed1:" + publica,
+	}), os.ReadFile)
+	if err != nil {
+		t.Fatalf("leerLlavero() error = %v", err)
+	}
+	hs, _ := leerLlavero(entorno(map[string]string{"JWT_KEYS": "ed1:" + publica}), os.ReadFile)
+
+	// Act
+	tokenEd, _ := firmante.firmar(claimsDePrueba())
+	tokenHS, _ := hs.firmar(claimsDePrueba())
+
+	// Assert
+	claims, err := verificador.validar(tokenEd)
+	if err != nil || claims.Username != "admin" {
+		t.Errorf("validar() token EdDSA = %v, %v", claims, err)
+	}
+	if _, err := verificador.validar(tokenHS); err == nil {
+		t.Error("token HS256 aceptado por un llavero EdDSA")
+	}
+}
@@ -1,138 +1,60 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
-
+	"pizzas-ecos/httputil"
 	"pizzas-ecos/logger"
 	"pizzas-ecos/models"
 )
 
-var JWTSecret = []byte(os.Getenv("JWT_SECRET"))
-
-func init() {
-	if len(JWTSecret) == 0 {
-		JWTSecret = []byte("uytrewghbvcxbvnbvnz")
-	}
-}
-
 /* =========================
-   AUTH MIDDLEWARE
+   AUTENTICACIÓN
 ========================= */
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-		// 🔴 CLAVE: ignorar preflight OPTIONS
-		if r.Method == http.MethodOptions {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		path := r.URL.Path
-		method := r.Method
-
-		// 🔐 RUTAS COMPLETAMENTE PROTEGIDAS
-		protectedPaths := map[string]bool{
-			"/api/v1/admin": true, // Admin dashboard
-		}
-
-		if protectedPaths[path] {
-			goto requireAuth
-		}
-
-		// 🔐 OPERACIONES PROTEGIDAS (POST/PUT/DELETE en productos y vendedores)
-		// POST crear productos (solo admin)
-		if method == http.MethodPost && (path == "/api/v1/productos" || path == "/api/v1/crear-producto") {
-			goto requireAuth
-		}
-
-		// PUT/DELETE editar/eliminar productos (solo admin)
-		if (method == http.MethodPut || method == http.MethodDelete) && path == "/api/v1/productos" {
-			goto requireAuth
-		}
-		if method == http.MethodPut && (path == "/api/v1/actualizar-producto" || path == "/api/v1/productos/:id") {
-			goto requireAuth
-		}
-		if method == http.MethodDelete && (path == "/api/v1/eliminar-producto" || path == "/api/v1/productos/:id") {
-			goto requireAuth
-		}
-
-		// POST crear vendedores (solo admin)
-		if method == http.MethodPost && (path == "/api/v1/vendedores" || path == "/api/v1/crear-vendedor") {
-			goto requireAuth
-		}
+// La política de cada ruta (pública, autenticada o por rol) se declara al
+// registrarla en routes y la aplica el router con ValidarToken
 
-		// PUT/DELETE editar/eliminar vendedores (solo admin)
-		if (method == http.MethodPut || method == http.MethodDelete) && path == "/api/v1/vendedores" {
-			goto requireAuth
-		}
-		if method == http.MethodPut && (path == "/api/v1/actualizar-vendedor" || path == "/api/v1/vendedores/:id") {
-			goto requireAuth
-		}
-		if method == http.MethodDelete && (path == "/api/v1/eliminar-vendedor" || path == "/api/v1/vendedores/:id") {
-			goto requireAuth
-		}
-
-		// POST crear usuarios (solo admin)
-		if method == http.MethodPost && (path == "/api/v1/usuarios" || path == "/api/v1/crear-usuario") {
-			goto requireAuth
-		}
-
-		// PUT/DELETE usuarios (solo admin)
-		if (method == http.MethodPut || method == http.MethodDelete) && (path == "/api/v1/usuarios" || path == "/api/v1/actualizar-usuario" || path == "/api/v1/eliminar-usuario") {
-			goto requireAuth
-		}
-
-		// ✅ TODO LO DEMÁS ES PÚBLICO
-		// - Todos pueden autenticarse (/login, /auth/login)
-		// - Todos pueden ver datos iniciales (/data)
-		// - Todos pueden ver productos (GET)
-		// - Todos pueden ver vendedores (GET)
-		// - Todos pueden crear/editar ventas
-		// - Todos pueden ver estadísticas
-		next.ServeHTTP(w, r)
-		return
-
-	requireAuth:
-		// Validar token JWT
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			unauthorized(w, "Token requerido para esta acción")
-			return
-		}
+// ValidarToken valida el header "Authorization: Bearer <jwt>" y retorna sus claims
+func ValidarToken(r *http.Request) (*models.TokenClaims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, errors.New("Token requerido para esta acción")
+	}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			unauthorized(w, "Formato de token inválido")
-			return
-		}
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, errors.New("Formato de token inválido")
+	}
 
-		token, err := jwt.ParseWithClaims(
-			parts[1],
-			&models.TokenClaims{},
-			func(token *jwt.Token) (interface{}, error) {
-				return JWTSecret, nil
-			},
-		)
+	l, err := claves()
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil || !token.Valid {
-			unauthorized(w, "Token inválido o expirado")
-			return
-		}
+	return l.validar(parts[1])
+}
 
-		next.ServeHTTP(w, r)
-	})
+// FirmarToken firma claims como JWT con la clave activa (ver CargarClaves)
+func FirmarToken(claims models.TokenClaims) (string, error) {
+	l, err := claves()
+	if err != nil {
+		return "", err
+	}
+	return l.firmar(claims)
 }
 
-func unauthorized(w http.ResponseWriter, msg string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusUnauthorized)
-	w.Write([]byte(`{"status":401,"message":"` + msg + `","code":"UNAUTHORIZED"}`))
+// ConPrincipal retorna r con el usuario de claims en el context, legible con
+// httputil.GetPrincipal
+func ConPrincipal(r *http.Request, claims *models.TokenClaims) *http.Request {
+	return httputil.WithPrincipal(r, &models.Principal{
+		UserID:   claims.UserID,
+		Username: claims.Username,
+		Rol:      claims.Rol,
+	})
 }
 
 /* =========================
@@ -172,7 +94,7 @@ func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
 				w.Header().Set("Vary", "Origin")
 				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Idempotency-Key")
 				w.Header().Set("Access-Control-Allow-Credentials", "true")
 				w.Header().Set("Access-Control-Max-Age", "86400")
 			}
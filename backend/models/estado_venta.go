@@ -0,0 +1,67 @@
+package models
+
+import "strings"
+
+// EstadoVenta es el estado del ciclo de vida de una venta
+type EstadoVenta string
+
+const (
+	EstadoSinPagar  EstadoVenta = "sin_pagar"
+	EstadoPagada    EstadoVenta = "pagada"
+	EstadoEntregada EstadoVenta = "entregada"
+	EstadoCancelada EstadoVenta = "cancelada"
+)
+
+// EstadosVenta lista los estados válidos en el orden natural del ciclo
+var EstadosVenta = []EstadoVenta{EstadoSinPagar, EstadoPagada, EstadoEntregada, EstadoCancelada}
+
+// transicionesVenta define a qué estados se puede pasar desde cada uno.
+// Entregar implica cobrar, así que sin_pagar -> entregada es válido.
+// pagada -> sin_pagar corrige un pago registrado por error.
+// entregada y cancelada son finales
+var transicionesVenta = map[EstadoVenta][]EstadoVenta{
+	EstadoSinPagar:  {EstadoPagada, EstadoEntregada, EstadoCancelada},
+	EstadoPagada:    {EstadoSinPagar, EstadoEntregada, EstadoCancelada},
+	EstadoEntregada: {},
+	EstadoCancelada: {},
+}
+
+// ParseEstadoVenta normaliza s y retorna el estado si es válido
+func ParseEstadoVenta(s string) (EstadoVenta, bool) {
+	e := EstadoVenta(strings.ToLower(strings.TrimSpace(s)))
+	return e, e.Valido()
+}
+
+// Valido indica si e es uno de los estados conocidos
+func (e EstadoVenta) Valido() bool {
+	_, ok := transicionesVenta[e]
+	return ok
+}
+
+// PuedeTransicionarA indica si una venta en estado e puede pasar a destino.
+// Quedarse en el mismo estado siempre es válido (edición sin cambio de estado)
+func (e EstadoVenta) PuedeTransicionarA(destino EstadoVenta) bool {
+	if e == destino {
+		return destino.Valido()
+	}
+	for _, permitido := range transicionesVenta[e] {
+		if permitido == destino {
+			return true
+		}
+	}
+	return false
+}
+
+// Cobrada indica si el estado cuenta como venta cobrada en las estadísticas
+func (e EstadoVenta) Cobrada() bool {
+	return e == EstadoPagada || e == EstadoEntregada
+}
+
+// EstadosVentaString retorna los estados separados por coma, para mensajes de error
+func EstadosVentaString() string {
+	nombres := make([]string, len(EstadosVenta))
+	for i, e := range EstadosVenta {
+		nombres[i] = string(e)
+	}
+	return strings.Join(nombres, ", ")
+}
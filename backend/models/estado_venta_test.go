@@ -0,0 +1,45 @@
+package models
+
+import "testing"
+
+func TestEstadoVenta_PuedeTransicionarA(t *testing.T) {
+	tests := []struct {
+		desde    EstadoVenta
+		hacia    EstadoVenta
+		expected bool
+	}{
+		{EstadoSinPagar, EstadoPagada, true},
+		{EstadoSinPagar, EstadoEntregada, true},
+		{EstadoSinPagar, EstadoCancelada, true},
+		{EstadoPagada, EstadoEntregada, true},
+		{EstadoPagada, EstadoSinPagar, true},
+		{EstadoPagada, EstadoPagada, true},
+		{EstadoEntregada, EstadoSinPagar, false},
+		{EstadoEntregada, EstadoCancelada, false},
+		{EstadoCancelada, EstadoEntregada, false},
+		{EstadoCancelada, EstadoSinPagar, false},
+		{EstadoCancelada, EstadoCancelada, true},
+		{EstadoSinPagar, EstadoVenta("reembolsada"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.desde)+"->"+string(tt.hacia), func(t *testing.T) {
+			// Act
+			result := tt.desde.PuedeTransicionarA(tt.hacia)
+
+			// Assert
+			if result != tt.expected {
+				t.Errorf("PuedeTransicionarA() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseEstadoVenta(t *testing.T) {
+	if e, ok := ParseEstadoVenta("  Pagada "); !ok || e != EstadoPagada {
+		t.Errorf("ParseEstadoVenta() = %v, %v; want pagada, true", e, ok)
+	}
+	if _, ok := ParseEstadoVenta("sin pagar"); ok {
+		t.Error("ParseEstadoVenta() aceptó un estado inválido")
+	}
+}
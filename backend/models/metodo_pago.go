@@ -0,0 +1,24 @@
+package models
+
+import "strings"
+
+// MetodosPago lista los métodos de pago aceptados al crear o editar una venta
+// y en el libro de pagos. Es la única lista: validadores, servicios y el
+// resumen de cobros la usan
+var MetodosPago = []string{"efectivo", "transferencia", "tarjeta", "qr"}
+
+// ParseMetodoPago normaliza s y retorna el método si es válido
+func ParseMetodoPago(s string) (string, bool) {
+	m := strings.ToLower(strings.TrimSpace(s))
+	for _, valido := range MetodosPago {
+		if m == valido {
+			return m, true
+		}
+	}
+	return m, false
+}
+
+// MetodosPagoString retorna los métodos separados por coma, para mensajes de error
+func MetodosPagoString() string {
+	return strings.Join(MetodosPago, ", ")
+}
@@ -0,0 +1,17 @@
+package models
+
+import "testing"
+
+func TestParseMetodoPago(t *testing.T) {
+	for _, entrada := range []string{"efectivo", " Tarjeta ", "QR", "transferencia"} {
+		if _, ok := ParseMetodoPago(entrada); !ok {
+			t.Errorf("ParseMetodoPago(%q) no es válido", entrada)
+		}
+	}
+	if m, ok := ParseMetodoPago(" Tarjeta "); m != "tarjeta" || !ok {
+		t.Errorf("ParseMetodoPago() = %q, %v; want tarjeta, true", m, ok)
+	}
+	if _, ok := ParseMetodoPago("cheque"); ok {
+		t.Error("ParseMetodoPago(cheque) debería ser inválido")
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -12,8 +13,10 @@ type ProductoItem struct {
 	Tipo      string  `json:"tipo"`       // "producto"
 	ProductID int     `json:"product_id"` // producto_id
 	Cantidad  int     `json:"cantidad"`   // cantidad
-	Precio    float64 `json:"precio"`     // precio unitario
+	Precio    float64 `json:"precio"`     // precio unitario al momento de la venta
 	Total     float64 `json:"total"`      // total (precio * cantidad)
+
+	PrecioActual float64 `json:"precio_actual,omitempty"` // precio vigente del catálogo (solo en lecturas)
 }
 
 // VentaRequest representa la solicitud para crear una venta
@@ -24,14 +27,78 @@ type VentaRequest struct {
 	PaymentMethod   string         `json:"payment_method"`
 	Estado          string         `json:"estado"`
 	TipoEntrega     string         `json:"tipo_entrega"`     // retiro o envio
-	TelefonoCliente int            `json:"telefono_cliente"` // 0 = no enviado/vacío
+	TelefonoCliente Telefono       `json:"telefono_cliente"` // "" = no enviado/vacío
+}
+
+// VentaCreada es el resultado de crear una venta
+type VentaCreada struct {
+	ID                int                `json:"id"`
+	Replay            bool               `json:"-"` // reintento con Idempotency-Key: venta original
+	DiferenciasPrecio []PrecioDiferencia `json:"diferencias_precio,omitempty"`
+}
+
+// PrecioDiferencia describe un item cuyo precio enviado por el cliente no
+// coincide con el del catálogo
+type PrecioDiferencia struct {
+	ProductoID     int     `json:"producto_id"`
+	PrecioEnviado  float64 `json:"precio_enviado"`
+	PrecioCatalogo float64 `json:"precio_catalogo"`
+	TotalEnviado   float64 `json:"total_enviado"`
+	TotalCalculado float64 `json:"total_calculado"`
+}
+
+// ClienteVenta identifica el cliente a asociar a una venta existente
+type ClienteVenta struct {
+	Nombre   string
+	Telefono Telefono // "" = no enviado
 }
 
 // DataResponse retorna vendedores, clientes y productos
 type DataResponse struct {
+	Campana             *Campana             `json:"campana"` // activa; nil si no hay ninguna
 	ClientesPorVendedor map[string][]Cliente `json:"clientesPorVendedor"`
 	Vendedores          []Vendedor           `json:"vendedores"`
-	Productos           []Producto           `json:"productos"`
+	Productos           []Producto           `json:"productos"` // de la campaña activa
+}
+
+// Campana es una edición de la venta de pizzas. Agrupa ventas y productos;
+// a lo sumo una está activa y recibe las ventas nuevas
+type Campana struct {
+	ID          int        `json:"id"`
+	Nombre      string     `json:"nombre"`
+	FechaInicio time.Time  `json:"fecha_inicio"`
+	FechaFin    *time.Time `json:"fecha_fin,omitempty"`
+	Activa      bool       `json:"activa"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CampanaRequest es el cuerpo para crear o actualizar una campaña. Las fechas
+// van como YYYY-MM-DD; fecha_fin vacía deja la campaña abierta
+type CampanaRequest struct {
+	Nombre      string `json:"nombre"`
+	FechaInicio string `json:"fecha_inicio"`
+	FechaFin    string `json:"fecha_fin"`
+	Activa      bool   `json:"activa"`
+	// CopiarProductosDe, al crear, copia los productos activos de esa campaña
+	CopiarProductosDe int `json:"copiar_productos_de,omitempty"`
+}
+
+// LimpiarBaseDatosRequest es el cuerpo de /limpiar-base-datos: la frase de
+// confirmación escrita por el admin
+type LimpiarBaseDatosRequest struct {
+	Confirmacion string `json:"confirmacion"`
+}
+
+// BackupDatos es el export completo que se escribe antes de limpiar la base
+type BackupDatos struct {
+	GeneradoAt  time.Time        `json:"generado_at"`
+	GeneradoPor string           `json:"generado_por"`
+	Ventas      []VentaStats     `json:"ventas"`
+	Pagos       []Pago           `json:"pagos"`
+	Clientes    []Cliente        `json:"clientes"`
+	Vendedores  []Vendedor       `json:"vendedores"`
+	Productos   []Producto       `json:"productos"`
+	Precios     []PrecioProducto `json:"precios"`
 }
 
 // Pizza estructura para pizzas (legado)
@@ -44,22 +111,160 @@ type Pizza struct {
 // VentaStats retorna estadísticas de una venta
 type VentaStats struct {
 	ID              int            `json:"id"`
+	CampanaID       int            `json:"campana_id"`
 	Vendedor        string         `json:"vendedor"`
 	Cliente         string         `json:"cliente"`
-	TelefonoCliente *int           `json:"telefono_cliente"`
+	TelefonoCliente *string        `json:"telefono_cliente"`
 	Total           float64        `json:"total"`
 	PaymentMethod   string         `json:"payment_method"`
 	Estado          string         `json:"estado"`
 	TipoEntrega     string         `json:"tipo_entrega"`
 	CreatedAt       time.Time      `json:"created_at"`
+	PagadaAt        *time.Time     `json:"pagada_at,omitempty"`
+	EntregadaAt     *time.Time     `json:"entregada_at,omitempty"`
+	CanceladaAt     *time.Time     `json:"cancelada_at,omitempty"`
+	RegistradaPor   string         `json:"registrada_por,omitempty"`
+	Pagado          float64        `json:"pagado"`
 	Items           []ProductoItem `json:"items"`
 }
 
+// Pago es una entrada del libro de pagos de una venta. Un monto negativo
+// revierte pagos anteriores
+type Pago struct {
+	ID            int       `json:"id"`
+	VentaID       int       `json:"venta_id"`
+	Monto         float64   `json:"monto"`
+	Metodo        string    `json:"metodo"`
+	RegistradoPor string    `json:"registrado_por,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PagoRequest estructura para registrar un pago de una venta
+type PagoRequest struct {
+	Monto  float64 `json:"monto"`
+	Metodo string  `json:"metodo"`
+}
+
+// VentaFiltro agrupa los filtros, el orden y la paginación del listado de ventas.
+// Los campos vacíos no filtran
+type VentaFiltro struct {
+	CampanaID         int        // 0 = todas
+	Desde             *time.Time // created_at >= Desde
+	Hasta             *time.Time // created_at < Hasta
+	Vendedor          string     // nombre exacto
+	Cliente           string     // parte del nombre
+	ClienteID         int        // 0 = todos
+	Estado            string
+	PaymentMethod     string
+	TipoEntrega       string
+	IncluirCanceladas bool
+	Orden             string // fecha, -fecha, total, -total (por defecto -fecha)
+	Limit             int    // 0 = sin límite
+	Cursor            string // next_cursor de la página anterior
+}
+
+// Entidades y acciones registradas en la auditoría
+const (
+	EntidadVenta    = "venta"
+	EntidadProducto = "producto"
+	EntidadVendedor = "vendedor"
+	EntidadUsuario  = "usuario"
+	EntidadCampana  = "campana"
+	EntidadCliente  = "cliente"
+
+	AccionCrear      = "crear"
+	AccionActualizar = "actualizar"
+	AccionEliminar   = "eliminar"
+	AccionFusionar   = "fusionar"
+	AccionExportar   = "exportar"
+	AccionAnonimizar = "anonimizar"
+)
+
+// Auditoria es una entrada del registro de cambios. Cambios tiene por cada
+// campo modificado un objeto {"antes": ..., "despues": ...}
+type Auditoria struct {
+	ID        int             `json:"id"`
+	Entidad   string          `json:"entidad"`
+	EntidadID int             `json:"entidad_id"`
+	Accion    string          `json:"accion"`
+	UsuarioID *int            `json:"usuario_id,omitempty"`
+	Usuario   string          `json:"usuario,omitempty"`
+	Cambios   json.RawMessage `json:"cambios"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditoriaFiltro agrupa los filtros del listado de auditoría
+type AuditoriaFiltro struct {
+	Entidad   string
+	EntidadID int        // 0 = cualquiera
+	Usuario   string     // username exacto
+	Desde     *time.Time // created_at >= Desde
+	Hasta     *time.Time // created_at < Hasta
+	AntesDeID int        // id < AntesDeID, para paginar; 0 = sin cota
+	Limit     int
+}
+
 // Cliente representa un cliente con teléfono
 type Cliente struct {
-	ID       int    `json:"id"`
-	Nombre   string `json:"nombre"`
-	Telefono int    `json:"telefono"`
+	ID       int      `json:"id"`
+	Nombre   string   `json:"nombre"`
+	Telefono Telefono `json:"telefono"` // E.164, "" = sin teléfono
+}
+
+// ClienteRequest es el cuerpo de alta y modificación de un cliente
+type ClienteRequest struct {
+	Nombre   string   `json:"nombre"`
+	Telefono Telefono `json:"telefono"` // "" = sin teléfono
+}
+
+// ClienteDuplicado es un par de clientes que probablemente son la misma
+// persona. Puntaje va de 0 a 1; Motivos explica de dónde sale
+type ClienteDuplicado struct {
+	Cliente   Cliente  `json:"cliente"`
+	Duplicado Cliente  `json:"duplicado"`
+	Puntaje   float64  `json:"puntaje"`
+	Motivos   []string `json:"motivos"`
+}
+
+// FusionarClienteRequest indica el cliente que se absorbe: sus ventas pasan
+// al cliente de la ruta y después se elimina
+type FusionarClienteRequest struct {
+	DuplicadoID int `json:"duplicado_id"`
+}
+
+// HistorialCliente son todas las ventas de un cliente, de la más reciente a
+// la más antigua, con sus totales
+type HistorialCliente struct {
+	Cliente Cliente        `json:"cliente"`
+	Resumen ResumenCliente `json:"resumen"`
+	Ventas  []VentaStats   `json:"ventas"`
+}
+
+// ResumenCliente son los totales de las compras de un cliente. Las ventas
+// canceladas figuran en el historial pero no cuentan
+type ResumenCliente struct {
+	TotalGastado     float64           `json:"total_gastado"`
+	Deuda            float64           `json:"deuda"` // saldo de las ventas sin_pagar
+	CantidadPedidos  int               `json:"cantidad_pedidos"`
+	ProductoFavorito *ProductoFavorito `json:"producto_favorito"` // nil si no compró nada
+	PrimeraCompra    *time.Time        `json:"primera_compra"`
+	UltimaCompra     *time.Time        `json:"ultima_compra"`
+}
+
+// ExportacionCliente son todos los datos guardados de un cliente: sus datos,
+// sus ventas y las entradas de auditoría del cliente y de esas ventas
+type ExportacionCliente struct {
+	Cliente     Cliente      `json:"cliente"`
+	Ventas      []VentaStats `json:"ventas"`
+	Auditoria   []Auditoria  `json:"auditoria"`
+	ExportadoAt time.Time    `json:"exportado_at"`
+}
+
+// ProductoFavorito es el producto del que un cliente compró más unidades.
+// Se agrupa por nombre porque cada campaña tiene sus propios productos
+type ProductoFavorito struct {
+	Tipo     string `json:"tipo"`
+	Cantidad int    `json:"cantidad"`
 }
 
 // Auth structs
@@ -69,10 +274,82 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`         // access token (JWT de vida corta)
+	RefreshToken string `json:"refresh_token"` // se usa una sola vez en /auth/refresh
+	ExpiresIn    int    `json:"expires_in"`    // segundos de vida del access token
+	User         User   `json:"user"`
+}
+
+// RefreshRequest es el cuerpo de /auth/refresh y /auth/logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
+// RefreshToken es un refresh token emitido. Solo se guarda el hash SHA-256;
+// los tokens obtenidos por rotación comparten Familia
+type RefreshToken struct {
+	ID         int
+	UsuarioID  int
+	TokenHash  string
+	Familia    string
+	ExpiresAt  time.Time
+	RevocadoAt *time.Time
+	CreatedAt  time.Time
+}
+
+// ChangePasswordRequest es el cuerpo de /auth/change-password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ResetPasswordRequest es el cuerpo de /auth/reset-password
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// PasswordResetResponse es el token de restablecimiento que genera un admin.
+// Link solo viene si PASSWORD_RESET_URL está configurada
+type PasswordResetResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Link      string    `json:"link,omitempty"`
+}
+
+// PasswordReset es un token de restablecimiento emitido. Solo se guarda el
+// hash SHA-256
+type PasswordReset struct {
+	ID        int
+	UsuarioID int
+	TokenHash string
+	ExpiresAt time.Time
+	UsadoAt   *time.Time
+	CreatedBy *int
+	CreatedAt time.Time
+}
+
+// Claves por las que se cuentan los intentos fallidos de login
+const (
+	IntentoPorUsuario = "usuario"
+	IntentoPorIP      = "ip"
+)
+
+// IntentoLogin acumula los fallos de login de un usuario o de una IP
+type IntentoLogin struct {
+	Tipo           string
+	Valor          string
+	Fallos         int
+	UltimoFallo    time.Time
+	BloqueadoHasta *time.Time
+}
+
+// Roles de usuario
+const (
+	RolAdmin    = "admin"
+	RolVendedor = "vendedor"
+)
+
 type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
@@ -80,11 +357,52 @@ type User struct {
 }
 
 type TokenClaims struct {
+	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
 	Rol      string `json:"rol"`
+	// EmitidoMs es iat en milisegundos Unix: iat solo tiene segundos y no
+	// alcanza para ubicar el token respecto de un cierre de sesiones
+	EmitidoMs int64 `json:"iat_ms,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Emitido retorna cuándo se emitió el token, con milisegundos si los trae
+// (los tokens anteriores a iat_ms solo tienen iat). Cero si no trae ninguno
+func (c *TokenClaims) Emitido() time.Time {
+	if c.EmitidoMs != 0 {
+		return time.UnixMilli(c.EmitidoMs)
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time
+	}
+	return time.Time{}
+}
+
+// Principal es el usuario autenticado que realiza la acción del request
+type Principal struct {
+	UserID   int
+	Username string
+	Rol      string
+}
+
+// UsuarioID retorna el ID para las columnas created_by/updated_by, o nil si
+// no hay usuario (ruta pública o token emitido antes de incluir user_id)
+func (p *Principal) UsuarioID() *int {
+	if p == nil || p.UserID == 0 {
+		return nil
+	}
+	id := p.UserID
+	return &id
+}
+
+// Nombre retorna el username, o "" si no hay usuario
+func (p *Principal) Nombre() string {
+	if p == nil {
+		return ""
+	}
+	return p.Username
+}
+
 // CreateUsuarioRequest estructura para crear usuario
 type CreateUsuarioRequest struct {
 	Username string `json:"username"`
@@ -102,6 +420,7 @@ type UpdateUsuarioRequest struct {
 // Producto estructura para productos
 type Producto struct {
 	ID          int       `json:"id"`
+	CampanaID   int       `json:"campana_id"`
 	TipoPizza   string    `json:"tipo_pizza"`
 	Descripcion string    `json:"descripcion"`
 	Precio      float64   `json:"precio"`
@@ -109,6 +428,13 @@ type Producto struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// PrecioProducto es una entrada del historial de precios de un producto
+type PrecioProducto struct {
+	ProductoID   int       `json:"producto_id"`
+	Precio       float64   `json:"precio"`
+	VigenteDesde time.Time `json:"vigente_desde"`
+}
+
 // Vendedor estructura para vendedores
 type Vendedor struct {
 	ID     int    `json:"id"`
@@ -117,6 +443,7 @@ type Vendedor struct {
 
 // CrearProductoRequest estructura para crear producto
 type CrearProductoRequest struct {
+	CampanaID   int     `json:"campana_id,omitempty"` // 0 = campaña activa
 	TipoPizza   string  `json:"tipo_pizza"`
 	Descripcion string  `json:"descripcion"`
 	Precio      float64 `json:"precio"`
@@ -248,7 +248,7 @@ func TestCliente_Validation(t *testing.T) {
 			cliente: Cliente{
 				ID:       1,
 				Nombre:   "María García",
-				Telefono: 123456789,
+				Telefono: "+541123456789",
 			},
 			expectValid: true,
 		},
@@ -257,7 +257,7 @@ func TestCliente_Validation(t *testing.T) {
 			cliente: Cliente{
 				ID:       1,
 				Nombre:   "",
-				Telefono: 123456789,
+				Telefono: "+541123456789",
 			},
 			expectValid: false,
 		},
@@ -266,7 +266,7 @@ func TestCliente_Validation(t *testing.T) {
 			cliente: Cliente{
 				ID:       0,
 				Nombre:   "María García",
-				Telefono: 123456789,
+				Telefono: "+541123456789",
 			},
 			expectValid: false,
 		},
@@ -294,7 +294,7 @@ func TestVenta_CalculateTotal(t *testing.T) {
 		ID:              1,
 		Vendedor:        "Juan Pérez",
 		Cliente:         "María García",
-		TelefonoCliente: &[]int{123456789}[0],
+		TelefonoCliente: &[]string{"+541123456789"}[0],
 		Total:           0, // Se calculará
 		PaymentMethod:   "efectivo",
 		Estado:          "pagada",
@@ -329,7 +329,7 @@ func TestDataResponse_Structure(t *testing.T) {
 	response := DataResponse{
 		ClientesPorVendedor: map[string][]Cliente{
 			"Juan Pérez": {
-				{ID: 1, Nombre: "María García", Telefono: 123456789},
+				{ID: 1, Nombre: "María García", Telefono: "+541123456789"},
 			},
 		},
 		Vendedores: []Vendedor{
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Telefono es un número de teléfono; "" significa sin teléfono. Los que
+// salen de la base están en formato E.164 ("+541123456789"); los que llegan
+// en un request se normalizan con el paquete telefono antes de guardarlos
+type Telefono string
+
+// UnmarshalJSON acepta el string nuevo y también el número entero que
+// enviaban los clientes anteriores, donde 0 era "sin teléfono"
+func (t *Telefono) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*t = ""
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = Telefono(strings.TrimSpace(s))
+		return nil
+	}
+
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil || n < 0 {
+		return fmt.Errorf("teléfono debe ser un string o un número entero positivo")
+	}
+	if n == 0 {
+		*t = ""
+	} else {
+		*t = Telefono(strconv.FormatInt(n, 10))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTelefono_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		want      Telefono
+		wantError bool
+	}{
+		{"string E.164", `{"telefono": "+5491123456789"}`, "+5491123456789", false},
+		{"string con separadores", `{"telefono": " 011 15-2345-6789 "}`, "011 15-2345-6789", false},
+		{"número viejo", `{"telefono": 1123456789}`, "1123456789", false},
+		{"cero es sin teléfono", `{"telefono": 0}`, "", false},
+		{"null es sin teléfono", `{"telefono": null}`, "", false},
+		{"número negativo", `{"telefono": -1}`, "", true},
+		{"número con decimales", `{"telefono": 11.5}`, "", true},
+		{"booleano", `{"telefono": true}`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var req struct {
+				Telefono Telefono `json:"telefono"`
+			}
+
+			// Act
+			err := json.Unmarshal([]byte(tt.json), &req)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Fatalf("Unmarshal() error = %v, wantError %v", err, tt.wantError)
+			}
+			if req.Telefono != tt.want {
+				t.Errorf("Telefono = %q, want %q", req.Telefono, tt.want)
+			}
+		})
+	}
+}
@@ -7,9 +7,54 @@ import (
 	"strings"
 
 	"pizzas-ecos/controllers"
+	"pizzas-ecos/database"
+	"pizzas-ecos/errors"
 	"pizzas-ecos/httputil"
+	"pizzas-ecos/logger"
+	"pizzas-ecos/middleware"
+	"pizzas-ecos/models"
+	"pizzas-ecos/services"
 )
 
+// Acceso es la política de autenticación que declara cada ruta al registrarse
+type Acceso struct {
+	Autenticado bool     // requiere un token válido
+	Roles       []string // roles permitidos; vacío = cualquier usuario autenticado
+}
+
+// Políticas de acceso usadas al registrar rutas
+var (
+	Publico        = Acceso{}
+	Autenticado    = Acceso{Autenticado: true}
+	SoloAdmin      = Acceso{Autenticado: true, Roles: []string{models.RolAdmin}}
+	AdminOVendedor = Acceso{Autenticado: true, Roles: []string{models.RolAdmin, models.RolVendedor}}
+)
+
+// Permite indica si un usuario autenticado con rol cumple la política
+func (a Acceso) Permite(rol string) bool {
+	if len(a.Roles) == 0 {
+		return true
+	}
+	for _, permitido := range a.Roles {
+		if permitido == rol {
+			return true
+		}
+	}
+	return false
+}
+
+// String describe la política: "publico", "autenticado" o "rol:admin,vendedor"
+func (a Acceso) String() string {
+	switch {
+	case !a.Autenticado:
+		return "publico"
+	case len(a.Roles) == 0:
+		return "autenticado"
+	default:
+		return "rol:" + strings.Join(a.Roles, ",")
+	}
+}
+
 // RouteGroup agrupa rutas con un prefijo y middleware común
 type RouteGroup struct {
 	prefix      string
@@ -23,6 +68,7 @@ type Route struct {
 	Path        string
 	Handler     http.HandlerFunc
 	Name        string
+	Acceso      Acceso
 	pathPattern *regexp.Regexp
 }
 
@@ -33,7 +79,14 @@ type CustomRouter struct {
 
 // Router maneja el registro de todas las rutas
 type Router struct {
-	groups []*RouteGroup
+	groups   []*RouteGroup
+	sesiones VerificadorSesion
+}
+
+// VerificadorSesion consulta si un token con firma válida fue revocado
+// (logout o revocación de sesiones por un admin)
+type VerificadorSesion interface {
+	TokenRevocado(claims *models.TokenClaims) (bool, error)
 }
 
 // NewRouter crea un nuevo router
@@ -54,43 +107,47 @@ func (r *Router) Group(prefix string, middlewares ...func(http.Handler) http.Han
 	return group
 }
 
-// GET registra una ruta GET
-func (rg *RouteGroup) GET(path string, handler http.HandlerFunc, name string) {
+// GET registra una ruta GET con su política de acceso
+func (rg *RouteGroup) GET(path string, handler http.HandlerFunc, name string, acceso Acceso) {
 	rg.routes = append(rg.routes, Route{
 		Method:  http.MethodGet,
 		Path:    rg.prefix + path,
 		Handler: handler,
 		Name:    name,
+		Acceso:  acceso,
 	})
 }
 
-// POST registra una ruta POST
-func (rg *RouteGroup) POST(path string, handler http.HandlerFunc, name string) {
+// POST registra una ruta POST con su política de acceso
+func (rg *RouteGroup) POST(path string, handler http.HandlerFunc, name string, acceso Acceso) {
 	rg.routes = append(rg.routes, Route{
 		Method:  http.MethodPost,
 		Path:    rg.prefix + path,
 		Handler: handler,
 		Name:    name,
+		Acceso:  acceso,
 	})
 }
 
-// PUT registra una ruta PUT
-func (rg *RouteGroup) PUT(path string, handler http.HandlerFunc, name string) {
+// PUT registra una ruta PUT con su política de acceso
+func (rg *RouteGroup) PUT(path string, handler http.HandlerFunc, name string, acceso Acceso) {
 	rg.routes = append(rg.routes, Route{
 		Method:  http.MethodPut,
 		Path:    rg.prefix + path,
 		Handler: handler,
 		Name:    name,
+		Acceso:  acceso,
 	})
 }
 
-// DELETE registra una ruta DELETE
-func (rg *RouteGroup) DELETE(path string, handler http.HandlerFunc, name string) {
+// DELETE registra una ruta DELETE con su política de acceso
+func (rg *RouteGroup) DELETE(path string, handler http.HandlerFunc, name string, acceso Acceso) {
 	rg.routes = append(rg.routes, Route{
 		Method:  http.MethodDelete,
 		Path:    rg.prefix + path,
 		Handler: handler,
 		Name:    name,
+		Acceso:  acceso,
 	})
 }
 
@@ -143,6 +200,11 @@ func (r *Router) Register(mux *http.ServeMux) {
 				ctx := context.WithValue(req.Context(), httputil.PathParamsKey, params)
 				req = req.WithContext(ctx)
 
+				req, ok := r.autorizar(w, req, route)
+				if !ok {
+					return
+				}
+
 				// Aplicar middlewares
 				handler := http.HandlerFunc(route.Handler)
 				for _, group := range r.groups {
@@ -165,6 +227,62 @@ func (r *Router) Register(mux *http.ServeMux) {
 	})
 }
 
+// autorizar aplica el Acceso de la ruta y deja el usuario del token en el
+// context (httputil.GetPrincipal). En rutas públicas un token válido es
+// opcional y uno inválido o revocado se ignora; en las protegidas responde
+// 401 (sin token válido o revocado) o 403 (rol no permitido) y retorna false
+func (r *Router) autorizar(w http.ResponseWriter, req *http.Request, route *Route) (*http.Request, bool) {
+	if !route.Acceso.Autenticado {
+		if req.Header.Get("Authorization") != "" {
+			if claims, err := middleware.ValidarToken(req); err == nil {
+				if revocado, err := r.tokenRevocado(claims); err == nil && !revocado {
+					return middleware.ConPrincipal(req, claims), true
+				}
+			}
+		}
+		return req, true
+	}
+
+	claims, err := middleware.ValidarToken(req)
+	if err != nil {
+		errors.WriteError(w, errors.ErrUnauthorized, err.Error())
+		return req, false
+	}
+	revocado, err := r.tokenRevocado(claims)
+	if err != nil {
+		logger.Error("Error verificando revocación de token", "TOKEN_REVOCATION_CHECK_ERROR", map[string]interface{}{
+			"username": claims.Username,
+			"error":    err.Error(),
+		})
+		errors.WriteError(w, errors.ErrServerError, "Error verificando sesión")
+		return req, false
+	}
+	if revocado {
+		errors.WriteError(w, errors.ErrUnauthorized, "Sesión revocada")
+		return req, false
+	}
+	if !route.Acceso.Permite(claims.Rol) {
+		logger.Warn("Acceso denegado por rol", map[string]interface{}{
+			"ruta":     route.Method + " " + route.Path,
+			"username": claims.Username,
+			"rol":      claims.Rol,
+			"requiere": route.Acceso.String(),
+		})
+		errors.WriteError(w, errors.ErrForbidden, "Rol sin permiso para esta acción")
+		return req, false
+	}
+	return middleware.ConPrincipal(req, claims), true
+}
+
+// tokenRevocado consulta el VerificadorSesion; sin verificador ningún token
+// se considera revocado
+func (r *Router) tokenRevocado(claims *models.TokenClaims) (bool, error) {
+	if r.sesiones == nil {
+		return false, nil
+	}
+	return r.sesiones.TokenRevocado(claims)
+}
+
 // extractParams extrae parámetros nombrados de una ruta
 // Ej: ruta="/api/v1/productos/:id", path="/api/v1/productos/123" retorna {"id": "123"}
 func extractParams(routePath, requestPath string) httputil.PathParams {
@@ -197,98 +315,157 @@ func (r *Router) GetRoutes() []Route {
 	return allRoutes
 }
 
-// SetupRoutes configura todas las rutas de la API
-func SetupRoutes() *Router {
+// SetupRoutes configura todas las rutas de la API sobre el Store indicado
+func SetupRoutes(store database.Store) *Router {
 	router := NewRouter()
 
+	// Inicializar servicios
+	ventaService := services.NewVentaService(store)
+	productoService := services.NewProductoService(store)
+	vendedorService := services.NewVendedorService(store)
+	dataService := services.NewDataService(store)
+	authService := services.NewAuthService(store)
+	usuarioService := services.NewUsuarioService(store)
+	auditoriaService := services.NewAuditoriaService(store)
+	campanaService := services.NewCampanaService(store)
+	clienteService := services.NewClienteService(store)
+
 	// Inicializar controladores
-	ventaCtrl := controllers.NewVentaController()
-	productoCtrl := controllers.NewProductoController()
-	vendedorCtrl := controllers.NewVendedorController()
-	dataCtrl := controllers.NewDataController()
-	authCtrl := controllers.NewAuthController()
-	usuarioCtrl := controllers.NewUsuarioController()
+	ventaCtrl := controllers.NewVentaController(ventaService)
+	productoCtrl := controllers.NewProductoController(productoService)
+	vendedorCtrl := controllers.NewVendedorController(vendedorService)
+	dataCtrl := controllers.NewDataController(dataService)
+	authCtrl := controllers.NewAuthController(authService)
+	usuarioCtrl := controllers.NewUsuarioController(usuarioService)
+	auditoriaCtrl := controllers.NewAuditoriaController(auditoriaService)
+	campanaCtrl := controllers.NewCampanaController(campanaService)
+	clienteCtrl := controllers.NewClienteController(clienteService)
+
+	// El router rechaza los access tokens revocados por logout o por un admin
+	router.sesiones = authService
 
 	// ============================================
-	// GRUPO: Autenticación (Sin middleware)
+	// GRUPO: Autenticación (público)
 	// ============================================
 	authGroup := router.Group("/api/v1/auth")
-	authGroup.POST("/login", authCtrl.Login, "Autenticar usuario")
+	authGroup.POST("/login", authCtrl.Login, "Autenticar usuario", Publico)
+	authGroup.POST("/refresh", authCtrl.Refresh, "Renovar sesión con refresh token", Publico)
+	authGroup.POST("/logout", authCtrl.Logout, "Cerrar sesión", Publico)
+	authGroup.POST("/change-password", authCtrl.ChangePassword, "Cambiar contraseña propia", Autenticado)
+	authGroup.POST("/reset-password", authCtrl.ResetPassword, "Restablecer contraseña con token", Publico)
 
 	// ============================================
-	// GRUPO: Datos iniciales (Sin middleware de auth)
+	// GRUPO: Datos iniciales (incluye clientes y teléfonos: requiere sesión)
 	// ============================================
 	dataGroup := router.Group("/api/v1/data")
-	dataGroup.GET("", dataCtrl.ObtenerData, "Obtener vendedores, clientes y productos")
+	dataGroup.GET("", dataCtrl.ObtenerData, "Obtener vendedores, clientes y productos", Autenticado)
 
 	// ============================================
-	// GRUPO: Ventas
+	// GRUPO: Ventas (lectura con sesión; crear, editar y cobrar admin o vendedor; borrar solo admin)
 	// ============================================
 	ventaGroup := router.Group("/api/v1/ventas")
-	ventaGroup.POST("", ventaCtrl.CrearVenta, "Crear nueva venta")
-	ventaGroup.PUT("/:id", ventaCtrl.ActualizarVenta, "Actualizar venta")
-	ventaGroup.GET("/estadisticas", ventaCtrl.ObtenerEstadisticas, "Obtener estadísticas")
-	ventaGroup.GET("/todas", ventaCtrl.ObtenerTodasVentas, "Obtener todas las ventas")
+	ventaGroup.POST("", ventaCtrl.CrearVenta, "Crear nueva venta", AdminOVendedor)
+	ventaGroup.PUT("/:id", ventaCtrl.ActualizarVenta, "Actualizar venta", AdminOVendedor)
+	ventaGroup.GET("/estadisticas", ventaCtrl.ObtenerEstadisticas, "Obtener estadísticas", Autenticado)
+	ventaGroup.GET("/todas", ventaCtrl.ObtenerTodasVentas, "Obtener todas las ventas", Autenticado)
+	// Después de las rutas fijas: /:id también matchea "todas" y "estadisticas"
+	ventaGroup.GET("/:id", ventaCtrl.ObtenerVenta, "Obtener venta", Autenticado)
+	ventaGroup.DELETE("/:id", ventaCtrl.EliminarVenta, "Eliminar venta", SoloAdmin)
+	ventaGroup.POST("/:id/pagos", ventaCtrl.RegistrarPago, "Registrar pago de venta", AdminOVendedor)
+	ventaGroup.GET("/:id/pagos", ventaCtrl.ObtenerPagos, "Obtener pagos de venta", Autenticado)
 
 	// ============================================
-	// GRUPO: Productos (SIN MIDDLEWARE - Auth aplicado globalmente)
+	// GRUPO: Productos (lectura pública, cambios solo admin)
 	// ============================================
 	productoGroup := router.Group("/api/v1/productos")
-	productoGroup.GET("", productoCtrl.Listar, "Listar productos")
-	productoGroup.POST("", productoCtrl.Crear, "Crear producto")
-	productoGroup.PUT("/:id", productoCtrl.Actualizar, "Actualizar producto")
-	productoGroup.DELETE("/:id", productoCtrl.Eliminar, "Eliminar producto")
+	productoGroup.GET("", productoCtrl.Listar, "Listar productos", Publico)
+	productoGroup.POST("", productoCtrl.Crear, "Crear producto", SoloAdmin)
+	productoGroup.PUT("/:id", productoCtrl.Actualizar, "Actualizar producto", SoloAdmin)
+	productoGroup.DELETE("/:id", productoCtrl.Eliminar, "Eliminar producto", SoloAdmin)
 
 	// ============================================
-	// GRUPO: Vendedores (SIN MIDDLEWARE - Auth aplicado globalmente)
+	// GRUPO: Vendedores (lectura pública, cambios solo admin)
 	// ============================================
 	vendedorGroup := router.Group("/api/v1/vendedores")
-	vendedorGroup.GET("", vendedorCtrl.Listar, "Listar vendedores")
-	vendedorGroup.POST("", vendedorCtrl.Crear, "Crear vendedor")
-	vendedorGroup.PUT("/:id", vendedorCtrl.Actualizar, "Actualizar vendedor")
-	vendedorGroup.DELETE("/:id", vendedorCtrl.Eliminar, "Eliminar vendedor")
+	vendedorGroup.GET("", vendedorCtrl.Listar, "Listar vendedores", Publico)
+	vendedorGroup.POST("", vendedorCtrl.Crear, "Crear vendedor", SoloAdmin)
+	vendedorGroup.PUT("/:id", vendedorCtrl.Actualizar, "Actualizar vendedor", SoloAdmin)
+	vendedorGroup.DELETE("/:id", vendedorCtrl.Eliminar, "Eliminar vendedor", SoloAdmin)
+
+	// ============================================
+	// GRUPO: Campañas (lectura pública, cambios solo admin)
+	// ============================================
+	campanaGroup := router.Group("/api/v1/campanas")
+	campanaGroup.GET("", campanaCtrl.Listar, "Listar campañas", Publico)
+	campanaGroup.POST("", campanaCtrl.Crear, "Crear campaña", SoloAdmin)
+	campanaGroup.PUT("/:id", campanaCtrl.Actualizar, "Actualizar campaña", SoloAdmin)
+
+	// ============================================
+	// GRUPO: Clientes (tienen teléfonos: requieren sesión; borrar, duplicados, fusión, exportación y anonimización solo admin)
+	// ============================================
+	clienteGroup := router.Group("/api/v1/clientes")
+	clienteGroup.GET("", clienteCtrl.Listar, "Listar o buscar clientes", Autenticado)
+	// Antes de /:id, que también matchea "duplicados"
+	clienteGroup.GET("/duplicados", clienteCtrl.Duplicados, "Detectar clientes duplicados", SoloAdmin)
+	clienteGroup.GET("/:id", clienteCtrl.Obtener, "Obtener cliente", Autenticado)
+	clienteGroup.GET("/:id/historial", clienteCtrl.Historial, "Historial de compras del cliente", Autenticado)
+	clienteGroup.POST("", clienteCtrl.Crear, "Crear cliente", Autenticado)
+	clienteGroup.PUT("/:id", clienteCtrl.Actualizar, "Actualizar cliente", Autenticado)
+	clienteGroup.DELETE("/:id", clienteCtrl.Eliminar, "Eliminar cliente", SoloAdmin)
+	clienteGroup.POST("/:id/fusionar", clienteCtrl.Fusionar, "Fusionar cliente duplicado", SoloAdmin)
+	clienteGroup.GET("/:id/exportar", clienteCtrl.Exportar, "Exportar datos personales del cliente", SoloAdmin)
+	clienteGroup.POST("/:id/anonimizar", clienteCtrl.Anonimizar, "Anonimizar cliente", SoloAdmin)
 
 	// ============================================
-	// GRUPO: Usuarios (SIN MIDDLEWARE - Auth aplicado globalmente)
+	// GRUPO: Usuarios (solo admin)
 	// ============================================
 	usuarioGroup := router.Group("/api/v1/usuarios")
-	usuarioGroup.GET("", usuarioCtrl.Listar, "Listar usuarios")
-	usuarioGroup.POST("", usuarioCtrl.Crear, "Crear usuario")
-	usuarioGroup.PUT("/:id", usuarioCtrl.Actualizar, "Actualizar usuario")
-	usuarioGroup.DELETE("/:id", usuarioCtrl.Eliminar, "Eliminar usuario")
+	usuarioGroup.GET("", usuarioCtrl.Listar, "Listar usuarios", SoloAdmin)
+	usuarioGroup.POST("", usuarioCtrl.Crear, "Crear usuario", SoloAdmin)
+	usuarioGroup.PUT("/:id", usuarioCtrl.Actualizar, "Actualizar usuario", SoloAdmin)
+	usuarioGroup.DELETE("/:id", usuarioCtrl.Eliminar, "Eliminar usuario", SoloAdmin)
+	usuarioGroup.POST("/:id/revocar-sesiones", usuarioCtrl.RevocarSesiones, "Revocar sesiones de usuario", SoloAdmin)
+	usuarioGroup.POST("/:id/desbloquear", usuarioCtrl.Desbloquear, "Desbloquear login de usuario", SoloAdmin)
+	usuarioGroup.POST("/:id/reset-password", usuarioCtrl.GenerarResetPassword, "Generar token de restablecimiento", SoloAdmin)
+
+	// ============================================
+	// GRUPO: Auditoría (solo admin)
+	// ============================================
+	auditoriaGroup := router.Group("/api/v1/auditoria")
+	auditoriaGroup.GET("", auditoriaCtrl.Listar, "Listar auditoría de cambios", SoloAdmin)
 
 	// ============================================
 	// GRUPO: Health Check
 	// ============================================
 	healthGroup := router.Group("/api/v1/health")
-	healthGroup.GET("", healthCtrl(), "Health check")
+	healthGroup.GET("", healthCtrl(), "Health check", Publico)
 
 	// ============================================
 	// RUTAS LEGACY para Backward Compatibility (ahora en /api/v1)
 	// ============================================
 	apiGroup := router.Group("/api/v1")
-	apiGroup.POST("/login", authCtrl.Login, "Login")
-	apiGroup.GET("/data", dataCtrl.ObtenerData, "Datos iniciales")
-	apiGroup.POST("/submit", ventaCtrl.CrearVenta, "Crear venta")
-	apiGroup.GET("/estadisticas", ventaCtrl.ObtenerTodasVentas, "Estadísticas")
-	apiGroup.GET("/estadisticas-sheet", ventaCtrl.ObtenerEstadisticas, "Estadísticas Sheet")
-	apiGroup.POST("/actualizar-venta/:id", ventaCtrl.ActualizarVenta, "Actualizar venta")
-
-	apiGroup.GET("/productos", productoCtrl.Listar, "Listar productos")
-	apiGroup.POST("/crear-producto", productoCtrl.Crear, "Crear producto")
-	apiGroup.PUT("/actualizar-producto/:id", productoCtrl.Actualizar, "Actualizar producto")
-	apiGroup.DELETE("/eliminar-producto/:id", productoCtrl.Eliminar, "Eliminar producto")
-
-	apiGroup.POST("/crear-vendedor", vendedorCtrl.Crear, "Crear vendedor")
-	apiGroup.PUT("/actualizar-vendedor/:id", vendedorCtrl.Actualizar, "Actualizar vendedor")
-	apiGroup.DELETE("/eliminar-vendedor/:id", vendedorCtrl.Eliminar, "Eliminar vendedor")
-
-	apiGroup.GET("/usuarios", usuarioCtrl.Listar, "Listar usuarios")
-	apiGroup.POST("/crear-usuario", usuarioCtrl.Crear, "Crear usuario")
-	apiGroup.PUT("/actualizar-usuario/:id", usuarioCtrl.Actualizar, "Actualizar usuario")
-	apiGroup.DELETE("/eliminar-usuario/:id", usuarioCtrl.Eliminar, "Eliminar usuario")
-
-	apiGroup.POST("/limpiar-base-datos", dataCtrl.LimpiarBaseDatos, "Limpiar base de datos")
+	apiGroup.POST("/login", authCtrl.Login, "Login", Publico)
+	apiGroup.GET("/data", dataCtrl.ObtenerData, "Datos iniciales", Autenticado)
+	apiGroup.POST("/submit", ventaCtrl.CrearVenta, "Crear venta", AdminOVendedor)
+	apiGroup.GET("/estadisticas", ventaCtrl.ObtenerTodasVentas, "Estadísticas", Autenticado)
+	apiGroup.GET("/estadisticas-sheet", ventaCtrl.ObtenerEstadisticas, "Estadísticas Sheet", Autenticado)
+	apiGroup.POST("/actualizar-venta/:id", ventaCtrl.ActualizarVenta, "Actualizar venta", AdminOVendedor)
+
+	apiGroup.GET("/productos", productoCtrl.Listar, "Listar productos", Publico)
+	apiGroup.POST("/crear-producto", productoCtrl.Crear, "Crear producto", SoloAdmin)
+	apiGroup.PUT("/actualizar-producto/:id", productoCtrl.Actualizar, "Actualizar producto", SoloAdmin)
+	apiGroup.DELETE("/eliminar-producto/:id", productoCtrl.Eliminar, "Eliminar producto", SoloAdmin)
+
+	apiGroup.POST("/crear-vendedor", vendedorCtrl.Crear, "Crear vendedor", SoloAdmin)
+	apiGroup.PUT("/actualizar-vendedor/:id", vendedorCtrl.Actualizar, "Actualizar vendedor", SoloAdmin)
+	apiGroup.DELETE("/eliminar-vendedor/:id", vendedorCtrl.Eliminar, "Eliminar vendedor", SoloAdmin)
+
+	apiGroup.GET("/usuarios", usuarioCtrl.Listar, "Listar usuarios", SoloAdmin)
+	apiGroup.POST("/crear-usuario", usuarioCtrl.Crear, "Crear usuario", SoloAdmin)
+	apiGroup.PUT("/actualizar-usuario/:id", usuarioCtrl.Actualizar, "Actualizar usuario", SoloAdmin)
+	apiGroup.DELETE("/eliminar-usuario/:id", usuarioCtrl.Eliminar, "Eliminar usuario", SoloAdmin)
+
+	apiGroup.POST("/limpiar-base-datos", dataCtrl.LimpiarBaseDatos, "Limpiar base de datos", SoloAdmin)
 
 	return router
 }
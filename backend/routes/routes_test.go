@@ -0,0 +1,215 @@
+package routes
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"pizzas-ecos/database"
+	"pizzas-ecos/httputil"
+	"pizzas-ecos/middleware"
+	"pizzas-ecos/models"
+	"pizzas-ecos/services"
+)
+
+// Regenerar la política esperada tras agregar o cambiar rutas:
+//
+//	go test ./routes -run TestPoliticaDeRutas -update
+var update = flag.Bool("update", false, "regenera testdata/politicas.golden")
+
+const politicasGolden = "testdata/politicas.golden"
+
+// TestMain firma los tokens de prueba con el secreto de desarrollo, que solo
+// se acepta con ENV=local
+func TestMain(m *testing.M) {
+	os.Setenv("ENV", "local")
+	os.Exit(m.Run())
+}
+
+// describirPoliticas lista "MÉTODO RUTA política" de cada ruta registrada
+func describirPoliticas(router *Router) string {
+	var b strings.Builder
+	b.WriteString("# Generado por: go test ./routes -run TestPoliticaDeRutas -update\n")
+	for _, route := range router.GetRoutes() {
+		fmt.Fprintf(&b, "%s %s %s\n", route.Method, route.Path, route.Acceso)
+	}
+	return b.String()
+}
+
+func TestPoliticaDeRutas(t *testing.T) {
+	// Arrange
+	router := SetupRoutes(database.NewMemoryStore())
+
+	// Act
+	actual := describirPoliticas(router)
+
+	// Assert
+	if *update {
+		if err := os.WriteFile(filepath.FromSlash(politicasGolden), []byte(actual), 0644); err != nil {
+			t.Fatalf("escribiendo %s: %v", politicasGolden, err)
+		}
+	}
+	esperado, err := os.ReadFile(filepath.FromSlash(politicasGolden))
+	if err != nil {
+		t.Fatalf("leyendo %s: %v", politicasGolden, err)
+	}
+	if actual != string(esperado) {
+		t.Errorf("la política de rutas cambió; revisar y regenerar con -update.\ngot:\n%s\nwant:\n%s", actual, esperado)
+	}
+}
+
+// tokenDePrueba crea el usuario "test-<rol>" en store y le inicia sesión
+// como lo hace el login
+func tokenDePrueba(t *testing.T, store database.Store, rol string) *models.LoginResponse {
+	t.Helper()
+	id, err := store.Usuarios().CreateUser("test-"+rol, "password", rol, nil)
+	if err != nil {
+		t.Fatalf("creando usuario: %v", err)
+	}
+	sesion, err := services.NewAuthService(store).IniciarSesion(&models.User{ID: id, Username: "test-" + rol, Rol: rol})
+	if err != nil {
+		t.Fatalf("iniciando sesión: %v", err)
+	}
+	return sesion
+}
+
+func TestRouter_AplicaPoliticaDeCadaRuta(t *testing.T) {
+	// Arrange
+	store := database.NewMemoryStore()
+	router := SetupRoutes(store)
+	mux := http.NewServeMux()
+	router.Register(mux)
+	tokenVendedor := tokenDePrueba(t, store, models.RolVendedor).Token
+
+	for _, route := range router.GetRoutes() {
+		if !route.Acceso.Autenticado {
+			continue
+		}
+		path := strings.ReplaceAll(route.Path, ":id", "1")
+
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			// Act: sin token
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(route.Method, path, nil))
+
+			// Assert
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("sin token status = %d, want 401", w.Code)
+			}
+
+			// Act: token de un rol que la ruta no permite
+			if route.Acceso.Permite(models.RolVendedor) {
+				return
+			}
+			req := httptest.NewRequest(route.Method, path, nil)
+			req.Header.Set("Authorization", "Bearer "+tokenVendedor)
+			w = httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			// Assert
+			if w.Code != http.StatusForbidden {
+				t.Errorf("rol vendedor status = %d, want 403", w.Code)
+			}
+		})
+	}
+}
+
+func TestRouter_TokenValidoLlegaAlHandler(t *testing.T) {
+	// Arrange
+	router := NewRouter()
+	var usuario string
+	router.Group("/api/v1/test").GET("", func(w http.ResponseWriter, r *http.Request) {
+		usuario = httputil.GetPrincipal(r).Nombre()
+	}, "Test", SoloAdmin)
+	mux := http.NewServeMux()
+	router.Register(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/test", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenDePrueba(t, database.NewMemoryStore(), models.RolAdmin).Token)
+	w := httptest.NewRecorder()
+
+	// Act
+	mux.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusOK || usuario != "test-admin" {
+		t.Errorf("status = %d, usuario = %q, want 200 y test-admin", w.Code, usuario)
+	}
+}
+
+func TestRouter_RechazaTokensRevocados(t *testing.T) {
+	// Arrange
+	store := database.NewMemoryStore()
+	router := SetupRoutes(store)
+	mux := http.NewServeMux()
+	router.Register(mux)
+	sesion := tokenDePrueba(t, store, models.RolAdmin)
+
+	// Token firmado con la clave correcta pero sin jti (emitido antes de las sesiones)
+	sinJTI, err := middleware.FirmarToken(models.TokenClaims{
+		UserID:   sesion.User.ID,
+		Username: sesion.User.Username,
+		Rol:      models.RolAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("firmando token: %v", err)
+	}
+
+	pedir := func(method, path, token, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Act + Assert: el token vigente pasa y el que no tiene jti no
+	if code := pedir(http.MethodGet, "/api/v1/usuarios", sesion.Token, ""); code != http.StatusOK {
+		t.Fatalf("token vigente status = %d, want 200", code)
+	}
+	if code := pedir(http.MethodGet, "/api/v1/usuarios", sinJTI, ""); code != http.StatusUnauthorized {
+		t.Errorf("token sin jti status = %d, want 401", code)
+	}
+
+	// Act + Assert: después del logout el mismo access token ya no sirve
+	if code := pedir(http.MethodPost, "/api/v1/auth/logout", sesion.Token, `{"refresh_token":"`+sesion.RefreshToken+`"}`); code != http.StatusOK {
+		t.Fatalf("logout status = %d, want 200", code)
+	}
+	if code := pedir(http.MethodGet, "/api/v1/usuarios", sesion.Token, ""); code != http.StatusUnauthorized {
+		t.Errorf("token tras logout status = %d, want 401", code)
+	}
+}
+
+func TestRouter_VentasRequierenRolAdminOVendedor(t *testing.T) {
+	// Arrange: un rol que no es admin ni vendedor
+	store := database.NewMemoryStore()
+	router := SetupRoutes(store)
+	mux := http.NewServeMux()
+	router.Register(mux)
+	token := tokenDePrueba(t, store, "lector").Token
+
+	for _, path := range []string{"/api/v1/ventas", "/api/v1/submit", "/api/v1/ventas/1/pagos"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(w, req)
+
+		// Assert
+		if w.Code != http.StatusForbidden {
+			t.Errorf("POST %s con rol lector status = %d, want 403", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"pizzas-ecos/database"
+	"pizzas-ecos/models"
+)
+
+// limitAuditoriaDefault es la cantidad de entradas que retorna el listado si
+// no se indica limit
+const limitAuditoriaDefault = 100
+
+var entidadesAuditadas = map[string]bool{
+	models.EntidadVenta:    true,
+	models.EntidadProducto: true,
+	models.EntidadVendedor: true,
+	models.EntidadUsuario:  true,
+	models.EntidadCampana:  true,
+	models.EntidadCliente:  true,
+}
+
+// AuditoriaServiceInterface define la consulta del registro de cambios
+type AuditoriaServiceInterface interface {
+	ObtenerAuditoria(filtro models.AuditoriaFiltro) ([]models.Auditoria, error)
+}
+
+// AuditoriaService expone el registro de cambios escrito por los demás servicios
+type AuditoriaService struct {
+	store database.Store
+}
+
+// NewAuditoriaService crea el servicio de auditoría sobre el store indicado
+func NewAuditoriaService(store database.Store) *AuditoriaService {
+	return &AuditoriaService{store: store}
+}
+
+// ObtenerAuditoria retorna las entradas que cumplen el filtro, de la más
+// nueva a la más vieja. Sin limit retorna las últimas 100
+func (s *AuditoriaService) ObtenerAuditoria(filtro models.AuditoriaFiltro) ([]models.Auditoria, error) {
+	if filtro.Entidad != "" && !entidadesAuditadas[filtro.Entidad] {
+		return nil, fmt.Errorf("%w: entidad %q no auditada", ErrFiltroInvalido, filtro.Entidad)
+	}
+	if filtro.Limit == 0 {
+		filtro.Limit = limitAuditoriaDefault
+	}
+
+	entradas, err := s.store.Auditoria().GetAuditoria(filtro)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo auditoría: %w", err)
+	}
+	return entradas, nil
+}
+
+// auditar registra un cambio en la transacción tx. antes es nil al crear y
+// despues es nil al eliminar; una actualización sin diferencias no se registra
+func auditar(tx database.Store, entidad string, id int, accion string, actor *models.Principal, antes, despues interface{}) error {
+	cambios, err := diffAuditoria(antes, despues)
+	if err != nil {
+		return fmt.Errorf("error armando auditoría: %w", err)
+	}
+	if cambios == nil {
+		return nil
+	}
+
+	_, err = tx.Auditoria().InsertAuditoria(models.Auditoria{
+		Entidad:   entidad,
+		EntidadID: id,
+		Accion:    accion,
+		UsuarioID: actor.UsuarioID(),
+		Usuario:   actor.Nombre(),
+		Cambios:   cambios,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error registrando auditoría: %w", err)
+	}
+	return nil
+}
+
+// diffAuditoria compara las representaciones JSON de antes y despues y
+// retorna {"campo": {"antes": x, "despues": y}} con los campos que difieren,
+// o nil si no hay diferencias
+func diffAuditoria(antes, despues interface{}) (json.RawMessage, error) {
+	a, err := aMapaJSON(antes)
+	if err != nil {
+		return nil, err
+	}
+	d, err := aMapaJSON(despues)
+	if err != nil {
+		return nil, err
+	}
+
+	type cambio struct {
+		Antes   interface{} `json:"antes"`
+		Despues interface{} `json:"despues"`
+	}
+	cambios := map[string]cambio{}
+	for campo, valor := range a {
+		if !reflect.DeepEqual(valor, d[campo]) {
+			cambios[campo] = cambio{Antes: valor, Despues: d[campo]}
+		}
+	}
+	for campo, valor := range d {
+		if _, ok := a[campo]; !ok {
+			cambios[campo] = cambio{Despues: valor}
+		}
+	}
+	if len(cambios) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(cambios)
+}
+
+// aMapaJSON convierte v (struct, puntero o nil) en sus campos JSON
+func aMapaJSON(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pizzas-ecos/database"
+	"pizzas-ecos/logger"
+	"pizzas-ecos/models"
+)
+
+// maxNombreCampana es el largo de la columna campanas.nombre
+const maxNombreCampana = 100
+
+// CampanaServiceInterface define los métodos del servicio de campañas
+type CampanaServiceInterface interface {
+	ObtenerCampanas() ([]models.Campana, error)
+	CrearCampana(req *models.CampanaRequest, actor *models.Principal) (int, error)
+	ActualizarCampana(id int, req *models.CampanaRequest, actor *models.Principal) error
+}
+
+// CampanaService administra las campañas (ediciones) de la venta. A lo sumo
+// una está activa: activar una desactiva las demás
+type CampanaService struct {
+	store database.Store
+}
+
+// NewCampanaService crea el servicio de campañas. Recibe el Store porque
+// crear una campaña puede copiar productos y cambiar otras campañas en la
+// misma transacción
+func NewCampanaService(store database.Store) *CampanaService {
+	return &CampanaService{store: store}
+}
+
+// ObtenerCampanas retorna todas las campañas, de la más reciente a la más antigua
+func (s *CampanaService) ObtenerCampanas() ([]models.Campana, error) {
+	campanas, err := s.store.Campanas().GetCampanas()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo campañas: %w", err)
+	}
+	return campanas, nil
+}
+
+// CrearCampana crea una campaña. Con CopiarProductosDe arranca con una copia
+// de los productos activos de esa campaña (mismos nombres y precios), que
+// después se pueden cambiar sin tocar la anterior
+func (s *CampanaService) CrearCampana(req *models.CampanaRequest, actor *models.Principal) (int, error) {
+	campana, err := campanaDesdeRequest(req)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = s.store.WithTx(context.Background(), func(tx database.Store) error {
+		var productos []models.Producto
+		if req.CopiarProductosDe != 0 {
+			origen, err := resolverCampana(tx.Campanas(), req.CopiarProductosDe)
+			if err != nil {
+				return err
+			}
+			if productos, err = tx.Productos().GetProductos(origen.ID); err != nil {
+				return fmt.Errorf("error obteniendo productos a copiar: %w", err)
+			}
+		}
+
+		if campana.Activa {
+			if err := desactivarCampanas(tx, 0, actor); err != nil {
+				return err
+			}
+		}
+		id, err = tx.Campanas().CreateCampana(campana, actor.UsuarioID())
+		if err != nil {
+			return err
+		}
+		for _, p := range productos {
+			if _, err := tx.Productos().CreateProducto(id, p.TipoPizza, p.Descripcion, p.Precio, actor.UsuarioID()); err != nil {
+				return fmt.Errorf("error copiando producto %d: %w", p.ID, err)
+			}
+		}
+
+		despues, err := tx.Campanas().GetCampanaByID(id)
+		if err != nil {
+			return err
+		}
+		return auditar(tx, models.EntidadCampana, id, models.AccionCrear, actor, nil, despues)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error creando campaña: %w", err)
+	}
+
+	logger.Info("CrearCampana: Campaña creada", map[string]interface{}{
+		"campana_id":          id,
+		"activa":              campana.Activa,
+		"copiar_productos_de": req.CopiarProductosDe,
+		"por":                 actor.Nombre(),
+	})
+	return id, nil
+}
+
+// ActualizarCampana cambia nombre, fechas o estado de una campaña. Activarla
+// desactiva la que estuviera activa; desactivarla deja la venta cerrada hasta
+// que se active otra
+func (s *CampanaService) ActualizarCampana(id int, req *models.CampanaRequest, actor *models.Principal) error {
+	campana, err := campanaDesdeRequest(req)
+	if err != nil {
+		return err
+	}
+	campana.ID = id
+
+	return s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Campanas().GetCampanaByID(id)
+		if err == sql.ErrNoRows {
+			return ErrCampanaNoEncontrada
+		}
+		if err != nil {
+			return err
+		}
+
+		if campana.Activa && !antes.Activa {
+			if err := desactivarCampanas(tx, id, actor); err != nil {
+				return err
+			}
+		}
+		if err := tx.Campanas().UpdateCampana(campana, actor.UsuarioID()); err != nil {
+			return err
+		}
+
+		despues, err := tx.Campanas().GetCampanaByID(id)
+		if err != nil {
+			return err
+		}
+		return auditar(tx, models.EntidadCampana, id, models.AccionActualizar, actor, antes, despues)
+	})
+}
+
+// desactivarCampanas desactiva, con su entrada de auditoría, toda campaña
+// activa distinta de excepto
+func desactivarCampanas(tx database.Store, excepto int, actor *models.Principal) error {
+	campanas, err := tx.Campanas().GetCampanas()
+	if err != nil {
+		return err
+	}
+	for _, c := range campanas {
+		if !c.Activa || c.ID == excepto {
+			continue
+		}
+		antes := c
+		c.Activa = false
+		if err := tx.Campanas().UpdateCampana(c, actor.UsuarioID()); err != nil {
+			return fmt.Errorf("error desactivando campaña %d: %w", c.ID, err)
+		}
+		if err := auditar(tx, models.EntidadCampana, c.ID, models.AccionActualizar, actor, antes, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// campanaDesdeRequest valida el request y lo convierte en campaña. Los
+// errores envuelven ErrCampanaInvalida
+func campanaDesdeRequest(req *models.CampanaRequest) (models.Campana, error) {
+	c := models.Campana{Nombre: strings.TrimSpace(req.Nombre), Activa: req.Activa}
+	if c.Nombre == "" {
+		return c, fmt.Errorf("%w: nombre es requerido", ErrCampanaInvalida)
+	}
+	if len([]rune(c.Nombre)) > maxNombreCampana {
+		return c, fmt.Errorf("%w: nombre demasiado largo (máximo %d caracteres)", ErrCampanaInvalida, maxNombreCampana)
+	}
+
+	inicio, err := time.Parse("2006-01-02", req.FechaInicio)
+	if err != nil {
+		return c, fmt.Errorf("%w: fecha_inicio debe tener formato YYYY-MM-DD", ErrCampanaInvalida)
+	}
+	c.FechaInicio = inicio
+
+	if req.FechaFin != "" {
+		fin, err := time.Parse("2006-01-02", req.FechaFin)
+		if err != nil {
+			return c, fmt.Errorf("%w: fecha_fin debe tener formato YYYY-MM-DD", ErrCampanaInvalida)
+		}
+		if fin.Before(inicio) {
+			return c, fmt.Errorf("%w: fecha_fin no puede ser anterior a fecha_inicio", ErrCampanaInvalida)
+		}
+		c.FechaFin = &fin
+	}
+	return c, nil
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"pizzas-ecos/database"
+	"pizzas-ecos/logger"
+	"pizzas-ecos/models"
+	"pizzas-ecos/telefono"
+)
+
+// Tamaño de la lista del autocompletado de clientes
+const (
+	LimitBusquedaClientes    = 10
+	MaxLimitBusquedaClientes = 50
+)
+
+// ClienteServiceInterface define los métodos del servicio de clientes
+type ClienteServiceInterface interface {
+	ObtenerClientes() ([]models.Cliente, error)
+	BuscarClientes(q string, limit int) ([]models.Cliente, error)
+	ObtenerCliente(id int) (*models.Cliente, error)
+	ObtenerHistorial(id int) (*models.HistorialCliente, error)
+	CrearCliente(req *models.ClienteRequest, actor *models.Principal) (int, error)
+	ActualizarCliente(id int, req *models.ClienteRequest, actor *models.Principal) error
+	EliminarCliente(id int, actor *models.Principal) error
+	BuscarDuplicados(umbral float64) ([]models.ClienteDuplicado, error)
+	FusionarClientes(id, duplicadoID int, actor *models.Principal) (int, error)
+	ExportarCliente(id int, actor *models.Principal) (*models.ExportacionCliente, error)
+	AnonimizarCliente(id int, actor *models.Principal) error
+}
+
+// ClienteService administra los clientes. Además de este servicio, CrearVenta
+// y ActualizarVenta crean clientes al vuelo por nombre
+type ClienteService struct {
+	store database.Store
+}
+
+// NewClienteService crea el servicio de clientes
+func NewClienteService(store database.Store) *ClienteService {
+	return &ClienteService{store: store}
+}
+
+// ObtenerClientes retorna todos los clientes, tengan o no ventas
+func (s *ClienteService) ObtenerClientes() ([]models.Cliente, error) {
+	clientes, err := s.store.Clientes().GetAllClientes()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo clientes: %w", err)
+	}
+	if clientes == nil {
+		clientes = []models.Cliente{}
+	}
+	return clientes, nil
+}
+
+// BuscarClientes retorna los clientes cuyo nombre o alguna de sus palabras
+// empieza con q, sin distinguir mayúsculas ni acentos, en el orden del
+// autocompletado. limit 0 usa LimitBusquedaClientes
+func (s *ClienteService) BuscarClientes(q string, limit int) ([]models.Cliente, error) {
+	if limit == 0 {
+		limit = LimitBusquedaClientes
+	}
+	if limit < 0 || limit > MaxLimitBusquedaClientes {
+		return nil, fmt.Errorf("%w: limit debe estar entre 1 y %d", ErrFiltroInvalido, MaxLimitBusquedaClientes)
+	}
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return []models.Cliente{}, nil
+	}
+
+	clientes, err := s.store.Clientes().BuscarClientes(q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error buscando clientes: %w", err)
+	}
+	return clientes, nil
+}
+
+// ObtenerCliente retorna un cliente o ErrClienteNoEncontrado
+func (s *ClienteService) ObtenerCliente(id int) (*models.Cliente, error) {
+	cliente, err := s.store.Clientes().GetClienteByID(id)
+	if err == sql.ErrNoRows {
+		return nil, ErrClienteNoEncontrado
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo cliente: %w", err)
+	}
+	return cliente, nil
+}
+
+// ObtenerHistorial retorna todas las ventas de un cliente, incluidas las
+// canceladas, con sus items y los totales de sus compras
+func (s *ClienteService) ObtenerHistorial(id int) (*models.HistorialCliente, error) {
+	cliente, err := s.ObtenerCliente(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ventas, _, err := s.store.Ventas().GetAllVentas(models.VentaFiltro{
+		ClienteID:         id,
+		IncluirCanceladas: true,
+		Orden:             "-fecha",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo ventas del cliente: %w", err)
+	}
+
+	return &models.HistorialCliente{
+		Cliente: *cliente,
+		Resumen: resumirCompras(ventas),
+		Ventas:  ventas,
+	}, nil
+}
+
+// resumirCompras calcula los totales de un cliente sin contar las ventas
+// canceladas. La deuda es lo que falta cobrar de las ventas sin_pagar,
+// descontando los pagos parciales
+func resumirCompras(ventas []models.VentaStats) models.ResumenCliente {
+	resumen := models.ResumenCliente{}
+	unidades := map[string]int{}
+	for i := range ventas {
+		v := &ventas[i]
+		if v.Estado == string(models.EstadoCancelada) {
+			continue
+		}
+
+		resumen.CantidadPedidos++
+		resumen.TotalGastado += v.Total
+		if v.Estado == string(models.EstadoSinPagar) {
+			resumen.Deuda += database.SaldoPendiente(v.Total, v.Pagado)
+		}
+		if resumen.PrimeraCompra == nil || v.CreatedAt.Before(*resumen.PrimeraCompra) {
+			resumen.PrimeraCompra = &v.CreatedAt
+		}
+		if resumen.UltimaCompra == nil || v.CreatedAt.After(*resumen.UltimaCompra) {
+			resumen.UltimaCompra = &v.CreatedAt
+		}
+		for _, item := range v.Items {
+			unidades[item.Tipo] += item.Cantidad
+		}
+	}
+	resumen.TotalGastado = database.RedondearMonto(resumen.TotalGastado)
+	resumen.Deuda = database.RedondearMonto(resumen.Deuda)
+
+	// A igual cantidad gana el primero en orden alfabético, para que el
+	// resultado no dependa del orden del map
+	for tipo, cantidad := range unidades {
+		f := resumen.ProductoFavorito
+		if f == nil || cantidad > f.Cantidad || (cantidad == f.Cantidad && tipo < f.Tipo) {
+			resumen.ProductoFavorito = &models.ProductoFavorito{Tipo: tipo, Cantidad: cantidad}
+		}
+	}
+	return resumen
+}
+
+// CrearCliente crea un cliente. El nombre normalizado no puede repetirse
+// porque las ventas identifican al cliente por nombre (ErrClienteDuplicado).
+// El teléfono se guarda en E.164 (ErrTelefonoInvalido si no puede existir)
+func (s *ClienteService) CrearCliente(req *models.ClienteRequest, actor *models.Principal) (int, error) {
+	nombre := strings.TrimSpace(req.Nombre)
+	tel, err := telefonoOpcional(req.Telefono)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = s.store.WithTx(context.Background(), func(tx database.Store) error {
+		var err error
+		id, err = tx.Clientes().CreateClienteWithTelefono(nombre, tel)
+		if err != nil {
+			return err
+		}
+		despues := models.Cliente{ID: id, Nombre: nombre, Telefono: telefonoModelo(tel)}
+		return auditar(tx, models.EntidadCliente, id, models.AccionCrear, actor, nil, despues)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error creando cliente: %w", err)
+	}
+
+	logger.Info("CrearCliente: Cliente creado", map[string]interface{}{
+		"cliente_id": id,
+		"por":        actor.Nombre(),
+	})
+	return id, nil
+}
+
+// ActualizarCliente reemplaza nombre y teléfono de un cliente. Telefono
+// vacío lo borra
+func (s *ClienteService) ActualizarCliente(id int, req *models.ClienteRequest, actor *models.Principal) error {
+	nombre := strings.TrimSpace(req.Nombre)
+	tel, err := telefonoOpcional(req.Telefono)
+	if err != nil {
+		return err
+	}
+
+	return s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Clientes().GetClienteByID(id)
+		if err == sql.ErrNoRows {
+			return ErrClienteNoEncontrado
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Clientes().UpdateCliente(id, nombre, tel); err != nil {
+			return err
+		}
+		despues := models.Cliente{ID: id, Nombre: nombre, Telefono: telefonoModelo(tel)}
+		return auditar(tx, models.EntidadCliente, id, models.AccionActualizar, actor, antes, despues)
+	})
+}
+
+// EliminarCliente elimina un cliente sin ventas. Con ventas retorna
+// ErrClienteConVentas: el historial de ventas no se pierde
+func (s *ClienteService) EliminarCliente(id int, actor *models.Principal) error {
+	return s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Clientes().GetClienteByID(id)
+		if err == sql.ErrNoRows {
+			return ErrClienteNoEncontrado
+		}
+		if err != nil {
+			return err
+		}
+		ventas, err := tx.Clientes().ContarVentasCliente(id)
+		if err != nil {
+			return err
+		}
+		if ventas > 0 {
+			return fmt.Errorf("%w (%d)", ErrClienteConVentas, ventas)
+		}
+		if err := tx.Clientes().DeleteCliente(id); err != nil {
+			return err
+		}
+		return auditar(tx, models.EntidadCliente, id, models.AccionEliminar, actor, antes, nil)
+	})
+}
+
+// BuscarDuplicados retorna los pares de clientes que probablemente son la
+// misma persona, con puntaje >= umbral. umbral 0 usa UmbralDuplicados
+func (s *ClienteService) BuscarDuplicados(umbral float64) ([]models.ClienteDuplicado, error) {
+	if umbral == 0 {
+		umbral = UmbralDuplicados
+	}
+	if umbral < 0 || umbral > 1 {
+		return nil, fmt.Errorf("%w: umbral debe estar entre 0 y 1", ErrFiltroInvalido)
+	}
+
+	clientes, err := s.store.Clientes().GetAllClientes()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo clientes: %w", err)
+	}
+	return detectarDuplicados(clientes, umbral), nil
+}
+
+// fusionCliente es el estado auditado del cliente que absorbe a otro
+type fusionCliente struct {
+	models.Cliente
+	FusionadoDe   int `json:"fusionado_de"`
+	VentasMovidas int `json:"ventas_movidas"`
+}
+
+// FusionarClientes pasa todas las ventas de duplicadoID al cliente id, le
+// copia el teléfono si no tenía y elimina el duplicado, todo en una
+// transacción. Retorna cuántas ventas movió
+func (s *ClienteService) FusionarClientes(id, duplicadoID int, actor *models.Principal) (int, error) {
+	if id == duplicadoID {
+		return 0, fmt.Errorf("%w: un cliente no puede fusionarse consigo mismo", ErrFusionInvalida)
+	}
+
+	var movidas int
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		cliente, err := tx.Clientes().GetClienteByID(id)
+		if err == sql.ErrNoRows {
+			return ErrClienteNoEncontrado
+		}
+		if err != nil {
+			return err
+		}
+		duplicado, err := tx.Clientes().GetClienteByID(duplicadoID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: duplicado_id %d", ErrClienteNoEncontrado, duplicadoID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if movidas, err = tx.Clientes().ReasignarVentasCliente(duplicadoID, id); err != nil {
+			return fmt.Errorf("error moviendo ventas: %w", err)
+		}
+		despues := fusionCliente{Cliente: *cliente, FusionadoDe: duplicadoID, VentasMovidas: movidas}
+		if cliente.Telefono == "" && duplicado.Telefono != "" {
+			tel := string(duplicado.Telefono)
+			if err := tx.Clientes().UpdateClienteTelefono(id, &tel); err != nil {
+				return err
+			}
+			despues.Telefono = duplicado.Telefono
+		}
+		if err := tx.Clientes().DeleteCliente(duplicadoID); err != nil {
+			return err
+		}
+
+		if err := auditar(tx, models.EntidadCliente, duplicadoID, models.AccionEliminar, actor, duplicado, nil); err != nil {
+			return err
+		}
+		return auditar(tx, models.EntidadCliente, id, models.AccionFusionar, actor, cliente, despues)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	logger.Info("FusionarClientes: Clientes fusionados", map[string]interface{}{
+		"cliente_id":     id,
+		"duplicado_id":   duplicadoID,
+		"ventas_movidas": movidas,
+		"por":            actor.Nombre(),
+	})
+	return movidas, nil
+}
+
+// telefonoOpcional normaliza el teléfono del request a E.164 y lo convierte
+// al valor que guarda el repositorio ("" = sin teléfono = nil)
+func telefonoOpcional(t models.Telefono) (*string, error) {
+	normalizado, err := telefono.Normalizar(string(t))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTelefonoInvalido, err)
+	}
+	if normalizado == "" {
+		return nil, nil
+	}
+	return &normalizado, nil
+}
+
+// telefonoModelo es la inversa de telefonoOpcional
+func telefonoModelo(t *string) models.Telefono {
+	if t == nil {
+		return ""
+	}
+	return models.Telefono(*t)
+}
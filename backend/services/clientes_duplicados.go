@@ -0,0 +1,192 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"pizzas-ecos/database"
+	"pizzas-ecos/models"
+)
+
+// UmbralDuplicados es el puntaje mínimo por defecto para reportar un par de
+// clientes como posible duplicado
+const UmbralDuplicados = 0.7
+
+// Pesos del puntaje: el nombre pesa más porque muchos clientes no tienen
+// teléfono cargado
+const (
+	pesoNombreDuplicado   = 0.7
+	pesoTelefonoDuplicado = 0.3
+)
+
+// Motivos de models.ClienteDuplicado
+const (
+	MotivoNombreSimilar    = "nombre_similar"
+	MotivoMismoTelefono    = "mismo_telefono"
+	MotivoTelefonoDistinto = "telefono_distinto"
+)
+
+// detectarDuplicados retorna los pares de clientes con puntaje >= umbral, del
+// más probable al menos probable. Para no comparar todos contra todos solo
+// compara pares que comparten teléfono o las primeras letras de alguna palabra
+func detectarDuplicados(clientes []models.Cliente, umbral float64) []models.ClienteDuplicado {
+	nombres := make([]string, len(clientes))
+	bloques := map[string][]int{}
+	for i, c := range clientes {
+		nombres[i] = database.NormalizarNombre(c.Nombre)
+		for clave := range clavesDeBloque(nombres[i], c.Telefono) {
+			bloques[clave] = append(bloques[clave], i)
+		}
+	}
+
+	type par struct{ a, b int }
+	vistos := map[par]bool{}
+	duplicados := []models.ClienteDuplicado{}
+	for _, indices := range bloques {
+		for x := 0; x < len(indices); x++ {
+			for y := x + 1; y < len(indices); y++ {
+				p := par{indices[x], indices[y]}
+				if vistos[p] {
+					continue
+				}
+				vistos[p] = true
+
+				puntaje, motivos := puntajeDuplicado(clientes[p.a], clientes[p.b], nombres[p.a], nombres[p.b])
+				if puntaje < umbral {
+					continue
+				}
+				a, b := clientes[p.a], clientes[p.b]
+				if b.ID < a.ID {
+					a, b = b, a
+				}
+				duplicados = append(duplicados, models.ClienteDuplicado{Cliente: a, Duplicado: b, Puntaje: puntaje, Motivos: motivos})
+			}
+		}
+	}
+
+	sort.Slice(duplicados, func(i, j int) bool {
+		a, b := duplicados[i], duplicados[j]
+		if a.Puntaje != b.Puntaje {
+			return a.Puntaje > b.Puntaje
+		}
+		if a.Cliente.ID != b.Cliente.ID {
+			return a.Cliente.ID < b.Cliente.ID
+		}
+		return a.Duplicado.ID < b.Duplicado.ID
+	})
+	return duplicados
+}
+
+// clavesDeBloque retorna las claves con las que se agrupan los candidatos: el
+// teléfono y las tres primeras letras de cada palabra de al menos tres letras
+// (las más cortas, como "de" o una inicial, juntarían demasiados clientes)
+func clavesDeBloque(nombre string, telefono models.Telefono) map[string]bool {
+	claves := map[string]bool{}
+	for _, palabra := range strings.Fields(nombre) {
+		if r := []rune(palabra); len(r) >= 3 {
+			claves["n:"+string(r[:3])] = true
+		}
+	}
+	if len(claves) == 0 {
+		claves["n:"+nombre] = true
+	}
+	if telefono != "" {
+		claves["t:"+string(telefono)] = true
+	}
+	return claves
+}
+
+// puntajeDuplicado combina la similitud de los nombres normalizados con el
+// teléfono: igual suma, distinto resta y si falta en alguno queda neutro
+func puntajeDuplicado(a, b models.Cliente, nombreA, nombreB string) (float64, []string) {
+	motivos := []string{}
+	similitud := similitudNombres(nombreA, nombreB)
+	if similitud >= 0.8 {
+		motivos = append(motivos, MotivoNombreSimilar)
+	}
+
+	telefono := 0.5
+	switch {
+	case a.Telefono == "" || b.Telefono == "":
+	case a.Telefono == b.Telefono:
+		telefono = 1
+		motivos = append(motivos, MotivoMismoTelefono)
+	default:
+		telefono = 0
+		motivos = append(motivos, MotivoTelefonoDistinto)
+	}
+
+	puntaje := pesoNombreDuplicado*similitud + pesoTelefonoDuplicado*telefono
+	return math.Round(puntaje*100) / 100, motivos
+}
+
+// similitudNombres va de 0 a 1 y tolera errores de tipeo, palabras en otro
+// orden ("perez juan") y nombres abreviados ("juan p" contra "juan perez")
+func similitudNombres(a, b string) float64 {
+	similitud := similitudLevenshtein(a, b)
+
+	palabrasA, palabrasB := strings.Fields(a), strings.Fields(b)
+	ordenadasA := append([]string(nil), palabrasA...)
+	ordenadasB := append([]string(nil), palabrasB...)
+	sort.Strings(ordenadasA)
+	sort.Strings(ordenadasB)
+	similitud = max(similitud, similitudLevenshtein(strings.Join(ordenadasA, " "), strings.Join(ordenadasB, " ")))
+
+	if palabrasAbreviadas(palabrasA, palabrasB) {
+		similitud = max(similitud, 0.85)
+	}
+	return similitud
+}
+
+// palabrasAbreviadas indica si cada palabra del nombre más corto (de al menos
+// dos palabras) es el comienzo de una palabra distinta del más largo
+func palabrasAbreviadas(a, b []string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(a) < 2 {
+		return false
+	}
+	usadas := make([]bool, len(b))
+	for _, corta := range a {
+		encontrada := false
+		for i, larga := range b {
+			if !usadas[i] && strings.HasPrefix(larga, corta) {
+				usadas[i], encontrada = true, true
+				break
+			}
+		}
+		if !encontrada {
+			return false
+		}
+	}
+	return true
+}
+
+// similitudLevenshtein es 1 - distancia/largo del más largo
+func similitudLevenshtein(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	largo := max(len(ra), len(rb))
+	if largo == 0 {
+		return 1
+	}
+
+	anterior := make([]int, len(rb)+1)
+	actual := make([]int, len(rb)+1)
+	for j := range anterior {
+		anterior[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		actual[0] = i
+		for j := 1; j <= len(rb); j++ {
+			costo := 1
+			if ra[i-1] == rb[j-1] {
+				costo = 0
+			}
+			actual[j] = min(anterior[j]+1, actual[j-1]+1, anterior[j-1]+costo)
+		}
+		anterior, actual = actual, anterior
+	}
+	return 1 - float64(anterior[len(rb)])/float64(largo)
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"pizzas-ecos/database"
+	"pizzas-ecos/logger"
+	"pizzas-ecos/models"
+)
+
+// Ejercicio de los derechos de acceso y supresión de la Ley 25.326 sobre los
+// datos personales de un cliente (nombre y teléfono)
+
+// nombreAnonimizado es el nombre que reemplaza al de un cliente anonimizado.
+// Lleva el id porque el nombre normalizado es único
+const nombreAnonimizado = "Cliente anonimizado %d"
+
+// registroExportacion es lo que queda en la auditoría de una exportación:
+// cuánto se entregó, nunca los datos en sí
+type registroExportacion struct {
+	Ventas    int `json:"ventas"`
+	Auditoria int `json:"auditoria"`
+}
+
+// ExportarCliente retorna todos los datos guardados de un cliente: sus datos,
+// sus ventas (incluidas las canceladas) y las entradas de auditoría que lo
+// mencionan. La exportación queda registrada en la auditoría
+func (s *ClienteService) ExportarCliente(id int, actor *models.Principal) (*models.ExportacionCliente, error) {
+	var exportacion *models.ExportacionCliente
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		cliente, err := tx.Clientes().GetClienteByID(id)
+		if err == sql.ErrNoRows {
+			return ErrClienteNoEncontrado
+		}
+		if err != nil {
+			return err
+		}
+		ventas, _, err := tx.Ventas().GetAllVentas(models.VentaFiltro{ClienteID: id, IncluirCanceladas: true, Orden: "-fecha"})
+		if err != nil {
+			return fmt.Errorf("error obteniendo ventas del cliente: %w", err)
+		}
+		auditoria, err := auditoriaDeCliente(tx, id, ventas)
+		if err != nil {
+			return err
+		}
+
+		exportacion = &models.ExportacionCliente{
+			Cliente:     *cliente,
+			Ventas:      ventas,
+			Auditoria:   auditoria,
+			ExportadoAt: time.Now(),
+		}
+		registro := registroExportacion{Ventas: len(ventas), Auditoria: len(auditoria)}
+		return auditar(tx, models.EntidadCliente, id, models.AccionExportar, actor, nil, registro)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("ExportarCliente: Datos del cliente exportados", map[string]interface{}{
+		"cliente_id": id,
+		"por":        actor.Nombre(),
+	})
+	return exportacion, nil
+}
+
+// AnonimizarCliente borra el nombre y el teléfono de un cliente. Sus ventas
+// y montos se conservan para que los totales de las campañas no cambien, y
+// las entradas de auditoría del cliente y de sus ventas se reescriben sin
+// ninguno de los nombres y teléfonos que tuvo. La auditoría de la
+// anonimización no guarda los datos borrados
+func (s *ClienteService) AnonimizarCliente(id int, actor *models.Principal) error {
+	nombre := fmt.Sprintf(nombreAnonimizado, id)
+
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		cliente, err := tx.Clientes().GetClienteByID(id)
+		if err == sql.ErrNoRows {
+			return ErrClienteNoEncontrado
+		}
+		if err != nil {
+			return err
+		}
+		ventas, _, err := tx.Ventas().GetAllVentas(models.VentaFiltro{ClienteID: id, IncluirCanceladas: true})
+		if err != nil {
+			return fmt.Errorf("error obteniendo ventas del cliente: %w", err)
+		}
+		auditoria, err := auditoriaDeCliente(tx, id, ventas)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Clientes().UpdateCliente(id, nombre, nil); err != nil {
+			return err
+		}
+
+		// Cliente guarda nombre y telefono; las ventas, cliente y telefono_cliente.
+		// Solo se reemplazan los datos de este cliente: una venta reasignada
+		// conserva el nombre del cliente que tenía antes
+		datos := datosPersonales(cliente, auditoria)
+		campos := map[string]map[string]interface{}{
+			models.EntidadCliente: {"nombre": nombre, "telefono": ""},
+			models.EntidadVenta:   {"cliente": nombre, "telefono_cliente": nil},
+		}
+		for _, entrada := range auditoria {
+			cambios, modificada, err := redactarCambios(entrada.Cambios, campos[entrada.Entidad], datos)
+			if err != nil {
+				return fmt.Errorf("error anonimizando auditoría %d: %w", entrada.ID, err)
+			}
+			if !modificada {
+				continue
+			}
+			if err := tx.Auditoria().UpdateAuditoriaCambios(entrada.ID, cambios); err != nil {
+				return fmt.Errorf("error anonimizando auditoría %d: %w", entrada.ID, err)
+			}
+		}
+
+		despues := models.Cliente{ID: id, Nombre: nombre}
+		return auditar(tx, models.EntidadCliente, id, models.AccionAnonimizar, actor, nil, despues)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("AnonimizarCliente: Cliente anonimizado", map[string]interface{}{
+		"cliente_id": id,
+		"por":        actor.Nombre(),
+	})
+	return nil
+}
+
+// auditoriaDeCliente retorna las entradas de auditoría del cliente y de sus
+// ventas, de la más nueva a la más vieja
+func auditoriaDeCliente(tx database.Store, id int, ventas []models.VentaStats) ([]models.Auditoria, error) {
+	entradas, err := auditoriaCompleta(tx, models.EntidadCliente, id)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo auditoría del cliente: %w", err)
+	}
+	for _, v := range ventas {
+		deVenta, err := auditoriaCompleta(tx, models.EntidadVenta, v.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error obteniendo auditoría de la venta %d: %w", v.ID, err)
+		}
+		entradas = append(entradas, deVenta...)
+	}
+
+	sort.Slice(entradas, func(i, j int) bool { return entradas[i].ID > entradas[j].ID })
+	return entradas, nil
+}
+
+// auditoriaCompleta retorna todas las entradas de auditoría de una entidad,
+// pidiéndolas de a MaxLimitAuditoria
+func auditoriaCompleta(tx database.Store, entidad string, id int) ([]models.Auditoria, error) {
+	filtro := models.AuditoriaFiltro{
+		Entidad:   entidad,
+		EntidadID: id,
+		Limit:     database.MaxLimitAuditoria,
+	}
+	var entradas []models.Auditoria
+	for {
+		pagina, err := tx.Auditoria().GetAuditoria(filtro)
+		if err != nil {
+			return nil, err
+		}
+		entradas = append(entradas, pagina...)
+		if len(pagina) < filtro.Limit {
+			return entradas, nil
+		}
+		filtro.AntesDeID = pagina[len(pagina)-1].ID
+	}
+}
+
+// datosPersonales retorna el nombre y el teléfono actuales del cliente y los
+// que tuvo antes según las entradas de auditoría del cliente
+func datosPersonales(cliente *models.Cliente, auditoria []models.Auditoria) map[string]bool {
+	datos := map[string]bool{cliente.Nombre: true}
+	if cliente.Telefono != "" {
+		datos[string(cliente.Telefono)] = true
+	}
+	for _, entrada := range auditoria {
+		if entrada.Entidad != models.EntidadCliente {
+			continue
+		}
+		var m map[string]map[string]interface{}
+		if err := json.Unmarshal(entrada.Cambios, &m); err != nil {
+			continue
+		}
+		for _, campo := range []string{"nombre", "telefono"} {
+			for _, valor := range m[campo] {
+				if v, ok := valor.(string); ok && v != "" {
+					datos[v] = true
+				}
+			}
+		}
+	}
+	return datos
+}
+
+// redactarCambios reemplaza en cambios ({"campo": {"antes", "despues"}}) los
+// valores de los campos indicados que están en datos. Indica si modificó algo
+func redactarCambios(cambios json.RawMessage, campos map[string]interface{}, datos map[string]bool) (json.RawMessage, bool, error) {
+	var m map[string]map[string]interface{}
+	if err := json.Unmarshal(cambios, &m); err != nil {
+		return nil, false, err
+	}
+
+	modificada := false
+	for campo, reemplazo := range campos {
+		cambio, ok := m[campo]
+		if !ok {
+			continue
+		}
+		for _, lado := range []string{"antes", "despues"} {
+			if valor, ok := cambio[lado].(string); ok && datos[valor] && valor != reemplazo {
+				cambio[lado] = reemplazo
+				modificada = true
+			}
+		}
+	}
+	if !modificada {
+		return cambios, false, nil
+	}
+
+	redactados, err := json.Marshal(m)
+	return redactados, true, err
+}
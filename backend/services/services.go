@@ -2,266 +2,897 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
 
 	"pizzas-ecos/database"
 	"pizzas-ecos/logger"
+	"pizzas-ecos/middleware"
 	"pizzas-ecos/models"
+	"pizzas-ecos/telefono"
+	"pizzas-ecos/validators"
+)
+
+// Errores de negocio que los controladores traducen a códigos HTTP
+var (
+	// ErrFiltroInvalido: orden, cursor o limit inválidos en un listado (400)
+	ErrFiltroInvalido = database.ErrFiltroInvalido
+	// ErrVentaNoEncontrada: la venta pedida no existe (404)
+	ErrVentaNoEncontrada = errors.New("venta no encontrada")
+	// ErrTransicionInvalida: el cambio de estado no está permitido (409)
+	ErrTransicionInvalida = errors.New("transición de estado no permitida")
+	// ErrPagoRechazado: la venta está cancelada o el monto excede el saldo (409)
+	ErrPagoRechazado = errors.New("pago rechazado")
+	// ErrIdempotenciaConflicto: la Idempotency-Key ya se usó con otro cuerpo (422)
+	ErrIdempotenciaConflicto = errors.New("Idempotency-Key reutilizada con otro cuerpo")
+	// ErrSesionInvalida: refresh token inexistente, vencido o revocado (401)
+	ErrSesionInvalida = errors.New("sesión inválida o expirada")
+	// ErrUsuarioNoEncontrado: el usuario pedido no existe (404)
+	ErrUsuarioNoEncontrado = errors.New("usuario no encontrado")
+	// ErrCredencialesInvalidas: usuario o contraseña incorrectos (401)
+	ErrCredencialesInvalidas = errors.New("credenciales inválidas")
+	// ErrLoginBloqueado: demasiados intentos fallidos del usuario o la IP (429).
+	// Se retorna envuelto en un *LoginBloqueadoError con la espera
+	ErrLoginBloqueado = errors.New("demasiados intentos fallidos")
+	// ErrPasswordDebil indica que la contraseña nueva no cumple la política
+	ErrPasswordDebil = errors.New("la contraseña no cumple la política")
+	// ErrResetInvalido indica un token de restablecimiento inexistente, usado o vencido
+	ErrResetInvalido = errors.New("token de restablecimiento inválido o expirado")
+	// ErrConfirmacionInvalida indica que no se escribió la frase de confirmación
+	ErrConfirmacionInvalida = errors.New("confirmación inválida")
+	// ErrCampanaNoEncontrada: la campaña pedida no existe (404)
+	ErrCampanaNoEncontrada = errors.New("campaña no encontrada")
+	// ErrSinCampanaActiva: no hay campaña activa que reciba ventas ni a la que
+	// resolver un pedido sin campana_id (409)
+	ErrSinCampanaActiva = errors.New("no hay una campaña activa")
+	// ErrCampanaInvalida: nombre o fechas de la campaña inválidos (400)
+	ErrCampanaInvalida = errors.New("campaña inválida")
+	// ErrProductoOtraCampana: un item de la venta es de otra campaña (409)
+	ErrProductoOtraCampana = database.ErrProductoOtraCampana
+	// ErrClienteNoEncontrado: el cliente pedido no existe (404)
+	ErrClienteNoEncontrado = errors.New("cliente no encontrado")
+	// ErrClienteDuplicado: ya hay otro cliente con el mismo nombre normalizado (409)
+	ErrClienteDuplicado = database.ErrClienteDuplicado
+	// ErrClienteConVentas: el cliente tiene ventas y no se puede eliminar (409)
+	ErrClienteConVentas = errors.New("el cliente tiene ventas asociadas")
+	// ErrFusionInvalida: se pidió fusionar un cliente consigo mismo (400)
+	ErrFusionInvalida = errors.New("fusión de clientes inválida")
+	// ErrTelefonoInvalido: el teléfono no puede existir en su país (400)
+	ErrTelefonoInvalido = telefono.ErrInvalido
+	// ErrVendedorNoEncontrado: el vendedor indicado en la venta no existe (422)
+	ErrVendedorNoEncontrado = errors.New("vendedor no encontrado")
+	// ErrProductoNoEncontrado: el producto pedido no existe (404; 422 si es un
+	// item de la venta)
+	ErrProductoNoEncontrado = database.ErrProductoNoEncontrado
+	// ErrProductoInactivo: un item de la venta es un producto dado de baja (422)
+	ErrProductoInactivo = database.ErrProductoInactivo
+	// ErrCantidadInvalida: un item de la venta tiene cantidad no positiva (422)
+	ErrCantidadInvalida = database.ErrCantidadInvalida
+	// ErrItemInvalido: un item a actualizar no trae producto_id o cantidad (400)
+	ErrItemInvalido = database.ErrItemInvalido
 )
 
+// LoginBloqueadoError indica cuánto falta para poder volver a intentar el login
+type LoginBloqueadoError struct {
+	Espera time.Duration
+}
+
+func (e *LoginBloqueadoError) Error() string {
+	return fmt.Sprintf("%s, reintentar en %s", ErrLoginBloqueado, e.Espera.Round(time.Second))
+}
+
+// Unwrap permite errors.Is(err, ErrLoginBloqueado)
+func (e *LoginBloqueadoError) Unwrap() error { return ErrLoginBloqueado }
+
+// ItemRechazadoError indica qué item de la venta (y qué producto) no se puede
+// vender, al crearla o al agregarle líneas (422)
+type ItemRechazadoError = database.ItemRechazadoError
+
 // VentaServiceInterface define los métodos del servicio de ventas
 type VentaServiceInterface interface {
-	CrearVenta(req *models.VentaRequest) (int, error)
-	ActualizarVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}) error
-	ObtenerEstadisticas() (map[string]interface{}, error)
-	ObtenerTodasVentas() ([]models.VentaStats, error)
+	CrearVenta(req *models.VentaRequest, actor *models.Principal) (*models.VentaCreada, error)
+	CrearVentaIdempotente(req *models.VentaRequest, clave string, actor *models.Principal) (*models.VentaCreada, error)
+	ActualizarVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}, cliente *models.ClienteVenta, actor *models.Principal) error
+	ObtenerEstadisticas(campanaID int) (map[string]interface{}, error)
+	ObtenerTodasVentas(filtro models.VentaFiltro) ([]models.VentaStats, string, error)
+	ObtenerVenta(id int) (*models.VentaStats, error)
+	EliminarVenta(id int, actor *models.Principal) error
+	RegistrarPago(ventaID int, req *models.PagoRequest, actor *models.Principal) (*models.Pago, error)
+	ObtenerPagos(ventaID int) ([]models.Pago, error)
 }
 
 // ProductoServiceInterface define los métodos del servicio de productos
 type ProductoServiceInterface interface {
-	ObtenerProductos() ([]models.Producto, error)
-	CrearProducto(req *models.CrearProductoRequest) (int64, error)
-	ActualizarProducto(id int, req *models.ActualizarProductoRequest) error
-	EliminarProducto(id int) error
+	ObtenerProductos(campanaID int) ([]models.Producto, error)
+	CrearProducto(req *models.CrearProductoRequest, actor *models.Principal) (int64, error)
+	ActualizarProducto(id int, req *models.ActualizarProductoRequest, actor *models.Principal) error
+	EliminarProducto(id int, actor *models.Principal) error
 }
 
 // VendedorServiceInterface define los métodos del servicio de vendedores
 type VendedorServiceInterface interface {
 	ObtenerVendedores() ([]models.Vendedor, error)
-	CrearVendedor(nombre string) (int64, error)
-	ActualizarVendedor(id int, nombre string) error
-	EliminarVendedor(id int) error
+	CrearVendedor(nombre string, actor *models.Principal) (int64, error)
+	ActualizarVendedor(id int, nombre string, actor *models.Principal) error
+	EliminarVendedor(id int, actor *models.Principal) error
 }
 
 // AuthServiceInterface define los métodos del servicio de autenticación
 type AuthServiceInterface interface {
-	AutenticarUsuario(username, password string) (*models.User, error)
+	AutenticarUsuario(username, password, ip string) (*models.User, error)
+	IniciarSesion(user *models.User) (*models.LoginResponse, error)
+	RefrescarSesion(refreshToken string) (*models.LoginResponse, error)
+	CerrarSesion(refreshToken string, claims *models.TokenClaims) error
+	CambiarPassword(actor *models.Principal, actual, nueva string) (*models.LoginResponse, error)
+	RestablecerPassword(token, nueva string) error
 }
 
 // VentaService contiene lógica de negocio para ventas
-type VentaService struct{}
+type VentaService struct {
+	store           database.Store
+	idempotenciaTTL time.Duration
+}
+
+// idempotenciaTTLPorDefecto es cuánto se recuerda una Idempotency-Key si
+// IDEMPOTENCY_TTL no está configurada
+const idempotenciaTTLPorDefecto = 24 * time.Hour
+
+// NewVentaService crea el servicio de ventas. Recibe el Store completo porque
+// una venta involucra vendedores, clientes y productos en una misma transacción
+func NewVentaService(store database.Store) *VentaService {
+	return &VentaService{store: store, idempotenciaTTL: duracionDesdeEnv("IDEMPOTENCY_TTL", idempotenciaTTLPorDefecto)}
+}
+
+// enteroDesdeEnv lee la variable nombre como entero positivo; si no está o es
+// inválida retorna porDefecto
+func enteroDesdeEnv(nombre string, porDefecto int) int {
+	valor := os.Getenv(nombre)
+	if valor == "" {
+		return porDefecto
+	}
+	n, err := strconv.Atoi(valor)
+	if err != nil || n <= 0 {
+		logger.Warn(nombre+" inválido, se usa el valor por defecto", map[string]interface{}{
+			"valor":       valor,
+			"por_defecto": porDefecto,
+		})
+		return porDefecto
+	}
+	return n
+}
+
+// booleanoDesdeEnv lee la variable nombre como booleano ("true", "0"...); si
+// no está o es inválida retorna porDefecto
+func booleanoDesdeEnv(nombre string, porDefecto bool) bool {
+	valor := os.Getenv(nombre)
+	if valor == "" {
+		return porDefecto
+	}
+	b, err := strconv.ParseBool(valor)
+	if err != nil {
+		logger.Warn(nombre+" inválido, se usa el valor por defecto", map[string]interface{}{
+			"valor":       valor,
+			"por_defecto": porDefecto,
+		})
+		return porDefecto
+	}
+	return b
+}
+
+// duracionDesdeEnv lee la variable nombre como duración de Go ("24h", "90m");
+// si no está o es inválida retorna porDefecto
+func duracionDesdeEnv(nombre string, porDefecto time.Duration) time.Duration {
+	valor := os.Getenv(nombre)
+	if valor == "" {
+		return porDefecto
+	}
+	ttl, err := time.ParseDuration(valor)
+	if err != nil || ttl <= 0 {
+		logger.Warn(nombre+" inválido, se usa el valor por defecto", map[string]interface{}{
+			"valor":       valor,
+			"por_defecto": porDefecto.String(),
+		})
+		return porDefecto
+	}
+	return ttl
+}
+
+// hashVentaRequest resume el cuerpo de la venta para comparar reintentos
+func hashVentaRequest(req *models.VentaRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
 
-// CrearVenta crea una nueva venta con validación de negocio y transacción
-func (s *VentaService) CrearVenta(req *models.VentaRequest) (int, error) {
+// CrearVenta crea una nueva venta con validación de negocio y transacción.
+// actor es el usuario autenticado que la registra (nil si no hay token). El
+// resultado informa los items cuyo precio enviado no coincidió con el catálogo
+func (s *VentaService) CrearVenta(req *models.VentaRequest, actor *models.Principal) (*models.VentaCreada, error) {
+	return s.crearVenta(req, "", actor)
+}
+
+// CrearVentaIdempotente crea la venta una sola vez por clave (header
+// Idempotency-Key) mientras la clave no venza. Un reintento con el mismo
+// cuerpo retorna la venta original con Replay en true (sin diferencias de
+// precio); con otro cuerpo retorna ErrIdempotenciaConflicto
+func (s *VentaService) CrearVentaIdempotente(req *models.VentaRequest, clave string, actor *models.Principal) (*models.VentaCreada, error) {
+	return s.crearVenta(req, clave, actor)
+}
+
+// crearVenta implementa CrearVenta; con clave no vacía la reserva en la misma
+// transacción que la venta
+func (s *VentaService) crearVenta(req *models.VentaRequest, clave string, actor *models.Principal) (*models.VentaCreada, error) {
 	ctx := context.Background()
 
 	// Validar datos requeridos
 	if err := s.validarVentaRequest(req); err != nil {
 		logger.Warn("CrearVenta: Validación fallida", map[string]interface{}{"error": err.Error()})
-		return 0, err
+		return nil, err
+	}
+
+	// El teléfono se normaliza antes del hash para que el mismo número con
+	// otro formato no cuente como otro pedido
+	telefono, err := telefonoOpcional(req.TelefonoCliente)
+	if err != nil {
+		return nil, err
+	}
+	req.TelefonoCliente = telefonoModelo(telefono)
+
+	var requestHash string
+	if clave != "" {
+		h, err := hashVentaRequest(req)
+		if err != nil {
+			return nil, fmt.Errorf("error calculando hash del request: %w", err)
+		}
+		requestHash = h
 	}
 
 	// Obtener ID del vendedor
-	vendedorID, err := database.GetVendedorID(req.Vendedor)
+	vendedorID, err := s.store.Vendedores().GetVendedorID(req.Vendedor)
+	if err == sql.ErrNoRows {
+		logger.Warn("CrearVenta: Vendedor no encontrado", map[string]interface{}{"vendedor": req.Vendedor})
+		return nil, fmt.Errorf("%w: %q", ErrVendedorNoEncontrado, req.Vendedor)
+	}
 	if err != nil {
-		logger.Error("CrearVenta: Vendedor no encontrado", "VENDOR_NOT_FOUND", map[string]interface{}{
+		logger.Error("CrearVenta: Error obteniendo vendedor", "VENDOR_LOOKUP_ERROR", map[string]interface{}{
 			"vendedor": req.Vendedor,
 			"error":    err.Error(),
 		})
-		return 0, fmt.Errorf("vendedor no encontrado: %w", err)
+		return nil, fmt.Errorf("error obteniendo vendedor: %w", err)
 	}
 
 	// Verificar que el vendedor existe
-	exists, err := database.ExistsVendedor(ctx, vendedorID)
-	if err != nil || !exists {
-		return 0, fmt.Errorf("vendedor no válido")
-	}
-
-	// Obtener o crear cliente
-	var clienteID *int
-	if req.Cliente != "" {
-		// Validar que cliente no sea solo espacios (trim)
-		cliente := strings.TrimSpace(req.Cliente)
-		if cliente == "" {
-			logger.Warn("CrearVenta: Cliente vacío después de trim", map[string]interface{}{
-				"cliente_original": req.Cliente,
-			})
-			return 0, fmt.Errorf("cliente no puede estar vacío")
-		}
-		// Intentar obtener cliente existente para posiblemente actualizar su teléfono
-		id, tel, exists, err := database.GetClienteByNombre(cliente)
-		if err == nil && exists {
-			logger.Info("CrearVenta: Cliente existente encontrado", map[string]interface{}{
-				"cliente":          cliente,
-				"cliente_id":       id,
-				"telefono_actual":  tel,
-				"telefono_enviado": req.TelefonoCliente,
-			})
-			// Si se envió teléfono y es distinto, actualizarlo
-			if req.TelefonoCliente != 0 && req.TelefonoCliente != tel {
-				telPtr := req.TelefonoCliente
-				if err := database.UpdateClienteTelefono(id, &telPtr); err != nil {
-					logger.Warn("CrearVenta: Error actualizando teléfono de cliente existente", map[string]interface{}{
-						"cliente_id":      id,
-						"cliente":         cliente,
-						"telefono_nuevo":  req.TelefonoCliente,
-						"telefono_actual": tel,
-						"error":           err.Error(),
-					})
-					// Continuar con la creación de la venta aunque falle la actualización del teléfono
-				} else {
-					logger.Info("CrearVenta: Teléfono actualizado para cliente existente", map[string]interface{}{
-						"cliente_id":        id,
-						"cliente":           cliente,
-						"telefono_anterior": tel,
-						"telefono_nuevo":    req.TelefonoCliente,
-					})
-				}
-			} else {
-				razon := "telefono_igual_al_actual"
-				if req.TelefonoCliente == 0 {
-					razon = "telefono_enviado_es_0"
+	exists, err := s.store.Vendedores().ExistsVendedor(ctx, vendedorID)
+	if err != nil {
+		return nil, fmt.Errorf("error verificando vendedor: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %q", ErrVendedorNoEncontrado, req.Vendedor)
+	}
+
+	// Cliente es requerido
+	cliente := strings.TrimSpace(req.Cliente)
+	if cliente == "" {
+		logger.Warn("CrearVenta: Cliente vacío después de trim", map[string]interface{}{
+			"cliente_original": req.Cliente,
+		})
+		return nil, fmt.Errorf("cliente es requerido")
+	}
+
+	estado := models.EstadoSinPagar
+	if req.Estado != "" {
+		estado, _ = models.ParseEstadoVenta(req.Estado)
+	}
+
+	// Cliente, venta y detalles se confirman o revierten juntos
+	var ventaID int
+	var total float64
+	var creada *models.VentaCreada
+	var replay *models.VentaCreada
+	err = s.store.WithTx(ctx, func(tx database.Store) error {
+		if clave != "" {
+			ahora := time.Now()
+			hash, previa, respuesta, err := tx.Ventas().ReservarClaveIdempotencia(clave, requestHash, ahora, ahora.Add(s.idempotenciaTTL))
+			if err != nil {
+				return fmt.Errorf("error reservando Idempotency-Key: %w", err)
+			}
+			if hash != requestHash {
+				return ErrIdempotenciaConflicto
+			}
+			if previa != 0 {
+				// La respuesta original tal cual; las claves completadas antes
+				// de guardarla solo tienen la venta
+				replay = &models.VentaCreada{ID: previa}
+				if respuesta != nil {
+					if err := json.Unmarshal(respuesta, replay); err != nil {
+						return fmt.Errorf("error leyendo respuesta de Idempotency-Key: %w", err)
+					}
 				}
-				logger.Info("CrearVenta: Teléfono no actualizado", map[string]interface{}{
-					"cliente":          cliente,
-					"telefono_actual":  tel,
-					"telefono_enviado": req.TelefonoCliente,
-					"razon":            razon,
+				replay.Replay = true
+				return nil
+			}
+		}
+
+		// La venta entra en la campaña activa y solo con productos de ella
+		campana, err := resolverCampana(tx.Campanas(), 0)
+		if err != nil {
+			return err
+		}
+
+		// Precios y total salen del catálogo, nunca del request
+		items, t, diffs, err := resolverPrecios(tx.Productos(), campana.ID, req.Items)
+		if err != nil {
+			return err
+		}
+		total = t
+
+		clienteID, err := resolverCliente(tx.Clientes(), cliente, telefono)
+		if err != nil {
+			return err
+		}
+
+		ventaID, err = tx.Ventas().InsertVenta(campana.ID, &clienteID, vendedorID, total, req.PaymentMethod, string(estado), req.TipoEntrega, actor.UsuarioID())
+		if err != nil {
+			logger.Error("CrearVenta: Error insertando venta", "VENTA_INSERT_ERROR", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return fmt.Errorf("error guardando venta: %w", err)
+		}
+
+		// Una venta que nace pagada o entregada registra esa transición
+		if estado != models.EstadoSinPagar {
+			if err := tx.Ventas().RegistrarTransicionVenta(ventaID, estado, time.Now()); err != nil {
+				return fmt.Errorf("error registrando transición: %w", err)
+			}
+		}
+
+		for _, item := range items {
+			if err := tx.Ventas().InsertDetalle(ventaID, item); err != nil {
+				logger.Error("CrearVenta: Error insertando detalle", "DETAIL_INSERT_ERROR", map[string]interface{}{
+					"venta_id": ventaID,
+					"error":    err.Error(),
 				})
+				return fmt.Errorf("error insertando detalle: %w", err)
 			}
-			clienteID = &id
-		} else {
-			// Crear cliente nuevo con teléfono opcional
-			var telPtr *int
-			if req.TelefonoCliente != 0 {
-				t := req.TelefonoCliente
-				telPtr = &t
+		}
+
+		// Una venta que nace cobrada queda saldada en el libro de pagos
+		if estado.Cobrada() {
+			if _, err := tx.Ventas().InsertPago(ventaID, total, req.PaymentMethod, ""); err != nil {
+				return fmt.Errorf("error registrando pago: %w", err)
 			}
-			newID, err := database.CreateClienteWithTelefono(req.Cliente, telPtr)
+		}
+
+		creada = &models.VentaCreada{ID: ventaID, DiferenciasPrecio: diffs}
+		if clave != "" {
+			respuesta, err := json.Marshal(creada)
 			if err != nil {
-				logger.Error("CrearVenta: Error creando cliente", "CLIENT_CREATE_ERROR", map[string]interface{}{
-					"cliente": req.Cliente,
-					"error":   err.Error(),
-				})
-				return 0, fmt.Errorf("error creando cliente: %w", err)
+				return fmt.Errorf("error serializando respuesta: %w", err)
+			}
+			if err := tx.Ventas().CompletarClaveIdempotencia(clave, ventaID, respuesta); err != nil {
+				return fmt.Errorf("error guardando Idempotency-Key: %w", err)
 			}
-			clienteID = &newID
-			logger.Info("CrearVenta: Cliente creado exitosamente", map[string]interface{}{
-				"cliente_id": newID,
-				"cliente":    req.Cliente,
-			})
 		}
-	} else {
-		// Cliente es requerido
-		return 0, fmt.Errorf("cliente es requerido")
-	}
 
-	// Iniciar transacción
-	tx, err := database.BeginTx(ctx)
+		return auditarVenta(tx, ventaID, models.AccionCrear, actor, nil)
+	})
 	if err != nil {
-		logger.Error("CrearVenta: Error iniciando transacción", "TX_BEGIN_ERROR", map[string]interface{}{
+		logger.Error("CrearVenta: Transacción revertida", "VENTA_TX_ERROR", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return 0, fmt.Errorf("error en transacción: %w", err)
+		return nil, err
+	}
+	if replay != nil {
+		logger.Info("CrearVenta: Reintento con Idempotency-Key, se retorna la venta original", map[string]interface{}{
+			"venta_id": replay.ID,
+			"clave":    clave,
+		})
+		return replay, nil
 	}
 
-	// Validar que tenemos cliente (requerido para insertar venta)
-	if clienteID == nil {
-		tx.Rollback()
-		logger.Error("CrearVenta: Cliente ID es nil", "CLIENT_ID_NIL", map[string]interface{}{})
-		return 0, fmt.Errorf("cliente es requerido para crear venta")
+	logger.Info("CrearVenta: Venta creada exitosamente", map[string]interface{}{
+		"venta_id": ventaID,
+		"total":    total,
+	})
+
+	return creada, nil
+}
+
+// resolverPrecios aplica database.PrecioItem a cada item y retorna los items
+// con precio de catálogo, el total de la venta y las diferencias con lo que
+// envió el cliente. Las diferencias no son error (puede tener el catálogo
+// desactualizado) pero se registran y se informan en la respuesta. Un item
+// que no se puede vender (producto inexistente, inactivo o de otra campaña que
+// campanaID) se rechaza con *ItemRechazadoError
+func resolverPrecios(repo database.ProductoRepository, campanaID int, items []models.ProductoItem) ([]models.ProductoItem, float64, []models.PrecioDiferencia, error) {
+	resueltos := make([]models.ProductoItem, 0, len(items))
+	total := 0.0
+	var diferencias []models.PrecioDiferencia
+
+	for i, item := range items {
+		producto, err := repo.GetProductoByID(item.ProductID)
+		if err == sql.ErrNoRows {
+			return nil, 0, nil, &ItemRechazadoError{Item: i, ProductoID: item.ProductID, Err: ErrProductoNoEncontrado}
+		}
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("item %d: error obteniendo producto %d: %w", i, item.ProductID, err)
+		}
+		if producto.CampanaID != campanaID {
+			return nil, 0, nil, &ItemRechazadoError{Item: i, ProductoID: item.ProductID, Err: ErrProductoOtraCampana}
+		}
+
+		resuelto, diff, err := database.PrecioItem(producto, item)
+		if err != nil {
+			logger.Warn("resolverPrecios: Item rechazado", map[string]interface{}{
+				"item":        i,
+				"producto_id": item.ProductID,
+				"error":       err.Error(),
+			})
+			return nil, 0, nil, &ItemRechazadoError{Item: i, ProductoID: item.ProductID, Err: err}
+		}
+		if diff != nil {
+			logger.Warn("resolverPrecios: Precio enviado no coincide con el catálogo", map[string]interface{}{
+				"item":            i,
+				"producto_id":     diff.ProductoID,
+				"precio_enviado":  diff.PrecioEnviado,
+				"precio_catalogo": diff.PrecioCatalogo,
+				"total_enviado":   diff.TotalEnviado,
+				"total_calculado": diff.TotalCalculado,
+			})
+			diferencias = append(diferencias, *diff)
+		}
+
+		resueltos = append(resueltos, resuelto)
+		total += resuelto.Total
 	}
 
-	// Calcular total
-	total := s.calcularTotal(req.Items)
+	return resueltos, total, diferencias, nil
+}
 
-	// Insertar venta
-	ventaID, err := database.InsertVenta(clienteID, vendedorID, total, req.PaymentMethod, req.Estado, req.TipoEntrega)
+// resolverCampana retorna la campaña id o, si id es 0, la activa. Recibe el
+// repositorio de la transacción en curso cuando hay una
+func resolverCampana(repo database.CampanaRepository, id int) (*models.Campana, error) {
+	var campana *models.Campana
+	var err error
+	if id == 0 {
+		campana, err = repo.GetCampanaActiva()
+		if err == sql.ErrNoRows {
+			return nil, ErrSinCampanaActiva
+		}
+	} else {
+		campana, err = repo.GetCampanaByID(id)
+		if err == sql.ErrNoRows {
+			return nil, ErrCampanaNoEncontrada
+		}
+	}
 	if err != nil {
-		tx.Rollback()
-		logger.Error("CrearVenta: Error insertando venta", "VENTA_INSERT_ERROR", map[string]interface{}{
-			"error": err.Error(),
+		return nil, fmt.Errorf("error obteniendo campaña: %w", err)
+	}
+	return campana, nil
+}
+
+// resolverCliente obtiene el cliente por nombre normalizado (actualizando su
+// teléfono si se envió uno distinto) o lo crea. Recibe el repositorio de la
+// transacción en curso
+func resolverCliente(repo database.ClienteRepository, nombre string, telefono *string) (int, error) {
+	id, tel, exists, err := repo.GetClienteByNombre(nombre)
+	if err != nil {
+		return 0, fmt.Errorf("error buscando cliente: %w", err)
+	}
+
+	if !exists {
+		// GetOrCreateCliente y no un INSERT: si otro pedido crea el mismo
+		// cliente en paralelo, retorna ese en lugar de duplicarlo
+		newID, err := repo.GetOrCreateCliente(nombre)
+		if err == nil && telefono != nil {
+			err = repo.UpdateClienteTelefono(newID, telefono)
+		}
+		if err != nil {
+			logger.Error("resolverCliente: Error creando cliente", "CLIENT_CREATE_ERROR", map[string]interface{}{
+				"cliente": nombre,
+				"error":   err.Error(),
+			})
+			return 0, fmt.Errorf("error creando cliente: %w", err)
+		}
+		logger.Info("resolverCliente: Cliente creado exitosamente", map[string]interface{}{
+			"cliente_id": newID,
+			"cliente":    nombre,
 		})
-		return 0, fmt.Errorf("error guardando venta: %w", err)
+		return newID, nil
+	}
+
+	// Si se envió teléfono y es distinto, actualizarlo
+	if telefono != nil && *telefono != tel {
+		if err := repo.UpdateClienteTelefono(id, telefono); err != nil {
+			logger.Warn("resolverCliente: Error actualizando teléfono de cliente existente", map[string]interface{}{
+				"cliente_id":      id,
+				"cliente":         nombre,
+				"telefono_nuevo":  *telefono,
+				"telefono_actual": tel,
+				"error":           err.Error(),
+			})
+			// Continuar aunque falle la actualización del teléfono
+		} else {
+			logger.Info("resolverCliente: Teléfono actualizado para cliente existente", map[string]interface{}{
+				"cliente_id":        id,
+				"cliente":           nombre,
+				"telefono_anterior": tel,
+				"telefono_nuevo":    *telefono,
+			})
+		}
 	}
 
-	// Insertar detalles
-	for _, item := range req.Items {
-		if err := database.InsertDetalle(ventaID, item); err != nil {
-			tx.Rollback()
-			logger.Error("CrearVenta: Error insertando detalle", "DETAIL_INSERT_ERROR", map[string]interface{}{
+	return id, nil
+}
+
+// ActualizarVenta actualiza una venta existente y, si cliente no es nil, la reasigna a ese cliente
+func (s *VentaService) ActualizarVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}, cliente *models.ClienteVenta, actor *models.Principal) error {
+	// Validar estado válido
+	nuevoEstado, ok := models.ParseEstadoVenta(estado)
+	if !ok {
+		return fmt.Errorf("estado inválido: %s", estado)
+	}
+
+	// Validar método de pago
+	paymentMethod, ok = models.ParseMetodoPago(paymentMethod)
+	if !ok {
+		return fmt.Errorf("método de pago inválido: %s", paymentMethod)
+	}
+
+	var clienteNombre string
+	var clienteTelefono *string
+	if cliente != nil {
+		clienteNombre = strings.TrimSpace(cliente.Nombre)
+		if clienteNombre == "" {
+			return fmt.Errorf("el cliente no puede estar vacío")
+		}
+		t, err := telefonoOpcional(cliente.Telefono)
+		if err != nil {
+			return err
+		}
+		clienteTelefono = t
+	}
+
+	// Detalles, cabecera y reasignación de cliente en una sola transacción
+	return s.store.WithTx(context.Background(), func(tx database.Store) error {
+		actual, err := tx.Ventas().GetEstadoVenta(ventaID)
+		if err == sql.ErrNoRows {
+			return ErrVentaNoEncontrada
+		}
+		if err != nil {
+			return fmt.Errorf("error obteniendo estado de venta: %w", err)
+		}
+		if !actual.PuedeTransicionarA(nuevoEstado) {
+			logger.Warn("ActualizarVenta: Transición rechazada", map[string]interface{}{
 				"venta_id": ventaID,
-				"error":    err.Error(),
+				"desde":    actual,
+				"hacia":    nuevoEstado,
 			})
-			return 0, fmt.Errorf("error insertando detalle: %w", err)
+			return fmt.Errorf("%w: de %s a %s", ErrTransicionInvalida, actual, nuevoEstado)
 		}
+
+		antes, err := tx.Ventas().GetVentaByID(ventaID)
+		if err != nil {
+			return fmt.Errorf("error obteniendo venta: %w", err)
+		}
+
+		if err := tx.Ventas().UpdateVenta(ventaID, string(nuevoEstado), paymentMethod, tipoEntrega, productosEliminar, productos, actor.UsuarioID()); err != nil {
+			return err
+		}
+
+		if actual != nuevoEstado {
+			if err := tx.Ventas().RegistrarTransicionVenta(ventaID, nuevoEstado, time.Now()); err != nil {
+				return fmt.Errorf("error registrando transición: %w", err)
+			}
+		}
+
+		if err := ajustarPagos(tx.Ventas(), ventaID, actual, nuevoEstado, paymentMethod); err != nil {
+			return err
+		}
+		if _, err := sincronizarEstadoPago(tx.Ventas(), ventaID, nuevoEstado); err != nil {
+			return err
+		}
+
+		if cliente != nil {
+			clienteID, err := resolverCliente(tx.Clientes(), clienteNombre, clienteTelefono)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Ventas().UpdateVentaClienteID(ventaID, clienteID); err != nil {
+				return fmt.Errorf("error asignando cliente a venta: %w", err)
+			}
+
+			logger.Info("ActualizarVenta: Cliente actualizado", map[string]interface{}{
+				"venta_id":   ventaID,
+				"cliente_id": clienteID,
+			})
+		}
+
+		return auditarVenta(tx, ventaID, models.AccionActualizar, actor, antes)
+	})
+}
+
+// auditarVenta registra el cambio de una venta comparando antes (nil si se
+// acaba de crear) con su estado actual dentro de la transacción
+func auditarVenta(tx database.Store, ventaID int, accion string, actor *models.Principal, antes *models.VentaStats) error {
+	despues, err := tx.Ventas().GetVentaByID(ventaID)
+	if err != nil {
+		return fmt.Errorf("error obteniendo venta: %w", err)
 	}
+	return auditar(tx, models.EntidadVenta, ventaID, accion, actor, antes, despues)
+}
 
-	// Commit de la transacción
-	if err := tx.Commit(); err != nil {
-		logger.Error("CrearVenta: Error en commit", "TX_COMMIT_ERROR", map[string]interface{}{
-			"venta_id": ventaID,
-			"error":    err.Error(),
-		})
-		return 0, fmt.Errorf("error completando venta: %w", err)
+// ajustarPagos lleva el libro de pagos al estado pedido en una edición manual.
+// Marcar la venta como cobrada registra el saldo con el método de la venta (o
+// la devolución del excedente si se quitaron items); volver de pagada a
+// sin_pagar o cancelarla revierte lo pagado con cada método, así el libro
+// registra la devolución en lugar de dejar pagos de una venta cancelada
+func ajustarPagos(repo database.VentaRepository, ventaID int, actual, nuevo models.EstadoVenta, metodo string) error {
+	switch {
+	case nuevo.Cobrada():
+		total, pagado, err := repo.GetSaldoVenta(ventaID)
+		if err != nil {
+			return fmt.Errorf("error obteniendo saldo: %w", err)
+		}
+		if saldo := database.SaldoPendiente(total, pagado); saldo != 0 {
+			if _, err := repo.InsertPago(ventaID, saldo, metodo, ""); err != nil {
+				return fmt.Errorf("error registrando pago: %w", err)
+			}
+		}
+
+	case nuevo == models.EstadoSinPagar && actual == models.EstadoPagada,
+		nuevo == models.EstadoCancelada && actual != models.EstadoCancelada:
+		pagos, err := repo.GetPagosVenta(ventaID)
+		if err != nil {
+			return fmt.Errorf("error obteniendo pagos: %w", err)
+		}
+		porMetodo := map[string]float64{}
+		metodos := []string{}
+		for _, p := range pagos {
+			if _, ok := porMetodo[p.Metodo]; !ok {
+				metodos = append(metodos, p.Metodo)
+			}
+			porMetodo[p.Metodo] += p.Monto
+		}
+		for _, m := range metodos {
+			if monto := database.RedondearMonto(porMetodo[m]); monto != 0 {
+				if _, err := repo.InsertPago(ventaID, -monto, m, ""); err != nil {
+					return fmt.Errorf("error revirtiendo pagos: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sincronizarEstadoPago deriva el estado de la venta de su saldo: sin_pagar
+// y pagada se alternan según lo pagado cubra o no el total. entregada y
+// cancelada son finales y no se tocan. Retorna el estado resultante
+func sincronizarEstadoPago(repo database.VentaRepository, ventaID int, actual models.EstadoVenta) (models.EstadoVenta, error) {
+	if actual != models.EstadoSinPagar && actual != models.EstadoPagada {
+		return actual, nil
 	}
 
-	logger.Info("CrearVenta: Venta creada exitosamente", map[string]interface{}{
+	total, pagado, err := repo.GetSaldoVenta(ventaID)
+	if err != nil {
+		return actual, fmt.Errorf("error obteniendo saldo: %w", err)
+	}
+	nuevo := models.EstadoSinPagar
+	if database.SaldoPendiente(total, pagado) <= 0 {
+		nuevo = models.EstadoPagada
+	}
+	if nuevo == actual {
+		return actual, nil
+	}
+
+	if err := repo.UpdateEstadoVenta(ventaID, nuevo); err != nil {
+		return actual, fmt.Errorf("error actualizando estado: %w", err)
+	}
+	if err := repo.RegistrarTransicionVenta(ventaID, nuevo, time.Now()); err != nil {
+		return actual, fmt.Errorf("error registrando transición: %w", err)
+	}
+	logger.Info("sincronizarEstadoPago: Estado derivado del saldo", map[string]interface{}{
 		"venta_id": ventaID,
+		"desde":    actual,
+		"hacia":    nuevo,
 		"total":    total,
+		"pagado":   pagado,
 	})
-
-	return ventaID, nil
+	return nuevo, nil
 }
 
-// ActualizarVenta actualiza una venta existente
-func (s *VentaService) ActualizarVenta(ventaID int, estado, paymentMethod, tipoEntrega string, productosEliminar []int, productos []map[string]interface{}) error {
-	// Validar estado válido
-	estadosValidos := map[string]bool{
-		"sin_pagar": true,
-		"pagada":    true,
-		"entregada": true,
-		"cancelada": true,
-	}
-	if !estadosValidos[estado] {
-		return fmt.Errorf("estado inválido: %s", estado)
+// RegistrarPago agrega un pago al libro de la venta y deriva su estado del
+// saldo resultante. Rechaza pagos sobre ventas canceladas o por más de lo
+// que falta cobrar
+func (s *VentaService) RegistrarPago(ventaID int, req *models.PagoRequest, actor *models.Principal) (*models.Pago, error) {
+	monto := database.RedondearMonto(req.Monto)
+	if monto <= 0 {
+		return nil, fmt.Errorf("el monto debe ser mayor a 0")
+	}
+	metodo, ok := models.ParseMetodoPago(req.Metodo)
+	if !ok {
+		return nil, fmt.Errorf("método de pago inválido: %s", req.Metodo)
+	}
+
+	var pago *models.Pago
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		// Bloquea la venta: dos pagos simultáneos no pueden exceder el saldo
+		estado, err := tx.Ventas().GetEstadoVenta(ventaID)
+		if err == sql.ErrNoRows {
+			return ErrVentaNoEncontrada
+		}
+		if err != nil {
+			return fmt.Errorf("error obteniendo estado de venta: %w", err)
+		}
+		if estado == models.EstadoCancelada {
+			return fmt.Errorf("%w: la venta está cancelada", ErrPagoRechazado)
+		}
+
+		total, pagado, err := tx.Ventas().GetSaldoVenta(ventaID)
+		if err != nil {
+			return fmt.Errorf("error obteniendo saldo: %w", err)
+		}
+		if saldo := database.SaldoPendiente(total, pagado); monto > saldo {
+			return fmt.Errorf("%w: el monto %.2f excede el saldo pendiente %.2f", ErrPagoRechazado, monto, saldo)
+		}
+
+		antes, err := tx.Ventas().GetVentaByID(ventaID)
+		if err != nil {
+			return fmt.Errorf("error obteniendo venta: %w", err)
+		}
+
+		id, err := tx.Ventas().InsertPago(ventaID, monto, metodo, actor.Nombre())
+		if err != nil {
+			return fmt.Errorf("error registrando pago: %w", err)
+		}
+		pago = &models.Pago{
+			ID:            id,
+			VentaID:       ventaID,
+			Monto:         monto,
+			Metodo:        metodo,
+			RegistradoPor: actor.Nombre(),
+			CreatedAt:     time.Now(),
+		}
+
+		if _, err := sincronizarEstadoPago(tx.Ventas(), ventaID, estado); err != nil {
+			return err
+		}
+		return auditarVenta(tx, ventaID, models.AccionActualizar, actor, antes)
+	})
+	if err != nil {
+		logger.Warn("RegistrarPago: Pago no registrado", map[string]interface{}{
+			"venta_id": ventaID,
+			"monto":    monto,
+			"error":    err.Error(),
+		})
+		return nil, err
 	}
 
-	// Validar método de pago
-	metodosPagos := map[string]bool{
-		"efectivo":      true,
-		"transferencia": true,
+	logger.Info("RegistrarPago: Pago registrado", map[string]interface{}{
+		"venta_id":       ventaID,
+		"pago_id":        pago.ID,
+		"monto":          pago.Monto,
+		"metodo":         pago.Metodo,
+		"registrado_por": actor.Nombre(),
+	})
+	return pago, nil
+}
+
+// ObtenerPagos retorna el libro de pagos de una venta
+func (s *VentaService) ObtenerPagos(ventaID int) ([]models.Pago, error) {
+	exists, err := s.store.Ventas().ExistsVenta(context.Background(), ventaID)
+	if err != nil {
+		return nil, fmt.Errorf("error verificando venta: %w", err)
 	}
-	if !metodosPagos[paymentMethod] {
-		return fmt.Errorf("método de pago inválido: %s", paymentMethod)
+	if !exists {
+		return nil, ErrVentaNoEncontrada
 	}
 
-	// Actualizar en BD
-	return database.UpdateVenta(ventaID, estado, paymentMethod, tipoEntrega, productosEliminar, productos)
+	pagos, err := s.store.Ventas().GetPagosVenta(ventaID)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo pagos: %w", err)
+	}
+	return pagos, nil
 }
 
-// ObtenerEstadisticas retorna estadísticas completas
-func (s *VentaService) ObtenerEstadisticas() (map[string]interface{}, error) {
-	resumen, err := database.GetResumen()
+// ObtenerEstadisticas retorna estadísticas completas de una campaña (0 = la
+// activa). Las campañas cerradas se consultan por su ID
+func (s *VentaService) ObtenerEstadisticas(campanaID int) (map[string]interface{}, error) {
+	campana, err := resolverCampana(s.store.Campanas(), campanaID)
+	if err != nil {
+		return nil, err
+	}
+
+	resumen, err := s.store.Ventas().GetResumen(campana.ID)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo resumen: %w", err)
 	}
 
-	vendedores, err := database.GetVendedoresConStats()
+	vendedores, err := s.store.Ventas().GetVendedoresConStats(campana.ID)
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo vendedores: %w", err)
 	}
 
-	ventas, err := database.GetAllVentas(false)
+	ventas, _, err := s.store.Ventas().GetAllVentas(models.VentaFiltro{CampanaID: campana.ID})
 	if err != nil {
 		return nil, fmt.Errorf("error obteniendo ventas: %w", err)
 	}
 
 	return map[string]interface{}{
+		"campana":    campana,
 		"resumen":    resumen,
 		"vendedores": vendedores,
 		"ventas":     ventas,
 	}, nil
 }
 
-// ObtenerTodasVentas retorna todas las ventas incluyendo canceladas
-func (s *VentaService) ObtenerTodasVentas() ([]models.VentaStats, error) {
-	ventas, err := database.GetAllVentas(true)
+// ObtenerTodasVentas retorna las ventas que cumplen el filtro y el cursor de
+// la página siguiente. Filtros de orden, cursor o limit inválidos retornan
+// un error que envuelve database.ErrFiltroInvalido
+func (s *VentaService) ObtenerTodasVentas(filtro models.VentaFiltro) ([]models.VentaStats, string, error) {
+	ventas, nextCursor, err := s.store.Ventas().GetAllVentas(filtro)
 	if err != nil {
-		return nil, fmt.Errorf("error obteniendo ventas: %w", err)
+		return nil, "", fmt.Errorf("error obteniendo ventas: %w", err)
+	}
+	return ventas, nextCursor, nil
+}
+
+// ObtenerVenta retorna una venta con sus items, cliente y teléfono
+func (s *VentaService) ObtenerVenta(id int) (*models.VentaStats, error) {
+	venta, err := s.store.Ventas().GetVentaByID(id)
+	if err == sql.ErrNoRows {
+		return nil, ErrVentaNoEncontrada
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo venta: %w", err)
 	}
-	return ventas, nil
+	return venta, nil
+}
+
+// EliminarVenta borra una venta cargada por error junto con sus detalles y pagos.
+// Para anularla conservando el registro se usa el estado "cancelada"
+func (s *VentaService) EliminarVenta(id int, actor *models.Principal) error {
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Ventas().GetVentaByID(id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Ventas().DeleteVenta(id); err != nil {
+			return err
+		}
+		return auditar(tx, models.EntidadVenta, id, models.AccionEliminar, actor, antes, nil)
+	})
+	if err == sql.ErrNoRows {
+		return ErrVentaNoEncontrada
+	}
+	if err != nil {
+		logger.Error("EliminarVenta: Error eliminando venta", "VENTA_DELETE_ERROR", map[string]interface{}{
+			"venta_id": id,
+			"error":    err.Error(),
+		})
+		return fmt.Errorf("error eliminando venta: %w", err)
+	}
+
+	logger.Info("EliminarVenta: Venta eliminada", map[string]interface{}{"venta_id": id})
+	return nil
 }
 
 // Validaciones privadas
@@ -277,13 +908,13 @@ func (s *VentaService) validarVentaRequest(req *models.VentaRequest) error {
 	}
 
 	// Validar que el vendedor existe
-	vendedorID, err := database.GetVendedorID(req.Vendedor)
+	vendedorID, err := s.store.Vendedores().GetVendedorID(req.Vendedor)
+	if err == sql.ErrNoRows || (err == nil && vendedorID <= 0) {
+		return fmt.Errorf("%w: %q", ErrVendedorNoEncontrado, req.Vendedor)
+	}
 	if err != nil {
 		return fmt.Errorf("error al validar vendedor: %w", err)
 	}
-	if vendedorID <= 0 {
-		return fmt.Errorf("vendedor '%s' no encontrado", req.Vendedor)
-	}
 
 	if req.Cliente == "" {
 		return fmt.Errorf("cliente es requerido")
@@ -295,10 +926,6 @@ func (s *VentaService) validarVentaRequest(req *models.VentaRequest) error {
 		return fmt.Errorf("nombre de cliente demasiado largo")
 	}
 
-	if req.TelefonoCliente != 0 && (req.TelefonoCliente < 10 || req.TelefonoCliente > 999999999999999) {
-		return fmt.Errorf("teléfono debe tener entre 2 y 15 dígitos")
-	}
-
 	if len(req.Items) == 0 {
 		return fmt.Errorf("al menos un item es requerido")
 	}
@@ -306,7 +933,8 @@ func (s *VentaService) validarVentaRequest(req *models.VentaRequest) error {
 		return fmt.Errorf("demasiados items (máximo 50)")
 	}
 
-	// Validar que cada item tenga datos válidos y producto existe
+	// Validar que cada item tenga datos válidos (la existencia y el precio
+	// del producto se resuelven dentro de la transacción)
 	for i, item := range req.Items {
 		if item.ProductID <= 0 {
 			return fmt.Errorf("item %d: product_id inválido", i)
@@ -320,45 +948,22 @@ func (s *VentaService) validarVentaRequest(req *models.VentaRequest) error {
 		if item.Precio < 0 {
 			return fmt.Errorf("item %d: precio no puede ser negativo", i)
 		}
-
-		// Validar que el producto existe
-		exists, err := database.ExistsProducto(context.Background(), item.ProductID)
-		if err != nil {
-			return fmt.Errorf("item %d: error al validar producto: %w", i, err)
-		}
-		if !exists {
-			return fmt.Errorf("item %d: producto con ID %d no existe", i, item.ProductID)
-		}
 	}
 
 	// Validar payment method
 	if req.PaymentMethod == "" {
 		return fmt.Errorf("método de pago es requerido")
 	}
-	validPayments := []string{"efectivo", "tarjeta", "transferencia", "qr"}
-	found := false
-	for _, p := range validPayments {
-		if strings.ToLower(req.PaymentMethod) == p {
-			found = true
-			break
-		}
-	}
-	if !found {
-		return fmt.Errorf("método de pago inválido (debe ser: efectivo, tarjeta, transferencia, qr)")
+	metodo, ok := models.ParseMetodoPago(req.PaymentMethod)
+	if !ok {
+		return fmt.Errorf("método de pago inválido (debe ser: %s)", models.MetodosPagoString())
 	}
+	req.PaymentMethod = metodo
 
 	// Validar estado
 	if req.Estado != "" {
-		validEstados := []string{"sin_pagar", "pagada", "entregada", "cancelada"}
-		found := false
-		for _, e := range validEstados {
-			if strings.ToLower(req.Estado) == e {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("estado inválido (debe ser: sin_pagar, pagada, entregada, cancelada)")
+		if _, ok := models.ParseEstadoVenta(req.Estado); !ok {
+			return fmt.Errorf("estado inválido (debe ser: %s)", models.EstadosVentaString())
 		}
 	}
 
@@ -376,227 +981,945 @@ func (s *VentaService) validarVentaRequest(req *models.VentaRequest) error {
 			return fmt.Errorf("tipo de entrega inválido (debe ser: retiro, envio, delivery)")
 		}
 	}
-
-	return nil
+
+	return nil
+}
+
+// ProductoService contiene lógica de negocio para productos
+type ProductoService struct {
+	store database.Store
+}
+
+// NewProductoService crea el servicio de productos. Recibe el Store porque
+// crear o actualizar un producto también escribe su historial de precios
+func NewProductoService(store database.Store) *ProductoService {
+	return &ProductoService{store: store}
+}
+
+// CrearProducto crea un nuevo producto en la campaña del request (por
+// defecto la activa)
+func (s *ProductoService) CrearProducto(req *models.CrearProductoRequest, actor *models.Principal) (int64, error) {
+	// Validar
+	if err := s.validarCrearProducto(req); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		campana, err := resolverCampana(tx.Campanas(), req.CampanaID)
+		if err != nil {
+			return err
+		}
+		id, err = tx.Productos().CreateProducto(campana.ID, req.TipoPizza, req.Descripcion, req.Precio, actor.UsuarioID())
+		if err != nil {
+			return err
+		}
+		despues, err := tx.Productos().GetProductoByID(int(id))
+		if err != nil {
+			return err
+		}
+		return auditar(tx, models.EntidadProducto, int(id), models.AccionCrear, actor, nil, despues)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error creando producto: %w", err)
+	}
+
+	return id, nil
+}
+
+// ActualizarProducto actualiza un producto
+func (s *ProductoService) ActualizarProducto(id int, req *models.ActualizarProductoRequest, actor *models.Principal) error {
+	if err := s.validarActualizarProducto(req); err != nil {
+		return err
+	}
+
+	// Producto, historial de precios y auditoría se actualizan juntos
+	return s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Productos().GetProductoByID(id)
+		if err == sql.ErrNoRows {
+			return ErrProductoNoEncontrado
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Productos().UpdateProducto(id, req.TipoPizza, req.Descripcion, req.Precio, req.Activo, actor.UsuarioID()); err != nil {
+			return err
+		}
+		return s.auditarProducto(tx, id, models.AccionActualizar, actor, antes)
+	})
+}
+
+// EliminarProducto elimina un producto (soft delete)
+func (s *ProductoService) EliminarProducto(id int, actor *models.Principal) error {
+	return s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Productos().GetProductoByID(id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Productos().DeleteProducto(id, actor.UsuarioID()); err != nil {
+			return err
+		}
+		return s.auditarProducto(tx, id, models.AccionEliminar, actor, antes)
+	})
+}
+
+// auditarProducto registra el cambio de un producto comparando antes con su
+// estado actual. El soft delete queda como eliminar con activo true → false
+func (s *ProductoService) auditarProducto(tx database.Store, id int, accion string, actor *models.Principal, antes *models.Producto) error {
+	despues, err := tx.Productos().GetProductoByID(id)
+	if err != nil {
+		return err
+	}
+	return auditar(tx, models.EntidadProducto, id, accion, actor, antes, despues)
+}
+
+// ObtenerProductos retorna lista de productos activos de una campaña (0 = la activa)
+func (s *ProductoService) ObtenerProductos(campanaID int) ([]models.Producto, error) {
+	campana, err := resolverCampana(s.store.Campanas(), campanaID)
+	if err != nil {
+		return nil, err
+	}
+	productos, err := s.store.Productos().GetProductos(campana.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo productos: %w", err)
+	}
+	return productos, nil
+}
+
+// Validaciones privadas
+func (s *ProductoService) validarCrearProducto(req *models.CrearProductoRequest) error {
+	if strings.TrimSpace(req.TipoPizza) == "" {
+		return fmt.Errorf("tipo_pizza es requerido")
+	}
+	if len(strings.TrimSpace(req.TipoPizza)) < 2 {
+		return fmt.Errorf("tipo_pizza debe tener al menos 2 caracteres")
+	}
+	if len(req.TipoPizza) > 50 {
+		return fmt.Errorf("tipo_pizza demasiado largo (máximo 50 caracteres)")
+	}
+
+	if len(strings.TrimSpace(req.Descripcion)) > 200 {
+		return fmt.Errorf("descripcion demasiado larga (máximo 200 caracteres)")
+	}
+
+	if req.Precio <= 0 {
+		return fmt.Errorf("precio debe ser mayor a 0")
+	}
+	if req.Precio > 500 {
+		return fmt.Errorf("precio demasiado alto (máximo $500)")
+	}
+
+	return nil
+}
+
+func (s *ProductoService) validarActualizarProducto(req *models.ActualizarProductoRequest) error {
+	if strings.TrimSpace(req.TipoPizza) == "" {
+		return fmt.Errorf("tipo_pizza es requerido")
+	}
+	if len(strings.TrimSpace(req.TipoPizza)) < 2 {
+		return fmt.Errorf("tipo_pizza debe tener al menos 2 caracteres")
+	}
+	if len(req.TipoPizza) > 50 {
+		return fmt.Errorf("tipo_pizza demasiado largo (máximo 50 caracteres)")
+	}
+
+	if req.Precio <= 0 {
+		return fmt.Errorf("precio debe ser mayor a 0")
+	}
+	if req.Precio > 500 {
+		return fmt.Errorf("precio demasiado alto (máximo $500)")
+	}
+
+	return nil
+}
+
+// VendedorService contiene lógica de negocio para vendedores
+type VendedorService struct {
+	store database.Store
+}
+
+// NewVendedorService crea el servicio de vendedores
+func NewVendedorService(store database.Store) *VendedorService {
+	return &VendedorService{store: store}
+}
+
+// CrearVendedor crea un nuevo vendedor
+func (s *VendedorService) CrearVendedor(nombre string, actor *models.Principal) (int64, error) {
+	if nombre == "" {
+		return 0, fmt.Errorf("nombre del vendedor es requerido")
+	}
+
+	var id int64
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		var err error
+		id, err = tx.Vendedores().CreateVendedor(nombre, actor.UsuarioID())
+		if err != nil {
+			return err
+		}
+		despues := models.Vendedor{ID: int(id), Nombre: nombre}
+		return auditar(tx, models.EntidadVendedor, int(id), models.AccionCrear, actor, nil, despues)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error creando vendedor: %w", err)
+	}
+
+	return id, nil
+}
+
+// ActualizarVendedor actualiza un vendedor
+func (s *VendedorService) ActualizarVendedor(id int, nombre string, actor *models.Principal) error {
+	if nombre == "" {
+		return fmt.Errorf("nombre del vendedor es requerido")
+	}
+
+	return s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Vendedores().GetVendedorByID(id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Vendedores().UpdateVendedor(id, nombre, actor.UsuarioID()); err != nil {
+			return err
+		}
+		despues := models.Vendedor{ID: id, Nombre: nombre}
+		return auditar(tx, models.EntidadVendedor, id, models.AccionActualizar, actor, antes, despues)
+	})
+}
+
+// EliminarVendedor elimina un vendedor
+func (s *VendedorService) EliminarVendedor(id int, actor *models.Principal) error {
+	return s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Vendedores().GetVendedorByID(id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Vendedores().DeleteVendedor(id); err != nil {
+			return err
+		}
+		return auditar(tx, models.EntidadVendedor, id, models.AccionEliminar, actor, antes, nil)
+	})
+}
+
+// ObtenerVendedores retorna lista de vendedores
+func (s *VendedorService) ObtenerVendedores() ([]models.Vendedor, error) {
+	vendedores, err := s.store.Vendedores().GetVendedores()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo vendedores: %w", err)
+	}
+	return vendedores, nil
+}
+
+// FraseLimpiarBaseDatos es la frase que el admin tiene que escribir para
+// confirmar la limpieza de la base
+const FraseLimpiarBaseDatos = "BORRAR TODOS LOS DATOS"
+
+// backupDirPorDefecto es dónde se escriben los backups si BACKUP_DIR no está
+const backupDirPorDefecto = "backups"
+
+// DataService contiene lógica para obtener datos generales
+type DataService struct {
+	store     database.Store
+	backupDir string
+}
+
+// NewDataService crea el servicio de datos generales
+func NewDataService(store database.Store) *DataService {
+	backupDir := os.Getenv("BACKUP_DIR")
+	if backupDir == "" {
+		backupDir = backupDirPorDefecto
+	}
+	return &DataService{store: store, backupDir: backupDir}
+}
+
+// ObtenerDataInicial retorna vendedores, clientes y la campaña activa con sus
+// productos. Sin campaña activa no hay productos para vender
+func (s *DataService) ObtenerDataInicial() (*models.DataResponse, error) {
+	vendedores, err := s.store.Vendedores().GetVendedores()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo vendedores: %w", err)
+	}
+
+	clientesPorVendedor, err := s.store.Clientes().GetClientesPorVendedor()
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo clientes: %w", err)
+	}
+
+	campana, err := resolverCampana(s.store.Campanas(), 0)
+	if errors.Is(err, ErrSinCampanaActiva) {
+		return &models.DataResponse{
+			Vendedores:          vendedores,
+			ClientesPorVendedor: clientesPorVendedor,
+			Productos:           []models.Producto{},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	productos, err := s.store.Productos().GetProductos(campana.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo productos: %w", err)
+	}
+
+	return &models.DataResponse{
+		Campana:             campana,
+		Vendedores:          vendedores,
+		ClientesPorVendedor: clientesPorVendedor,
+		Productos:           productos,
+	}, nil
+}
+
+// LimpiarBaseDatos elimina todos los datos excepto usuarios y campañas: la
+// campaña activa se conserva para seguir recibiendo productos y ventas.
+// confirmacion tiene que ser FraseLimpiarBaseDatos. Todo ocurre en una
+// transacción: primero se escribe un export completo en BACKUP_DIR y recién
+// entonces se borra; si el backup falla no se borra nada. Retorna la ruta del
+// backup
+func (s *DataService) LimpiarBaseDatos(confirmacion string, actor *models.Principal) (string, error) {
+	if confirmacion != FraseLimpiarBaseDatos {
+		return "", ErrConfirmacionInvalida
+	}
+
+	var ruta string
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		datos, err := exportarDatos(tx, actor)
+		if err != nil {
+			return fmt.Errorf("error exportando datos: %w", err)
+		}
+		ruta, err = escribirBackup(s.backupDir, datos)
+		if err != nil {
+			return fmt.Errorf("error escribiendo backup: %w", err)
+		}
+
+		// Eliminar en el orden correcto para evitar restricciones de foreign keys
+		if err := tx.Ventas().ClearDetalleVentas(); err != nil {
+			return fmt.Errorf("error eliminando detalles: %w", err)
+		}
+		if err := tx.Ventas().ClearVentas(); err != nil {
+			return fmt.Errorf("error eliminando ventas: %w", err)
+		}
+		if err := tx.Clientes().ClearClientes(); err != nil {
+			return fmt.Errorf("error eliminando clientes: %w", err)
+		}
+		if err := tx.Vendedores().ClearVendedores(); err != nil {
+			return fmt.Errorf("error eliminando vendedores: %w", err)
+		}
+		if err := tx.Productos().ClearProductos(); err != nil {
+			return fmt.Errorf("error eliminando productos: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("LimpiarBaseDatos: Error limpiando, no se borró nada", "DATABASE_CLEAR_ERROR", map[string]interface{}{
+			"por":   actor.Nombre(),
+			"error": err.Error(),
+		})
+		return "", err
+	}
+
+	logger.Warn("LimpiarBaseDatos: Base de datos limpiada", map[string]interface{}{
+		"por":    actor.Nombre(),
+		"backup": ruta,
+	})
+	return ruta, nil
+}
+
+// exportarDatos lee todo lo que borra LimpiarBaseDatos
+func exportarDatos(tx database.Store, actor *models.Principal) (*models.BackupDatos, error) {
+	datos := &models.BackupDatos{GeneradoAt: time.Now(), GeneradoPor: actor.Nombre()}
+	var err error
+
+	datos.Ventas, _, err = tx.Ventas().GetAllVentas(models.VentaFiltro{IncluirCanceladas: true, Orden: "fecha"})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range datos.Ventas {
+		pagos, err := tx.Ventas().GetPagosVenta(v.ID)
+		if err != nil {
+			return nil, err
+		}
+		datos.Pagos = append(datos.Pagos, pagos...)
+	}
+	if datos.Clientes, err = tx.Clientes().GetAllClientes(); err != nil {
+		return nil, err
+	}
+	if datos.Vendedores, err = tx.Vendedores().GetVendedores(); err != nil {
+		return nil, err
+	}
+	if datos.Productos, err = tx.Productos().GetAllProductos(); err != nil {
+		return nil, err
+	}
+	for _, p := range datos.Productos {
+		precios, err := tx.Productos().GetHistorialPrecios(p.ID)
+		if err != nil {
+			return nil, err
+		}
+		datos.Precios = append(datos.Precios, precios...)
+	}
+	return datos, nil
 }
 
-func (s *VentaService) calcularTotal(items []models.ProductoItem) float64 {
-	total := 0.0
-	for _, item := range items {
-		total += item.Total
+// escribirBackup guarda datos como JSON en dir (creándolo si hace falta) y
+// retorna la ruta. Escribe a un temporal y lo renombra para no dejar un
+// backup a medias
+func escribirBackup(dir string, datos *models.BackupDatos) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
 	}
-	return total
-}
-
-// ProductoService contiene lógica de negocio para productos
-type ProductoService struct{}
-
-// CrearProducto crea un nuevo producto
-func (s *ProductoService) CrearProducto(req *models.CrearProductoRequest) (int64, error) {
-	// Validar
-	if err := s.validarCrearProducto(req); err != nil {
-		return 0, err
+	tmp, err := os.CreateTemp(dir, "backup-*.json.tmp")
+	if err != nil {
+		return "", err
 	}
+	defer os.Remove(tmp.Name())
 
-	id, err := database.CreateProducto(req.TipoPizza, req.Descripcion, req.Precio)
-	if err != nil {
-		return 0, fmt.Errorf("error creando producto: %w", err)
+	enc := json.NewEncoder(tmp)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(datos); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
 	}
 
-	return id, nil
+	ruta := filepath.Join(dir, "backup-"+datos.GeneradoAt.Format("20060102-150405.000000000")+".json")
+	if err := os.Rename(tmp.Name(), ruta); err != nil {
+		return "", err
+	}
+	return ruta, nil
 }
 
-// ActualizarProducto actualiza un producto
-func (s *ProductoService) ActualizarProducto(id int, req *models.ActualizarProductoRequest) error {
-	if err := s.validarActualizarProducto(req); err != nil {
-		return err
+// Duraciones por defecto de los tokens si ACCESS_TOKEN_TTL y
+// REFRESH_TOKEN_TTL no están configuradas
+const (
+	accessTTLPorDefecto  = 15 * time.Minute
+	refreshTTLPorDefecto = 7 * 24 * time.Hour
+)
+
+// Límites por defecto de intentos fallidos de login (LOGIN_MAX_INTENTOS,
+// LOGIN_MAX_INTENTOS_IP y LOGIN_BLOQUEO)
+const (
+	maxIntentosUsuarioPorDefecto = 5
+	maxIntentosIPPorDefecto      = 20
+	bloqueoLoginPorDefecto       = 15 * time.Minute
+	// retrasoLoginMaximo acota la espera progresiva entre intentos fallidos
+	retrasoLoginMaximo = 30 * time.Second
+)
+
+// Política por defecto de contraseñas nuevas (PASSWORD_MIN_LENGTH,
+// PASSWORD_REQUIRE_DIGIT y PASSWORD_REQUIRE_UPPER) y vigencia de los tokens
+// de restablecimiento (PASSWORD_RESET_TTL)
+const (
+	passwordMinLongitudPorDefecto = 8
+	resetTTLPorDefecto            = time.Hour
+)
+
+// politicaPasswordDesdeEnv arma la política de contraseñas nuevas
+func politicaPasswordDesdeEnv() validators.PoliticaPassword {
+	return validators.PoliticaPassword{
+		MinLongitud:    enteroDesdeEnv("PASSWORD_MIN_LENGTH", passwordMinLongitudPorDefecto),
+		RequiereNumero: booleanoDesdeEnv("PASSWORD_REQUIRE_DIGIT", true),
+		RequiereMayus:  booleanoDesdeEnv("PASSWORD_REQUIRE_UPPER", false),
 	}
+}
 
-	return database.UpdateProducto(id, req.TipoPizza, req.Descripcion, req.Precio, req.Activo)
+// validarPassword aplica la política; el error envuelve ErrPasswordDebil con
+// el detalle de lo que falta
+func validarPassword(password string, politica validators.PoliticaPassword) error {
+	if v := validators.ValidatePassword(password, politica); !v.IsValid() {
+		return fmt.Errorf("%w: %s", ErrPasswordDebil, v.GetMessage())
+	}
+	return nil
 }
 
-// EliminarProducto elimina un producto (soft delete)
-func (s *ProductoService) EliminarProducto(id int) error {
-	return database.DeleteProducto(id)
+// AuthService contiene lógica de autenticación y sesiones
+type AuthService struct {
+	store              database.Store
+	accessTTL          time.Duration
+	refreshTTL         time.Duration
+	maxIntentosUsuario int
+	maxIntentosIP      int
+	bloqueoLogin       time.Duration
+	politica           validators.PoliticaPassword
 }
 
-// ObtenerProductos retorna lista de productos activos
-func (s *ProductoService) ObtenerProductos() ([]models.Producto, error) {
-	productos, err := database.GetProductos()
-	if err != nil {
-		return nil, fmt.Errorf("error obteniendo productos: %w", err)
+// NewAuthService crea el servicio de autenticación. Recibe el Store porque la
+// rotación de refresh tokens y el conteo de intentos se hacen en transacciones
+func NewAuthService(store database.Store) *AuthService {
+	return &AuthService{
+		store:              store,
+		accessTTL:          duracionDesdeEnv("ACCESS_TOKEN_TTL", accessTTLPorDefecto),
+		refreshTTL:         duracionDesdeEnv("REFRESH_TOKEN_TTL", refreshTTLPorDefecto),
+		maxIntentosUsuario: enteroDesdeEnv("LOGIN_MAX_INTENTOS", maxIntentosUsuarioPorDefecto),
+		maxIntentosIP:      enteroDesdeEnv("LOGIN_MAX_INTENTOS_IP", maxIntentosIPPorDefecto),
+		bloqueoLogin:       duracionDesdeEnv("LOGIN_BLOQUEO", bloqueoLoginPorDefecto),
+		politica:           politicaPasswordDesdeEnv(),
 	}
-	return productos, nil
 }
 
-// Validaciones privadas
-func (s *ProductoService) validarCrearProducto(req *models.CrearProductoRequest) error {
-	if strings.TrimSpace(req.TipoPizza) == "" {
-		return fmt.Errorf("tipo_pizza es requerido")
+// AutenticarUsuario verifica usuario y contraseña llevando la cuenta de los
+// fallos por usuario y por IP. Cada fallo obliga a esperar el doble que el
+// anterior antes de reintentar (hasta 30s) y al llegar al máximo el usuario o
+// la IP quedan bloqueados durante LOGIN_BLOQUEO. Mientras tanto retorna un
+// *LoginBloqueadoError; con credenciales incorrectas, ErrCredencialesInvalidas
+func (s *AuthService) AutenticarUsuario(username, password, ip string) (*models.User, error) {
+	ahora := time.Now()
+	var user *models.User
+	var fallo error
+
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		porUsuario, err := intentoLogin(tx, models.IntentoPorUsuario, username)
+		if err != nil {
+			return err
+		}
+		porIP, err := intentoLogin(tx, models.IntentoPorIP, ip)
+		if err != nil {
+			return err
+		}
+		if espera := maxDuracion(s.esperaLogin(porUsuario, ahora), s.esperaLogin(porIP, ahora)); espera > 0 {
+			return &LoginBloqueadoError{Espera: espera}
+		}
+
+		user, err = tx.Usuarios().GetUserByCredentials(username, password)
+		if err == nil {
+			_, err = tx.IntentosLogin().BorrarIntentoLogin(models.IntentoPorUsuario, username)
+			return err
+		}
+		if err != sql.ErrNoRows && !errors.Is(err, database.ErrPasswordInvalida) {
+			return err
+		}
+
+		// El fallo se registra y la transacción termina bien para que persista
+		fallo = ErrCredencialesInvalidas
+		if err := s.registrarFalloLogin(tx, porUsuario, s.maxIntentosUsuario, ahora); err != nil {
+			return err
+		}
+		return s.registrarFalloLogin(tx, porIP, s.maxIntentosIP, ahora)
+	})
+	var bloqueo *LoginBloqueadoError
+	if errors.As(err, &bloqueo) {
+		logger.Warn("Login: Intento rechazado por bloqueo", map[string]interface{}{
+			"username": username,
+			"ip":       ip,
+			"espera":   bloqueo.Espera.Round(time.Second).String(),
+		})
+		return nil, err
 	}
-	if len(strings.TrimSpace(req.TipoPizza)) < 2 {
-		return fmt.Errorf("tipo_pizza debe tener al menos 2 caracteres")
+	if err != nil {
+		logger.Error("AutenticarUsuario: Error verificando credenciales", "LOGIN_ERROR", map[string]interface{}{
+			"username": username,
+			"error":    err.Error(),
+		})
+		return nil, fmt.Errorf("error autenticando usuario: %w", err)
 	}
-	if len(req.TipoPizza) > 50 {
-		return fmt.Errorf("tipo_pizza demasiado largo (máximo 50 caracteres)")
+	if fallo != nil {
+		return nil, fallo
 	}
+	return user, nil
+}
 
-	if len(strings.TrimSpace(req.Descripcion)) > 200 {
-		return fmt.Errorf("descripcion demasiado larga (máximo 200 caracteres)")
+// intentoLogin retorna los fallos registrados de tipo/valor, o un intento
+// vacío si no hay
+func intentoLogin(tx database.Store, tipo, valor string) (*models.IntentoLogin, error) {
+	intento, err := tx.IntentosLogin().GetIntentoLogin(tipo, valor)
+	if err == sql.ErrNoRows {
+		return &models.IntentoLogin{Tipo: tipo, Valor: valor}, nil
 	}
+	return intento, err
+}
 
-	if req.Precio <= 0 {
-		return fmt.Errorf("precio debe ser mayor a 0")
+// esperaLogin es cuánto falta para que el usuario o IP pueda reintentar: el
+// resto del bloqueo, o el retraso progresivo desde el último fallo
+func (s *AuthService) esperaLogin(i *models.IntentoLogin, ahora time.Time) time.Duration {
+	if i.BloqueadoHasta != nil && i.BloqueadoHasta.After(ahora) {
+		return i.BloqueadoHasta.Sub(ahora)
 	}
-	if req.Precio > 500 {
-		return fmt.Errorf("precio demasiado alto (máximo $500)")
+	if i.Fallos == 0 || ahora.Sub(i.UltimoFallo) > s.bloqueoLogin {
+		return 0
 	}
-
-	return nil
+	return i.UltimoFallo.Add(retrasoLogin(i.Fallos)).Sub(ahora)
 }
 
-func (s *ProductoService) validarActualizarProducto(req *models.ActualizarProductoRequest) error {
-	if strings.TrimSpace(req.TipoPizza) == "" {
-		return fmt.Errorf("tipo_pizza es requerido")
+// registrarFalloLogin suma un fallo y bloquea al llegar a max. Los fallos más
+// viejos que LOGIN_BLOQUEO se olvidan
+func (s *AuthService) registrarFalloLogin(tx database.Store, i *models.IntentoLogin, max int, ahora time.Time) error {
+	if ahora.Sub(i.UltimoFallo) > s.bloqueoLogin {
+		i.Fallos = 0
+	}
+	i.Fallos++
+	i.UltimoFallo = ahora
+	i.BloqueadoHasta = nil
+	if i.Fallos >= max {
+		hasta := ahora.Add(s.bloqueoLogin)
+		i.BloqueadoHasta = &hasta
+		i.Fallos = 0
+		logger.Warn("Login: Bloqueado por intentos fallidos", map[string]interface{}{
+			"tipo":  i.Tipo,
+			"valor": i.Valor,
+			"hasta": hasta.Format(time.RFC3339),
+		})
 	}
-	if len(strings.TrimSpace(req.TipoPizza)) < 2 {
-		return fmt.Errorf("tipo_pizza debe tener al menos 2 caracteres")
+	return tx.IntentosLogin().GuardarIntentoLogin(*i)
+}
+
+// retrasoLogin es la espera mínima tras fallos intentos fallidos: 1s, 2s,
+// 4s... hasta retrasoLoginMaximo
+func retrasoLogin(fallos int) time.Duration {
+	if fallos > 6 {
+		return retrasoLoginMaximo
 	}
-	if len(req.TipoPizza) > 50 {
-		return fmt.Errorf("tipo_pizza demasiado largo (máximo 50 caracteres)")
+	retraso := time.Second << (fallos - 1)
+	if retraso > retrasoLoginMaximo {
+		return retrasoLoginMaximo
 	}
+	return retraso
+}
 
-	if req.Precio <= 0 {
-		return fmt.Errorf("precio debe ser mayor a 0")
+// maxDuracion retorna la mayor de a y b
+func maxDuracion(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
 	}
-	if req.Precio > 500 {
-		return fmt.Errorf("precio demasiado alto (máximo $500)")
+	return b
+}
+
+// IniciarSesion emite un access token y un refresh token de una familia nueva
+func (s *AuthService) IniciarSesion(user *models.User) (*models.LoginResponse, error) {
+	familia, err := tokenAleatorioHex(16)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	var resp *models.LoginResponse
+	err = s.store.WithTx(context.Background(), func(tx database.Store) error {
+		var err error
+		resp, err = s.emitirTokens(tx, user, familia, time.Now())
+		return err
+	})
+	if err != nil {
+		logger.Error("IniciarSesion: Error emitiendo tokens", "TOKEN_GENERATION_ERROR", map[string]interface{}{
+			"usuario_id": user.ID,
+			"error":      err.Error(),
+		})
+		return nil, fmt.Errorf("error iniciando sesión: %w", err)
+	}
+	return resp, nil
 }
 
-// VendedorService contiene lógica de negocio para vendedores
-type VendedorService struct{}
+// RefrescarSesion canjea un refresh token por un par nuevo de la misma
+// familia. Cada refresh token sirve una sola vez: si llega uno ya usado se
+// asume robado y se revoca toda la familia
+func (s *AuthService) RefrescarSesion(refreshToken string) (*models.LoginResponse, error) {
+	ahora := time.Now()
+	var resp *models.LoginResponse
+	reutilizado := false
+
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		actual, err := tx.Sesiones().GetRefreshToken(hashToken(refreshToken))
+		if err == sql.ErrNoRows {
+			return ErrSesionInvalida
+		}
+		if err != nil {
+			return err
+		}
+		if actual.RevocadoAt != nil {
+			// La revocación de la familia tiene que persistir, así que la
+			// transacción termina bien y el error se retorna después
+			reutilizado = true
+			return tx.Sesiones().RevocarFamiliaRefresh(actual.Familia, ahora)
+		}
+		if !actual.ExpiresAt.After(ahora) {
+			return ErrSesionInvalida
+		}
 
-// CrearVendedor crea un nuevo vendedor
-func (s *VendedorService) CrearVendedor(nombre string) (int64, error) {
-	if nombre == "" {
-		return 0, fmt.Errorf("nombre del vendedor es requerido")
+		user, err := tx.Usuarios().GetUserByID(actual.UsuarioID)
+		if err == sql.ErrNoRows {
+			return ErrSesionInvalida
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Sesiones().RevocarRefreshToken(actual.ID, ahora); err != nil {
+			return err
+		}
+		resp, err = s.emitirTokens(tx, user, actual.Familia, time.Now())
+		return err
+	})
+	if reutilizado && err == nil {
+		logger.Warn("RefrescarSesion: Refresh token reutilizado, se revoca la familia", map[string]interface{}{})
+		return nil, ErrSesionInvalida
+	}
+	if errors.Is(err, ErrSesionInvalida) {
+		return nil, err
 	}
-
-	id, err := database.CreateVendedor(nombre)
 	if err != nil {
-		return 0, fmt.Errorf("error creando vendedor: %w", err)
+		logger.Error("RefrescarSesion: Error rotando refresh token", "TOKEN_REFRESH_ERROR", map[string]interface{}{"error": err.Error()})
+		return nil, fmt.Errorf("error refrescando sesión: %w", err)
 	}
-
-	return id, nil
+	return resp, nil
 }
 
-// ActualizarVendedor actualiza un vendedor
-func (s *VendedorService) ActualizarVendedor(id int, nombre string) error {
-	if nombre == "" {
-		return fmt.Errorf("nombre del vendedor es requerido")
+// CerrarSesion revoca la familia del refresh token y, si hay claims, el
+// access token que hizo el request. Tokens desconocidos se ignoran, así que
+// cerrar una sesión dos veces no falla
+func (s *AuthService) CerrarSesion(refreshToken string, claims *models.TokenClaims) error {
+	ahora := time.Now()
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		if refreshToken != "" {
+			actual, err := tx.Sesiones().GetRefreshToken(hashToken(refreshToken))
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			if err == nil {
+				if err := tx.Sesiones().RevocarFamiliaRefresh(actual.Familia, ahora); err != nil {
+					return err
+				}
+			}
+		}
+		if claims != nil && claims.ID != "" && claims.ExpiresAt != nil {
+			return tx.Sesiones().RevocarAccessToken(claims.ID, claims.ExpiresAt.Time, ahora)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("CerrarSesion: Error revocando tokens", "LOGOUT_ERROR", map[string]interface{}{"error": err.Error()})
+		return fmt.Errorf("error cerrando sesión: %w", err)
 	}
-
-	return database.UpdateVendedor(id, nombre)
+	return nil
 }
 
-// EliminarVendedor elimina un vendedor
-func (s *VendedorService) EliminarVendedor(id int) error {
-	return database.DeleteVendedor(id)
+// TokenRevocado indica si un access token con firma válida fue revocado.
+// Tokens sin jti, usuario o fecha de emisión (anteriores a las sesiones) se
+// consideran revocados
+func (s *AuthService) TokenRevocado(claims *models.TokenClaims) (bool, error) {
+	emitido := claims.Emitido()
+	if claims.ID == "" || claims.UserID == 0 || emitido.IsZero() {
+		return true, nil
+	}
+	return s.store.Sesiones().TokenRevocado(claims.ID, claims.UserID, emitido)
 }
 
-// ObtenerVendedores retorna lista de vendedores
-func (s *VendedorService) ObtenerVendedores() ([]models.Vendedor, error) {
-	vendedores, err := database.GetVendedores()
+// CambiarPassword cambia la contraseña del usuario autenticado si actual es
+// correcta y nueva cumple la política. Todas las sesiones del usuario se
+// cierran (access y refresh tokens, como en RestablecerPassword) y se retorna
+// un par de tokens nuevo para la sesión que hizo el cambio
+func (s *AuthService) CambiarPassword(actor *models.Principal, actual, nueva string) (*models.LoginResponse, error) {
+	if err := validarPassword(nueva, s.politica); err != nil {
+		return nil, err
+	}
+	familia, err := tokenAleatorioHex(16)
 	if err != nil {
-		return nil, fmt.Errorf("error obteniendo vendedores: %w", err)
+		return nil, err
 	}
-	return vendedores, nil
-}
 
-// DataService contiene lógica para obtener datos generales
-type DataService struct{}
+	var resp *models.LoginResponse
+	err = s.store.WithTx(context.Background(), func(tx database.Store) error {
+		user, err := tx.Usuarios().GetUserByID(actor.UserID)
+		if err == sql.ErrNoRows {
+			return ErrUsuarioNoEncontrado
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Usuarios().GetUserByCredentials(user.Username, actual); err != nil {
+			if err == sql.ErrNoRows || errors.Is(err, database.ErrPasswordInvalida) {
+				return ErrCredencialesInvalidas
+			}
+			return err
+		}
 
-// ObtenerDataInicial retorna vendedores, clientes y productos
-func (s *DataService) ObtenerDataInicial() (*models.DataResponse, error) {
-	vendedores, err := database.GetVendedores()
+		if err := tx.Usuarios().UpdateUser(user.ID, user.Username, nueva, user.Rol, actor.UsuarioID()); err != nil {
+			return err
+		}
+		antes := usuarioAuditado{User: user}
+		despues := usuarioAuditado{User: user, Password: "modificada"}
+		if err := auditar(tx, models.EntidadUsuario, user.ID, models.AccionActualizar, actor, antes, despues); err != nil {
+			return err
+		}
+		// sesiones_desde revoca hasta su milisegundo inclusive: el token
+		// nuevo se emite en el siguiente
+		desde := time.Now().Truncate(time.Millisecond)
+		if err := tx.Sesiones().RevocarSesionesUsuario(user.ID, desde); err != nil {
+			return err
+		}
+		resp, err = s.emitirTokens(tx, user, familia, desde.Add(time.Millisecond))
+		return err
+	})
+	if errors.Is(err, ErrCredencialesInvalidas) || errors.Is(err, ErrUsuarioNoEncontrado) {
+		logger.Warn("CambiarPassword: Contraseña actual incorrecta", map[string]interface{}{"username": actor.Nombre()})
+		return nil, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error obteniendo vendedores: %w", err)
+		logger.Error("CambiarPassword: Error cambiando contraseña", "PASSWORD_CHANGE_ERROR", map[string]interface{}{
+			"username": actor.Nombre(),
+			"error":    err.Error(),
+		})
+		return nil, fmt.Errorf("error cambiando contraseña: %w", err)
 	}
 
-	clientesPorVendedor, err := database.GetClientesPorVendedor()
-	if err != nil {
-		return nil, fmt.Errorf("error obteniendo clientes: %w", err)
+	logger.Info("CambiarPassword: Contraseña cambiada", map[string]interface{}{"username": actor.Nombre()})
+	return resp, nil
+}
+
+// RestablecerPassword canjea un token de restablecimiento generado por un
+// admin: fija la contraseña nueva, consume el token, revoca todas las
+// sesiones del usuario y levanta su bloqueo de login si lo tenía
+func (s *AuthService) RestablecerPassword(token, nueva string) error {
+	if err := validarPassword(nueva, s.politica); err != nil {
+		return err
 	}
 
-	productos, err := database.GetProductos()
+	ahora := time.Now()
+	var username string
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		reset, err := tx.Sesiones().GetPasswordReset(hashToken(token))
+		if err == sql.ErrNoRows {
+			return ErrResetInvalido
+		}
+		if err != nil {
+			return err
+		}
+		if reset.UsadoAt != nil || !reset.ExpiresAt.After(ahora) {
+			return ErrResetInvalido
+		}
+
+		user, err := tx.Usuarios().GetUserByID(reset.UsuarioID)
+		if err == sql.ErrNoRows {
+			return ErrResetInvalido
+		}
+		if err != nil {
+			return err
+		}
+		username = user.Username
+
+		if err := tx.Usuarios().UpdateUser(user.ID, user.Username, nueva, user.Rol, nil); err != nil {
+			return err
+		}
+		if err := tx.Sesiones().MarcarPasswordResetUsado(reset.ID, ahora); err != nil {
+			return err
+		}
+		if err := tx.Sesiones().RevocarSesionesUsuario(user.ID, ahora); err != nil {
+			return err
+		}
+		if _, err := tx.IntentosLogin().BorrarIntentoLogin(models.IntentoPorUsuario, user.Username); err != nil {
+			return err
+		}
+		antes := usuarioAuditado{User: user}
+		despues := usuarioAuditado{User: user, Password: "restablecida"}
+		return auditar(tx, models.EntidadUsuario, user.ID, models.AccionActualizar, nil, antes, despues)
+	})
+	if errors.Is(err, ErrResetInvalido) {
+		logger.Warn("RestablecerPassword: Token inválido o expirado", map[string]interface{}{})
+		return err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error obteniendo productos: %w", err)
+		logger.Error("RestablecerPassword: Error restableciendo contraseña", "PASSWORD_RESET_ERROR", map[string]interface{}{"error": err.Error()})
+		return fmt.Errorf("error restableciendo contraseña: %w", err)
 	}
 
-	return &models.DataResponse{
-		Vendedores:          vendedores,
-		ClientesPorVendedor: clientesPorVendedor,
-		Productos:           productos,
-	}, nil
+	logger.Info("RestablecerPassword: Contraseña restablecida", map[string]interface{}{"username": username})
+	return nil
 }
 
-// LimpiarBaseDatos elimina todos los datos excepto usuarios
-func (s *DataService) LimpiarBaseDatos() error {
-	// Eliminar en el orden correcto para evitar restricciones de foreign keys
-	// 1. Eliminar detalles de ventas
-	if err := database.ClearDetalleVentas(); err != nil {
-		logger.Error("LimpiarBaseDatos: Error eliminando detalles", "CLEAR_DETAIL_ERROR", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("error eliminando detalles: %w", err)
+// emitirTokens firma un access token emitido en ahora y guarda un refresh
+// token nuevo de la familia indicada
+func (s *AuthService) emitirTokens(tx database.Store, user *models.User, familia string, ahora time.Time) (*models.LoginResponse, error) {
+	jti, err := tokenAleatorioHex(16)
+	if err != nil {
+		return nil, err
+	}
+	access, err := middleware.FirmarToken(models.TokenClaims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Rol:       user.Rol,
+		EmitidoMs: ahora.UnixMilli(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(ahora),
+			ExpiresAt: jwt.NewNumericDate(ahora.Add(s.accessTTL)),
+		},
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// 2. Eliminar ventas
-	if err := database.ClearVentas(); err != nil {
-		logger.Error("LimpiarBaseDatos: Error eliminando ventas", "CLEAR_VENTAS_ERROR", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("error eliminando ventas: %w", err)
+	refresh, err := tokenAleatorio(32)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Sesiones().InsertRefreshToken(models.RefreshToken{
+		UsuarioID: user.ID,
+		TokenHash: hashToken(refresh),
+		Familia:   familia,
+		ExpiresAt: ahora.Add(s.refreshTTL),
+		CreatedAt: ahora,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// 3. Eliminar clientes
-	if err := database.ClearClientes(); err != nil {
-		logger.Error("LimpiarBaseDatos: Error eliminando clientes", "CLEAR_CLIENTES_ERROR", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("error eliminando clientes: %w", err)
-	}
+	return &models.LoginResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+		User:         *user,
+	}, nil
+}
 
-	// 4. Eliminar vendedores
-	if err := database.ClearVendedores(); err != nil {
-		logger.Error("LimpiarBaseDatos: Error eliminando vendedores", "CLEAR_VENDEDORES_ERROR", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("error eliminando vendedores: %w", err)
+// tokenAleatorio retorna n bytes aleatorios en base64 apto para URLs
+func tokenAleatorio(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
 
-	// 5. Eliminar productos
-	if err := database.ClearProductos(); err != nil {
-		logger.Error("LimpiarBaseDatos: Error eliminando productos", "CLEAR_PRODUCTOS_ERROR", map[string]interface{}{"error": err.Error()})
-		return fmt.Errorf("error eliminando productos: %w", err)
+// tokenAleatorioHex retorna n bytes aleatorios en hexadecimal
+func tokenAleatorioHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(b), nil
+}
 
-	logger.Info("LimpiarBaseDatos: Base de datos limpiada exitosamente", map[string]interface{}{})
-	return nil
+// hashToken es el hash con que se guarda un refresh token: el valor en claro
+// solo lo conoce el cliente
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-// AuthService contiene lógica de autenticación
-type AuthService struct{}
+// UsuarioService contiene lógica de negocio para usuarios
+type UsuarioService struct {
+	store    database.Store
+	resetTTL time.Duration
+	resetURL string
+}
 
-// AutenticarUsuario autentica un usuario y retorna token
-func (s *AuthService) AutenticarUsuario(username, passwordHash string) (*models.User, error) {
-	user, err := database.GetUserByCredentials(username, passwordHash)
-	if err != nil {
-		return nil, fmt.Errorf("credenciales inválidas")
+// NewUsuarioService crea el servicio de usuarios
+func NewUsuarioService(store database.Store) *UsuarioService {
+	return &UsuarioService{
+		store:    store,
+		resetTTL: duracionDesdeEnv("PASSWORD_RESET_TTL", resetTTLPorDefecto),
+		resetURL: os.Getenv("PASSWORD_RESET_URL"),
 	}
-	return user, nil
 }
 
-type UsuarioService struct{}
+// usuarioAuditado es la forma en que un usuario queda en la auditoría: el
+// hash nunca se registra, solo si la contraseña cambió
+type usuarioAuditado struct {
+	*models.User
+	Password string `json:"password,omitempty"`
+}
 
 // ObtenerTodos obtiene todos los usuarios sin mostrar contraseñas
 func (s *UsuarioService) ObtenerTodos() ([]models.User, error) {
-	usuarios, err := database.GetAllUsers()
+	usuarios, err := s.store.Usuarios().GetAllUsers()
 	if err != nil {
 		logger.Error("ObtenerTodos: Error al obtener usuarios", "USUARIOS_GET_ERROR", map[string]interface{}{
 			"error": err.Error(),
@@ -607,9 +1930,9 @@ func (s *UsuarioService) ObtenerTodos() ([]models.User, error) {
 }
 
 // CrearUsuario crea un nuevo usuario con contraseña hasheada
-func (s *UsuarioService) CrearUsuario(username, password, rol string) (int, error) {
+func (s *UsuarioService) CrearUsuario(username, password, rol string, actor *models.Principal) (int, error) {
 	// Validar que el usuario no exista
-	exists, err := database.UserExists(username)
+	exists, err := s.store.Usuarios().UserExists(username)
 	if err != nil {
 		logger.Error("CrearUsuario: Error verificando existencia", "USER_CHECK_ERROR", map[string]interface{}{
 			"username": username,
@@ -624,7 +1947,16 @@ func (s *UsuarioService) CrearUsuario(username, password, rol string) (int, erro
 	}
 
 	// Crear usuario
-	usuarioID, err := database.CreateUser(username, password, rol)
+	var usuarioID int
+	err = s.store.WithTx(context.Background(), func(tx database.Store) error {
+		var err error
+		usuarioID, err = tx.Usuarios().CreateUser(username, password, rol, actor.UsuarioID())
+		if err != nil {
+			return err
+		}
+		despues := &models.User{ID: usuarioID, Username: username, Rol: rol}
+		return auditar(tx, models.EntidadUsuario, usuarioID, models.AccionCrear, actor, nil, usuarioAuditado{User: despues})
+	})
 	if err != nil {
 		logger.Error("CrearUsuario: Error al crear", "USER_CREATE_ERROR", map[string]interface{}{
 			"username": username,
@@ -642,8 +1974,21 @@ func (s *UsuarioService) CrearUsuario(username, password, rol string) (int, erro
 }
 
 // ActualizarUsuario actualiza un usuario existente
-func (s *UsuarioService) ActualizarUsuario(usuarioID int, username, password, rol string) error {
-	err := database.UpdateUser(usuarioID, username, password, rol)
+func (s *UsuarioService) ActualizarUsuario(usuarioID int, username, password, rol string, actor *models.Principal) error {
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Usuarios().GetUserByID(usuarioID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Usuarios().UpdateUser(usuarioID, username, password, rol, actor.UsuarioID()); err != nil {
+			return err
+		}
+		despues := usuarioAuditado{User: &models.User{ID: usuarioID, Username: username, Rol: rol}}
+		if password != "" {
+			despues.Password = "modificada"
+		}
+		return auditar(tx, models.EntidadUsuario, usuarioID, models.AccionActualizar, actor, usuarioAuditado{User: antes}, despues)
+	})
 	if err != nil {
 		logger.Error("ActualizarUsuario: Error al actualizar", "USER_UPDATE_ERROR", map[string]interface{}{
 			"usuario_id": usuarioID,
@@ -661,8 +2006,19 @@ func (s *UsuarioService) ActualizarUsuario(usuarioID int, username, password, ro
 }
 
 // EliminarUsuario elimina un usuario
-func (s *UsuarioService) EliminarUsuario(usuarioID int) error {
-	err := database.DeleteUser(usuarioID)
+func (s *UsuarioService) EliminarUsuario(usuarioID int, actor *models.Principal) error {
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		antes, err := tx.Usuarios().GetUserByID(usuarioID)
+		if err != nil {
+			return err
+		}
+		// La entrada va antes del DELETE: si el usuario se borra a sí mismo,
+		// ON DELETE SET NULL limpia usuario_id y queda el username
+		if err := auditar(tx, models.EntidadUsuario, usuarioID, models.AccionEliminar, actor, usuarioAuditado{User: antes}, nil); err != nil {
+			return err
+		}
+		return tx.Usuarios().DeleteUser(usuarioID)
+	})
 	if err != nil {
 		logger.Error("EliminarUsuario: Error al eliminar", "USER_DELETE_ERROR", map[string]interface{}{
 			"usuario_id": usuarioID,
@@ -676,3 +2032,105 @@ func (s *UsuarioService) EliminarUsuario(usuarioID int) error {
 	})
 	return nil
 }
+
+// RevocarSesiones invalida todos los access y refresh tokens emitidos hasta
+// ahora para el usuario; tendrá que volver a iniciar sesión
+func (s *UsuarioService) RevocarSesiones(usuarioID int, actor *models.Principal) error {
+	err := s.store.WithTx(context.Background(), func(tx database.Store) error {
+		return tx.Sesiones().RevocarSesionesUsuario(usuarioID, time.Now())
+	})
+	if err == sql.ErrNoRows {
+		return ErrUsuarioNoEncontrado
+	}
+	if err != nil {
+		logger.Error("RevocarSesiones: Error revocando sesiones", "SESSION_REVOKE_ERROR", map[string]interface{}{
+			"usuario_id": usuarioID,
+			"error":      err.Error(),
+		})
+		return fmt.Errorf("error revocando sesiones: %w", err)
+	}
+
+	logger.Info("RevocarSesiones: Sesiones revocadas", map[string]interface{}{
+		"usuario_id": usuarioID,
+		"por":        actor.Nombre(),
+	})
+	return nil
+}
+
+// DesbloquearUsuario borra los fallos de login del usuario, levantando el
+// bloqueo si lo tenía. Los bloqueos por IP vencen solos
+func (s *UsuarioService) DesbloquearUsuario(usuarioID int, actor *models.Principal) error {
+	user, err := s.store.Usuarios().GetUserByID(usuarioID)
+	if err == sql.ErrNoRows {
+		return ErrUsuarioNoEncontrado
+	}
+	if err != nil {
+		return fmt.Errorf("error obteniendo usuario: %w", err)
+	}
+
+	bloqueado, err := s.store.IntentosLogin().BorrarIntentoLogin(models.IntentoPorUsuario, user.Username)
+	if err != nil {
+		logger.Error("DesbloquearUsuario: Error borrando intentos", "LOGIN_UNLOCK_ERROR", map[string]interface{}{
+			"usuario_id": usuarioID,
+			"error":      err.Error(),
+		})
+		return fmt.Errorf("error desbloqueando usuario: %w", err)
+	}
+
+	logger.Info("Login: Usuario desbloqueado", map[string]interface{}{
+		"username":     user.Username,
+		"por":          actor.Nombre(),
+		"tenia_fallos": bloqueado,
+	})
+	return nil
+}
+
+// GenerarResetPassword emite un token de un solo uso para que el usuario
+// elija una contraseña nueva en /auth/reset-password. Solo se guarda su hash
+// y cualquier token anterior sin usar deja de valer. Si PASSWORD_RESET_URL
+// está configurada la respuesta incluye el link con el token
+func (s *UsuarioService) GenerarResetPassword(usuarioID int, actor *models.Principal) (*models.PasswordResetResponse, error) {
+	token, err := tokenAleatorio(32)
+	if err != nil {
+		return nil, err
+	}
+	ahora := time.Now()
+	resp := &models.PasswordResetResponse{Token: token, ExpiresAt: ahora.Add(s.resetTTL)}
+
+	var username string
+	err = s.store.WithTx(context.Background(), func(tx database.Store) error {
+		user, err := tx.Usuarios().GetUserByID(usuarioID)
+		if err != nil {
+			return err
+		}
+		username = user.Username
+		_, err = tx.Sesiones().InsertPasswordReset(models.PasswordReset{
+			UsuarioID: usuarioID,
+			TokenHash: hashToken(token),
+			ExpiresAt: resp.ExpiresAt,
+			CreatedBy: actor.UsuarioID(),
+			CreatedAt: ahora,
+		})
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrUsuarioNoEncontrado
+	}
+	if err != nil {
+		logger.Error("GenerarResetPassword: Error generando token", "PASSWORD_RESET_CREATE_ERROR", map[string]interface{}{
+			"usuario_id": usuarioID,
+			"error":      err.Error(),
+		})
+		return nil, fmt.Errorf("error generando restablecimiento: %w", err)
+	}
+
+	if s.resetURL != "" {
+		resp.Link = s.resetURL + url.QueryEscape(token)
+	}
+	logger.Info("GenerarResetPassword: Token de restablecimiento generado", map[string]interface{}{
+		"username": username,
+		"por":      actor.Nombre(),
+		"expira":   resp.ExpiresAt,
+	})
+	return resp, nil
+}
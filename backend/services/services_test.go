@@ -1,268 +1,46 @@
 package services
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"pizzas-ecos/database"
+	"pizzas-ecos/middleware"
 	"pizzas-ecos/models"
 )
 
-// Mock para database functions
-type mockDatabase struct {
-	getVendedorIDFunc          func(nombre string) (int, error)
-	existsVendedorFunc         func(ctx context.Context, id int) (bool, error)
-	existsProductoFunc         func(ctx context.Context, id int) (bool, error)
-	getOrCreateClienteFunc     func(nombre string) (int, error)
-	crearVentaFunc             func(ctx context.Context, venta *models.VentaRequest) (int, error)
-	crearDetalleVentaFunc      func(ctx context.Context, detalles []models.ProductoItem) error
-	getProductosFunc           func() ([]models.Producto, error)
-	crearProductoFunc          func(producto *models.Producto) (*models.Producto, error)
-	actualizarProductoFunc     func(id int, producto *models.Producto) (*models.Producto, error)
-	eliminarProductoFunc       func(id int) error
-	getVendedoresFunc          func() ([]models.Vendedor, error)
-	crearVendedorFunc          func(vendedor *models.Vendedor) (*models.Vendedor, error)
-	actualizarVendedorFunc     func(id int, vendedor *models.Vendedor) (*models.Vendedor, error)
-	eliminarVendedorFunc       func(id int) error
-	validarCredencialesFunc    func(usuario, password string) (*models.User, error)
-	getClientesPorVendedorFunc func() (map[string][]models.Cliente, error)
+// TestMain firma los tokens de prueba con el secreto de desarrollo, que solo
+// se acepta con ENV=local
+func TestMain(m *testing.M) {
+	os.Setenv("ENV", "local")
+	os.Exit(m.Run())
 }
 
-func (m *mockDatabase) GetVendedorID(nombre string) (int, error) {
-	if m.getVendedorIDFunc != nil {
-		return m.getVendedorIDFunc(nombre)
+// newTestStore crea un store en memoria con un vendedor y un producto de prueba
+func newTestStore(t *testing.T) *database.MemoryStore {
+	t.Helper()
+	store := database.NewMemoryStore()
+	if _, err := store.Vendedores().CreateVendedor("Juan Pérez", nil); err != nil {
+		t.Fatalf("CreateVendedor() error = %v", err)
 	}
-	return 1, nil
-}
-
-func (m *mockDatabase) ExistsVendedor(ctx context.Context, id int) (bool, error) {
-	if m.existsVendedorFunc != nil {
-		return m.existsVendedorFunc(ctx, id)
-	}
-	return true, nil
-}
-
-func (m *mockDatabase) ExistsProducto(ctx context.Context, id int) (bool, error) {
-	if m.existsProductoFunc != nil {
-		return m.existsProductoFunc(ctx, id)
-	}
-	return true, nil
-}
-
-func (m *mockDatabase) GetOrCreateCliente(nombre string) (int, error) {
-	if m.getOrCreateClienteFunc != nil {
-		return m.getOrCreateClienteFunc(nombre)
-	}
-	return 1, nil
-}
-
-func (m *mockDatabase) CrearVenta(ctx context.Context, venta *models.VentaRequest) (int, error) {
-	if m.crearVentaFunc != nil {
-		return m.crearVentaFunc(ctx, venta)
-	}
-	return 1, nil
-}
-
-func (m *mockDatabase) CrearDetalleVenta(ctx context.Context, detalles []models.ProductoItem) error {
-	if m.crearDetalleVentaFunc != nil {
-		return m.crearDetalleVentaFunc(ctx, detalles)
-	}
-	return nil
-}
-
-func (m *mockDatabase) GetProductos() ([]models.Producto, error) {
-	if m.getProductosFunc != nil {
-		return m.getProductosFunc()
-	}
-	return []models.Producto{{ID: 1, TipoPizza: "Margherita", Precio: 10.0}}, nil
-}
-
-func (m *mockDatabase) CrearProducto(producto *models.Producto) (*models.Producto, error) {
-	if m.crearProductoFunc != nil {
-		return m.crearProductoFunc(producto)
-	}
-	return &models.Producto{ID: 1, TipoPizza: producto.TipoPizza, Precio: producto.Precio}, nil
-}
-
-func (m *mockDatabase) ActualizarProducto(id int, producto *models.Producto) (*models.Producto, error) {
-	if m.actualizarProductoFunc != nil {
-		return m.actualizarProductoFunc(id, producto)
-	}
-	return &models.Producto{ID: id, TipoPizza: producto.TipoPizza, Precio: producto.Precio}, nil
-}
-
-func (m *mockDatabase) EliminarProducto(id int) error {
-	if m.eliminarProductoFunc != nil {
-		return m.eliminarProductoFunc(id)
-	}
-	return nil
-}
-
-func (m *mockDatabase) GetVendedores() ([]models.Vendedor, error) {
-	if m.getVendedoresFunc != nil {
-		return m.getVendedoresFunc()
-	}
-	return []models.Vendedor{{ID: 1, Nombre: "Juan Pérez"}}, nil
-}
-
-func (m *mockDatabase) CrearVendedor(vendedor *models.Vendedor) (*models.Vendedor, error) {
-	if m.crearVendedorFunc != nil {
-		return m.crearVendedorFunc(vendedor)
-	}
-	return &models.Vendedor{ID: 1, Nombre: vendedor.Nombre}, nil
-}
-
-func (m *mockDatabase) ActualizarVendedor(id int, vendedor *models.Vendedor) (*models.Vendedor, error) {
-	if m.actualizarVendedorFunc != nil {
-		return m.actualizarVendedorFunc(id, vendedor)
-	}
-	return &models.Vendedor{ID: id, Nombre: vendedor.Nombre}, nil
-}
-
-func (m *mockDatabase) EliminarVendedor(id int) error {
-	if m.eliminarVendedorFunc != nil {
-		return m.eliminarVendedorFunc(id)
-	}
-	return nil
-}
-
-func (m *mockDatabase) ValidarCredenciales(usuario, password string) (*models.User, error) {
-	if m.validarCredencialesFunc != nil {
-		return m.validarCredencialesFunc(usuario, password)
-	}
-	return &models.User{Username: "Admin", Rol: "admin"}, nil
-}
-
-func (m *mockDatabase) GetClientesPorVendedor() (map[string][]models.Cliente, error) {
-	if m.getClientesPorVendedorFunc != nil {
-		return m.getClientesPorVendedorFunc()
-	}
-	return map[string][]models.Cliente{
-		"Juan Pérez": {{ID: 1, Nombre: "María García"}},
-	}, nil
-}
-
-// DatabaseInterface define las operaciones de base de datos
-type DatabaseInterface interface {
-	GetVendedorID(nombre string) (int, error)
-	ExistsVendedor(ctx context.Context, id int) (bool, error)
-	ExistsProducto(ctx context.Context, id int) (bool, error)
-	GetOrCreateCliente(nombre string) (int, error)
-	CrearVenta(ctx context.Context, venta *models.VentaRequest) (int, error)
-	CrearDetalleVenta(ctx context.Context, detalles []models.ProductoItem) error
-	GetProductos() ([]models.Producto, error)
-	CrearProducto(producto *models.Producto) (*models.Producto, error)
-	ActualizarProducto(id int, producto *models.Producto) (*models.Producto, error)
-	EliminarProducto(id int) error
-	GetVendedores() ([]models.Vendedor, error)
-	CrearVendedor(vendedor *models.Vendedor) (*models.Vendedor, error)
-	ActualizarVendedor(id int, vendedor *models.Vendedor) (*models.Vendedor, error)
-	EliminarVendedor(id int) error
-	ValidarCredenciales(usuario, password string) (*models.User, error)
-	GetClientesPorVendedor() (map[string][]models.Cliente, error)
-}
-
-// VentaService con inyección de dependencias
-type TestableVentaService struct {
-	db DatabaseInterface
-}
-
-func NewTestableVentaService(db DatabaseInterface) *TestableVentaService {
-	return &TestableVentaService{db: db}
-}
-
-func (s *TestableVentaService) CrearVenta(req *models.VentaRequest) (int, error) {
-	ctx := context.Background()
-
-	// Validar datos requeridos
-	if err := s.validarVentaRequest(req); err != nil {
-		return 0, err
-	}
-
-	// Obtener ID del vendedor
-	vendedorID, err := s.db.GetVendedorID(req.Vendedor)
-	if err != nil {
-		return 0, fmt.Errorf("vendedor no encontrado: %w", err)
-	}
-
-	// Verificar que el vendedor existe
-	exists, err := s.db.ExistsVendedor(ctx, vendedorID)
-	if err != nil || !exists {
-		return 0, fmt.Errorf("vendedor no válido")
-	}
-
-	// Crear venta
-	ventaID, err := s.db.CrearVenta(ctx, req)
-	if err != nil {
-		return 0, fmt.Errorf("error al crear venta: %w", err)
-	}
-
-	// Crear detalles de venta
-	detalles := s.crearDetallesVenta(ventaID, req.Items)
-	if err := s.db.CrearDetalleVenta(ctx, detalles); err != nil {
-		return 0, fmt.Errorf("error al crear detalles de venta: %w", err)
+	if _, err := store.Productos().CreateProducto(1, "Margherita", "Clásica", 10.0, nil); err != nil {
+		t.Fatalf("CreateProducto() error = %v", err)
 	}
-
-	return ventaID, nil
-}
-
-func (s *TestableVentaService) validarVentaRequest(req *models.VentaRequest) error {
-	if req.Vendedor == "" {
-		return fmt.Errorf("vendedor es requerido")
-	}
-	if req.Cliente == "" {
-		return fmt.Errorf("cliente es requerido")
-	}
-	if len(req.Items) == 0 {
-		return fmt.Errorf("al menos un item es requerido")
-	}
-	for _, item := range req.Items {
-		if item.ProductID <= 0 || item.Cantidad <= 0 || item.Precio <= 0 {
-			return fmt.Errorf("item inválido: producto_id=%d, cantidad=%d, precio=%.2f",
-				item.ProductID, item.Cantidad, item.Precio)
-		}
-		// Validar que el producto existe
-		exists, err := s.db.ExistsProducto(context.Background(), item.ProductID)
-		if err != nil {
-			return fmt.Errorf("error al validar producto: %w", err)
-		}
-		if !exists {
-			return fmt.Errorf("producto con ID %d no existe", item.ProductID)
-		}
-	}
-	// Teléfono es opcional, solo validar formato si está presente
-	if req.TelefonoCliente != 0 && (req.TelefonoCliente < 10000000 || req.TelefonoCliente > 999999999) {
-		return fmt.Errorf("teléfono debe tener entre 8 y 9 dígitos")
-	}
-	return nil
-}
-
-func (s *TestableVentaService) calcularTotal(items []models.ProductoItem) float64 {
-	total := 0.0
-	for _, item := range items {
-		total += item.Precio * float64(item.Cantidad)
-	}
-	return total
-}
-
-func (s *TestableVentaService) crearDetallesVenta(ventaID int, items []models.ProductoItem) []models.ProductoItem {
-	detalles := make([]models.ProductoItem, len(items))
-	for i, item := range items {
-		detalles[i] = models.ProductoItem{
-			ProductID: item.ProductID,
-			Cantidad:  item.Cantidad,
-			Precio:    item.Precio,
-			Total:     item.Precio * float64(item.Cantidad),
-		}
-	}
-	return detalles
+	return store
 }
 
 func TestVentaService_CrearVenta(t *testing.T) {
 	tests := []struct {
 		name        string
 		request     *models.VentaRequest
-		mockSetup   func(*mockDatabase)
 		expectError bool
 		expectedID  int
 	}{
@@ -272,20 +50,12 @@ func TestVentaService_CrearVenta(t *testing.T) {
 				Vendedor: "Juan Pérez",
 				Cliente:  "María García",
 				Items: []models.ProductoItem{
-					{ProductID: 1, Cantidad: 2, Precio: 10.0},
+					{ProductID: 1, Cantidad: 2, Precio: 10.0, Total: 20.0},
 				},
 				PaymentMethod: "efectivo",
 				Estado:        "pagada",
 				TipoEntrega:   "retiro",
 			},
-			mockSetup: func(m *mockDatabase) {
-				m.getVendedorIDFunc = func(nombre string) (int, error) { return 1, nil }
-				m.existsVendedorFunc = func(ctx context.Context, id int) (bool, error) { return true, nil }
-				m.existsProductoFunc = func(ctx context.Context, id int) (bool, error) { return true, nil }
-				m.getOrCreateClienteFunc = func(nombre string) (int, error) { return 1, nil }
-				m.crearVentaFunc = func(ctx context.Context, venta *models.VentaRequest) (int, error) { return 1, nil }
-				m.crearDetalleVentaFunc = func(ctx context.Context, detalles []models.ProductoItem) error { return nil }
-			},
 			expectError: false,
 			expectedID:  1,
 		},
@@ -298,7 +68,6 @@ func TestVentaService_CrearVenta(t *testing.T) {
 					{ProductID: 1, Cantidad: 1, Precio: 10.0},
 				},
 			},
-			mockSetup:   func(m *mockDatabase) {},
 			expectError: true,
 			expectedID:  0,
 		},
@@ -311,7 +80,6 @@ func TestVentaService_CrearVenta(t *testing.T) {
 					{ProductID: 1, Cantidad: 1, Precio: 10.0},
 				},
 			},
-			mockSetup:   func(m *mockDatabase) {},
 			expectError: true,
 			expectedID:  0,
 		},
@@ -322,7 +90,6 @@ func TestVentaService_CrearVenta(t *testing.T) {
 				Cliente:  "María García",
 				Items:    []models.ProductoItem{},
 			},
-			mockSetup:   func(m *mockDatabase) {},
 			expectError: true,
 			expectedID:  0,
 		},
@@ -335,9 +102,6 @@ func TestVentaService_CrearVenta(t *testing.T) {
 					{ProductID: 1, Cantidad: 1, Precio: 10.0},
 				},
 			},
-			mockSetup: func(m *mockDatabase) {
-				m.getVendedorIDFunc = func(nombre string) (int, error) { return 0, fmt.Errorf("vendedor no encontrado") }
-			},
 			expectError: true,
 			expectedID:  0,
 		},
@@ -349,11 +113,7 @@ func TestVentaService_CrearVenta(t *testing.T) {
 				Items: []models.ProductoItem{
 					{ProductID: 999, Cantidad: 1, Precio: 10.0},
 				},
-			},
-			mockSetup: func(m *mockDatabase) {
-				m.getVendedorIDFunc = func(nombre string) (int, error) { return 1, nil }
-				m.existsVendedorFunc = func(ctx context.Context, id int) (bool, error) { return true, nil }
-				m.existsProductoFunc = func(ctx context.Context, id int) (bool, error) { return false, nil }
+				PaymentMethod: "efectivo",
 			},
 			expectError: true,
 			expectedID:  0,
@@ -363,13 +123,14 @@ func TestVentaService_CrearVenta(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Arrange
-			mockDB := &mockDatabase{}
-			tt.mockSetup(mockDB)
-
-			service := NewTestableVentaService(mockDB)
+			service := NewVentaService(newTestStore(t))
 
 			// Act
-			id, err := service.CrearVenta(tt.request)
+			creada, err := service.CrearVenta(tt.request, nil)
+			id := 0
+			if creada != nil {
+				id = creada.ID
+			}
 
 			// Assert
 			if tt.expectError && err == nil {
@@ -387,127 +148,1484 @@ func TestVentaService_CrearVenta(t *testing.T) {
 	}
 }
 
-type TestableProductoService struct {
-	db DatabaseInterface
-}
-
-func NewTestableProductoService(db DatabaseInterface) *TestableProductoService {
-	return &TestableProductoService{db: db}
-}
+func TestVentaService_CrearVentaIdempotente(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	service := NewVentaService(store)
+	nuevaVenta := func(cantidad int) *models.VentaRequest {
+		return &models.VentaRequest{
+			Vendedor:      "Juan Pérez",
+			Cliente:       "María García",
+			Items:         []models.ProductoItem{{ProductID: 1, Cantidad: cantidad, Precio: 8}},
+			PaymentMethod: "efectivo",
+		}
+	}
 
-func (s *TestableProductoService) ObtenerProductos() ([]models.Producto, error) {
-	return s.db.GetProductos()
-}
+	// Act
+	original, err := service.CrearVentaIdempotente(nuevaVenta(1), "clave-1", nil)
+	if err != nil || original.Replay {
+		t.Fatalf("CrearVentaIdempotente() = %+v, %v", original, err)
+	}
+	repetida, err := service.CrearVentaIdempotente(nuevaVenta(1), "clave-1", nil)
 
-func (s *TestableProductoService) CrearProducto(req *models.CrearProductoRequest) (int64, error) {
-	// Validar
-	if req.TipoPizza == "" {
-		return 0, fmt.Errorf("tipo de pizza es requerido")
+	// Assert: el reintento retorna la misma venta, con las mismas diferencias
+	// de precio, sin crear otra
+	if err != nil || !repetida.Replay || repetida.ID != original.ID {
+		t.Fatalf("reintento = %+v, %v, want id %d con Replay", repetida, err, original.ID)
+	}
+	if len(original.DiferenciasPrecio) != 1 || !reflect.DeepEqual(repetida.DiferenciasPrecio, original.DiferenciasPrecio) {
+		t.Errorf("DiferenciasPrecio del reintento = %+v, want %+v", repetida.DiferenciasPrecio, original.DiferenciasPrecio)
+	}
+	if _, err := service.CrearVentaIdempotente(nuevaVenta(2), "clave-1", nil); !errors.Is(err, ErrIdempotenciaConflicto) {
+		t.Errorf("reintento con otro cuerpo error = %v, want ErrIdempotenciaConflicto", err)
 	}
-	if req.Precio <= 0 {
-		return 0, fmt.Errorf("precio debe ser mayor a 0")
+	ventas, _, _ := store.Ventas().GetAllVentas(models.VentaFiltro{})
+	if len(ventas) != 1 {
+		t.Fatalf("GetAllVentas() len = %d, want 1", len(ventas))
 	}
 
-	producto := &models.Producto{
-		TipoPizza:   req.TipoPizza,
-		Descripcion: req.Descripcion,
-		Precio:      req.Precio,
-		Activo:      true,
+	// Una clave vencida se puede reutilizar
+	service.idempotenciaTTL = -time.Second
+	if _, err := service.CrearVentaIdempotente(nuevaVenta(1), "clave-2", nil); err != nil {
+		t.Fatalf("CrearVentaIdempotente() error = %v", err)
 	}
+	if venta, _ := service.CrearVentaIdempotente(nuevaVenta(1), "clave-2", nil); venta != nil && venta.Replay {
+		t.Error("una clave vencida no debería repetir la venta original")
+	}
+}
+
+func TestVentaService_ActualizarVentaEsAtomica(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	service := NewVentaService(store)
 
-	created, err := s.db.CrearProducto(producto)
+	creada, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1, Precio: 10.0, Total: 10.0}},
+		PaymentMethod: "efectivo",
+		Estado:        "sin_pagar",
+		TipoEntrega:   "retiro",
+	}, nil)
 	if err != nil {
-		return 0, err
+		t.Fatalf("CrearVenta() error = %v", err)
 	}
+	ventaID := creada.ID
 
-	return int64(created.ID), nil
-}
+	// Act: el producto 999 no existe, así que toda la actualización debe revertirse
+	err = service.ActualizarVenta(ventaID, "pagada", "efectivo", "retiro", nil,
+		[]map[string]interface{}{{"producto_id": float64(999), "cantidad": float64(1)}},
+		&models.ClienteVenta{Nombre: "Pedro López"}, nil)
 
-func TestProductoService_ObtenerProductos(t *testing.T) {
-	// Arrange
-	mockDB := &mockDatabase{
-		getProductosFunc: func() ([]models.Producto, error) {
-			return []models.Producto{
-				{ID: 1, TipoPizza: "Margherita", Precio: 10.0},
-				{ID: 2, TipoPizza: "Pepperoni", Precio: 12.0},
-			}, nil
-		},
+	// Assert
+	if err == nil {
+		t.Fatal("ActualizarVenta() expected error but got none")
+	}
+
+	ventas, _, _ := store.Ventas().GetAllVentas(models.VentaFiltro{IncluirCanceladas: true})
+	if len(ventas) != 1 {
+		t.Fatalf("GetAllVentas() len = %d, want 1", len(ventas))
+	}
+	if ventas[0].Estado != "sin_pagar" {
+		t.Errorf("estado = %s, want sin_pagar (rollback)", ventas[0].Estado)
 	}
+	if ventas[0].Cliente != "María García" {
+		t.Errorf("cliente = %s, want María García (rollback)", ventas[0].Cliente)
+	}
+	if _, _, exists, _ := store.Clientes().GetClienteByNombre("Pedro López"); exists {
+		t.Error("el cliente creado dentro de la transacción no debería existir tras el rollback")
+	}
+}
 
-	service := NewTestableProductoService(mockDB)
+func TestVentaService_CrearVentaUsaPrecioDeCatalogo(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	inactivoID, _ := store.Productos().CreateProducto(1, "Fugazzeta", "", 12.0, nil)
+	store.Productos().DeleteProducto(int(inactivoID), nil)
+	service := NewVentaService(store)
 
-	// Act
-	productos, err := service.ObtenerProductos()
+	// Act: el cliente intenta pagar $1 por dos pizzas de $10
+	creada, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 2, Precio: 0.5, Total: 1}},
+		PaymentMethod: "efectivo",
+	}, nil)
 
 	// Assert
 	if err != nil {
-		t.Errorf("ObtenerProductos() error = %v, want nil", err)
+		t.Fatalf("CrearVenta() unexpected error: %v", err)
+	}
+	want := []models.PrecioDiferencia{{ProductoID: 1, PrecioEnviado: 0.5, PrecioCatalogo: 10, TotalEnviado: 1, TotalCalculado: 20}}
+	if !reflect.DeepEqual(creada.DiferenciasPrecio, want) {
+		t.Errorf("DiferenciasPrecio = %+v, want %+v", creada.DiferenciasPrecio, want)
+	}
+	ventas, _, _ := store.Ventas().GetAllVentas(models.VentaFiltro{IncluirCanceladas: true})
+	if len(ventas) != 1 || ventas[0].Total != 20 {
+		t.Fatalf("venta = %+v, want total 20", ventas)
+	}
+	if ventas[0].Items[0].Precio != 10 || ventas[0].Items[0].Total != 20 {
+		t.Errorf("item = %+v, want precio 10 y total 20", ventas[0].Items[0])
 	}
 
-	if len(productos) != 2 {
-		t.Errorf("ObtenerProductos() len = %v, want 2", len(productos))
+	// Act: un producto inactivo no se puede vender
+	_, err = service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: int(inactivoID), Cantidad: 1}},
+		PaymentMethod: "efectivo",
+	}, nil)
+
+	// Assert
+	var rechazo *ItemRechazadoError
+	if !errors.Is(err, ErrProductoInactivo) || !errors.As(err, &rechazo) || rechazo.ProductoID != int(inactivoID) {
+		t.Errorf("CrearVenta() error = %v, want ErrProductoInactivo del producto %d", err, inactivoID)
 	}
 
-	if productos[0].TipoPizza != "Margherita" {
-		t.Errorf("ObtenerProductos() first product = %v, want Margherita", productos[0].TipoPizza)
+	// Act + Assert: producto y vendedor inexistentes
+	_, err = service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 99, Cantidad: 1}},
+		PaymentMethod: "efectivo",
+	}, nil)
+	if !errors.Is(err, ErrProductoNoEncontrado) || !errors.As(err, &rechazo) || rechazo.ProductoID != 99 {
+		t.Errorf("CrearVenta() error = %v, want ErrProductoNoEncontrado del producto 99", err)
+	}
+	_, err = service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Nadie",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+		PaymentMethod: "efectivo",
+	}, nil)
+	if !errors.Is(err, ErrVendedorNoEncontrado) {
+		t.Errorf("CrearVenta() error = %v, want ErrVendedorNoEncontrado", err)
 	}
 }
 
-func TestProductoService_CrearProducto(t *testing.T) {
+func TestVentaService_ActualizarVentaRechazaItems(t *testing.T) {
+	// Arrange: una venta con una línea de Margherita y un producto dado de baja
+	store := newTestStore(t)
+	inactivoID, _ := store.Productos().CreateProducto(1, "Fugazzeta", "", 12.0, nil)
+	store.Productos().DeleteProducto(int(inactivoID), nil)
+	service := NewVentaService(store)
+	creada, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+		PaymentMethod: "efectivo",
+	}, nil)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	venta, _ := store.Ventas().GetVentaByID(creada.ID)
+	detalleID := float64(venta.Items[0].DetalleID)
+
 	tests := []struct {
-		name        string
-		request     *models.CrearProductoRequest
-		mockSetup   func(*mockDatabase)
-		expectError bool
+		name       string
+		producto   map[string]interface{}
+		wantErr    error
+		wantItemDe int // producto_id del ItemRechazadoError; 0 = no es un rechazo de item
 	}{
-		{
-			name: "producto válido debe crearse exitosamente",
-			request: &models.CrearProductoRequest{
-				TipoPizza:   "Nueva Pizza",
-				Descripcion: "Deliciosa pizza nueva",
-				Precio:      15.0,
-			},
-			mockSetup: func(m *mockDatabase) {
-				m.crearProductoFunc = func(producto *models.Producto) (*models.Producto, error) {
-					return &models.Producto{ID: 1, TipoPizza: producto.TipoPizza, Precio: producto.Precio}, nil
-				}
-			},
-			expectError: false,
-		},
-		{
-			name: "producto sin tipo debe fallar",
-			request: &models.CrearProductoRequest{
-				TipoPizza: "",
-				Precio:    10.0,
-			},
-			mockSetup:   func(m *mockDatabase) {},
-			expectError: true,
-		},
+		{"producto inexistente", map[string]interface{}{"producto_id": float64(99), "cantidad": float64(1)}, ErrProductoNoEncontrado, 99},
+		{"producto inactivo", map[string]interface{}{"producto_id": float64(inactivoID), "cantidad": float64(1)}, ErrProductoInactivo, int(inactivoID)},
+		{"cantidad cero en línea nueva", map[string]interface{}{"producto_id": float64(1), "cantidad": float64(0)}, ErrCantidadInvalida, 1},
+		{"cantidad cero en línea existente", map[string]interface{}{"detalle_id": detalleID, "producto_id": float64(1), "cantidad": float64(0)}, ErrCantidadInvalida, 1},
+		{"sin producto_id", map[string]interface{}{"cantidad": float64(1)}, ErrItemInvalido, 0},
+		{"cantidad no numérica", map[string]interface{}{"producto_id": float64(1), "cantidad": "dos"}, ErrItemInvalido, 0},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Arrange
-			mockDB := &mockDatabase{}
-			tt.mockSetup(mockDB)
-
-			service := NewTestableProductoService(mockDB)
-
 			// Act
-			id, err := service.CrearProducto(tt.request)
+			err := service.ActualizarVenta(creada.ID, "sin_pagar", "efectivo", "retiro", nil,
+				[]map[string]interface{}{tt.producto}, nil, nil)
 
 			// Assert
-			if tt.expectError && err == nil {
-				t.Error("CrearProducto() expected error but got none")
-			}
-
-			if !tt.expectError && err != nil {
-				t.Errorf("CrearProducto() unexpected error: %v", err)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ActualizarVenta() error = %v, want %v", err, tt.wantErr)
 			}
-
-			if !tt.expectError && id == 0 {
-				t.Error("CrearProducto() expected id but got 0")
+			var rechazo *ItemRechazadoError
+			if esRechazo := errors.As(err, &rechazo); esRechazo != (tt.wantItemDe != 0) || (esRechazo && rechazo.ProductoID != tt.wantItemDe) {
+				t.Errorf("ActualizarVenta() error = %v, want rechazo del producto %d", err, tt.wantItemDe)
 			}
 		})
 	}
 }
+
+func TestVentaService_ActualizarVentaTransiciones(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	service := NewVentaService(store)
+	creada, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+		PaymentMethod: "efectivo",
+	}, nil)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	ventaID := creada.ID
+
+	pasos := []struct {
+		estado  string
+		wantErr error
+	}{
+		{"pagada", nil},
+		{"entregada", nil},
+		{"cancelada", ErrTransicionInvalida},
+		{"sin_pagar", ErrTransicionInvalida},
+	}
+
+	for _, paso := range pasos {
+		// Act
+		err := service.ActualizarVenta(ventaID, paso.estado, "efectivo", "retiro", nil, nil, nil, nil)
+
+		// Assert
+		if !errors.Is(err, paso.wantErr) {
+			t.Fatalf("ActualizarVenta(%s) error = %v, want %v", paso.estado, err, paso.wantErr)
+		}
+	}
+
+	venta, _ := service.ObtenerVenta(ventaID)
+	if venta.Estado != "entregada" {
+		t.Errorf("estado = %s, want entregada", venta.Estado)
+	}
+	if venta.PagadaAt == nil || venta.EntregadaAt == nil || venta.CanceladaAt != nil {
+		t.Errorf("marcas = pagada %v, entregada %v, cancelada %v", venta.PagadaAt, venta.EntregadaAt, venta.CanceladaAt)
+	}
+
+	if err := service.ActualizarVenta(999, "pagada", "efectivo", "retiro", nil, nil, nil, nil); !errors.Is(err, ErrVentaNoEncontrada) {
+		t.Errorf("ActualizarVenta() venta inexistente error = %v, want ErrVentaNoEncontrada", err)
+	}
+}
+
+func TestVentaService_CancelarVentaPagadaRevierteLosPagos(t *testing.T) {
+	// Arrange: una venta de 20 pagada con efectivo y transferencia
+	store := newTestStore(t)
+	service := NewVentaService(store)
+	creada, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 2}},
+		PaymentMethod: "efectivo",
+	}, nil)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	for _, pago := range []models.PagoRequest{{Monto: 15, Metodo: "efectivo"}, {Monto: 5, Metodo: "transferencia"}} {
+		if _, err := service.RegistrarPago(creada.ID, &pago, nil); err != nil {
+			t.Fatalf("RegistrarPago() error = %v", err)
+		}
+	}
+
+	// Act
+	err = service.ActualizarVenta(creada.ID, "cancelada", "efectivo", "retiro", nil, nil, nil, nil)
+
+	// Assert: cada método queda con su devolución y el saldo pagado en cero
+	if err != nil {
+		t.Fatalf("ActualizarVenta(cancelada) error = %v", err)
+	}
+	pagos, _ := service.ObtenerPagos(creada.ID)
+	porMetodo := map[string]float64{}
+	for _, p := range pagos {
+		porMetodo[p.Metodo] += p.Monto
+	}
+	if len(pagos) != 4 || porMetodo["efectivo"] != 0 || porMetodo["transferencia"] != 0 {
+		t.Errorf("pagos = %+v, want devolución de 15 en efectivo y 5 en transferencia", pagos)
+	}
+	if _, pagado, _ := store.Ventas().GetSaldoVenta(creada.ID); pagado != 0 {
+		t.Errorf("pagado = %.2f, want 0", pagado)
+	}
+}
+
+func TestVentaService_RegistrarPagoParcialYDividido(t *testing.T) {
+	// Arrange: venta de 20.00 sin pagar
+	store := newTestStore(t)
+	service := NewVentaService(store)
+	creada, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 2}},
+		PaymentMethod: "efectivo",
+	}, nil)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	ventaID := creada.ID
+
+	pasos := []struct {
+		monto      float64
+		metodo     string
+		wantErr    error
+		wantEstado string
+		wantPagado float64
+	}{
+		{12.5, "efectivo", nil, "sin_pagar", 12.5},
+		{10, "transferencia", ErrPagoRechazado, "sin_pagar", 12.5},
+		{7.5, "transferencia", nil, "pagada", 20},
+		{1, "efectivo", ErrPagoRechazado, "pagada", 20},
+	}
+
+	for _, paso := range pasos {
+		// Act
+		_, err := service.RegistrarPago(ventaID, &models.PagoRequest{Monto: paso.monto, Metodo: paso.metodo}, &models.Principal{Username: "admin"})
+
+		// Assert
+		if !errors.Is(err, paso.wantErr) {
+			t.Fatalf("RegistrarPago(%.2f) error = %v, want %v", paso.monto, err, paso.wantErr)
+		}
+		venta, _ := service.ObtenerVenta(ventaID)
+		if venta.Estado != paso.wantEstado || venta.Pagado != paso.wantPagado {
+			t.Errorf("tras RegistrarPago(%.2f) estado = %s pagado = %.2f, want %s %.2f",
+				paso.monto, venta.Estado, venta.Pagado, paso.wantEstado, paso.wantPagado)
+		}
+	}
+
+	pagos, err := service.ObtenerPagos(ventaID)
+	if err != nil || len(pagos) != 2 || pagos[0].RegistradoPor != "admin" {
+		t.Errorf("ObtenerPagos() = %+v, %v", pagos, err)
+	}
+
+	resumen, _ := store.Ventas().GetResumen(1)
+	if resumen["efectivo_cobrado"] != 12.5 || resumen["transferencia_cobrada"] != 7.5 || resumen["pendiente_cobro"] != 0.0 {
+		t.Errorf("GetResumen() = %v", resumen)
+	}
+
+	// Volver a sin_pagar revierte los pagos de cada método
+	if err := service.ActualizarVenta(ventaID, "sin_pagar", "efectivo", "retiro", nil, nil, nil, nil); err != nil {
+		t.Fatalf("ActualizarVenta() error = %v", err)
+	}
+	venta, _ := service.ObtenerVenta(ventaID)
+	if venta.Estado != "sin_pagar" || venta.Pagado != 0 {
+		t.Errorf("tras revertir estado = %s pagado = %.2f", venta.Estado, venta.Pagado)
+	}
+
+	if _, err := service.RegistrarPago(ventaID, &models.PagoRequest{Monto: 5, Metodo: "cheque"}, nil); err == nil {
+		t.Error("RegistrarPago() con método inválido no retornó error")
+	}
+	if _, err := service.ObtenerPagos(999); !errors.Is(err, ErrVentaNoEncontrada) {
+		t.Errorf("ObtenerPagos() venta inexistente error = %v, want ErrVentaNoEncontrada", err)
+	}
+}
+
+func TestVentaService_ResumenIncluyeTodosLosMetodosDePago(t *testing.T) {
+	// Arrange: una venta cobrada con tarjeta al crearla y otra pagada con qr
+	store := newTestStore(t)
+	service := NewVentaService(store)
+	if _, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+		PaymentMethod: "Tarjeta",
+		Estado:        "pagada",
+	}, nil); err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	creada, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 2}},
+		PaymentMethod: "efectivo",
+	}, nil)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+
+	// Act
+	if _, err := service.RegistrarPago(creada.ID, &models.PagoRequest{Monto: 20, Metodo: "qr"}, nil); err != nil {
+		t.Fatalf("RegistrarPago(qr) error = %v", err)
+	}
+	resumen, err := store.Ventas().GetResumen(1)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("GetResumen() error = %v", err)
+	}
+	want := map[string]float64{"efectivo": 0, "transferencia": 0, "tarjeta": 10, "qr": 20}
+	if got := resumen["cobrado_por_metodo"]; !reflect.DeepEqual(got, want) || resumen["total_cobrado"] != 30.0 {
+		t.Errorf("cobrado_por_metodo = %v, total_cobrado = %v, want %v y 30", got, resumen["total_cobrado"], want)
+	}
+}
+
+func TestVentaService_EstadisticasDeVendedorUsanLibroDePagos(t *testing.T) {
+	// Arrange: una venta de 20 con un pago parcial de 12.5
+	store := newTestStore(t)
+	service := NewVentaService(store)
+	creada, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 2}},
+		PaymentMethod: "efectivo",
+	}, nil)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	if _, err := service.RegistrarPago(creada.ID, &models.PagoRequest{Monto: 12.5, Metodo: "efectivo"}, nil); err != nil {
+		t.Fatalf("RegistrarPago() error = %v", err)
+	}
+
+	// Act
+	vendedores, err := store.Ventas().GetVendedoresConStats(1)
+
+	// Assert
+	if err != nil || len(vendedores) != 1 {
+		t.Fatalf("GetVendedoresConStats() = %v, %v", vendedores, err)
+	}
+	if v := vendedores[0]; v["pagado"] != 12.5 || v["deuda"] != 7.5 || v["total"] != 20.0 {
+		t.Errorf("vendedor = %v, want pagado 12.5, deuda 7.5 y total 20", v)
+	}
+}
+
+func TestVentaService_RegistraUsuarioQueCreaLaVenta(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	service := NewVentaService(store)
+	usuarioID, err := store.Usuarios().CreateUser("cajero", "secreta123", models.RolVendedor, nil)
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	actor := &models.Principal{UserID: usuarioID, Username: "cajero", Rol: models.RolVendedor}
+	nuevaVenta := func() *models.VentaRequest {
+		return &models.VentaRequest{
+			Vendedor:      "Juan Pérez",
+			Cliente:       "María García",
+			Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+			PaymentMethod: "efectivo",
+		}
+	}
+
+	// Act
+	creada, err := service.CrearVenta(nuevaVenta(), actor)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	conUsuario := creada.ID
+	creada, err = service.CrearVenta(nuevaVenta(), nil)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	anonima := creada.ID
+
+	// Assert
+	if venta, _ := service.ObtenerVenta(conUsuario); venta.RegistradaPor != "cajero" {
+		t.Errorf("RegistradaPor = %q, want %q", venta.RegistradaPor, "cajero")
+	}
+	if venta, _ := service.ObtenerVenta(anonima); venta.RegistradaPor != "" {
+		t.Errorf("RegistradaPor sin usuario = %q, want vacío", venta.RegistradaPor)
+	}
+
+	// Borrar el usuario deja la venta sin autor (ON DELETE SET NULL)
+	if err := store.Usuarios().DeleteUser(usuarioID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if venta, _ := service.ObtenerVenta(conUsuario); venta.RegistradaPor != "" {
+		t.Errorf("RegistradaPor tras borrar usuario = %q, want vacío", venta.RegistradaPor)
+	}
+}
+
+func TestVentaService_ObtenerYEliminarVenta(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	service := NewVentaService(store)
+	creada, err := service.CrearVenta(&models.VentaRequest{
+		Vendedor:        "Juan Pérez",
+		Cliente:         "María García",
+		TelefonoCliente: "11 5555-4444",
+		Items:           []models.ProductoItem{{ProductID: 1, Cantidad: 2}},
+		PaymentMethod:   "efectivo",
+	}, nil)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	ventaID := creada.ID
+
+	// Act
+	venta, err := service.ObtenerVenta(ventaID)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ObtenerVenta() error = %v", err)
+	}
+	if venta.Cliente != "María García" || venta.TelefonoCliente == nil || *venta.TelefonoCliente != "+541155554444" || len(venta.Items) != 1 {
+		t.Errorf("ObtenerVenta() = %+v", venta)
+	}
+
+	// Act
+	err = service.EliminarVenta(ventaID, nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("EliminarVenta() error = %v", err)
+	}
+	if _, err := service.ObtenerVenta(ventaID); !errors.Is(err, ErrVentaNoEncontrada) {
+		t.Errorf("ObtenerVenta() tras eliminar error = %v, want ErrVentaNoEncontrada", err)
+	}
+	if err := service.EliminarVenta(ventaID, nil); !errors.Is(err, ErrVentaNoEncontrada) {
+		t.Errorf("EliminarVenta() repetido error = %v, want ErrVentaNoEncontrada", err)
+	}
+}
+
+func TestProductoService_CambioDePrecioNoAlteraVentasPasadas(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	ventaService := NewVentaService(store)
+	productoService := NewProductoService(store)
+
+	_, err := ventaService.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 2}},
+		PaymentMethod: "efectivo",
+	}, nil)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+
+	// Act
+	err = productoService.ActualizarProducto(1, &models.ActualizarProductoRequest{
+		TipoPizza: "Margherita",
+		Precio:    15.0,
+		Activo:    true,
+	}, nil)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ActualizarProducto() error = %v", err)
+	}
+	ventas, _, _ := store.Ventas().GetAllVentas(models.VentaFiltro{IncluirCanceladas: true})
+	item := ventas[0].Items[0]
+	if item.Precio != 10 || item.Total != 20 || ventas[0].Total != 20 {
+		t.Errorf("item = %+v, total venta = %v; want precio 10 y total 20", item, ventas[0].Total)
+	}
+	if item.PrecioActual != 15 {
+		t.Errorf("PrecioActual = %v, want 15", item.PrecioActual)
+	}
+
+	historial, _ := store.Productos().GetHistorialPrecios(1)
+	if len(historial) != 2 || historial[0].Precio != 10 || historial[1].Precio != 15 {
+		t.Errorf("GetHistorialPrecios() = %+v, want [10 15]", historial)
+	}
+}
+
+func TestProductoService_ActualizarProductoInexistente(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	service := NewProductoService(store)
+
+	// Act
+	err := service.ActualizarProducto(99, &models.ActualizarProductoRequest{
+		TipoPizza: "Napolitana",
+		Precio:    12.0,
+		Activo:    true,
+	}, nil)
+
+	// Assert
+	if !errors.Is(err, ErrProductoNoEncontrado) {
+		t.Errorf("ActualizarProducto() error = %v, want ErrProductoNoEncontrado", err)
+	}
+}
+
+func TestProductoService_ObtenerProductos(t *testing.T) {
+	// Arrange
+	store := database.NewMemoryStore()
+	store.Productos().CreateProducto(1, "Pepperoni", "", 12.0, nil)
+	store.Productos().CreateProducto(1, "Margherita", "", 10.0, nil)
+
+	service := NewProductoService(store)
+
+	// Act
+	productos, err := service.ObtenerProductos(0)
+
+	// Assert
+	if err != nil {
+		t.Errorf("ObtenerProductos() error = %v, want nil", err)
+	}
+
+	if len(productos) != 2 {
+		t.Fatalf("ObtenerProductos() len = %v, want 2", len(productos))
+	}
+
+	if productos[0].TipoPizza != "Margherita" {
+		t.Errorf("ObtenerProductos() first product = %v, want Margherita", productos[0].TipoPizza)
+	}
+}
+
+func TestProductoService_CrearProducto(t *testing.T) {
+	tests := []struct {
+		name        string
+		request     *models.CrearProductoRequest
+		expectError bool
+	}{
+		{
+			name: "producto válido debe crearse exitosamente",
+			request: &models.CrearProductoRequest{
+				TipoPizza:   "Nueva Pizza",
+				Descripcion: "Deliciosa pizza nueva",
+				Precio:      15.0,
+			},
+			expectError: false,
+		},
+		{
+			name: "producto sin tipo debe fallar",
+			request: &models.CrearProductoRequest{
+				TipoPizza: "",
+				Precio:    10.0,
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			service := NewProductoService(database.NewMemoryStore())
+
+			// Act
+			id, err := service.CrearProducto(tt.request, nil)
+
+			// Assert
+			if tt.expectError && err == nil {
+				t.Error("CrearProducto() expected error but got none")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Errorf("CrearProducto() unexpected error: %v", err)
+			}
+
+			if !tt.expectError && id == 0 {
+				t.Error("CrearProducto() expected id but got 0")
+			}
+		})
+	}
+}
+
+func TestAuditoria_RegistraCambiosConDiff(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	adminID, _ := store.Usuarios().CreateUser("admin", "secreta123", models.RolAdmin, nil)
+	admin := &models.Principal{UserID: adminID, Username: "admin", Rol: models.RolAdmin}
+	ventaService := NewVentaService(store)
+	usuarioService := NewUsuarioService(store)
+	auditoriaService := NewAuditoriaService(store)
+
+	// Act: crear, cobrar y eliminar una venta; cambiar la contraseña de un usuario
+	creada, err := ventaService.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+		PaymentMethod: "efectivo",
+	}, admin)
+	if err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	ventaID := creada.ID
+	if err := ventaService.ActualizarVenta(ventaID, "pagada", "efectivo", "retiro", nil, nil, nil, admin); err != nil {
+		t.Fatalf("ActualizarVenta() error = %v", err)
+	}
+	if err := ventaService.EliminarVenta(ventaID, admin); err != nil {
+		t.Fatalf("EliminarVenta() error = %v", err)
+	}
+	if err := usuarioService.ActualizarUsuario(adminID, "admin", "otra-clave-123", models.RolAdmin, admin); err != nil {
+		t.Fatalf("ActualizarUsuario() error = %v", err)
+	}
+
+	// Assert
+	entradas, err := auditoriaService.ObtenerAuditoria(models.AuditoriaFiltro{Entidad: models.EntidadVenta, EntidadID: ventaID})
+	if err != nil {
+		t.Fatalf("ObtenerAuditoria() error = %v", err)
+	}
+	acciones := []string{}
+	for _, e := range entradas {
+		acciones = append(acciones, e.Accion)
+		if e.Usuario != "admin" || e.UsuarioID == nil || *e.UsuarioID != adminID {
+			t.Errorf("entrada %d actor = %q %v, want admin", e.ID, e.Usuario, e.UsuarioID)
+		}
+	}
+	if want := []string{models.AccionEliminar, models.AccionActualizar, models.AccionCrear}; !reflect.DeepEqual(acciones, want) {
+		t.Fatalf("acciones = %v, want %v", acciones, want)
+	}
+
+	var cambios map[string]struct {
+		Antes   interface{} `json:"antes"`
+		Despues interface{} `json:"despues"`
+	}
+	if err := json.Unmarshal(entradas[1].Cambios, &cambios); err != nil {
+		t.Fatalf("cambios no es JSON: %v", err)
+	}
+	if c := cambios["estado"]; c.Antes != "sin_pagar" || c.Despues != "pagada" {
+		t.Errorf("cambios[estado] = %+v, want sin_pagar → pagada", c)
+	}
+	if _, ok := cambios["vendedor"]; ok {
+		t.Errorf("cambios incluye campos sin modificar: %s", entradas[1].Cambios)
+	}
+
+	usuarios, _ := auditoriaService.ObtenerAuditoria(models.AuditoriaFiltro{Entidad: models.EntidadUsuario, Usuario: "admin"})
+	if len(usuarios) != 1 || !strings.Contains(string(usuarios[0].Cambios), `"password"`) ||
+		strings.Contains(string(usuarios[0].Cambios), "otra-clave-123") {
+		t.Errorf("auditoría de usuario = %+v", usuarios)
+	}
+
+	if _, err := auditoriaService.ObtenerAuditoria(models.AuditoriaFiltro{Entidad: "clientes"}); !errors.Is(err, ErrFiltroInvalido) {
+		t.Errorf("ObtenerAuditoria() entidad inválida error = %v, want ErrFiltroInvalido", err)
+	}
+}
+
+// claimsDe valida un access token como lo hace el router
+func claimsDe(t *testing.T, token string) *models.TokenClaims {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	claims, err := middleware.ValidarToken(req)
+	if err != nil {
+		t.Fatalf("ValidarToken() error = %v", err)
+	}
+	return claims
+}
+
+func TestAuthService_RotacionYRevocacionDeSesiones(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	userID, _ := store.Usuarios().CreateUser("vendedor", "secreta123", models.RolVendedor, nil)
+	user := &models.User{ID: userID, Username: "vendedor", Rol: models.RolVendedor}
+	admin := &models.Principal{Username: "admin", Rol: models.RolAdmin}
+	authService := NewAuthService(store)
+	usuarioService := NewUsuarioService(store)
+
+	// Act + Assert: el refresh token rota y cada uno sirve una sola vez
+	primera, err := authService.IniciarSesion(user)
+	if err != nil {
+		t.Fatalf("IniciarSesion() error = %v", err)
+	}
+	rotada, err := authService.RefrescarSesion(primera.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefrescarSesion() error = %v", err)
+	}
+	if rotada.RefreshToken == primera.RefreshToken || rotada.User.ID != userID {
+		t.Fatalf("RefrescarSesion() = %+v, want refresh token nuevo del mismo usuario", rotada)
+	}
+
+	// Act + Assert: reusar el refresh token viejo revoca toda la familia
+	if _, err := authService.RefrescarSesion(primera.RefreshToken); !errors.Is(err, ErrSesionInvalida) {
+		t.Errorf("reuso de refresh token error = %v, want ErrSesionInvalida", err)
+	}
+	if _, err := authService.RefrescarSesion(rotada.RefreshToken); !errors.Is(err, ErrSesionInvalida) {
+		t.Errorf("refresh de familia revocada error = %v, want ErrSesionInvalida", err)
+	}
+
+	// Act + Assert: logout revoca el access token y su refresh token
+	sesion, _ := authService.IniciarSesion(user)
+	claims := claimsDe(t, sesion.Token)
+	if revocado, err := authService.TokenRevocado(claims); err != nil || revocado {
+		t.Fatalf("TokenRevocado() = %v, %v; want false", revocado, err)
+	}
+	if err := authService.CerrarSesion(sesion.RefreshToken, claims); err != nil {
+		t.Fatalf("CerrarSesion() error = %v", err)
+	}
+	if err := authService.CerrarSesion(sesion.RefreshToken, claims); err != nil {
+		t.Errorf("CerrarSesion() repetido error = %v, want nil", err)
+	}
+	if revocado, _ := authService.TokenRevocado(claims); !revocado {
+		t.Error("TokenRevocado() tras logout = false, want true")
+	}
+	if _, err := authService.RefrescarSesion(sesion.RefreshToken); !errors.Is(err, ErrSesionInvalida) {
+		t.Errorf("refresh tras logout error = %v, want ErrSesionInvalida", err)
+	}
+
+	// Act + Assert: un admin revoca todas las sesiones del usuario
+	otra, _ := authService.IniciarSesion(user)
+	if err := usuarioService.RevocarSesiones(userID, admin); err != nil {
+		t.Fatalf("RevocarSesiones() error = %v", err)
+	}
+	if revocado, _ := authService.TokenRevocado(claimsDe(t, otra.Token)); !revocado {
+		t.Error("TokenRevocado() tras revocar sesiones = false, want true")
+	}
+	if _, err := authService.RefrescarSesion(otra.RefreshToken); !errors.Is(err, ErrSesionInvalida) {
+		t.Errorf("refresh tras revocar sesiones error = %v, want ErrSesionInvalida", err)
+	}
+	if err := usuarioService.RevocarSesiones(9999, admin); !errors.Is(err, ErrUsuarioNoEncontrado) {
+		t.Errorf("RevocarSesiones() de usuario inexistente error = %v, want ErrUsuarioNoEncontrado", err)
+	}
+}
+
+func TestAuthService_BloqueoPorIntentosFallidos(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	userID, _ := store.Usuarios().CreateUser("vendedor", "secreta123", models.RolVendedor, nil)
+	authService := NewAuthService(store)
+	usuarioService := NewUsuarioService(store)
+	admin := &models.Principal{Username: "admin", Rol: models.RolAdmin}
+
+	// Act + Assert: un fallo obliga a esperar antes del próximo intento
+	if _, err := authService.AutenticarUsuario("vendedor", "mala", "10.0.0.1"); !errors.Is(err, ErrCredencialesInvalidas) {
+		t.Fatalf("contraseña incorrecta error = %v, want ErrCredencialesInvalidas", err)
+	}
+	var bloqueo *LoginBloqueadoError
+	_, err := authService.AutenticarUsuario("vendedor", "secreta123", "10.0.0.1")
+	if !errors.As(err, &bloqueo) || bloqueo.Espera > time.Second {
+		t.Fatalf("reintento inmediato error = %v, want espera de hasta 1s", err)
+	}
+
+	// Act + Assert: el fallo que llega al máximo bloquea al usuario desde cualquier IP
+	store.IntentosLogin().GuardarIntentoLogin(models.IntentoLogin{
+		Tipo:        models.IntentoPorUsuario,
+		Valor:       "vendedor",
+		Fallos:      maxIntentosUsuarioPorDefecto - 1,
+		UltimoFallo: time.Now().Add(-time.Minute),
+	})
+	if _, err := authService.AutenticarUsuario("vendedor", "mala", "10.0.0.2"); !errors.Is(err, ErrCredencialesInvalidas) {
+		t.Fatalf("último fallo error = %v, want ErrCredencialesInvalidas", err)
+	}
+	_, err = authService.AutenticarUsuario("vendedor", "secreta123", "10.0.0.3")
+	if !errors.As(err, &bloqueo) || bloqueo.Espera < bloqueoLoginPorDefecto-time.Minute {
+		t.Fatalf("login bloqueado error = %v, want espera de ~%s", err, bloqueoLoginPorDefecto)
+	}
+
+	// Act + Assert: un admin levanta el bloqueo
+	if err := usuarioService.DesbloquearUsuario(userID, admin); err != nil {
+		t.Fatalf("DesbloquearUsuario() error = %v", err)
+	}
+	user, err := authService.AutenticarUsuario("vendedor", "secreta123", "10.0.0.3")
+	if err != nil || user.ID != userID {
+		t.Errorf("login tras desbloquear = %v, %v; want usuario %d", user, err, userID)
+	}
+	if err := usuarioService.DesbloquearUsuario(9999, admin); !errors.Is(err, ErrUsuarioNoEncontrado) {
+		t.Errorf("DesbloquearUsuario() de usuario inexistente error = %v, want ErrUsuarioNoEncontrado", err)
+	}
+}
+
+func TestAuthService_CambiarPasswordAceptaElTokenNuevoEnElMismoSegundo(t *testing.T) {
+	// Arrange: una sesión abierta justo antes del cambio, en el mismo segundo
+	store := newTestStore(t)
+	userID, _ := store.Usuarios().CreateUser("vendedor", "secreta123", models.RolVendedor, nil)
+	authService := NewAuthService(store)
+	actor := &models.Principal{UserID: userID, Username: "vendedor", Rol: models.RolVendedor}
+	vieja, _ := authService.IniciarSesion(&models.User{ID: userID, Username: "vendedor", Rol: models.RolVendedor})
+
+	// Act
+	inicio := time.Now()
+	nueva, err := authService.CambiarPassword(actor, "secreta123", "OtraClave99")
+	duracion := time.Since(inicio)
+
+	// Assert: sin esperar al segundo siguiente, el token nuevo vale y el viejo no
+	if err != nil {
+		t.Fatalf("CambiarPassword() error = %v", err)
+	}
+	if duracion > 500*time.Millisecond {
+		t.Errorf("CambiarPassword() tardó %v, want sin esperar al segundo siguiente", duracion)
+	}
+	claims := claimsDe(t, nueva.Token)
+	if claims.EmitidoMs == 0 {
+		t.Errorf("token nuevo sin iat_ms")
+	}
+	if revocado, err := authService.TokenRevocado(claims); err != nil || revocado {
+		t.Errorf("access token nuevo revocado = %v, %v, want false", revocado, err)
+	}
+	if revocado, err := authService.TokenRevocado(claimsDe(t, vieja.Token)); err != nil || !revocado {
+		t.Errorf("access token anterior revocado = %v, %v, want true", revocado, err)
+	}
+	// Un token sin iat_ms (anterior al claim) emitido en el mismo segundo
+	// también queda revocado
+	legado := claimsDe(t, vieja.Token)
+	legado.EmitidoMs = 0
+	if revocado, err := authService.TokenRevocado(legado); err != nil || !revocado {
+		t.Errorf("access token sin iat_ms revocado = %v, %v, want true", revocado, err)
+	}
+}
+
+func TestAuthService_CambioYRestablecimientoDePassword(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	userID, _ := store.Usuarios().CreateUser("vendedor", "secreta123", models.RolVendedor, nil)
+	authService := NewAuthService(store)
+	usuarioService := NewUsuarioService(store)
+	actor := &models.Principal{UserID: userID, Username: "vendedor", Rol: models.RolVendedor}
+	admin := &models.Principal{Username: "admin", Rol: models.RolAdmin}
+	sesion, _ := authService.IniciarSesion(&models.User{ID: userID, Username: "vendedor", Rol: models.RolVendedor})
+
+	// Act + Assert: la contraseña actual y la política se verifican
+	if _, err := authService.CambiarPassword(actor, "mala", "OtraClave99"); !errors.Is(err, ErrCredencialesInvalidas) {
+		t.Fatalf("contraseña actual incorrecta error = %v, want ErrCredencialesInvalidas", err)
+	}
+	if _, err := authService.CambiarPassword(actor, "secreta123", "corta"); !errors.Is(err, ErrPasswordDebil) {
+		t.Fatalf("contraseña débil error = %v, want ErrPasswordDebil", err)
+	}
+
+	// Act + Assert: el cambio cierra las demás sesiones y entrega un par nuevo
+	nueva, err := authService.CambiarPassword(actor, "secreta123", "OtraClave99")
+	if err != nil || nueva.RefreshToken == "" {
+		t.Fatalf("CambiarPassword() = %v, %v", nueva, err)
+	}
+	if _, err := authService.RefrescarSesion(sesion.RefreshToken); !errors.Is(err, ErrSesionInvalida) {
+		t.Errorf("refresh de otra sesión tras el cambio error = %v, want ErrSesionInvalida", err)
+	}
+	if revocado, err := authService.TokenRevocado(claimsDe(t, sesion.Token)); err != nil || !revocado {
+		t.Errorf("access token de otra sesión tras el cambio revocado = %v, %v, want true", revocado, err)
+	}
+	if revocado, err := authService.TokenRevocado(claimsDe(t, nueva.Token)); err != nil || revocado {
+		t.Errorf("access token nuevo revocado = %v, %v, want false", revocado, err)
+	}
+	if _, err := authService.RefrescarSesion(nueva.RefreshToken); err != nil {
+		t.Errorf("refresh de la sesión nueva error = %v", err)
+	}
+	if _, err := store.Usuarios().GetUserByCredentials("vendedor", "OtraClave99"); err != nil {
+		t.Errorf("login con la contraseña nueva error = %v", err)
+	}
+
+	// Act + Assert: un token nuevo invalida el anterior y sirve una sola vez
+	viejo, _ := usuarioService.GenerarResetPassword(userID, admin)
+	reset, err := usuarioService.GenerarResetPassword(userID, admin)
+	if err != nil {
+		t.Fatalf("GenerarResetPassword() error = %v", err)
+	}
+	if err := authService.RestablecerPassword(viejo.Token, "Restablecida1"); !errors.Is(err, ErrResetInvalido) {
+		t.Errorf("token reemplazado error = %v, want ErrResetInvalido", err)
+	}
+	if err := authService.RestablecerPassword(reset.Token, "Restablecida1"); err != nil {
+		t.Fatalf("RestablecerPassword() error = %v", err)
+	}
+	if err := authService.RestablecerPassword(reset.Token, "Restablecida2"); !errors.Is(err, ErrResetInvalido) {
+		t.Errorf("token reutilizado error = %v, want ErrResetInvalido", err)
+	}
+	if _, err := store.Usuarios().GetUserByCredentials("vendedor", "Restablecida1"); err != nil {
+		t.Errorf("login con la contraseña restablecida error = %v", err)
+	}
+
+	// Act + Assert: un token vencido no sirve
+	store.Sesiones().InsertPasswordReset(models.PasswordReset{
+		UsuarioID: userID,
+		TokenHash: hashToken("vencido"),
+		ExpiresAt: time.Now().Add(-time.Minute),
+		CreatedAt: time.Now().Add(-time.Hour),
+	})
+	if err := authService.RestablecerPassword("vencido", "Restablecida3"); !errors.Is(err, ErrResetInvalido) {
+		t.Errorf("token vencido error = %v, want ErrResetInvalido", err)
+	}
+	if _, err := usuarioService.GenerarResetPassword(9999, admin); !errors.Is(err, ErrUsuarioNoEncontrado) {
+		t.Errorf("GenerarResetPassword() de usuario inexistente error = %v, want ErrUsuarioNoEncontrado", err)
+	}
+}
+
+func TestDataService_LimpiarBaseDatosConBackup(t *testing.T) {
+	// Arrange
+	store := newTestStore(t)
+	ventaService := NewVentaService(store)
+	if _, err := ventaService.CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "María García",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 2}},
+		PaymentMethod: "efectivo",
+		Estado:        "pagada",
+		TipoEntrega:   "retiro",
+	}, nil); err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	dataService := NewDataService(store)
+	dataService.backupDir = filepath.Join(t.TempDir(), "backups")
+	admin := &models.Principal{UserID: 1, Username: "admin", Rol: models.RolAdmin}
+
+	// Act + Assert: sin la frase no se borra nada ni se escribe backup
+	if _, err := dataService.LimpiarBaseDatos("si", admin); !errors.Is(err, ErrConfirmacionInvalida) {
+		t.Fatalf("confirmación incorrecta error = %v, want ErrConfirmacionInvalida", err)
+	}
+	if ventas, _, _ := store.Ventas().GetAllVentas(models.VentaFiltro{}); len(ventas) != 1 {
+		t.Fatalf("ventas tras confirmación incorrecta = %d, want 1", len(ventas))
+	}
+
+	// Act
+	ruta, err := dataService.LimpiarBaseDatos(FraseLimpiarBaseDatos, admin)
+
+	// Assert: el backup tiene lo que se borró
+	if err != nil {
+		t.Fatalf("LimpiarBaseDatos() error = %v", err)
+	}
+	contenido, err := os.ReadFile(ruta)
+	if err != nil {
+		t.Fatalf("leyendo backup: %v", err)
+	}
+	var backup models.BackupDatos
+	if err := json.Unmarshal(contenido, &backup); err != nil {
+		t.Fatalf("backup no es JSON válido: %v", err)
+	}
+	if len(backup.Ventas) != 1 || len(backup.Ventas[0].Items) != 1 || len(backup.Pagos) != 1 ||
+		len(backup.Clientes) != 1 || len(backup.Vendedores) != 1 || len(backup.Productos) != 1 ||
+		backup.GeneradoPor != "admin" {
+		t.Errorf("backup incompleto: %+v", backup)
+	}
+	ventas, _, _ := store.Ventas().GetAllVentas(models.VentaFiltro{IncluirCanceladas: true})
+	productos, _ := store.Productos().GetAllProductos()
+	if len(ventas) != 0 || len(productos) != 0 {
+		t.Errorf("quedaron %d ventas y %d productos, want 0", len(ventas), len(productos))
+	}
+	if activa, err := store.Campanas().GetCampanaActiva(); err != nil || activa.ID != 1 {
+		t.Errorf("GetCampanaActiva() = %+v, %v; want la campaña 1 conservada", activa, err)
+	}
+}
+
+func TestCampanaService_SeparaVentasProductosYEstadisticas(t *testing.T) {
+	// Arrange: una venta en la campaña inicial
+	store := newTestStore(t)
+	ventas := NewVentaService(store)
+	campanas := NewCampanaService(store)
+	admin := &models.Principal{Username: "admin"}
+	venta := func(productoID int) error {
+		_, err := ventas.CrearVenta(&models.VentaRequest{
+			Vendedor:      "Juan Pérez",
+			Cliente:       "María García",
+			Items:         []models.ProductoItem{{ProductID: productoID, Cantidad: 1}},
+			PaymentMethod: "efectivo",
+			TipoEntrega:   "retiro",
+		}, nil)
+		return err
+	}
+	if err := venta(1); err != nil {
+		t.Fatalf("CrearVenta() en campaña inicial error = %v", err)
+	}
+
+	// Act: nueva campaña activa con el catálogo de la anterior
+	if _, err := campanas.CrearCampana(&models.CampanaRequest{Nombre: "2027", FechaInicio: "2027-02-01", FechaFin: "2027-01-01"}, admin); !errors.Is(err, ErrCampanaInvalida) {
+		t.Errorf("CrearCampana() con fechas invertidas error = %v, want ErrCampanaInvalida", err)
+	}
+	nuevaID, err := campanas.CrearCampana(&models.CampanaRequest{
+		Nombre: "2027", FechaInicio: "2027-05-01", Activa: true, CopiarProductosDe: 1,
+	}, admin)
+	if err != nil {
+		t.Fatalf("CrearCampana() error = %v", err)
+	}
+
+	// Assert: la anterior se desactivó y el catálogo copiado es independiente
+	anterior, _ := store.Campanas().GetCampanaByID(1)
+	if anterior.Activa {
+		t.Error("la campaña anterior sigue activa")
+	}
+	productos, _ := NewProductoService(store).ObtenerProductos(0)
+	if len(productos) != 1 || productos[0].ID == 1 || productos[0].CampanaID != nuevaID || productos[0].Precio != 10.0 {
+		t.Fatalf("productos de la campaña activa = %+v", productos)
+	}
+	if err := venta(1); !errors.Is(err, ErrProductoOtraCampana) {
+		t.Errorf("CrearVenta() con producto de la campaña anterior error = %v, want ErrProductoOtraCampana", err)
+	}
+	if err := venta(productos[0].ID); err != nil {
+		t.Fatalf("CrearVenta() en campaña nueva error = %v", err)
+	}
+	if err := venta(productos[0].ID); err != nil {
+		t.Fatalf("CrearVenta() en campaña nueva error = %v", err)
+	}
+
+	// Las estadísticas de cada campaña cuentan solo sus ventas
+	for _, tt := range []struct {
+		campanaID int
+		want      int
+		nombre    string
+	}{{0, 2, "2027"}, {nuevaID, 2, "2027"}, {1, 1, "Campaña inicial"}} {
+		stats, err := ventas.ObtenerEstadisticas(tt.campanaID)
+		if err != nil {
+			t.Fatalf("ObtenerEstadisticas(%d) error = %v", tt.campanaID, err)
+		}
+		resumen := stats["resumen"].(map[string]interface{})
+		if resumen["ventas_totales"] != tt.want || len(stats["ventas"].([]models.VentaStats)) != tt.want ||
+			stats["campana"].(*models.Campana).Nombre != tt.nombre {
+			t.Errorf("ObtenerEstadisticas(%d) = %v ventas, campaña %v; want %d de %s",
+				tt.campanaID, resumen["ventas_totales"], stats["campana"], tt.want, tt.nombre)
+		}
+	}
+	if _, err := ventas.ObtenerEstadisticas(99); !errors.Is(err, ErrCampanaNoEncontrada) {
+		t.Errorf("ObtenerEstadisticas(99) error = %v, want ErrCampanaNoEncontrada", err)
+	}
+
+	// Sin campaña activa no se puede vender
+	if err := campanas.ActualizarCampana(nuevaID, &models.CampanaRequest{Nombre: "2027", FechaInicio: "2027-05-01"}, admin); err != nil {
+		t.Fatalf("ActualizarCampana() error = %v", err)
+	}
+	if err := venta(productos[0].ID); !errors.Is(err, ErrSinCampanaActiva) {
+		t.Errorf("CrearVenta() sin campaña activa error = %v, want ErrSinCampanaActiva", err)
+	}
+	auditoria, _ := NewAuditoriaService(store).ObtenerAuditoria(models.AuditoriaFiltro{Entidad: models.EntidadCampana})
+	if len(auditoria) != 3 {
+		t.Errorf("auditoría de campañas = %d entradas, want 3 (crear, desactivar anterior, cerrar)", len(auditoria))
+	}
+}
+
+func TestClienteService_BusquedaYABM(t *testing.T) {
+	// Arrange: "José Martínez" tiene una venta, el resto no
+	store := newTestStore(t)
+	clientes := NewClienteService(store)
+	admin := &models.Principal{Username: "admin"}
+	if _, err := NewVentaService(store).CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "José Martínez",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+		PaymentMethod: "efectivo",
+		TipoEntrega:   "retiro",
+	}, nil); err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	for _, nombre := range []string{"Ana Josefina Ruiz", "Josefa Díaz", "Jose", "Pedro Gómez"} {
+		if _, err := clientes.CrearCliente(&models.ClienteRequest{Nombre: nombre}, admin); err != nil {
+			t.Fatalf("CrearCliente(%q) error = %v", nombre, err)
+		}
+	}
+
+	// Act + Assert: sin acentos ni mayúsculas; exacto, prefijo del nombre
+	// (primero el que tiene ventas) y prefijo de otra palabra
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"JOSE", []string{"Jose", "José Martínez", "Josefa Díaz", "Ana Josefina Ruiz"}},
+		{"gomez", []string{"Pedro Gómez"}},
+		{"mart", []string{"José Martínez"}},
+		{"xyz", []string{}},
+	}
+	for _, tt := range tests {
+		got, err := clientes.BuscarClientes(tt.q, 0)
+		if err != nil {
+			t.Fatalf("BuscarClientes(%q) error = %v", tt.q, err)
+		}
+		nombres := []string{}
+		for _, c := range got {
+			nombres = append(nombres, c.Nombre)
+		}
+		if strings.Join(nombres, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("BuscarClientes(%q) = %v, want %v", tt.q, nombres, tt.want)
+		}
+	}
+	if got, _ := clientes.BuscarClientes("jose", 2); len(got) != 2 {
+		t.Errorf("BuscarClientes() con limit 2 = %d clientes", len(got))
+	}
+	if _, err := clientes.BuscarClientes("jose", MaxLimitBusquedaClientes+1); !errors.Is(err, ErrFiltroInvalido) {
+		t.Errorf("BuscarClientes() con limit excesivo error = %v, want ErrFiltroInvalido", err)
+	}
+
+	// Nombre repetido, actualización y baja
+	if _, err := clientes.CrearCliente(&models.ClienteRequest{Nombre: "Jose"}, admin); !errors.Is(err, ErrClienteDuplicado) {
+		t.Errorf("CrearCliente() repetido error = %v, want ErrClienteDuplicado", err)
+	}
+	pedro := buscarUno(t, clientes, "pedro")
+	if err := clientes.ActualizarCliente(pedro.ID, &models.ClienteRequest{Nombre: "Pedro Gómez", Telefono: "1144445555"}, admin); err != nil {
+		t.Fatalf("ActualizarCliente() error = %v", err)
+	}
+	if got, _ := clientes.ObtenerCliente(pedro.ID); got.Telefono != "+541144445555" {
+		t.Errorf("teléfono actualizado = %q, want +541144445555", got.Telefono)
+	}
+	jose := buscarUno(t, clientes, "josé m")
+	if err := clientes.EliminarCliente(jose.ID, admin); !errors.Is(err, ErrClienteConVentas) {
+		t.Errorf("EliminarCliente() con ventas error = %v, want ErrClienteConVentas", err)
+	}
+	if err := clientes.EliminarCliente(pedro.ID, admin); err != nil {
+		t.Fatalf("EliminarCliente() error = %v", err)
+	}
+	if _, err := clientes.ObtenerCliente(pedro.ID); !errors.Is(err, ErrClienteNoEncontrado) {
+		t.Errorf("ObtenerCliente() eliminado error = %v, want ErrClienteNoEncontrado", err)
+	}
+	auditoria, _ := NewAuditoriaService(store).ObtenerAuditoria(models.AuditoriaFiltro{Entidad: models.EntidadCliente, EntidadID: pedro.ID})
+	if len(auditoria) != 3 {
+		t.Errorf("auditoría del cliente = %d entradas, want 3 (crear, actualizar, eliminar)", len(auditoria))
+	}
+}
+
+// buscarUno retorna el primer resultado de la búsqueda de clientes
+func buscarUno(t *testing.T, s *ClienteService, q string) models.Cliente {
+	t.Helper()
+	got, err := s.BuscarClientes(q, 1)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("BuscarClientes(%q) = %v, %v", q, got, err)
+	}
+	return got[0]
+}
+
+func TestPuntajeDuplicado(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    models.Cliente
+		want    float64
+		motivos string
+	}{
+		{"error de tipeo sin teléfonos", models.Cliente{Nombre: "Juan Pérez"}, models.Cliente{Nombre: "juan peres"}, 0.78, "nombre_similar"},
+		{"error de tipeo con el mismo teléfono", models.Cliente{Nombre: "Juan Pérez", Telefono: "+541122334455"}, models.Cliente{Nombre: "Juan Peres", Telefono: "+541122334455"}, 0.93, "nombre_similar,mismo_telefono"},
+		{"palabras en otro orden", models.Cliente{Nombre: "Juan Pérez"}, models.Cliente{Nombre: "Pérez Juan"}, 0.85, "nombre_similar"},
+		{"teléfonos distintos", models.Cliente{Nombre: "Juan Pérez", Telefono: "+541122334455"}, models.Cliente{Nombre: "Pérez Juan", Telefono: "+541199998888"}, 0.7, "nombre_similar,telefono_distinto"},
+		{"nombres distintos", models.Cliente{Nombre: "María Gómez"}, models.Cliente{Nombre: "Juan Pérez"}, 0.34, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, motivos := puntajeDuplicado(tt.a, tt.b, database.NormalizarNombre(tt.a.Nombre), database.NormalizarNombre(tt.b.Nombre))
+
+			// Assert
+			if got != tt.want || strings.Join(motivos, ",") != tt.motivos {
+				t.Errorf("puntajeDuplicado() = %v %v, want %v [%s]", got, motivos, tt.want, tt.motivos)
+			}
+		})
+	}
+}
+
+func TestClienteService_DuplicadosYFusion(t *testing.T) {
+	// Arrange: dos pedidos con el mismo nombre escrito distinto son un solo
+	// cliente; "Juan Peres" es otro cliente con el mismo teléfono
+	store := newTestStore(t)
+	ventas := NewVentaService(store)
+	clientes := NewClienteService(store)
+	admin := &models.Principal{Username: "admin"}
+	venta := func(cliente string, telefono models.Telefono) {
+		t.Helper()
+		if _, err := ventas.CrearVenta(&models.VentaRequest{
+			Vendedor:        "Juan Pérez",
+			Cliente:         cliente,
+			TelefonoCliente: telefono,
+			Items:           []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+			PaymentMethod:   "efectivo",
+			TipoEntrega:     "retiro",
+		}, nil); err != nil {
+			t.Fatalf("CrearVenta(%q) error = %v", cliente, err)
+		}
+	}
+	venta("Juan Pérez", "")
+	venta("  juan   perez ", "")
+	venta("Juan Peres", "011 2233-4455")
+	venta("Juan Peres", "")
+	venta("María Gómez", "")
+
+	todos, _ := clientes.ObtenerClientes()
+	if len(todos) != 3 {
+		t.Fatalf("clientes = %+v, want 3 (Juan Pérez, Juan Peres, María Gómez)", todos)
+	}
+	if _, err := clientes.CrearCliente(&models.ClienteRequest{Nombre: "JUAN PÉREZ"}, admin); !errors.Is(err, ErrClienteDuplicado) {
+		t.Errorf("CrearCliente() con el nombre en mayúsculas error = %v, want ErrClienteDuplicado", err)
+	}
+
+	// Act: detectar
+	duplicados, err := clientes.BuscarDuplicados(0)
+	if err != nil {
+		t.Fatalf("BuscarDuplicados() error = %v", err)
+	}
+
+	// Assert: un solo par, el más antiguo como cliente
+	if len(duplicados) != 1 || duplicados[0].Cliente.Nombre != "Juan Pérez" || duplicados[0].Duplicado.Nombre != "Juan Peres" {
+		t.Fatalf("BuscarDuplicados() = %+v, want el par Juan Pérez / Juan Peres", duplicados)
+	}
+	par := duplicados[0]
+	if _, err := clientes.BuscarDuplicados(1.5); !errors.Is(err, ErrFiltroInvalido) {
+		t.Errorf("BuscarDuplicados(1.5) error = %v, want ErrFiltroInvalido", err)
+	}
+
+	// Act: fusionar
+	if _, err := clientes.FusionarClientes(par.Cliente.ID, par.Cliente.ID, admin); !errors.Is(err, ErrFusionInvalida) {
+		t.Errorf("FusionarClientes() consigo mismo error = %v, want ErrFusionInvalida", err)
+	}
+	movidas, err := clientes.FusionarClientes(par.Cliente.ID, par.Duplicado.ID, admin)
+	if err != nil {
+		t.Fatalf("FusionarClientes() error = %v", err)
+	}
+
+	// Assert: las ventas y el teléfono pasaron al cliente y el duplicado no existe
+	if movidas != 2 {
+		t.Errorf("ventas movidas = %d, want 2", movidas)
+	}
+	if n, _ := store.Clientes().ContarVentasCliente(par.Cliente.ID); n != 4 {
+		t.Errorf("ventas del cliente fusionado = %d, want 4", n)
+	}
+	if got, _ := clientes.ObtenerCliente(par.Cliente.ID); got.Telefono != "+541122334455" {
+		t.Errorf("teléfono del cliente fusionado = %q, want +541122334455", got.Telefono)
+	}
+	if _, err := clientes.ObtenerCliente(par.Duplicado.ID); !errors.Is(err, ErrClienteNoEncontrado) {
+		t.Errorf("ObtenerCliente(duplicado) error = %v, want ErrClienteNoEncontrado", err)
+	}
+	auditoria, _ := NewAuditoriaService(store).ObtenerAuditoria(models.AuditoriaFiltro{Entidad: models.EntidadCliente, EntidadID: par.Cliente.ID})
+	if len(auditoria) != 1 || auditoria[0].Accion != models.AccionFusionar {
+		t.Errorf("auditoría del cliente = %+v, want una entrada fusionar", auditoria)
+	}
+}
+
+func TestClienteService_ObtenerHistorial(t *testing.T) {
+	// Arrange: Ana tiene una venta con un pago parcial, una pagada y una
+	// cancelada; la de Pedro no es parte de su historial
+	store := newTestStore(t)
+	if _, err := store.Productos().CreateProducto(1, "Fugazzeta", "", 12.0, nil); err != nil {
+		t.Fatalf("CreateProducto() error = %v", err)
+	}
+	ventas := NewVentaService(store)
+	clientes := NewClienteService(store)
+	venta := func(cliente, estado string, productoID, cantidad int) int {
+		t.Helper()
+		creada, err := ventas.CrearVenta(&models.VentaRequest{
+			Vendedor:      "Juan Pérez",
+			Cliente:       cliente,
+			Items:         []models.ProductoItem{{ProductID: productoID, Cantidad: cantidad}},
+			PaymentMethod: "efectivo",
+			Estado:        estado,
+			TipoEntrega:   "retiro",
+		}, nil)
+		if err != nil {
+			t.Fatalf("CrearVenta(%q) error = %v", cliente, err)
+		}
+		id := creada.ID
+		return id
+	}
+	conPago := venta("Ana Díaz", "sin_pagar", 1, 2)
+	if _, err := ventas.RegistrarPago(conPago, &models.PagoRequest{Monto: 5, Metodo: "efectivo"}, nil); err != nil {
+		t.Fatalf("RegistrarPago() error = %v", err)
+	}
+	venta("Ana Díaz", "pagada", 2, 1)
+	cancelada := venta("Ana Díaz", "sin_pagar", 2, 3)
+	if err := ventas.ActualizarVenta(cancelada, "cancelada", "efectivo", "retiro", nil, nil, nil, nil); err != nil {
+		t.Fatalf("ActualizarVenta(cancelada) error = %v", err)
+	}
+	venta("Pedro Gómez", "sin_pagar", 1, 4)
+	ana := buscarUno(t, clientes, "ana")
+
+	// Act
+	historial, err := clientes.ObtenerHistorial(ana.ID)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ObtenerHistorial() error = %v", err)
+	}
+	if len(historial.Ventas) != 3 || historial.Ventas[0].ID != cancelada || len(historial.Ventas[0].Items) != 1 {
+		t.Errorf("ventas = %+v, want las 3 de Ana con items, la más reciente primero", historial.Ventas)
+	}
+	r := historial.Resumen
+	if r.CantidadPedidos != 2 || r.TotalGastado != 32 || r.Deuda != 15 {
+		t.Errorf("resumen = %+v, want 2 pedidos, total 32 y deuda 15 (sin la cancelada)", r)
+	}
+	if r.ProductoFavorito == nil || r.ProductoFavorito.Tipo != "Margherita" || r.ProductoFavorito.Cantidad != 2 {
+		t.Errorf("producto favorito = %+v, want Margherita x2", r.ProductoFavorito)
+	}
+	if r.PrimeraCompra == nil || r.UltimaCompra == nil || r.UltimaCompra.Before(*r.PrimeraCompra) {
+		t.Errorf("primera = %v, última = %v", r.PrimeraCompra, r.UltimaCompra)
+	}
+	if _, err := clientes.ObtenerHistorial(999); !errors.Is(err, ErrClienteNoEncontrado) {
+		t.Errorf("ObtenerHistorial() inexistente error = %v, want ErrClienteNoEncontrado", err)
+	}
+}
+
+func TestClienteService_ExportarYAnonimizar(t *testing.T) {
+	// Arrange: Ana cambió de nombre y tiene una venta propia y otra que era
+	// de Pedro
+	store := newTestStore(t)
+	ventas := NewVentaService(store)
+	clientes := NewClienteService(store)
+	admin := &models.Principal{Username: "admin"}
+	venta := func(cliente string, telefono models.Telefono) int {
+		t.Helper()
+		creada, err := ventas.CrearVenta(&models.VentaRequest{
+			Vendedor:        "Juan Pérez",
+			Cliente:         cliente,
+			TelefonoCliente: telefono,
+			Items:           []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+			PaymentMethod:   "efectivo",
+			TipoEntrega:     "retiro",
+		}, admin)
+		if err != nil {
+			t.Fatalf("CrearVenta(%q) error = %v", cliente, err)
+		}
+		id := creada.ID
+		return id
+	}
+	venta("Ana Díaz", "11 2233-4455")
+	ana := buscarUno(t, clientes, "ana")
+	if err := clientes.ActualizarCliente(ana.ID, &models.ClienteRequest{Nombre: "Ana María Díaz", Telefono: ana.Telefono}, admin); err != nil {
+		t.Fatalf("ActualizarCliente() error = %v", err)
+	}
+	dePedro := venta("Pedro Gómez", "")
+	if err := ventas.ActualizarVenta(dePedro, "sin_pagar", "efectivo", "retiro", nil, nil, &models.ClienteVenta{Nombre: "Ana María Díaz"}, admin); err != nil {
+		t.Fatalf("ActualizarVenta() error = %v", err)
+	}
+
+	// Act
+	exportacion, err := clientes.ExportarCliente(ana.ID, admin)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ExportarCliente() error = %v", err)
+	}
+	if exportacion.Cliente.Nombre != "Ana María Díaz" || len(exportacion.Ventas) != 2 || len(exportacion.Auditoria) == 0 {
+		t.Errorf("exportación = %+v, want Ana con 2 ventas y su auditoría", exportacion)
+	}
+
+	// Act
+	err = clientes.AnonimizarCliente(ana.ID, admin)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("AnonimizarCliente() error = %v", err)
+	}
+	anonimo, _ := clientes.ObtenerCliente(ana.ID)
+	if anonimo.Nombre != fmt.Sprintf("Cliente anonimizado %d", ana.ID) || anonimo.Telefono != "" {
+		t.Errorf("cliente anonimizado = %+v", anonimo)
+	}
+	historial, _ := clientes.ObtenerHistorial(ana.ID)
+	if len(historial.Ventas) != 2 || historial.Resumen.TotalGastado != 20 {
+		t.Errorf("ventas = %d, total = %v, want las 2 ventas por 20", len(historial.Ventas), historial.Resumen.TotalGastado)
+	}
+	auditoria, _ := NewAuditoriaService(store).ObtenerAuditoria(models.AuditoriaFiltro{})
+	acciones := map[string]bool{}
+	registro, _ := json.Marshal(auditoria)
+	for _, entrada := range auditoria {
+		acciones[entrada.Accion] = true
+	}
+	if !acciones[models.AccionExportar] || !acciones[models.AccionAnonimizar] {
+		t.Errorf("acciones auditadas = %v, want exportar y anonimizar", acciones)
+	}
+	if strings.Contains(string(registro), "Díaz") || strings.Contains(string(registro), "1122334455") {
+		t.Errorf("la auditoría conserva datos de Ana: %s", registro)
+	}
+	if !strings.Contains(string(registro), "Pedro Gómez") {
+		t.Errorf("la auditoría perdió el cliente anterior de la venta reasignada: %s", registro)
+	}
+	if err := clientes.AnonimizarCliente(999, admin); !errors.Is(err, ErrClienteNoEncontrado) {
+		t.Errorf("AnonimizarCliente() inexistente error = %v, want ErrClienteNoEncontrado", err)
+	}
+}
+
+func TestClienteService_AnonimizarRecorreTodaLaAuditoria(t *testing.T) {
+	// Arrange: el nombre anterior de Ana quedó detrás de más de una página de
+	// entradas de auditoría
+	store := newTestStore(t)
+	clientes := NewClienteService(store)
+	admin := &models.Principal{Username: "admin"}
+	if _, err := NewVentaService(store).CrearVenta(&models.VentaRequest{
+		Vendedor:      "Juan Pérez",
+		Cliente:       "Ana Díaz",
+		Items:         []models.ProductoItem{{ProductID: 1, Cantidad: 1}},
+		PaymentMethod: "efectivo",
+	}, admin); err != nil {
+		t.Fatalf("CrearVenta() error = %v", err)
+	}
+	ana := buscarUno(t, clientes, "ana")
+	if err := clientes.ActualizarCliente(ana.ID, &models.ClienteRequest{Nombre: "Ana María Díaz"}, admin); err != nil {
+		t.Fatalf("ActualizarCliente() error = %v", err)
+	}
+	for i := 0; i < database.MaxLimitAuditoria; i++ {
+		if _, err := store.Auditoria().InsertAuditoria(models.Auditoria{
+			Entidad:   models.EntidadCliente,
+			EntidadID: ana.ID,
+			Accion:    models.AccionActualizar,
+			Cambios:   json.RawMessage(`{"notas":{"antes":"a","despues":"b"}}`),
+			CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatalf("InsertAuditoria() error = %v", err)
+		}
+	}
+
+	// Act
+	err := clientes.AnonimizarCliente(ana.ID, admin)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("AnonimizarCliente() error = %v", err)
+	}
+	auditoria, err := auditoriaCompleta(store, models.EntidadCliente, ana.ID)
+	if err != nil {
+		t.Fatalf("auditoriaCompleta() error = %v", err)
+	}
+	if len(auditoria) <= database.MaxLimitAuditoria {
+		t.Errorf("auditoriaCompleta() = %d entradas, want más de %d", len(auditoria), database.MaxLimitAuditoria)
+	}
+	registro, _ := json.Marshal(auditoria)
+	if strings.Contains(string(registro), "Díaz") {
+		t.Errorf("la auditoría conserva el nombre anterior de Ana")
+	}
+}
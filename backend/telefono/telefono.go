@@ -0,0 +1,190 @@
+// Package telefono normaliza números de teléfono al formato E.164
+// ("+541123456789"), el que se guarda en clientes.telefono
+package telefono
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"pizzas-ecos/logger"
+)
+
+// ErrInvalido indica un número que no puede existir: caracteres que no son
+// dígitos, demasiado corto o largo para su país, o más de 15 dígitos
+var ErrInvalido = errors.New("teléfono inválido")
+
+// maxDigitosE164 es el largo máximo de un número E.164 sin el '+'
+const maxDigitosE164 = 15
+
+// pais describe el plan de numeración de un país: su código, el prefijo
+// troncal que se marca dentro del país y el largo del número nacional
+type pais struct {
+	codigo      string
+	troncal     string
+	minNacional int
+	maxNacional int
+}
+
+// paises son los países que se validan con su largo nacional. Los números
+// internacionales de otros países solo se validan contra el largo de E.164
+var paises = map[string]pais{
+	"AR": {codigo: "54", troncal: "0", minNacional: 10, maxNacional: 11},
+	"BO": {codigo: "591", troncal: "0", minNacional: 8, maxNacional: 8},
+	"BR": {codigo: "55", troncal: "0", minNacional: 10, maxNacional: 11},
+	"CL": {codigo: "56", minNacional: 9, maxNacional: 9},
+	"ES": {codigo: "34", minNacional: 9, maxNacional: 9},
+	"PY": {codigo: "595", troncal: "0", minNacional: 7, maxNacional: 9},
+	"US": {codigo: "1", troncal: "1", minNacional: 10, maxNacional: 10},
+	"UY": {codigo: "598", troncal: "0", minNacional: 8, maxNacional: 8},
+}
+
+// PaisPorDefecto es el país (código ISO de dos letras) que se asume para los
+// números sin código de país. Se lee de TELEFONO_PAIS; por defecto AR
+var PaisPorDefecto = paisDesdeEnv()
+
+func paisDesdeEnv() string {
+	valor := strings.ToUpper(strings.TrimSpace(os.Getenv("TELEFONO_PAIS")))
+	if valor == "" {
+		return "AR"
+	}
+	if _, ok := paises[valor]; !ok {
+		logger.Warn("TELEFONO_PAIS no soportado, se usa el valor por defecto", map[string]interface{}{
+			"valor":       valor,
+			"por_defecto": "AR",
+		})
+		return "AR"
+	}
+	return valor
+}
+
+// Normalizar convierte un número a E.164 asumiendo PaisPorDefecto para los
+// que no traen código de país. "" retorna "" (sin teléfono)
+func Normalizar(numero string) (string, error) {
+	return NormalizarPara(numero, PaisPorDefecto)
+}
+
+// NormalizarPara convierte un número a E.164. Acepta espacios, guiones,
+// puntos y paréntesis como separadores; "+" o "00" inicial indican que el
+// número trae código de país y si no, se asume codigoPais
+func NormalizarPara(numero, codigoPais string) (string, error) {
+	numero = strings.TrimSpace(numero)
+	if numero == "" {
+		return "", nil
+	}
+
+	internacional := strings.HasPrefix(numero, "+")
+	digitos, err := soloDigitos(strings.TrimPrefix(numero, "+"))
+	if err != nil {
+		return "", err
+	}
+	if !internacional && strings.HasPrefix(digitos, "00") {
+		digitos, internacional = digitos[2:], true
+	}
+
+	if internacional {
+		return normalizarInternacional(digitos)
+	}
+
+	p, ok := paises[codigoPais]
+	if !ok {
+		return "", fmt.Errorf("país %q no soportado", codigoPais)
+	}
+	if nacional, ok := p.nacional(digitos); ok {
+		return armar(p.codigo, nacional)
+	}
+	// Los teléfonos cargados como entero solían incluir el código de país
+	// sin el '+' (por ejemplo 5491123456789)
+	if strings.HasPrefix(digitos, p.codigo) {
+		if nacional, ok := p.nacional(digitos[len(p.codigo):]); ok {
+			return armar(p.codigo, nacional)
+		}
+	}
+	return "", fmt.Errorf("%w: %s no es un número válido de %s", ErrInvalido, numero, codigoPais)
+}
+
+// normalizarInternacional valida un número que trae código de país
+func normalizarInternacional(digitos string) (string, error) {
+	for largo := 3; largo >= 1; largo-- {
+		if len(digitos) <= largo {
+			continue
+		}
+		for iso, p := range paises {
+			if p.codigo != digitos[:largo] {
+				continue
+			}
+			nacional, ok := p.nacional(digitos[largo:])
+			if !ok {
+				return "", fmt.Errorf("%w: +%s no es un número válido de %s", ErrInvalido, digitos, iso)
+			}
+			return armar(p.codigo, nacional)
+		}
+	}
+
+	if len(digitos) < 8 || digitos[0] == '0' {
+		return "", fmt.Errorf("%w: +%s es demasiado corto", ErrInvalido, digitos)
+	}
+	return armar("", digitos)
+}
+
+// nacional quita el prefijo troncal (y en Argentina el 15 de los celulares)
+// y retorna el número nacional si su largo es posible en el país
+func (p pais) nacional(digitos string) (string, bool) {
+	if p.troncal != "" {
+		digitos = strings.TrimPrefix(digitos, p.troncal)
+	}
+	if p.codigo == "54" {
+		digitos = celularArgentino(digitos)
+	}
+
+	if len(digitos) < p.minNacional || len(digitos) > p.maxNacional || digitos[0] == '0' {
+		return "", false
+	}
+	// En Argentina solo los celulares (con 9 adelante) tienen 11 dígitos
+	if p.codigo == "54" && len(digitos) == 11 && digitos[0] != '9' {
+		return "", false
+	}
+	return digitos, true
+}
+
+// celularArgentino pasa un celular marcado localmente (característica + 15 +
+// número, 12 dígitos) al formato internacional: 9 + característica + número.
+// La característica tiene de 2 a 4 dígitos
+func celularArgentino(digitos string) string {
+	if len(digitos) != 12 {
+		return digitos
+	}
+	for largo := 2; largo <= 4; largo++ {
+		if digitos[largo:largo+2] == "15" {
+			return "9" + digitos[:largo] + digitos[largo+2:]
+		}
+	}
+	return digitos
+}
+
+// soloDigitos quita los separadores admitidos y falla ante cualquier otro caracter
+func soloDigitos(s string) (string, error) {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", fmt.Errorf("%w: solo puede tener dígitos, espacios, guiones, puntos, paréntesis y un + inicial", ErrInvalido)
+		}
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("%w: no tiene dígitos", ErrInvalido)
+	}
+	return b.String(), nil
+}
+
+// armar une código y número nacional y verifica el largo máximo de E.164
+func armar(codigo, nacional string) (string, error) {
+	if len(codigo)+len(nacional) > maxDigitosE164 {
+		return "", fmt.Errorf("%w: más de %d dígitos", ErrInvalido, maxDigitosE164)
+	}
+	return "+" + codigo + nacional, nil
+}
@@ -0,0 +1,53 @@
+package telefono
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizarPara(t *testing.T) {
+	tests := []struct {
+		name      string
+		numero    string
+		pais      string
+		want      string
+		wantError bool
+	}{
+		{"vacío es sin teléfono", "  ", "AR", "", false},
+		{"fijo con característica", "11 4567-8901", "AR", "+541145678901", false},
+		{"fijo con 0 troncal y paréntesis", "(011) 4567-8901", "AR", "+541145678901", false},
+		{"celular con 15", "011 15 2345-6789", "AR", "+5491123456789", false},
+		{"celular del interior con 15", "0341 15 512-3456", "AR", "+5493415123456", false},
+		{"celular con 9", "9 11 2345 6789", "AR", "+5491123456789", false},
+		{"internacional con +", "+54 9 11 2345-6789", "AR", "+5491123456789", false},
+		{"internacional con 00", "0054 11 4567 8901", "AR", "+541145678901", false},
+		{"entero viejo con código de país", "5491123456789", "AR", "+5491123456789", false},
+		{"otro país con +", "+598 99 123 456", "AR", "+59899123456", false},
+		{"país por defecto uruguay", "099 123 456", "UY", "+59899123456", false},
+		{"país sin plan conocido", "+44 20 7946 0958", "AR", "+442079460958", false},
+		{"demasiado corto", "4567", "AR", "", true},
+		{"fijo argentino de 11 dígitos sin 9", "11 4567 89012", "AR", "", true},
+		{"largo inválido para el país", "+598 99 123", "AR", "", true},
+		{"más de 15 dígitos", "+1234567890123456", "AR", "", true},
+		{"letras", "11-ABCD-1234", "AR", "", true},
+		{"+ en el medio", "11+45678901", "AR", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := NormalizarPara(tt.numero, tt.pais)
+
+			// Assert
+			if (err != nil) != tt.wantError {
+				t.Fatalf("NormalizarPara(%q) error = %v, wantError %v", tt.numero, err, tt.wantError)
+			}
+			if err != nil && !errors.Is(err, ErrInvalido) {
+				t.Errorf("NormalizarPara(%q) error = %v, want ErrInvalido", tt.numero, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizarPara(%q) = %q, want %q", tt.numero, got, tt.want)
+			}
+		})
+	}
+}
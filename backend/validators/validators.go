@@ -3,8 +3,11 @@ package validators
 import (
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"pizzas-ecos/models"
+	"pizzas-ecos/telefono"
 )
 
 // ValidationError contiene errores de validación
@@ -111,6 +114,30 @@ func ValidateLoginRequest(username, password string) *ValidateRequest {
 	return v
 }
 
+// PoliticaPassword son los requisitos de una contraseña nueva
+type PoliticaPassword struct {
+	MinLongitud    int
+	RequiereNumero bool
+	RequiereMayus  bool
+}
+
+// ValidatePassword valida una contraseña nueva contra la política
+func ValidatePassword(password string, politica PoliticaPassword) *ValidateRequest {
+	v := &ValidateRequest{}
+
+	if utf8.RuneCountInString(password) < politica.MinLongitud {
+		v.Add("new_password", fmt.Sprintf("Contraseña debe tener al menos %d caracteres", politica.MinLongitud))
+	}
+	if politica.RequiereNumero && !strings.ContainsAny(password, "0123456789") {
+		v.Add("new_password", "Contraseña debe incluir al menos un número")
+	}
+	if politica.RequiereMayus && !strings.ContainsFunc(password, unicode.IsUpper) {
+		v.Add("new_password", "Contraseña debe incluir al menos una mayúscula")
+	}
+
+	return v
+}
+
 // ValidateID valida que un ID sea válido
 func ValidateID(id interface{}) *ValidateRequest {
 	v := &ValidateRequest{}
@@ -158,11 +185,9 @@ func ValidateVentaRequestCompleto(req interface{}) *ValidateRequest {
 		v.Add("cliente", "Nombre de cliente demasiado largo (máximo 100 caracteres)")
 	}
 
-	// Validar teléfono (opcional - 0 significa vacío)
-	if ventaReq.TelefonoCliente != 0 {
-		if ventaReq.TelefonoCliente < 10 || ventaReq.TelefonoCliente > 999999999999999 {
-			v.Add("telefono_cliente", "Teléfono debe tener entre 2 y 15 dígitos")
-		}
+	// Validar teléfono (opcional - vacío significa sin teléfono)
+	if _, err := telefono.Normalizar(string(ventaReq.TelefonoCliente)); err != nil {
+		v.Add("telefono_cliente", err.Error())
 	}
 
 	// Validar items
@@ -191,21 +216,17 @@ func ValidateVentaRequestCompleto(req interface{}) *ValidateRequest {
 	if strings.TrimSpace(ventaReq.PaymentMethod) == "" {
 		v.Add("payment_method", "Método de pago es requerido")
 	} else {
-		validPayments := []string{"efectivo", "tarjeta", "transferencia", "qr"}
-		if !contains(validPayments, strings.ToLower(ventaReq.PaymentMethod)) {
-			v.Add("payment_method", "Método de pago inválido (debe ser: efectivo, tarjeta, transferencia, qr)")
+		if _, ok := models.ParseMetodoPago(ventaReq.PaymentMethod); !ok {
+			v.Add("payment_method", "Método de pago inválido (debe ser: "+models.MetodosPagoString()+")")
 		}
 	}
 
 	// Validar estado
 	if strings.TrimSpace(ventaReq.Estado) == "" {
-		ventaReq.Estado = "sin_pagar" // Default
+		ventaReq.Estado = string(models.EstadoSinPagar) // Default
 	} else {
-		// Normalizar el estado
-		estadoNormalizado := strings.ToLower(strings.TrimSpace(ventaReq.Estado))
-		validEstados := []string{"sin_pagar", "pagada", "entregada", "cancelada"}
-		if !contains(validEstados, estadoNormalizado) {
-			v.Add("estado", "Estado inválido (debe ser: sin_pagar, pagada, entregada, cancelada)")
+		if _, ok := models.ParseEstadoVenta(ventaReq.Estado); !ok {
+			v.Add("estado", "Estado inválido (debe ser: "+models.EstadosVentaString()+")")
 		}
 	}
 
@@ -274,6 +295,27 @@ func ValidateVendedorRequestCompleto(nombre string) *ValidateRequest {
 	return v
 }
 
+// ValidateClienteRequest valida el alta o modificación de un cliente con las
+// mismas reglas que el cliente de una venta
+func ValidateClienteRequest(req *models.ClienteRequest) *ValidateRequest {
+	v := &ValidateRequest{}
+
+	if strings.TrimSpace(req.Nombre) == "" {
+		v.Add("nombre", "Nombre es requerido")
+	} else if len(strings.TrimSpace(req.Nombre)) < 2 {
+		v.Add("nombre", "Nombre debe tener al menos 2 caracteres")
+	} else if len(req.Nombre) > 100 {
+		v.Add("nombre", "Nombre demasiado largo (máximo 100 caracteres)")
+	}
+
+	// Teléfono opcional - vacío significa sin teléfono
+	if _, err := telefono.Normalizar(string(req.Telefono)); err != nil {
+		v.Add("telefono", err.Error())
+	}
+
+	return v
+}
+
 // Helper functions
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
@@ -286,6 +286,54 @@ func TestValidateVendedorRequest(t *testing.T) {
 	}
 }
 
+func TestValidatePassword(t *testing.T) {
+	estricta := PoliticaPassword{MinLongitud: 8, RequiereNumero: true, RequiereMayus: true}
+
+	tests := []struct {
+		name           string
+		password       string
+		politica       PoliticaPassword
+		expectedErrors int
+	}{
+		{
+			name:           "contraseña que cumple la política debe pasar",
+			password:       "Pizzeria2024",
+			politica:       estricta,
+			expectedErrors: 0,
+		},
+		{
+			name:           "contraseña corta debe fallar",
+			password:       "Pz1",
+			politica:       estricta,
+			expectedErrors: 1,
+		},
+		{
+			name:           "sin número ni mayúscula debe fallar dos veces",
+			password:       "pizzeria",
+			politica:       estricta,
+			expectedErrors: 2,
+		},
+		{
+			name:           "la longitud cuenta caracteres, no bytes",
+			password:       "ñañañaña",
+			politica:       PoliticaPassword{MinLongitud: 8},
+			expectedErrors: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange & Act
+			result := ValidatePassword(tt.password, tt.politica)
+
+			// Assert
+			if len(result.Errors) != tt.expectedErrors {
+				t.Errorf("ValidatePassword() errors = %v, want %d", result.Errors, tt.expectedErrors)
+			}
+		})
+	}
+}
+
 func TestValidateVentaRequestCompleto(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -297,7 +345,7 @@ func TestValidateVentaRequestCompleto(t *testing.T) {
 			request: &models.VentaRequest{
 				Vendedor:        "Juan Pérez",
 				Cliente:         "María García",
-				TelefonoCliente: 123456789,
+				TelefonoCliente: "011 4567-8901",
 				Items: []models.ProductoItem{
 					{ProductID: 1, Cantidad: 2, Precio: 10.0},
 				},
@@ -337,7 +385,7 @@ func TestValidateVentaRequestCompleto(t *testing.T) {
 			request: &models.VentaRequest{
 				Vendedor:        "Juan Pérez",
 				Cliente:         "María García",
-				TelefonoCliente: 1234567, // 7 dígitos - inválido
+				TelefonoCliente: "1234567", // 7 dígitos - inválido en AR
 				Items: []models.ProductoItem{
 					{ProductID: 1, Cantidad: 1, Precio: 10.0},
 				},
@@ -479,3 +527,33 @@ func TestValidateVendedorRequestCompleto(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateClienteRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		req            models.ClienteRequest
+		expectValid    bool
+		expectedErrors int
+	}{
+		{"cliente con teléfono debe pasar", models.ClienteRequest{Nombre: "María López", Telefono: "11 3456-7890"}, true, 0},
+		{"cliente sin teléfono debe pasar", models.ClienteRequest{Nombre: "María López"}, true, 0},
+		{"cliente sin nombre debe fallar", models.ClienteRequest{Nombre: "  "}, false, 1},
+		{"cliente con nombre muy corto debe fallar", models.ClienteRequest{Nombre: "M"}, false, 1},
+		{"teléfono de un dígito debe fallar", models.ClienteRequest{Nombre: "María", Telefono: "5"}, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			result := ValidateClienteRequest(&tt.req)
+
+			// Assert
+			if result.IsValid() != tt.expectValid {
+				t.Errorf("ValidateClienteRequest() valid = %v, want %v", result.IsValid(), tt.expectValid)
+			}
+			if len(result.Errors) != tt.expectedErrors {
+				t.Errorf("ValidateClienteRequest() errors count = %v, want %v", len(result.Errors), tt.expectedErrors)
+			}
+		})
+	}
+}
@@ -206,8 +206,9 @@ if (cancelLogout) {
 }
 
 if (confirmLogout) {
-    confirmLogout.addEventListener('click', () => {
-        sessionStorage.removeItem('authToken');
+    confirmLogout.addEventListener('click', async () => {
+        // Revoca la sesión en el backend y limpia tokens
+        await api.logout();
         sessionStorage.removeItem('userId');
         window.location.href = 'login.html';
     });
@@ -1035,8 +1036,13 @@ function setupClearDatabaseBtn() {
         const confirmation1 = confirm('⚠️ ADVERTENCIA: Esto eliminará TODOS los datos de la aplicación (ventas, detalles, clientes, vendedores y productos) pero mantendrá los usuarios.\n\n¿Estás seguro?');
         if (!confirmation1) return;
 
-        const confirmation2 = confirm('Esta es la última advertencia. Esta acción NO SE PUEDE DESHACER.\n\n¿Realmente quieres continuar?');
-        if (!confirmation2) return;
+        const frase = 'BORRAR TODOS LOS DATOS';
+        const confirmacion = prompt(`Esta es la última advertencia. Antes de borrar se guarda un backup en el servidor.\n\nPara continuar escribí: ${frase}`);
+        if (confirmacion === null) return;
+        if (confirmacion.trim() !== frase) {
+            showError('La frase de confirmación no coincide');
+            return;
+        }
 
         try {
             showLoadingSpinner(true);
@@ -1045,7 +1051,8 @@ function setupClearDatabaseBtn() {
                 headers: {
                     'Content-Type': 'application/json',
                     'Authorization': `Bearer ${sessionStorage.getItem('authToken')}`
-                }
+                },
+                body: JSON.stringify({ confirmacion: confirmacion.trim() })
             });
 
             const responseData = await response.json();
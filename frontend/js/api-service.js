@@ -7,6 +7,9 @@ class APIService {
     constructor(baseURL) {
         this._baseURL = baseURL;
         this.token = this.getStoredToken();
+        this.refreshTimer = null;
+        this.refreshEnCurso = null;
+        this.programarRefresh();
     }
 
     // Getter para baseURL que siempre usa la URL actualizada
@@ -65,6 +68,81 @@ class APIService {
         }
     }
 
+    /**
+     * Guarda la sesión completa de login/refresh: access token, refresh token
+     * y vencimiento del access token. Programa la renovación antes de que venza
+     */
+    setSession(sesion) {
+        this.setToken(sesion.token);
+        sessionStorage.setItem('refreshToken', sesion.refresh_token || '');
+        if (sesion.expires_in) {
+            sessionStorage.setItem('tokenExpiresAt', String(Date.now() + sesion.expires_in * 1000));
+        }
+        this.programarRefresh();
+    }
+
+    /**
+     * Borra access token, refresh token y la renovación programada
+     */
+    clearSession() {
+        this.setToken(null);
+        sessionStorage.removeItem('refreshToken');
+        sessionStorage.removeItem('tokenExpiresAt');
+        if (this.refreshTimer) {
+            clearTimeout(this.refreshTimer);
+            this.refreshTimer = null;
+        }
+    }
+
+    /**
+     * Programa refreshSession() un minuto antes de que venza el access token
+     */
+    programarRefresh() {
+        if (this.refreshTimer) {
+            clearTimeout(this.refreshTimer);
+            this.refreshTimer = null;
+        }
+        const expiresAt = Number(sessionStorage.getItem('tokenExpiresAt'));
+        if (!expiresAt || !sessionStorage.getItem('refreshToken')) return;
+
+        const espera = Math.max(expiresAt - Date.now() - 60 * 1000, 0);
+        this.refreshTimer = setTimeout(() => {
+            this.refreshSession().catch(() => {});
+        }, espera);
+    }
+
+    /**
+     * POST /auth/refresh - Canjea el refresh token por una sesión nueva.
+     * Requests concurrentes comparten el mismo canje: cada refresh token
+     * sirve una sola vez. Retorna false si la sesión ya no es válida
+     */
+    async refreshSession() {
+        const refreshToken = sessionStorage.getItem('refreshToken');
+        if (!refreshToken) return false;
+
+        if (!this.refreshEnCurso) {
+            this.refreshEnCurso = (async () => {
+                try {
+                    const response = await fetch(`${this.baseURL}/auth/refresh`, {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        body: JSON.stringify({ refresh_token: refreshToken })
+                    });
+                    if (!response.ok) {
+                        this.clearSession();
+                        return false;
+                    }
+                    const data = await response.json();
+                    this.setSession(data.data || data);
+                    return true;
+                } finally {
+                    this.refreshEnCurso = null;
+                }
+            })();
+        }
+        return this.refreshEnCurso;
+    }
+
     /**
      * Headers por defecto (incluye token si existe)
      */
@@ -81,7 +159,7 @@ class APIService {
     /**
      * Wrapper para fetch con manejo de errores
      */
-    async request(endpoint, options = {}) {
+    async request(endpoint, options = {}, reintento = false) {
         const url = `${this.baseURL}${endpoint}`;
         const config = {
             ...options,
@@ -94,9 +172,12 @@ class APIService {
         try {
             const response = await fetch(url, config);
 
-            // Si recibimos 401, token expiró
+            // Si recibimos 401, token expiró: renovar una vez y reintentar
             if (response.status === 401) {
-                this.setToken(null);
+                if (!reintento && !endpoint.startsWith('/auth/') && await this.refreshSession()) {
+                    return this.request(endpoint, options, true);
+                }
+                this.clearSession();
                 window.location.href = '/login.html';
                 return null;
             }
@@ -124,17 +205,68 @@ class APIService {
             method: 'POST',
             body: JSON.stringify({ username, password })
         });
-        if (data && data.token) {
-            this.setToken(data.token);
+        const sesion = (data && data.data) || data;
+        if (sesion && sesion.token) {
+            this.setSession(sesion);
         }
         return data;
     }
 
     /**
-     * Logout - Limpia token
+     * Logout - Limpia la sesión local y la revoca en el backend
      */
     logout() {
-        this.setToken(null);
+        const token = this.token;
+        const refreshToken = sessionStorage.getItem('refreshToken');
+        this.clearSession();
+
+        return this.revocarSesion(token, refreshToken);
+    }
+
+    /**
+     * POST /auth/logout - Revoca access y refresh token en el backend.
+     * Un error no impide el logout local
+     */
+    async revocarSesion(token, refreshToken) {
+        const headers = { 'Content-Type': 'application/json' };
+        if (token) {
+            headers['Authorization'] = `Bearer ${token}`;
+        }
+        try {
+            await fetch(`${this.baseURL}/auth/logout`, {
+                method: 'POST',
+                headers,
+                body: JSON.stringify({ refresh_token: refreshToken || '' })
+            });
+        } catch (error) {
+            console.error('API Error [/auth/logout]:', error);
+        }
+    }
+
+    /**
+     * POST /auth/change-password - Cambia la contraseña propia. El backend
+     * cierra las demás sesiones y devuelve un par de tokens nuevo
+     */
+    async changePassword(currentPassword, newPassword) {
+        const data = await this.request('/auth/change-password', {
+            method: 'POST',
+            body: JSON.stringify({ current_password: currentPassword, new_password: newPassword })
+        });
+        const sesion = (data && data.data) || data;
+        if (sesion && sesion.token) {
+            this.setSession(sesion);
+        }
+        return data;
+    }
+
+    /**
+     * POST /auth/reset-password - Canjea un token de restablecimiento
+     */
+    async resetPassword(token, newPassword) {
+        return this.request('/auth/reset-password', {
+            method: 'POST',
+            body: JSON.stringify({ token, new_password: newPassword })
+        });
     }
 
     // ============= DATA ENDPOINTS =============
@@ -183,19 +315,22 @@ class APIService {
     }
 
     /**
-     * GET /estadisticas-sheet - Obtener estadísticas resumidas
+     * GET /estadisticas-sheet - Obtener estadísticas resumidas de una campaña
+     * (por defecto la activa)
      */
-    async obtenerEstadisticas() {
-        return this.request('/estadisticas-sheet');
+    async obtenerEstadisticas(campanaId) {
+        const query = campanaId ? `?campana_id=${encodeURIComponent(campanaId)}` : '';
+        return this.request(`/estadisticas-sheet${query}`);
     }
 
     // ============= PRODUCTOS =============
 
     /**
-     * GET /productos - Listar productos
+     * GET /productos - Listar productos de una campaña (por defecto la activa)
      */
-    async obtenerProductos() {
-        return this.request('/productos');
+    async obtenerProductos(campanaId) {
+        const query = campanaId ? `?campana_id=${encodeURIComponent(campanaId)}` : '';
+        return this.request(`/productos${query}`);
     }
 
     /**
@@ -227,6 +362,127 @@ class APIService {
         });
     }
 
+    // ============= CAMPAÑAS =============
+
+    /**
+     * GET /campanas - Listar campañas
+     */
+    async obtenerCampanas() {
+        return this.request('/campanas');
+    }
+
+    /**
+     * POST /campanas - Crear campaña
+     */
+    async crearCampana(campanaData) {
+        return this.request('/campanas', {
+            method: 'POST',
+            body: JSON.stringify(campanaData)
+        });
+    }
+
+    /**
+     * PUT /campanas/:id - Actualizar o activar campaña
+     */
+    async actualizarCampana(id, campanaData) {
+        return this.request(`/campanas/${id}`, {
+            method: 'PUT',
+            body: JSON.stringify(campanaData)
+        });
+    }
+
+    // ============= CLIENTES =============
+
+    /**
+     * GET /clientes - Listar clientes
+     */
+    async obtenerClientes() {
+        return this.request('/clientes');
+    }
+
+    /**
+     * GET /clientes?q= - Autocompletado de clientes por prefijo
+     */
+    async buscarClientes(q, limit) {
+        const query = limit ? `&limit=${encodeURIComponent(limit)}` : '';
+        return this.request(`/clientes?q=${encodeURIComponent(q)}${query}`);
+    }
+
+    /**
+     * GET /clientes/:id - Obtener cliente
+     */
+    async obtenerCliente(id) {
+        return this.request(`/clientes/${id}`);
+    }
+
+    /**
+     * GET /clientes/:id/historial - Ventas del cliente y resumen de sus compras
+     */
+    async obtenerHistorialCliente(id) {
+        return this.request(`/clientes/${id}/historial`);
+    }
+
+    /**
+     * POST /clientes - Crear cliente
+     */
+    async crearCliente(clienteData) {
+        return this.request('/clientes', {
+            method: 'POST',
+            body: JSON.stringify(clienteData)
+        });
+    }
+
+    /**
+     * PUT /clientes/:id - Actualizar cliente
+     */
+    async actualizarCliente(id, clienteData) {
+        return this.request(`/clientes/${id}`, {
+            method: 'PUT',
+            body: JSON.stringify(clienteData)
+        });
+    }
+
+    /**
+     * DELETE /clientes/:id - Eliminar cliente
+     */
+    async eliminarCliente(id) {
+        return this.request(`/clientes/${id}`, {
+            method: 'DELETE'
+        });
+    }
+
+    /**
+     * GET /clientes/duplicados - Posibles clientes duplicados (admin)
+     */
+    async obtenerClientesDuplicados(umbral) {
+        const query = umbral ? `?umbral=${encodeURIComponent(umbral)}` : '';
+        return this.request(`/clientes/duplicados${query}`);
+    }
+
+    /**
+     * POST /clientes/:id/fusionar - Absorber un cliente duplicado (admin)
+     */
+    async fusionarClientes(id, duplicadoId) {
+        return this.request(`/clientes/${id}/fusionar`, {
+            method: 'POST',
+            body: JSON.stringify({ duplicado_id: duplicadoId })
+        });
+    }
+
+    /**
+     * GET /clientes/:id/exportar - Todos los datos guardados del cliente (admin)
+     */
+    async exportarCliente(id) {
+        return this.request(`/clientes/${id}/exportar`);
+    }
+
+    /**
+     * POST /clientes/:id/anonimizar - Borrar nombre y teléfono conservando las ventas (admin)
+     */
+    async anonimizarCliente(id) {
+        return this.request(`/clientes/${id}/anonimizar`, { method: 'POST' });
+    }
+
     // ============= VENDEDORES =============
 
     /**
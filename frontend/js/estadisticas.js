@@ -916,10 +916,7 @@ async function guardarCambios() {
             payment_method: pago,
             tipo_entrega: entrega,
             cliente: cliente,
-            telefono_cliente: (function(){
-                const v = document.getElementById('editarTelefono').value || '';
-                return v.trim() === '' ? 0 : parseInt(v);
-            })(),
+            telefono_cliente: (document.getElementById('editarTelefono').value || '').trim(),
             productos: productosActualizados
         };
         
@@ -929,7 +926,7 @@ async function guardarCambios() {
 
         const response = await fetch(`${API_BASE}/actualizar-venta/${ventaEnEdicion.id}`, {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers: api.getHeaders(),
             body: JSON.stringify(payload)
         });
 
@@ -81,7 +81,13 @@ document.addEventListener('DOMContentLoaded', async () => {
         UIUtils.showSpinner(true);
         const url = `${API_BASE}/data`;
         Logger.log('📡 Fetching from:', url);
-        const resp = await fetch(url);
+        // /data incluye clientes y teléfonos: requiere sesión
+        const resp = await fetch(url, { headers: api.getHeaders() });
+        if (resp.status === 401) {
+            clearTimeout(spinnerTimeout);
+            window.location.href = 'login.html';
+            return;
+        }
         
         if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
         const jsonResp = await resp.json();
@@ -241,13 +247,8 @@ document.addEventListener('DOMContentLoaded', async () => {
             const data = {
                 vendedor: vend,
                 cliente: cliente,
-                telefono_cliente: (function(){
-                    const v = document.getElementById('telefono_cliente').value || '';
-                    const trimmed = v.trim();
-                    if (trimmed === '') return 0;
-                    const num = parseInt(trimmed, 10);
-                    return isNaN(num) ? 0 : num;
-                })(),
+                // El backend normaliza el teléfono a E.164; '' es sin teléfono
+                telefono_cliente: (document.getElementById('telefono_cliente').value || '').trim(),
                 items: combos,
                 payment_method: pago,
                 estado: est,
@@ -265,7 +266,7 @@ document.addEventListener('DOMContentLoaded', async () => {
             try {
                 const resp = await fetch(`${API_BASE}/submit`, {
                     method: 'POST',
-                    headers: { 'Content-Type': 'application/json' },
+                    headers: api.getHeaders(),
                     body: JSON.stringify(data)
                 });
                 if (!resp.ok) {